@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -35,14 +36,81 @@ type GuestdemoSpec struct {
 	// +kubebuilder:validation:Minimum:=6000
 	Port int `json:"port,omitempty"`
 	Num  int `json:"num,omitempty"`
+
+	// Image overrides the Redis image from the operator config.
+	Image string `json:"image,omitempty"`
+	// Resources overrides the Redis resources from the operator config.
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Probes override the default probes, which run redis-cli ping.
+	// Pods with outdated probes are replaced one at a time.
+	// +optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+	// PodSecurityContext is merged over the default pod security context,
+	// which runs Redis as its uid 999 under the restricted Pod Security
+	// Standard. Pods with an outdated one are replaced one at a time.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext is merged over the default security context of the
+	// Redis container, which drops all capabilities and privilege
+	// escalation.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	// NetworkPolicy turns off the NetworkPolicy that limits Redis to
+	// AllowedClients. It is created by default.
+	// +optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+	// AllowedClients may connect to Redis while the NetworkPolicy is
+	// enabled. Without any, the pods of the Guestdemo namespace may.
+	// +optional
+	AllowedClients []AllowedClient `json:"allowedClients,omitempty"`
+}
+
+// NetworkPolicySpec controls the generated NetworkPolicy.
+type NetworkPolicySpec struct {
+	// Enabled false deletes the generated NetworkPolicy.
+	// +kubebuilder:default=true
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// AllowedClient admits the pods of a namespace, or some of them.
+type AllowedClient struct {
+	// Namespace of the clients, the Guestdemo namespace when empty.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// PodSelector selects the clients in Namespace, all of its pods when
+	// unset.
+	// +optional
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+}
+
+// ProbesSpec overrides the generated probes. A probe with a handler
+// replaces the default check; its timing fields replace the default ones
+// when set.
+type ProbesSpec struct {
+	// +optional
+	Startup *corev1.Probe `json:"startup,omitempty"`
+	// +optional
+	Liveness *corev1.Probe `json:"liveness,omitempty"`
+	// +optional
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
 }
 
 // GuestdemoStatus defines the observed state of Guestdemo.
 type GuestdemoStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// ConditionPodSecurityCompliant is True unless the namespace enforces a
+// Pod Security level the Redis pods break.
+const ConditionPodSecurityCompliant = "PodSecurityCompliant"
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
@@ -38,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	webappv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
 	"my.domain/demo/internal/controller"
 	// +kubebuilder:scaffold:imports
 )
@@ -63,6 +64,7 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var configFile string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -81,6 +83,8 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&configFile, "config", "",
+		"Path to the operator config file holding default images, pull secrets and labels. Reloaded on change.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -202,9 +206,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	operatorConfig, err := config.NewStore(configFile, ctrl.Log.WithName("config"))
+	if err != nil {
+		setupLog.Error(err, "unable to load operator config", "config", configFile)
+		os.Exit(1)
+	}
+	if err := mgr.Add(operatorConfig); err != nil {
+		setupLog.Error(err, "unable to watch operator config")
+		os.Exit(1)
+	}
+
 	if err = (&controller.GuestdemoReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+		Config: operatorConfig,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Guestdemo")
 		os.Exit(1)
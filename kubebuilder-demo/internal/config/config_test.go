@@ -0,0 +1,120 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"my.domain/demo/internal/config"
+)
+
+var _ = Describe("OperatorConfig", func() {
+	It("keeps defaults for fields missing from the file", func() {
+		cfg, err := config.Parse([]byte(`
+apiVersion: config.webapp.my.domain/v1alpha1
+kind: OperatorConfig
+labels:
+  team: modelops
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Image(config.ComponentRedis, "")).To(Equal("xci-harbor.enflame.cn/docker.io/library/redis:5-alpine"))
+		Expect(cfg.Labels).To(HaveKeyWithValue("team", "modelops"))
+	})
+
+	It("rejects unknown config versions", func() {
+		_, err := config.Parse([]byte("apiVersion: config.webapp.my.domain/v2\nkind: OperatorConfig\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("prefers the spec image and rewrites it through the mirror", func() {
+		cfg := config.Default()
+		cfg.Registry.Mirrors = []config.RegistryMirror{{Prefix: "docker.io/", Replacement: "mirror.local/docker.io/"}}
+
+		Expect(cfg.Image(config.ComponentRedis, "redis:7")).To(Equal("mirror.local/docker.io/library/redis:7"))
+		Expect(cfg.Image(config.ComponentRedis, "bitnami/redis:7.2")).To(Equal("mirror.local/docker.io/bitnami/redis:7.2"))
+		Expect(cfg.Image(config.ComponentRedis, "")).To(Equal("xci-harbor.enflame.cn/docker.io/library/redis:5-alpine"))
+		Expect(cfg.Image(config.ComponentRedis, "quay.io/org/app")).To(Equal("quay.io/org/app"))
+		Expect(cfg.Image(config.ComponentRedis, "localhost:5000/app")).To(Equal("localhost:5000/app"))
+	})
+
+	It("only uses default resources when the spec has none", func() {
+		cfg := config.Default()
+		cfg.Resources = map[string]corev1.ResourceRequirements{
+			config.ComponentRedis: {Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}},
+		}
+		override := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}}
+
+		Expect(cfg.ResourcesFor(config.ComponentRedis, corev1.ResourceRequirements{}).Requests).To(HaveKey(corev1.ResourceMemory))
+		Expect(cfg.ResourcesFor(config.ComponentRedis, override)).To(Equal(override))
+	})
+
+	It("lets object labels win over the default labels", func() {
+		cfg := config.Default()
+		cfg.Labels = map[string]string{"team": "modelops", "app": "default"}
+
+		Expect(cfg.WithLabels(map[string]string{"app": "redis"})).To(Equal(map[string]string{"team": "modelops", "app": "redis"}))
+	})
+})
+
+var _ = Describe("Store", func() {
+	It("reloads the file and notifies listeners", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		write := func(image string) {
+			data := "apiVersion: config.webapp.my.domain/v1alpha1\nkind: OperatorConfig\nimages:\n  redis: " + image + "\n"
+			Expect(os.WriteFile(path, []byte(data), 0o644)).To(Succeed())
+		}
+		write("redis:6")
+
+		store, err := config.NewStore(path, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Get().Image(config.ComponentRedis, "")).To(Equal("redis:6"))
+
+		changed := make(chan string, 1)
+		store.OnChange(func(_ context.Context, cfg *config.OperatorConfig) {
+			select {
+			case changed <- cfg.Images[config.ComponentRedis]:
+			default:
+			}
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			defer GinkgoRecover()
+			Expect(store.Start(ctx)).To(Succeed())
+		}()
+
+		Eventually(func() string {
+			write("redis:7")
+			return store.Get().Image(config.ComponentRedis, "")
+		}, 5*time.Second, 100*time.Millisecond).Should(Equal("redis:7"))
+		Eventually(changed).Should(Receive(Equal("redis:7")))
+	})
+
+	It("returns the defaults when nil", func() {
+		var store *config.Store
+		Expect(store.Get().Image(config.ComponentRedis, "")).To(ContainSubstring("redis:5-alpine"))
+	})
+})
@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// Store keeps the last successfully loaded OperatorConfig. The directory of
+// the file is watched rather than the file itself because kubelet updates
+// mounted ConfigMaps by swapping a symlink.
+type Store struct {
+	path string
+	log  logr.Logger
+
+	mu        sync.RWMutex
+	current   *OperatorConfig
+	listeners []func(context.Context, *OperatorConfig)
+}
+
+// NewStore loads the config at path. An empty path means defaults only.
+func NewStore(path string, log logr.Logger) (*Store, error) {
+	s := &Store{path: path, log: log, current: Default()}
+	if path == "" {
+		return s, nil
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	s.current = cfg
+	return s, nil
+}
+
+// Get returns the current config. A nil Store returns the defaults.
+func (s *Store) Get() *OperatorConfig {
+	if s == nil {
+		return Default()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// OnChange registers fn to be called after every successful reload.
+func (s *Store) OnChange(fn func(context.Context, *OperatorConfig)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Start implements manager.Runnable and watches the config file until ctx
+// is done.
+func (s *Store) Start(ctx context.Context) error {
+	if s.path == "" {
+		<-ctx.Done()
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			s.log.Error(err, "Config watch error", "path", s.path)
+		case <-watcher.Events:
+			s.reload(ctx)
+		}
+	}
+}
+
+func (s *Store) reload(ctx context.Context) {
+	cfg, err := Load(s.path)
+	if err != nil {
+		// Keep running with the last good config.
+		s.log.Error(err, "Failed to reload operator config", "path", s.path)
+		return
+	}
+
+	s.mu.Lock()
+	if reflect.DeepEqual(cfg, s.current) {
+		s.mu.Unlock()
+		return
+	}
+	s.current = cfg
+	listeners := append([]func(context.Context, *OperatorConfig){}, s.listeners...)
+	s.mu.Unlock()
+
+	s.log.Info("Operator config reloaded", "path", s.path)
+	for _, fn := range listeners {
+		fn(ctx, cfg)
+	}
+}
@@ -19,26 +19,35 @@ package controller
 import (
 	"context"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"log"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	webappv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
 )
 
 // GuestdemoReconciler reconciles a Guestdemo object
 type GuestdemoReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Config *config.Store
 }
 
 // +kubebuilder:rbac:groups=webapp.my.domain,resources=guestdemoes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=webapp.my.domain,resources=guestdemoes/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=webapp.my.domain,resources=guestdemoes/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;patch;delete
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -68,10 +77,17 @@ func (r *GuestdemoReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}
 
+	if guestdemo.DeletionTimestamp.IsZero() {
+		if err := r.setPodSecurityCondition(ctx, guestdemo, r.Config.Get()); err != nil {
+			log.Println("Update pod security condition fail:", err)
+			return ctrl.Result{}, err
+		}
+	}
+
 	updateFlag := false
 	redisPodNames := GetRedisPodName(guestdemo)
 	for _, podName := range redisPodNames {
-		finalizerPodName, err := CreateRedis(podName, r.Client, guestdemo, r.Scheme)
+		finalizerPodName, err := CreateRedis(podName, r.Client, guestdemo, r.Scheme, r.Config.Get())
 		if err != nil {
 			log.Println("Create redis pod fail:", err)
 			return ctrl.Result{}, err
@@ -93,6 +109,17 @@ func (r *GuestdemoReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}
 
+	if guestdemo.DeletionTimestamp.IsZero() {
+		if err := EnsureRedisNetworkPolicy(ctx, r.Client, guestdemo, r.Scheme, r.Config.Get()); err != nil {
+			log.Println("Ensure redis networkpolicy fail:", err)
+			return ctrl.Result{}, err
+		}
+		if err := RolloutRedis(ctx, r.Client, guestdemo, redisPodNames, r.Config.Get()); err != nil {
+			log.Println("Rollout redis pod fail:", err)
+			return ctrl.Result{}, err
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
@@ -132,9 +159,30 @@ func (r *GuestdemoReconciler) ClearRedis(guestdemo *webappv1.Guestdemo, ctx cont
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *GuestdemoReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&webappv1.Guestdemo{}).
 		//Named("guestdemo").
 		Owns(&corev1.Pod{}).
-		Complete(r)
+		Owns(&networkingv1.NetworkPolicy{})
+
+	if r.Config != nil {
+		reloaded := make(chan event.GenericEvent)
+		r.Config.OnChange(func(ctx context.Context, _ *config.OperatorConfig) {
+			guestdemos := &webappv1.GuestdemoList{}
+			if err := r.List(ctx, guestdemos); err != nil {
+				log.Println("List guestdemo fail:", err)
+				return
+			}
+			for i := range guestdemos.Items {
+				select {
+				case reloaded <- event.GenericEvent{Object: &guestdemos.Items[i]}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+		builder = builder.WatchesRawSource(source.Channel(reloaded, &handler.EnqueueRequestForObject{}))
+	}
+
+	return builder.Complete(r)
 }
@@ -0,0 +1,97 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	webappv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+func redisNetworkPolicyName(guestdemo *webappv1.Guestdemo) string {
+	return guestdemo.Name + "-redis"
+}
+
+// networkPolicyEnabled is true unless spec.networkPolicy.enabled is false.
+func networkPolicyEnabled(guestdemo *webappv1.Guestdemo) bool {
+	policy := guestdemo.Spec.NetworkPolicy
+	return policy == nil || policy.Enabled == nil || *policy.Enabled
+}
+
+// allowedClientPeer turns an entry of spec.allowedClients into a
+// NetworkPolicy peer. Namespaces are matched by their name label.
+func allowedClientPeer(allowed webappv1.AllowedClient) networkingv1.NetworkPolicyPeer {
+	peer := networkingv1.NetworkPolicyPeer{PodSelector: allowed.PodSelector}
+	if allowed.Namespace != "" {
+		peer.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{corev1.LabelMetadataName: allowed.Namespace},
+		}
+	} else if peer.PodSelector == nil {
+		peer.PodSelector = &metav1.LabelSelector{}
+	}
+	return peer
+}
+
+// EnsureRedisNetworkPolicy limits the Redis port to spec.allowedClients,
+// or deletes the NetworkPolicy when spec.networkPolicy turns it off.
+// Without allowedClients the pods of the Guestdemo namespace, where its
+// clients run unless told otherwise, keep their access.
+func EnsureRedisNetworkPolicy(ctx context.Context, c client.Client, guestdemo *webappv1.Guestdemo, scheme *runtime.Scheme, cfg *config.OperatorConfig) error {
+	policy := &networkingv1.NetworkPolicy{}
+	policy.Name = redisNetworkPolicyName(guestdemo)
+	policy.Namespace = guestdemo.Namespace
+
+	if !networkPolicyEnabled(guestdemo) {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(policy), policy); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if !metav1.IsControlledBy(policy, guestdemo) {
+			return nil
+		}
+		log.Println("Delete redis networkpolicy:", policy.Name)
+		return client.IgnoreNotFound(c.Delete(ctx, policy))
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, c, policy, func() error {
+		port := intstr.FromInt(guestdemo.Spec.Port)
+		rule := networkingv1.NetworkPolicyIngressRule{Ports: []networkingv1.NetworkPolicyPort{{Port: &port}}}
+		allowedClients := guestdemo.Spec.AllowedClients
+		if len(allowedClients) == 0 {
+			allowedClients = []webappv1.AllowedClient{{}}
+		}
+		for _, allowed := range allowedClients {
+			rule.From = append(rule.From, allowedClientPeer(allowed))
+		}
+		policy.Labels = cfg.WithLabels(redisLabels(guestdemo))
+		policy.Annotations = cfg.WithAnnotations(policy.Annotations)
+		policy.Spec.PodSelector = metav1.LabelSelector{MatchLabels: redisLabels(guestdemo)}
+		policy.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+		policy.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{rule}
+		return controllerutil.SetControllerReference(guestdemo, policy, scheme)
+	})
+	return err
+}
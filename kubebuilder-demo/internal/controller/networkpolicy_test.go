@@ -0,0 +1,102 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	webappv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+func TestEnsureRedisNetworkPolicy(t *testing.T) {
+	disabled := false
+	workers := &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}}
+	tests := []struct {
+		name     string
+		mutate   func(guestdemo *webappv1.Guestdemo)
+		wantFrom []networkingv1.NetworkPolicyPeer
+		deleted  bool
+	}{
+		{
+			name:     "default admits the Guestdemo namespace",
+			mutate:   func(guestdemo *webappv1.Guestdemo) {},
+			wantFrom: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+		},
+		{
+			name: "allowedClients",
+			mutate: func(guestdemo *webappv1.Guestdemo) {
+				guestdemo.Spec.AllowedClients = []webappv1.AllowedClient{
+					{PodSelector: workers},
+					{Namespace: "jobs"},
+				}
+			},
+			wantFrom: []networkingv1.NetworkPolicyPeer{
+				{PodSelector: workers},
+				{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{corev1.LabelMetadataName: "jobs"}}},
+			},
+		},
+		{
+			name: "disabled",
+			mutate: func(guestdemo *webappv1.Guestdemo) {
+				guestdemo.Spec.NetworkPolicy = &webappv1.NetworkPolicySpec{Enabled: &disabled}
+			},
+			deleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			guestdemo := sampleGuestdemo()
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			utilruntime.Must(webappv1.AddToScheme(scheme))
+			c := fake.NewClientBuilder().WithScheme(scheme).Build()
+			// The policy of an earlier reconcile, which the current one
+			// updates or deletes.
+			g.Expect(EnsureRedisNetworkPolicy(ctx, c, guestdemo, scheme, config.Default())).To(Succeed())
+
+			tt.mutate(guestdemo)
+			g.Expect(EnsureRedisNetworkPolicy(ctx, c, guestdemo, scheme, config.Default())).To(Succeed())
+			policy := &networkingv1.NetworkPolicy{}
+			err := c.Get(ctx, client.ObjectKey{Name: redisNetworkPolicyName(guestdemo), Namespace: guestdemo.Namespace}, policy)
+			if tt.deleted {
+				g.Expect(errors.IsNotFound(err)).To(BeTrue())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(policy.Spec.PodSelector.MatchLabels).To(Equal(redisLabels(guestdemo)))
+			g.Expect(policy.Spec.Ingress).To(HaveLen(1), "an empty ingress would deny every client")
+			g.Expect(policy.Spec.Ingress[0].Ports).To(ConsistOf(HaveField("Port", HaveValue(Equal(intstr.FromInt(6379))))))
+			g.Expect(policy.Spec.Ingress[0].From).To(Equal(tt.wantFrom))
+		})
+	}
+}
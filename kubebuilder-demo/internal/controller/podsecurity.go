@@ -0,0 +1,193 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	webappv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// redisUser is the uid of the redis user in the official images, which
+// owns /data. The group is left to the image: it differs between the
+// Debian and Alpine variants.
+const redisUser int64 = 999
+
+// podSecurityEnforceLabel names the Pod Security Standard admission
+// applies to the pods of a namespace.
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// redisSecurityContexts are the pod and container security contexts of the
+// Redis pods: the restricted Pod Security Standard as redisUser, with
+// volumes group owned by it, and spec.podSecurityContext and
+// spec.securityContext merged on top.
+func redisSecurityContexts(guestdemo *webappv1.Guestdemo) (*corev1.PodSecurityContext, *corev1.SecurityContext) {
+	user := redisUser
+	nonRoot := true
+	escalation := false
+	onRootMismatch := corev1.FSGroupChangeOnRootMismatch
+	podSecurity := &corev1.PodSecurityContext{
+		RunAsNonRoot:        &nonRoot,
+		RunAsUser:           &user,
+		FSGroup:             &user,
+		FSGroupChangePolicy: &onRootMismatch,
+		SeccompProfile:      &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	security := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &escalation,
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+	return mergeSecurityContext(podSecurity, guestdemo.Spec.PodSecurityContext),
+		mergeSecurityContext(security, guestdemo.Spec.SecurityContext)
+}
+
+// mergeSecurityContext returns defaults with the fields set in override
+// replaced.
+func mergeSecurityContext[T corev1.PodSecurityContext | corev1.SecurityContext](defaults, override *T) *T {
+	if override == nil {
+		return defaults
+	}
+	original, _ := json.Marshal(defaults)
+	patch, _ := json.Marshal(override)
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, *new(T))
+	if err != nil {
+		return override
+	}
+	result := new(T)
+	if err := json.Unmarshal(merged, result); err != nil {
+		return override
+	}
+	return result
+}
+
+// setPodSecurityCondition records in the status whether the Redis pods
+// pass the level the namespace enforces. Admission rejects pods that do
+// not, so CreateRedis fails and the condition tells which fields to fix.
+func (r *GuestdemoReconciler) setPodSecurityCondition(ctx context.Context, guestdemo *webappv1.Guestdemo, cfg *config.OperatorConfig) error {
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: guestdemo.Namespace}, namespace); err != nil {
+		return err
+	}
+	level := namespace.Labels[podSecurityEnforceLabel]
+	cond := metav1.Condition{
+		Type:               webappv1.ConditionPodSecurityCompliant,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NotEnforced",
+		Message:            "The namespace enforces neither baseline nor restricted",
+		ObservedGeneration: guestdemo.Generation,
+	}
+	if level == "baseline" || level == "restricted" {
+		violations := podSecurityViolations(level, &newRedisPod("", guestdemo, cfg).Spec)
+		if len(violations) == 0 {
+			cond.Reason = "Compliant"
+			cond.Message = "The redis pods meet the " + level + " level"
+		} else {
+			log.Println("Redis pods of", guestdemo.Name, "break the", level, "pod security level:", strings.Join(violations, "; "))
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = "Violation"
+			cond.Message = fmt.Sprintf("Namespace %s enforces %s and rejects the redis pods: %s",
+				guestdemo.Namespace, level, strings.Join(violations, "; "))
+		}
+	}
+	if !meta.SetStatusCondition(&guestdemo.Status.Conditions, cond) {
+		return nil
+	}
+	return r.Status().Update(ctx, guestdemo)
+}
+
+// baselineCapabilities are the capabilities baseline lets a container add.
+var baselineCapabilities = []corev1.Capability{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// podSecurityViolations lists the security context controls of level that
+// spec breaks. The Redis pods have no volumes, host namespaces or host
+// ports, so only the contexts users can override are checked.
+func podSecurityViolations(level string, spec *corev1.PodSpec) []string {
+	restricted := level == "restricted"
+	pod := spec.SecurityContext
+	if pod == nil {
+		pod = &corev1.PodSecurityContext{}
+	}
+	var violations []string
+	for _, c := range spec.Containers {
+		sc := c.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		caps := sc.Capabilities
+		if caps == nil {
+			caps = &corev1.Capabilities{}
+		}
+		seccomp := sc.SeccompProfile
+		if seccomp == nil {
+			seccomp = pod.SeccompProfile
+		}
+		nonRoot := sc.RunAsNonRoot
+		if nonRoot == nil {
+			nonRoot = pod.RunAsNonRoot
+		}
+		user := sc.RunAsUser
+		if user == nil {
+			user = pod.RunAsUser
+		}
+
+		if sc.Privileged != nil && *sc.Privileged {
+			violations = append(violations, "privileged containers are not allowed")
+		}
+		if seccomp != nil && seccomp.Type == corev1.SeccompProfileTypeUnconfined {
+			violations = append(violations, "seccompProfile Unconfined is not allowed")
+		}
+		for _, capability := range caps.Add {
+			if !slices.Contains(baselineCapabilities, capability) || (restricted && capability != "NET_BIND_SERVICE") {
+				violations = append(violations, "adding capability "+string(capability)+" is not allowed")
+			}
+		}
+		if !restricted {
+			continue
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violations = append(violations, "allowPrivilegeEscalation must be false")
+		}
+		if nonRoot == nil || !*nonRoot {
+			violations = append(violations, "runAsNonRoot must be true")
+		}
+		if user != nil && *user == 0 {
+			violations = append(violations, "runAsUser 0 is not allowed")
+		}
+		if seccomp == nil {
+			violations = append(violations, "a RuntimeDefault or Localhost seccompProfile is required")
+		}
+		if !slices.Contains(caps.Drop, "ALL") {
+			violations = append(violations, "capabilities must drop ALL")
+		}
+	}
+	return violations
+}
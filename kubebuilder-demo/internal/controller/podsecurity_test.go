@@ -0,0 +1,155 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	webappv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+func TestRedisSecurityContexts(t *testing.T) {
+	g := NewWithT(t)
+	guestdemo := sampleGuestdemo()
+
+	pod, container := redisSecurityContexts(guestdemo)
+	g.Expect(pod.RunAsNonRoot).To(HaveValue(BeTrue()))
+	g.Expect(pod.RunAsUser).To(HaveValue(Equal(redisUser)))
+	g.Expect(pod.RunAsGroup).To(BeNil(), "the group is left to the image")
+	g.Expect(pod.FSGroup).To(HaveValue(Equal(redisUser)))
+	g.Expect(pod.SeccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+	g.Expect(container.AllowPrivilegeEscalation).To(HaveValue(BeFalse()))
+	g.Expect(container.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+
+	// Overrides are merged field by field.
+	user := int64(1000)
+	guestdemo.Spec.PodSecurityContext = &corev1.PodSecurityContext{RunAsUser: &user}
+	guestdemo.Spec.SecurityContext = &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_BIND_SERVICE"}}}
+	pod, container = redisSecurityContexts(guestdemo)
+	g.Expect(pod.RunAsUser).To(HaveValue(Equal(user)))
+	g.Expect(pod.FSGroup).To(HaveValue(Equal(redisUser)))
+	g.Expect(container.Capabilities.Add).To(ConsistOf(corev1.Capability("NET_BIND_SERVICE")))
+	g.Expect(container.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+}
+
+func TestPodSecurityViolations(t *testing.T) {
+	yes := true
+	root := int64(0)
+	tests := []struct {
+		name       string
+		level      string
+		mutate     func(*corev1.PodSpec)
+		violations []string
+	}{
+		{name: "restricted defaults", level: "restricted", mutate: func(*corev1.PodSpec) {}},
+		{name: "privileged", level: "baseline", mutate: func(s *corev1.PodSpec) {
+			s.Containers[0].SecurityContext.Privileged = &yes
+		}, violations: []string{"privileged containers are not allowed"}},
+		{name: "unconfined seccomp", level: "baseline", mutate: func(s *corev1.PodSpec) {
+			s.SecurityContext.SeccompProfile.Type = corev1.SeccompProfileTypeUnconfined
+		}, violations: []string{"seccompProfile Unconfined is not allowed"}},
+		{name: "baseline capability under baseline", level: "baseline", mutate: func(s *corev1.PodSpec) {
+			s.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"CHOWN"}
+		}},
+		{name: "baseline capability under restricted", level: "restricted", mutate: func(s *corev1.PodSpec) {
+			s.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"NET_BIND_SERVICE", "CHOWN"}
+		}, violations: []string{"adding capability CHOWN is not allowed"}},
+		{name: "SYS_ADMIN", level: "baseline", mutate: func(s *corev1.PodSpec) {
+			s.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"SYS_ADMIN"}
+		}, violations: []string{"adding capability SYS_ADMIN is not allowed"}},
+		{name: "root", level: "restricted", mutate: func(s *corev1.PodSpec) {
+			s.SecurityContext.RunAsUser = &root
+		}, violations: []string{"runAsUser 0 is not allowed"}},
+		{name: "no security context", level: "restricted", mutate: func(s *corev1.PodSpec) {
+			s.SecurityContext = nil
+			s.Containers[0].SecurityContext = nil
+		}, violations: []string{
+			"allowPrivilegeEscalation must be false",
+			"runAsNonRoot must be true",
+			"a RuntimeDefault or Localhost seccompProfile is required",
+			"capabilities must drop ALL",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			spec := newRedisPod("cache-0", sampleGuestdemo(), config.Default()).Spec
+			tt.mutate(&spec)
+			g.Expect(podSecurityViolations(tt.level, &spec)).To(Equal(tt.violations))
+		})
+	}
+}
+
+func TestSetPodSecurityCondition(t *testing.T) {
+	escalation := true
+	tests := []struct {
+		name     string
+		level    string
+		override *corev1.SecurityContext
+		status   metav1.ConditionStatus
+		reason   string
+		message  string
+	}{
+		{name: "not enforced", status: metav1.ConditionTrue, reason: "NotEnforced"},
+		{name: "restricted", level: "restricted", status: metav1.ConditionTrue, reason: "Compliant"},
+		{name: "override violates", level: "restricted", override: &corev1.SecurityContext{AllowPrivilegeEscalation: &escalation},
+			status: metav1.ConditionFalse, reason: "Violation", message: "allowPrivilegeEscalation must be false"},
+		{name: "override allowed by baseline", level: "baseline", override: &corev1.SecurityContext{AllowPrivilegeEscalation: &escalation},
+			status: metav1.ConditionTrue, reason: "Compliant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			scheme := runtime.NewScheme()
+			utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+			utilruntime.Must(webappv1.AddToScheme(scheme))
+			guestdemo := sampleGuestdemo()
+			guestdemo.Spec.SecurityContext = tt.override
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: guestdemo.Namespace}}
+			if tt.level != "" {
+				namespace.Labels = map[string]string{podSecurityEnforceLabel: tt.level}
+			}
+			r := &GuestdemoReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(guestdemo, namespace).
+					WithStatusSubresource(&webappv1.Guestdemo{}).Build(),
+				Scheme: scheme,
+			}
+
+			g.Expect(r.setPodSecurityCondition(ctx, guestdemo, config.Default())).To(Succeed())
+			stored := &webappv1.Guestdemo{}
+			g.Expect(r.Get(ctx, client.ObjectKeyFromObject(guestdemo), stored)).To(Succeed())
+			cond := meta.FindStatusCondition(stored.Status.Conditions, webappv1.ConditionPodSecurityCompliant)
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Status).To(Equal(tt.status))
+			g.Expect(cond.Reason).To(Equal(tt.reason))
+			g.Expect(cond.Message).To(ContainSubstring(tt.message))
+		})
+	}
+}
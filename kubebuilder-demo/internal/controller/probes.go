@@ -0,0 +1,105 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+
+	webappv1 "my.domain/demo/api/v1"
+)
+
+// podSpecAnnotation records the spec a pod was created with: the image,
+// pull secrets and resources from the operator config as well as the
+// probes and security contexts. Most of it cannot be updated on a pod, a
+// pod whose value differs from the desired one is replaced.
+const podSpecAnnotation = "webapp.my.domain/pod-spec-hash"
+
+// redisStartupSeconds is how long Redis may take to load its data before
+// the liveness probe takes over.
+const redisStartupSeconds = 60
+
+// redisProbes are the default Redis probes with spec.probes on top.
+// redis-cli exits 0 on an error reply as well, so the reply is checked.
+func redisProbes(guestdemo *webappv1.Guestdemo) webappv1.ProbesSpec {
+	ping := corev1.ProbeHandler{Exec: &corev1.ExecAction{
+		Command: []string{"sh", "-c", "redis-cli ping | grep -q PONG"},
+	}}
+	defaults := webappv1.ProbesSpec{
+		Startup:   &corev1.Probe{ProbeHandler: ping, PeriodSeconds: 5, TimeoutSeconds: 5, FailureThreshold: redisStartupSeconds / 5},
+		Liveness:  &corev1.Probe{ProbeHandler: ping, PeriodSeconds: 10, TimeoutSeconds: 5, FailureThreshold: 3},
+		Readiness: &corev1.Probe{ProbeHandler: ping, PeriodSeconds: 5, TimeoutSeconds: 5, FailureThreshold: 3},
+	}
+	overrides := guestdemo.Spec.Probes
+	if overrides == nil {
+		overrides = &webappv1.ProbesSpec{}
+	}
+	return webappv1.ProbesSpec{
+		Startup:   overrideProbe(defaults.Startup, overrides.Startup),
+		Liveness:  overrideProbe(defaults.Liveness, overrides.Liveness),
+		Readiness: overrideProbe(defaults.Readiness, overrides.Readiness),
+	}
+}
+
+// overrideProbe completes override with the fields it leaves unset from
+// def. It returns nil when neither has a handler.
+func overrideProbe(def, override *corev1.Probe) *corev1.Probe {
+	if override == nil {
+		return def.DeepCopy()
+	}
+	probe := override.DeepCopy()
+	if def == nil {
+		def = &corev1.Probe{}
+	}
+	if probe.ProbeHandler == (corev1.ProbeHandler{}) {
+		probe.ProbeHandler = *def.ProbeHandler.DeepCopy()
+	}
+	if probe.ProbeHandler == (corev1.ProbeHandler{}) {
+		return nil
+	}
+	if probe.InitialDelaySeconds == 0 {
+		probe.InitialDelaySeconds = def.InitialDelaySeconds
+	}
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = def.TimeoutSeconds
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = def.PeriodSeconds
+	}
+	if probe.SuccessThreshold == 0 {
+		probe.SuccessThreshold = def.SuccessThreshold
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = def.FailureThreshold
+	}
+	if probe.TerminationGracePeriodSeconds == nil {
+		probe.TerminationGracePeriodSeconds = def.TerminationGracePeriodSeconds
+	}
+	return probe
+}
+
+// podSpecHash is the podSpecAnnotation value of pod. It covers the whole
+// desired spec, so a reloaded operator config reaches the pods as well.
+func podSpecHash(pod *corev1.Pod) string {
+	data, _ := json.Marshal(pod.Spec)
+	hash := fnv.New32a()
+	hash.Write(data)
+	return strconv.FormatUint(uint64(hash.Sum32()), 16)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	webappv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// Plain tests on the fake client, next to the envtest suite.
+
+func sampleGuestdemo() *webappv1.Guestdemo {
+	return &webappv1.Guestdemo{
+		ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "default", UID: "cache-uid"},
+		Spec:       webappv1.GuestdemoSpec{Port: 6379, Num: 2},
+	}
+}
+
+func TestRedisProbes(t *testing.T) {
+	g := NewWithT(t)
+	guestdemo := sampleGuestdemo()
+
+	probes := redisProbes(guestdemo)
+	for _, probe := range []*corev1.Probe{probes.Startup, probes.Liveness, probes.Readiness} {
+		g.Expect(probe.Exec.Command).To(Equal([]string{"sh", "-c", "redis-cli ping | grep -q PONG"}))
+	}
+	g.Expect(probes.Startup.FailureThreshold * probes.Startup.PeriodSeconds).To(BeEquivalentTo(redisStartupSeconds))
+
+	tcp := corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{}}
+	guestdemo.Spec.Probes = &webappv1.ProbesSpec{
+		Startup:   &corev1.Probe{FailureThreshold: 60},
+		Readiness: &corev1.Probe{ProbeHandler: tcp},
+	}
+	probes = redisProbes(guestdemo)
+	g.Expect(probes.Startup.Exec).NotTo(BeNil(), "an override without handler keeps the default check")
+	g.Expect(probes.Startup.FailureThreshold).To(BeEquivalentTo(60))
+	g.Expect(probes.Startup.PeriodSeconds).To(BeEquivalentTo(5))
+	g.Expect(probes.Readiness.ProbeHandler).To(Equal(tcp))
+	g.Expect(probes.Readiness.FailureThreshold).To(BeEquivalentTo(3))
+	g.Expect(overrideProbe(nil, &corev1.Probe{PeriodSeconds: 10})).To(BeNil(), "a probe without handler has nothing to run")
+}
+
+func TestRolloutRedis(t *testing.T) {
+	cfg := config.Default()
+	ready := corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}}
+	tests := []struct {
+		name     string
+		outdated []bool
+		ready    []bool
+		replaced string
+	}{
+		{name: "up to date", outdated: []bool{false, false}, ready: []bool{true, true}},
+		{name: "first outdated pod", outdated: []bool{false, true}, ready: []bool{true, true}, replaced: "cache-1"},
+		{name: "another pod not ready", outdated: []bool{true, false}, ready: []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			guestdemo := sampleGuestdemo()
+			names := GetRedisPodName(guestdemo)
+			var objs []client.Object
+			for i, name := range names {
+				pod := newRedisPod(name, guestdemo, cfg)
+				if tt.outdated[i] {
+					pod.Annotations[podSpecAnnotation] = "outdated"
+				}
+				if tt.ready[i] {
+					pod.Status = ready
+				}
+				objs = append(objs, pod)
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).Build()
+
+			g.Expect(RolloutRedis(ctx, c, guestdemo, names, cfg)).To(Succeed())
+			for _, name := range names {
+				err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: guestdemo.Namespace}, &corev1.Pod{})
+				if name == tt.replaced {
+					g.Expect(errors.IsNotFound(err)).To(BeTrue(), "%s is replaced", name)
+				} else {
+					g.Expect(err).NotTo(HaveOccurred(), "%s is kept", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRolloutRedisOnConfigChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(cfg *config.OperatorConfig)
+		replaced bool
+	}{
+		{name: "unchanged", change: func(cfg *config.OperatorConfig) {}},
+		{name: "image", change: func(cfg *config.OperatorConfig) {
+			cfg.Images[config.ComponentRedis] = "redis:7-alpine"
+		}, replaced: true},
+		{name: "registry mirror", change: func(cfg *config.OperatorConfig) {
+			cfg.Registry.Mirrors = []config.RegistryMirror{{Prefix: "xci-harbor.enflame.cn/", Replacement: "mirror.example.com/"}}
+		}, replaced: true},
+		{name: "pull secrets", change: func(cfg *config.OperatorConfig) {
+			cfg.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+		}, replaced: true},
+		{name: "resources", change: func(cfg *config.OperatorConfig) {
+			cfg.Resources = map[string]corev1.ResourceRequirements{config.ComponentRedis: {
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+			}}
+		}, replaced: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			guestdemo := sampleGuestdemo()
+			names := GetRedisPodName(guestdemo)
+			var objs []client.Object
+			for _, name := range names {
+				pod := newRedisPod(name, guestdemo, config.Default())
+				pod.Status = corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}}
+				objs = append(objs, pod)
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).Build()
+
+			cfg := config.Default()
+			tt.change(cfg)
+			g.Expect(RolloutRedis(ctx, c, guestdemo, names, cfg)).To(Succeed())
+			err := c.Get(ctx, client.ObjectKey{Name: names[0], Namespace: guestdemo.Namespace}, &corev1.Pod{})
+			g.Expect(errors.IsNotFound(err)).To(Equal(tt.replaced))
+			g.Expect(c.Get(ctx, client.ObjectKey{Name: names[1], Namespace: guestdemo.Namespace}, &corev1.Pod{})).To(Succeed(), "one pod at a time")
+		})
+	}
+}
@@ -8,39 +8,131 @@ package controller
 import (
 	"context"
 	"fmt"
+	"log"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	webappv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func CreateRedis(podName string, client client.Client, guestdemo *webappv1.Guestdemo, scheme *runtime.Scheme) (string, error) {
+func CreateRedis(podName string, client client.Client, guestdemo *webappv1.Guestdemo, scheme *runtime.Scheme, cfg *config.OperatorConfig) (string, error) {
 	if IsExists(podName, guestdemo, client) {
 		return podName, nil
 	}
+	newPod := newRedisPod(podName, guestdemo, cfg)
+	err := controllerutil.SetControllerReference(guestdemo, newPod, scheme)
+	if err != nil {
+		return podName, err
+	}
+	return podName, client.Create(context.Background(), newPod)
+}
+
+// redisLabels identify the Redis pods of guestdemo, the NetworkPolicy
+// selects them by these.
+func redisLabels(guestdemo *webappv1.Guestdemo) map[string]string {
+	return map[string]string{
+		"app":          "guestdemo",
+		"guestdemo_cr": guestdemo.Name,
+		"tier":         "redis",
+	}
+}
+
+// newRedisPod is the desired Redis pod podName, annotated with the hash of
+// the fields RolloutRedis compares.
+func newRedisPod(podName string, guestdemo *webappv1.Guestdemo, cfg *config.OperatorConfig) *corev1.Pod {
 	newPod := &corev1.Pod{}
 	newPod.Name = podName
 	newPod.Namespace = guestdemo.Namespace
+	probes := redisProbes(guestdemo)
+	podSecurity, security := redisSecurityContexts(guestdemo)
+	newPod.Labels = cfg.WithLabels(redisLabels(guestdemo))
+	newPod.Spec.ImagePullSecrets = cfg.ImagePullSecrets
+	newPod.Spec.SecurityContext = podSecurity
 	newPod.Spec.Containers = []corev1.Container{
 		{
 			Name:            guestdemo.Name,
-			Image:           "xci-harbor.enflame.cn/docker.io/library/redis:5-alpine",
+			Image:           cfg.Image(config.ComponentRedis, guestdemo.Spec.Image),
 			ImagePullPolicy: corev1.PullIfNotPresent,
+			Resources:       cfg.ResourcesFor(config.ComponentRedis, guestdemo.Spec.Resources),
 			Ports: []corev1.ContainerPort{
 				{
 					ContainerPort: int32(guestdemo.Spec.Port),
 				},
 			},
+			StartupProbe:    probes.Startup,
+			LivenessProbe:   probes.Liveness,
+			ReadinessProbe:  probes.Readiness,
+			SecurityContext: security,
 		},
 	}
+	newPod.Annotations = cfg.WithAnnotations(map[string]string{podSpecAnnotation: podSpecHash(newPod)})
+	return newPod
+}
+
+// RolloutRedis replaces the first Redis pod whose spec is outdated, see
+// podSpecAnnotation. Nothing is deleted while a pod is missing,
+// terminating or not ready, so the pods are replaced one at a time; their
+// events trigger the next step. Missing labels are added in place.
+func RolloutRedis(ctx context.Context, client client.Client, guestdemo *webappv1.Guestdemo, podNames []string, cfg *config.OperatorConfig) error {
+	want := podSpecHash(newRedisPod("", guestdemo, cfg))
+	var outdated *corev1.Pod
+	for _, podName := range podNames {
+		pod := &corev1.Pod{}
+		if err := client.Get(ctx, types.NamespacedName{Name: podName, Namespace: guestdemo.Namespace}, pod); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if err := labelRedisPod(ctx, client, guestdemo, pod); err != nil {
+			return err
+		}
+		if !pod.DeletionTimestamp.IsZero() || !isPodReady(pod) {
+			return nil
+		}
+		if outdated == nil && pod.Annotations[podSpecAnnotation] != want {
+			outdated = pod
+		}
+	}
+	if outdated == nil {
+		return nil
+	}
+	log.Println("Replace outdated redis pod:", outdated.Name)
+	return client.Delete(ctx, outdated)
+}
 
-	err := controllerutil.SetControllerReference(guestdemo, newPod, scheme)
-	if err != nil {
-		return podName, err
+// labelRedisPod adds the redisLabels missing on a pod created by an
+// earlier release. Labels can be changed in place, unlike the probes.
+func labelRedisPod(ctx context.Context, c client.Client, guestdemo *webappv1.Guestdemo, pod *corev1.Pod) error {
+	patch := client.MergeFrom(pod.DeepCopy())
+	changed := false
+	for k, v := range redisLabels(guestdemo) {
+		if pod.Labels[k] != v {
+			if pod.Labels == nil {
+				pod.Labels = map[string]string{}
+			}
+			pod.Labels[k] = v
+			changed = true
+		}
 	}
-	return podName, client.Create(context.Background(), newPod)
+	if !changed {
+		return nil
+	}
+	return c.Patch(ctx, pod, patch)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
 }
 
 func IsExists(podName string, guestdemo *webappv1.Guestdemo, client client.Client) bool {
@@ -17,9 +17,11 @@ limitations under the License.
 package v1
 
 import (
-	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func init() {
@@ -31,6 +33,15 @@ func init() {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="MySQL",type=integer,JSONPath=`.status.mysql.readyReplicas`,description="Ready MySQL replicas"
+//+kubebuilder:printcolumn:name="Webapp",type=integer,JSONPath=`.status.webapp.readyReplicas`,description="Ready webapp replicas"
+//+kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.webapp.desiredReplicas`,description="Desired webapp replicas"
+//+kubebuilder:printcolumn:name="Endpoint",type=string,JSONPath=`.status.webapp.endpoint`,priority=1
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`,priority=1
+//+kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.revision`,priority=1
+//+kubebuilder:printcolumn:name="Blocked On",type=string,JSONPath=`.status.blockedOn.node`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // WebService is the Schema for the webservices API
 type WebService struct {
@@ -42,21 +53,325 @@ type WebService struct {
 }
 
 // WebServiceSpec defines the desired state of WebService
+// +kubebuilder:validation:XValidation:rule="has(self.database) || has(self.mysql)",message="spec.database is required"
+// +kubebuilder:validation:XValidation:rule="has(self.webapp) || (has(self.components) && size(self.components) > 0)",message="spec.webapp or spec.components is required"
+// +kubebuilder:validation:XValidation:rule="has(self.nameOverride) == has(oldSelf.nameOverride) && (!has(self.nameOverride) || self.nameOverride == oldSelf.nameOverride)",message="spec.nameOverride is immutable"
 type WebServiceSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
 	// Foo is an example field of WebService. Edit webservice_types.go to remove/update
 
-	Mysql  *WebServiceDbSpec     `json:"mysql,omitempty"`
+	// NameOverride replaces the WebService name at the start of the names
+	// of its children, e.g. "<nameOverride>-<component>". It is set at
+	// creation and cannot be changed.
+	//+kubebuilder:validation:MaxLength=40
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	//+optional
+	NameOverride string `json:"nameOverride,omitempty"`
+
+	// Database is the database of the webapp, MySQL unless engine says
+	// otherwise.
+	//+optional
+	Database *WebServiceDbSpec `json:"database,omitempty"`
+	// Deprecated: use Database. Mysql is only read when Database is unset.
+	//+optional
+	Mysql *WebServiceDbSpec `json:"mysql,omitempty"`
+	// Webapp is the single application tier of earlier releases. It is
+	// reconciled as the first component.
+	//+optional
 	Webapp *WebServiceWebappSpec `json:"webapp,omitempty"`
+	// Components are further application tiers, such as an API, a worker
+	// and a frontend. Each one runs as the Deployment and Service
+	// "<webservice>-<component>" and is applied once the components in its
+	// dependsOn are ready.
+	//+listType=map
+	//+listMapKey=name
+	//+optional
+	Components []WebServiceWebappSpec `json:"components,omitempty"`
+
+	// DeletionPolicy decides what happens to the MySQL data when the
+	// WebService is deleted. Delete removes the Secret and the PVCs, Retain
+	// keeps them, Snapshot keeps them and dumps the database to a separate
+	// PVC before MySQL is stopped.
+	//+kubebuilder:default=Delete
+	//+optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// AdoptionPolicy decides what happens when a Deployment, StatefulSet,
+	// Service or Secret the operator is about to create already exists
+	// without being controlled by the WebService. Never refuses it,
+	// IfLabeled only adopts objects labelled
+	// "app.enflame.cn/adopt: <webservice>", Always adopts any object no
+	// other controller owns. Adopted objects are reconciled to the spec.
+	//+kubebuilder:default=Never
+	//+optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// RevisionHistoryLimit is the number of specs kept as ControllerRevisions
+	// for rollbackTo, the current one included.
+	//+kubebuilder:default=10
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// RollbackTo restores the spec recorded as this revision, see
+	// status.revision and "kubectl webservice history". The operator
+	// replaces the spec with the recorded one, which leaves rollbackTo
+	// unset, and records it again as the newest revision.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+
+	// Migrations bring the schema up to date before every webapp rollout.
+	//+optional
+	Migrations *MigrationsSpec `json:"migrations,omitempty"`
+
+	// NetworkPolicy controls the NetworkPolicies generated for the
+	// database and the components, which are created unless disabled.
+	//+optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+}
+
+// NetworkPolicySpec controls the generated NetworkPolicies. The database
+// only accepts the components, its own pods, the Jobs of the WebService
+// and the operator; each component only accepts traffic on its ports.
+type NetworkPolicySpec struct {
+	// Enabled set to false deletes the generated NetworkPolicies.
+	//+kubebuilder:default=true
+	//+optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// NetworkPolicyEnabled reports whether the NetworkPolicies are generated,
+// which they are unless spec.networkPolicy.enabled is false.
+func (s *WebServiceSpec) NetworkPolicyEnabled() bool {
+	return s.NetworkPolicy == nil || s.NetworkPolicy.Enabled == nil || *s.NetworkPolicy.Enabled
+}
+
+// MigrationsSpec is either a migration image or a ConfigMap of SQL files.
+// They run as a Job once MySQL is ready, again whenever the webapp image or
+// the migrations change, and the webapp Deployment is only updated once the
+// Job succeeded.
+type MigrationsSpec struct {
+	// Image runs Command with the same database variables as the webapp.
+	// An empty Image with an empty ConfigMap runs Command in the webapp
+	// image.
+	//+optional
+	Image string `json:"image,omitempty"`
+	//+optional
+	Command []string `json:"command,omitempty"`
+	//+optional
+	Args []string `json:"args,omitempty"`
+
+	// ConfigMap holds versioned SQL files such as "V001__init.sql". Keys
+	// ending in .sql are applied in lexical order, each one once: applied
+	// versions are recorded in the schema_migrations table.
+	//+optional
+	ConfigMap *corev1.LocalObjectReference `json:"configMap,omitempty"`
 }
 
+// DeletionPolicy controls the teardown of the database of a WebService.
+// +kubebuilder:validation:Enum=Delete;Retain;Snapshot
+type DeletionPolicy string
+
+const (
+	DeletionPolicyDelete   DeletionPolicy = "Delete"
+	DeletionPolicyRetain   DeletionPolicy = "Retain"
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
+// AdoptionPolicy controls the adoption of existing objects by a WebService.
+// +kubebuilder:validation:Enum=Never;IfLabeled;Always
+type AdoptionPolicy string
+
+const (
+	AdoptionPolicyNever     AdoptionPolicy = "Never"
+	AdoptionPolicyIfLabeled AdoptionPolicy = "IfLabeled"
+	AdoptionPolicyAlways    AdoptionPolicy = "Always"
+)
+
 // WebServiceStatus defines the observed state of WebService
 type WebServiceStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	appsv1.DeploymentStatus `json:",inline"`
+
+	// ObservedGeneration is the generation of the spec the children were last synced to.
+	ObservedGeneration int64           `json:"observedGeneration,omitempty"`
+	Phase              WebServicePhase `json:"phase,omitempty"`
+
+	Mysql  *ComponentStatus `json:"mysql,omitempty"`
+	Webapp *ComponentStatus `json:"webapp,omitempty"`
+	// Components reports spec.components in dependency order. A component
+	// waiting for its dependencies has no entry yet.
+	Components []ComponentStatus `json:"components,omitempty"`
+
+	// Topology is the MySQL replication topology.
+	Topology *MysqlTopologyStatus `json:"topology,omitempty"`
+
+	// LastBackupScheduleTime is the last time a scheduled backup was
+	// created.
+	LastBackupScheduleTime *metav1.Time `json:"lastBackupScheduleTime,omitempty"`
+	// Backups lists the newest backups of this WebService, newest first.
+	Backups []BackupRecord `json:"backups,omitempty"`
+
+	// Migrations reports the last successful schema migration.
+	Migrations *MigrationStatus `json:"migrations,omitempty"`
+
+	// DatabaseSecret is the Secret the MySQL credentials are read from.
+	DatabaseSecret string `json:"databaseSecret,omitempty"`
+	// Binding references the servicebinding.io binding Secret, which makes
+	// the WebService a Provisioned Service.
+	Binding *corev1.LocalObjectReference `json:"binding,omitempty"`
+
+	// URL is where the webapp is reachable from outside the cluster: the
+	// Ingress, else the first HTTPRoute hostname, else the LoadBalancer
+	// address. Empty for ClusterIP and NodePort only exposure.
+	URL string `json:"url,omitempty"`
+
+	// Revision numbers the spec the children were last synced to,
+	// CurrentRevision is the ControllerRevision that holds it.
+	Revision        int64  `json:"revision,omitempty"`
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// BlockedOn is the child the reconcile waits for before applying the
+	// children that depend on it. Unset once every child is applied and
+	// ready.
+	BlockedOn *BlockedNode `json:"blockedOn,omitempty"`
+
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// BlockedNode is a node of the reconcile graph that is not ready yet.
+type BlockedNode struct {
+	// Node names the child, such as "database", "schema" or
+	// "component/api".
+	Node string `json:"node"`
+	// Reason tells what the node waits for.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Since is when the reconcile started waiting for the node. The
+	// requeue interval grows with the wait.
+	Since metav1.Time `json:"since"`
+}
+
+// WebServicePhase summarises the state of all components.
+// +kubebuilder:validation:Enum=Pending;DatabaseProvisioning;Deploying;Ready;Degraded;Terminating
+type WebServicePhase string
+
+const (
+	PhasePending              WebServicePhase = "Pending"
+	PhaseDatabaseProvisioning WebServicePhase = "DatabaseProvisioning"
+	PhaseDeploying            WebServicePhase = "Deploying"
+	PhaseReady                WebServicePhase = "Ready"
+	PhaseDegraded             WebServicePhase = "Degraded"
+	PhaseTerminating          WebServicePhase = "Terminating"
+)
+
+// Condition types set on WebServiceStatus.Conditions.
+const (
+	ConditionReady         = "Ready"
+	ConditionDatabaseReady = "DatabaseReady"
+	ConditionWebappReady   = "WebappReady"
+	// ConditionComponentsReady is true when every entry of spec.components
+	// is ready.
+	ConditionComponentsReady = "ComponentsReady"
+	// ConditionTerminating carries the current teardown step as reason.
+	ConditionTerminating = "Terminating"
+	// ConditionStorageMigrated tracks the move of a Deployment based MySQL
+	// to the StatefulSet.
+	ConditionStorageMigrated = "StorageMigrated"
+	// ConditionReplicationReady is true when every MySQL replica is
+	// replicating from the primary.
+	ConditionReplicationReady = "ReplicationReady"
+	// ConditionBackupScheduled is false when spec.database.backup.schedule
+	// cannot be parsed.
+	ConditionBackupScheduled = "BackupScheduled"
+	// ConditionSchemaMigrated is false while the migrations of the current
+	// webapp image are pending, running or failed. The webapp Deployment
+	// is left untouched until it is true.
+	ConditionSchemaMigrated = "SchemaMigrated"
+	// ConditionExposed is false when spec.webapp.expose asks for something
+	// the cluster cannot provide, such as an HTTPRoute without the Gateway
+	// API.
+	ConditionExposed = "Exposed"
+	// ConditionPodSecurityCompliant is false when the pods, with their
+	// securityContext overrides and podTemplate overlays, break the Pod
+	// Security level the namespace enforces.
+	ConditionPodSecurityCompliant = "PodSecurityCompliant"
+	// ConditionConflict is true while a child cannot be applied because an
+	// object of that name exists and spec.adoptionPolicy refuses to adopt
+	// it.
+	ConditionConflict = "Conflict"
+)
+
+// ComponentStatus reports the health of one component's workload.
+type ComponentStatus struct {
+	// Name of the workload backing the component.
+	Name            string `json:"name,omitempty"`
+	DesiredReplicas int32  `json:"desiredReplicas"`
+	ReadyReplicas   int32  `json:"readyReplicas"`
+	Image           string `json:"image,omitempty"`
+	// Endpoint is the in-cluster address of the component's Service.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// MysqlTopologyStatus names the primary and reports every replica.
+type MysqlTopologyStatus struct {
+	// Primary is the pod behind the write Service.
+	Primary  string               `json:"primary,omitempty"`
+	Replicas []MysqlReplicaStatus `json:"replicas,omitempty"`
+}
+
+// MysqlReplicaState is the progress of one replica.
+// +kubebuilder:validation:Enum=Pending;Seeding;Replicating;Failed
+type MysqlReplicaState string
+
+const (
+	// MysqlReplicaPending waits for the pod to become ready.
+	MysqlReplicaPending MysqlReplicaState = "Pending"
+	// MysqlReplicaSeeding copies the primary into the replica.
+	MysqlReplicaSeeding     MysqlReplicaState = "Seeding"
+	MysqlReplicaReplicating MysqlReplicaState = "Replicating"
+	// MysqlReplicaFailed has a stopped replication thread, see LastError.
+	MysqlReplicaFailed MysqlReplicaState = "Failed"
+)
+
+// MysqlReplicaStatus is the replication state of one replica pod.
+type MysqlReplicaStatus struct {
+	Pod   string            `json:"pod"`
+	State MysqlReplicaState `json:"state"`
+	// LagSeconds is Seconds_Behind_Source as last seen by the operator.
+	//+optional
+	LagSeconds *int64 `json:"lagSeconds,omitempty"`
+	//+optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// MigrationStatus identifies the migrations the database schema is at.
+type MigrationStatus struct {
+	// AppliedVersion is the last SQL file of the ConfigMap without its
+	// extension, or the migration image.
+	AppliedVersion string `json:"appliedVersion,omitempty"`
+	// WebappImage is the webapp image the migrations were run for.
+	WebappImage string `json:"webappImage,omitempty"`
+	// Hash identifies the migrations and the webapp image, a different
+	// hash runs the migrations again.
+	Hash string `json:"hash,omitempty"`
+	// Job is the Job that applied them.
+	Job string `json:"job,omitempty"`
+	// AppliedTime is when the Job succeeded.
+	AppliedTime *metav1.Time `json:"appliedTime,omitempty"`
+}
+
+// BackupRecord is the outcome of one WebServiceBackup.
+type BackupRecord struct {
+	Name           string       `json:"name"`
+	Phase          BackupPhase  `json:"phase,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	Location       string       `json:"location,omitempty"`
+	SizeBytes      int64        `json:"sizeBytes,omitempty"`
+	Checksum       string       `json:"checksum,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -69,19 +384,313 @@ type WebServiceList struct {
 }
 
 type WebServiceDbSpec struct {
-	Name      string                      `json:"name"`
-	Size      *int32                      `json:"size"`
-	Image     string                      `json:"image"`
+	// Engine selects the database. Replicas and Xtrabackup backups need
+	// MySQL, backups and the Snapshot deletion policy a MySQL compatible
+	// engine.
+	//+kubebuilder:default=MySQL
+	//+optional
+	Engine DatabaseEngine `json:"engine,omitempty"`
+
+	// Name is the client Service of WebServices reconciled before child
+	// names were derived from the WebService name. The Service is now
+	// "<name>-<engine component>".
+	Name string `json:"name"`
+	// Deprecated: use Replicas. A Size above 1 is read as Size-1 replicas.
+	//+optional
+	Size *int32 `json:"size,omitempty"`
+	// Replicas is the number of read replicas next to the primary. They
+	// replicate asynchronously with GTID auto-positioning and are served by
+	// the "<name>-read" Service.
+	//+kubebuilder:validation:Minimum=0
+	//+optional
+	Replicas  *int32                      `json:"replicas,omitempty"`
+	Image     string                      `json:"image,omitempty"`
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 	Envs      []corev1.EnvVar             `json:"envs,omitempty"`
 	Ports     []corev1.ServicePort        `json:"ports,omitempty"`
+	Auth      *MysqlAuthSpec              `json:"auth,omitempty"`
+	Storage   *MysqlStorageSpec           `json:"storage,omitempty"`
+	// Backup takes WebServiceBackups on a schedule.
+	//+optional
+	Backup *MysqlBackupSpec `json:"backup,omitempty"`
+
+	// PodTemplate overlays the database pod template the same way as
+	// the podTemplate of a component. The data volume and the image,
+	// ports and resources of the database container stay with the
+	// operator.
+	//+kubebuilder:pruning:PreserveUnknownFields
+	//+kubebuilder:validation:Schemaless
+	//+kubebuilder:validation:Type=object
+	//+optional
+	PodTemplate *runtime.RawExtension `json:"podTemplate,omitempty"`
+
+	// Probes override the default probes of the database container,
+	// which ping the server with the client tool of the engine.
+	//+optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+
+	// PodSecurityContext is merged over the restricted defaults of the
+	// database pod: non-root uid, gid and fsGroup 999, the user of the
+	// official images, and the RuntimeDefault seccomp profile.
+	//+optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext is merged over the restricted defaults of the
+	// database container, see WebServiceWebappSpec.SecurityContext.
+	//+optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// MysqlBackupSpec schedules backups. Scheduled backups are
+// WebServiceBackups labelled with the WebService name, they are not owned
+// by it and outlive it.
+type MysqlBackupSpec struct {
+	// Schedule in cron format, e.g. "0 3 * * *". Missed runs are not
+	// caught up, only the latest one is taken.
+	//+kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+	// Retention is the number of scheduled backups to keep, older ones
+	// are deleted together with their files.
+	//+kubebuilder:default=7
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	Retention int32 `json:"retention,omitempty"`
+	//+kubebuilder:default=Mysqldump
+	//+optional
+	Method BackupMethod `json:"method,omitempty"`
+	//+optional
+	Storage BackupStorage `json:"storage,omitempty"`
 }
 
+// MysqlStorageSpec sizes the data volume of each MySQL pod.
+type MysqlStorageSpec struct {
+	// Size can be increased later, which expands the PVCs online when the
+	// StorageClass allows volume expansion. Shrinking is ignored.
+	//+kubebuilder:default="1Gi"
+	//+optional
+	Size resource.Quantity `json:"size,omitempty"`
+	// StorageClassName of the data volumes, the cluster default if unset.
+	// It cannot be changed once the StatefulSet exists.
+	//+optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+// DatabaseEngine is the database server run for a WebService.
+// +kubebuilder:validation:Enum=MySQL;MariaDB;PostgreSQL
+type DatabaseEngine string
+
+const (
+	DatabaseEngineMySQL      DatabaseEngine = "MySQL"
+	DatabaseEngineMariaDB    DatabaseEngine = "MariaDB"
+	DatabaseEnginePostgreSQL DatabaseEngine = "PostgreSQL"
+)
+
+// DatabaseSpec returns spec.database, or the deprecated spec.mysql when it
+// is unset. It is nil when neither is set: the CRD refuses that, but
+// WebServices stored before the rule existed can still lack both.
+func (s *WebServiceSpec) DatabaseSpec() *WebServiceDbSpec {
+	if s == nil {
+		return nil
+	}
+	if s.Database != nil {
+		return s.Database
+	}
+	return s.Mysql
+}
+
+// MysqlAuthSpec selects the MySQL credentials. Without ExistingSecretRef the
+// operator generates a "<name>-mysql-auth" Secret with random passwords.
+type MysqlAuthSpec struct {
+	// ExistingSecretRef names a Secret in the WebService namespace with the
+	// keys "root-password", "username" and "password". It is never modified.
+	//+optional
+	ExistingSecretRef *corev1.LocalObjectReference `json:"existingSecretRef,omitempty"`
+}
+
+// WebServiceWebappSpec is one application tier, spec.webapp or an entry of
+// spec.components.
 type WebServiceWebappSpec struct {
+	// Name names the children of the tier, "<webservice>-<name>". The
+	// database children are named after the engine, so mysql, mariadb
+	// and postgresql, alone or followed by -headless or -read, are reserved.
+	//+kubebuilder:validation:XValidation:rule="!self.matches('^(mysql|mariadb|postgresql)(-headless|-read)?$')",message="the name is reserved for the database children"
 	Name      string                      `json:"name"`
 	Size      *int32                      `json:"size"`
-	Image     string                      `json:"image"`
+	Image     string                      `json:"image,omitempty"`
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 	Envs      []corev1.EnvVar             `json:"envs,omitempty"`
 	Ports     []corev1.ServicePort        `json:"ports,omitempty"`
+
+	// DatabaseBinding wires the MySQL of this WebService into the webapp.
+	// Unset means Env mode with the default variable names.
+	//+optional
+	DatabaseBinding *DatabaseBindingSpec `json:"databaseBinding,omitempty"`
+
+	// Expose publishes the webapp. Unset keeps the NodePort Service.
+	//+optional
+	Expose *ExposeSpec `json:"expose,omitempty"`
+
+	// Autoscaling hands the replica count over to a HorizontalPodAutoscaler,
+	// Size is ignored while it is set. Removing it deletes the HPA.
+	//+optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// DependsOn names the components that must be ready before this one
+	// is applied. Every component waits for the database anyway.
+	//+optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// PodTemplate is strategically merged into the pod template the
+	// operator generates: containers, volumes and env are merged by name,
+	// so sidecars, init containers, volumes, probes, a securityContext or a
+	// serviceAccountName can be added. The operator owns the pod labels it
+	// sets and the image, ports and resources of the component container, an
+	// overlay changing them is refused.
+	//+kubebuilder:pruning:PreserveUnknownFields
+	//+kubebuilder:validation:Schemaless
+	//+kubebuilder:validation:Type=object
+	//+optional
+	PodTemplate *runtime.RawExtension `json:"podTemplate,omitempty"`
+
+	// Probes override the default probes of the component container,
+	// which check its first port.
+	//+optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+
+	// PodSecurityContext is merged over the restricted defaults of the
+	// pod, runAsNonRoot and the RuntimeDefault seccomp profile; the fields
+	// set here win. The image must run as a non-root user unless runAsUser
+	// is set.
+	//+optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext is merged over the restricted defaults of the
+	// component container: no privilege escalation and all capabilities
+	// dropped.
+	//+optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// ProbesSpec overrides generated probes one by one. A probe that sets a
+// handler replaces the default check, the timing fields it sets replace
+// the default timings and the others are kept.
+type ProbesSpec struct {
+	//+optional
+	Startup *corev1.Probe `json:"startup,omitempty"`
+	//+optional
+	Liveness *corev1.Probe `json:"liveness,omitempty"`
+	//+optional
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+}
+
+// AutoscalingSpec is rendered into an autoscaling/v2 HPA named like the
+// webapp Deployment. Without any target the HPA aims at 80% CPU.
+// +kubebuilder:validation:XValidation:rule="!has(self.minReplicas) || self.minReplicas <= self.maxReplicas",message="minReplicas must not exceed maxReplicas"
+type AutoscalingSpec struct {
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default=1
+	//+optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	//+kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilizationPercentage is relative to the CPU requests of
+	// the webapp container.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// TargetMemoryUtilizationPercentage is relative to the memory requests
+	// of the webapp container.
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+	// Metrics are custom targets, Pods, Object, External or
+	// ContainerResource metrics, added after the CPU and memory targets.
+	//+optional
+	Metrics []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
+	//+optional
+	Behavior *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+}
+
+// ExposeSpec selects the type of the webapp Service and the optional
+// Ingress and Gateway API HTTPRoute routing to it. The Ingress and the
+// HTTPRoute are named like the Service.
+type ExposeSpec struct {
+	//+kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	//+kubebuilder:default=NodePort
+	//+optional
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+	//+optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+	// HTTPRoute needs the Gateway API CRDs, which are looked up when the
+	// operator starts.
+	//+optional
+	HTTPRoute *HTTPRouteSpec `json:"httpRoute,omitempty"`
+}
+
+// IngressSpec routes Host and Path to the first port of the webapp
+// Service.
+type IngressSpec struct {
+	Host string `json:"host"`
+	// Path is a prefix.
+	//+kubebuilder:default="/"
+	//+optional
+	Path string `json:"path,omitempty"`
+	//+optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+	// TLSSecretName terminates TLS for Host with the given Secret.
+	//+optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// Annotations are added to the Ingress, e.g. for the controller.
+	//+optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// HTTPRouteSpec attaches the webapp to existing Gateways.
+type HTTPRouteSpec struct {
+	//+kubebuilder:validation:MinItems=1
+	ParentRefs []GatewayParentRef `json:"parentRefs"`
+	//+optional
+	Hostnames []string `json:"hostnames,omitempty"`
+	// Path is a prefix.
+	//+kubebuilder:default="/"
+	//+optional
+	Path string `json:"path,omitempty"`
+}
+
+// GatewayParentRef names a Gateway and optionally one of its listeners.
+type GatewayParentRef struct {
+	Name string `json:"name"`
+	// Namespace defaults to the namespace of the WebService.
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
+	//+optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// DatabaseBindingMode selects how the connection is handed to the webapp.
+// +kubebuilder:validation:Enum=Env;DSN;None
+type DatabaseBindingMode string
+
+const (
+	// DatabaseBindingEnv injects DB_HOST, DB_PORT, DB_NAME, DB_USER and DB_PASSWORD.
+	DatabaseBindingEnv DatabaseBindingMode = "Env"
+	// DatabaseBindingDSN injects the connection URI as DATABASE_URL only.
+	DatabaseBindingDSN DatabaseBindingMode = "DSN"
+	// DatabaseBindingNone injects nothing.
+	DatabaseBindingNone DatabaseBindingMode = "None"
+)
+
+// DatabaseBindingSpec configures the database binding. The values come from
+// a Secret laid out as a servicebinding.io binding, with the keys type,
+// provider, host, port, database, username, password and uri.
+type DatabaseBindingSpec struct {
+	//+kubebuilder:default=Env
+	//+optional
+	Mode DatabaseBindingMode `json:"mode,omitempty"`
+	// EnvNames renames injected variables, keyed by binding key, e.g.
+	// {"host": "MYSQL_HOST", "uri": "SPRING_DATASOURCE_URL"}.
+	//+optional
+	EnvNames map[string]string `json:"envNames,omitempty"`
+	// Mount projects the binding as files under
+	// $SERVICE_BINDING_ROOT/<type>, with SERVICE_BINDING_ROOT=/bindings.
+	//+optional
+	Mount bool `json:"mount,omitempty"`
 }
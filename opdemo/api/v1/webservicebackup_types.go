@@ -0,0 +1,167 @@
+/*
+Copyright 2024 yuanji.cai.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&WebServiceBackup{}, &WebServiceBackupList{})
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="WebService",type=string,JSONPath=`.spec.webServiceName`
+//+kubebuilder:printcolumn:name="Method",type=string,JSONPath=`.spec.method`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.sizeBytes`
+//+kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.status.location`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// WebServiceBackup is one backup of the MySQL database of a WebService. It
+// is taken once by a Job, deleting it also deletes the backup file.
+type WebServiceBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebServiceBackupSpec   `json:"spec,omitempty"`
+	Status WebServiceBackupStatus `json:"status,omitempty"`
+}
+
+// WebServiceBackupSpec selects the WebService and where its backup goes.
+type WebServiceBackupSpec struct {
+	// WebServiceName is the WebService in the same namespace to back up.
+	//+kubebuilder:validation:MinLength=1
+	WebServiceName string `json:"webServiceName"`
+	//+kubebuilder:default=Mysqldump
+	//+optional
+	Method BackupMethod `json:"method,omitempty"`
+	//+optional
+	Storage BackupStorage `json:"storage,omitempty"`
+}
+
+// BackupMethod selects the tool the backup Job runs.
+// +kubebuilder:validation:Enum=Mysqldump;Xtrabackup
+type BackupMethod string
+
+const (
+	// BackupMethodMysqldump takes a logical, gzipped SQL dump of all
+	// databases. It can be restored in place, into a clone and to a point
+	// in time.
+	BackupMethodMysqldump BackupMethod = "Mysqldump"
+	// BackupMethodXtrabackup copies the data directory of the primary. It
+	// is faster for large databases but can only be restored into a clone.
+	BackupMethodXtrabackup BackupMethod = "Xtrabackup"
+)
+
+// BackupStorage is where backup files are kept. S3 wins if both are set,
+// a PVC named "<webservice>-backups" is used if neither is.
+type BackupStorage struct {
+	//+optional
+	PVC *PVCBackupStorage `json:"pvc,omitempty"`
+	//+optional
+	S3 *S3BackupStorage `json:"s3,omitempty"`
+}
+
+// PVCBackupStorage keeps backups on a PVC. The PVC is created if it does not
+// exist and has no owner, it outlives the WebService.
+type PVCBackupStorage struct {
+	// ClaimName defaults to "<webservice>-backups".
+	//+optional
+	ClaimName string `json:"claimName,omitempty"`
+	// Size is only used when the PVC is created.
+	//+kubebuilder:default="5Gi"
+	//+optional
+	Size resource.Quantity `json:"size,omitempty"`
+}
+
+// S3BackupStorage uploads backups to a bucket of an S3-compatible object
+// store such as AWS S3 or MinIO.
+type S3BackupStorage struct {
+	// Endpoint is the URL of the object store, e.g.
+	// "http://minio.minio.svc:9000" or "https://s3.eu-west-1.amazonaws.com".
+	//+kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+	//+kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to the object keys.
+	//+optional
+	Prefix string `json:"prefix,omitempty"`
+	//+kubebuilder:default=us-east-1
+	//+optional
+	Region string `json:"region,omitempty"`
+	// CredentialsSecretRef names a Secret with the keys "access-key-id"
+	// and "secret-access-key".
+	CredentialsSecretRef corev1.LocalObjectReference `json:"credentialsSecretRef"`
+}
+
+// Keys of the Secret referenced by S3BackupStorage.CredentialsSecretRef.
+const (
+	S3AccessKeyIDKey     = "access-key-id"
+	S3SecretAccessKeyKey = "secret-access-key"
+)
+
+// WebServiceBackupStatus describes the backup file once the Job is done.
+type WebServiceBackupStatus struct {
+	Phase BackupPhase `json:"phase,omitempty"`
+	// Job is the Job taking the backup.
+	Job            string       `json:"job,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Path of the backup file, relative to the PVC root or the object key
+	// in the bucket.
+	Path string `json:"path,omitempty"`
+	// Location is Path as a URL, "pvc://<claim>/<path>" or
+	// "s3://<bucket>/<key>".
+	Location  string `json:"location,omitempty"`
+	SizeBytes int64  `json:"sizeBytes,omitempty"`
+	// Checksum of the backup file as "sha256:<hex>".
+	Checksum string `json:"checksum,omitempty"`
+	// BinlogFile and BinlogPosition are the binary log coordinates of the
+	// primary the backup is consistent with. Point in time restores replay
+	// the binary log from there.
+	BinlogFile     string `json:"binlogFile,omitempty"`
+	BinlogPosition int64  `json:"binlogPosition,omitempty"`
+	// CredentialsSecret holds a copy of the MySQL credentials at backup
+	// time. The backup contains the MySQL accounts, a clone needs the
+	// matching passwords.
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	Message           string `json:"message,omitempty"`
+}
+
+// BackupPhase is the progress of a backup or a restore.
+// +kubebuilder:validation:Enum=Pending;Running;Completed;Failed
+type BackupPhase string
+
+const (
+	BackupPending   BackupPhase = "Pending"
+	BackupRunning   BackupPhase = "Running"
+	BackupCompleted BackupPhase = "Completed"
+	BackupFailed    BackupPhase = "Failed"
+)
+
+//+kubebuilder:object:root=true
+
+// WebServiceBackupList contains a list of WebServiceBackup
+type WebServiceBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebServiceBackup `json:"items"`
+}
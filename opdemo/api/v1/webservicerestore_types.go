@@ -0,0 +1,89 @@
+/*
+Copyright 2024 yuanji.cai.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&WebServiceRestore{}, &WebServiceRestoreList{})
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.spec.backupName`
+//+kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.status.targetWebService`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// WebServiceRestore loads a WebServiceBackup into the WebService it was
+// taken from, or into a new WebService cloned from it.
+type WebServiceRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebServiceRestoreSpec   `json:"spec,omitempty"`
+	Status WebServiceRestoreStatus `json:"status,omitempty"`
+}
+
+// WebServiceRestoreSpec selects the backup and the target.
+type WebServiceRestoreSpec struct {
+	// BackupName is a completed WebServiceBackup in the same namespace.
+	//+kubebuilder:validation:MinLength=1
+	BackupName string `json:"backupName"`
+	// Target clones the backup into a new WebService. Without it the
+	// backup is restored in place, which overwrites the current data.
+	//+optional
+	Target *RestoreTarget `json:"target,omitempty"`
+	// PointInTime replays the binary log of the source primary from the
+	// backup up to this time. The source must still have the binary logs.
+	// Only Mysqldump backups support it.
+	//+optional
+	PointInTime *metav1.Time `json:"pointInTime,omitempty"`
+}
+
+// RestoreTarget is the WebService a backup is cloned into.
+type RestoreTarget struct {
+	// Name of the new WebService. It must not exist yet.
+	//+kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Spec of the new WebService. It defaults to the spec of the source
+	// WebService with the Service names prefixed by Name.
+	//+optional
+	Spec *WebServiceSpec `json:"spec,omitempty"`
+}
+
+// WebServiceRestoreStatus reports the restore.
+type WebServiceRestoreStatus struct {
+	Phase BackupPhase `json:"phase,omitempty"`
+	// Job is the Job loading the backup.
+	Job string `json:"job,omitempty"`
+	// TargetWebService is the WebService the backup is restored into.
+	TargetWebService string       `json:"targetWebService,omitempty"`
+	CompletionTime   *metav1.Time `json:"completionTime,omitempty"`
+	Message          string       `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WebServiceRestoreList contains a list of WebServiceRestore
+type WebServiceRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebServiceRestore `json:"items"`
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2024 yuanji.cai.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-webservice shows the spec history of a WebService and rolls it
+// back. Installed on the PATH it runs as "kubectl webservice".
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/history"
+)
+
+const usage = `Show and roll back the spec history of a WebService.
+
+Usage:
+  kubectl webservice history NAME                 list the revisions
+  kubectl webservice history NAME --revision N    show what revision N changed
+  kubectl webservice diff NAME FROM [TO]          compare two revisions, TO defaults to the current one
+  kubectl webservice rollback NAME REVISION       restore the spec of a revision
+
+Flags:
+`
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(appv1.AddToScheme(scheme))
+}
+
+func main() {
+	flags := pflag.NewFlagSet("kubectl-webservice", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	overrides := &clientcmd.ConfigOverrides{}
+	loading := clientcmd.NewDefaultClientConfigLoadingRules()
+	flags.StringVar(&loading.ExplicitPath, "kubeconfig", "", "Path to the kubeconfig file.")
+	flags.StringVar(&overrides.CurrentContext, "context", "", "The kubeconfig context to use.")
+	flags.StringVarP(&overrides.Context.Namespace, "namespace", "n", "", "The namespace of the WebService.")
+	revision := flags.Int64("revision", 0, "Show the changes of this revision instead of the list.")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	args := flags.Args()
+	if len(args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	kubeconfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loading, overrides)
+	namespace, _, err := kubeconfig.Namespace()
+	if err != nil {
+		fail(err)
+	}
+	cfg, err := kubeconfig.ClientConfig()
+	if err != nil {
+		fail(err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		fail(err)
+	}
+	cmd := &command{Client: c, out: os.Stdout, key: types.NamespacedName{Namespace: namespace, Name: args[1]}}
+
+	ctx := context.Background()
+	switch {
+	case args[0] == "history" && len(args) == 2 && *revision == 0:
+		err = cmd.list(ctx)
+	case args[0] == "history" && len(args) == 2:
+		err = cmd.diff(ctx, nil, revision)
+	case args[0] == "diff" && (len(args) == 3 || len(args) == 4):
+		var from, to *int64
+		if from, err = parseRevision(args[2]); err == nil && len(args) == 4 {
+			to, err = parseRevision(args[3])
+		}
+		if err == nil {
+			err = cmd.diff(ctx, from, to)
+		}
+	case args[0] == "rollback" && len(args) == 3:
+		var to *int64
+		if to, err = parseRevision(args[2]); err == nil {
+			err = cmd.rollback(ctx, *to)
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
+
+func parseRevision(s string) (*int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("revision %q is not a positive number", s)
+	}
+	return &n, nil
+}
+
+// command runs the subcommands against the WebService key and prints to
+// out.
+type command struct {
+	client.Client
+	out io.Writer
+	key types.NamespacedName
+}
+
+// load returns the WebService and its revisions, oldest first.
+func (c *command) load(ctx context.Context) (*appv1.WebService, []appsv1.ControllerRevision, error) {
+	ws := &appv1.WebService{}
+	if err := c.Get(ctx, c.key, ws); err != nil {
+		return nil, nil, err
+	}
+	var list appsv1.ControllerRevisionList
+	if err := c.List(ctx, &list, client.InNamespace(c.key.Namespace), history.Selector(c.key.Name)); err != nil {
+		return nil, nil, err
+	}
+	history.Sort(list.Items)
+	return ws, list.Items, nil
+}
+
+func (c *command) list(ctx context.Context) error {
+	ws, revs, err := c.load(ctx)
+	if err != nil {
+		return err
+	}
+	if len(revs) == 0 {
+		fmt.Fprintf(c.out, "WebService %s has no recorded revisions yet\n", c.key)
+		return nil
+	}
+	w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tNAME\tAGE\tCURRENT")
+	for _, rev := range revs {
+		current := ""
+		if rev.Name == ws.Status.CurrentRevision {
+			current = "*"
+		}
+		age := time.Since(rev.CreationTimestamp.Time).Round(time.Second)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", rev.Revision, rev.Name, age, current)
+	}
+	return w.Flush()
+}
+
+// diff prints the changes from revision from to revision to. A nil to is
+// the current revision, a nil from the revision before to.
+func (c *command) diff(ctx context.Context, from, to *int64) error {
+	ws, revs, err := c.load(ctx)
+	if err != nil {
+		return err
+	}
+	if to == nil {
+		to = &ws.Status.Revision
+	}
+	b := history.Find(revs, *to)
+	if b == nil {
+		return fmt.Errorf("WebService %s has no revision %d", c.key, *to)
+	}
+	var a *appsv1.ControllerRevision
+	if from != nil {
+		if a = history.Find(revs, *from); a == nil {
+			return fmt.Errorf("WebService %s has no revision %d", c.key, *from)
+		}
+	} else {
+		for i := range revs {
+			if revs[i].Revision < b.Revision {
+				a = &revs[i]
+			}
+		}
+	}
+	out, err := history.Diff(a, b)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		out = "The specs are the same.\n"
+	}
+	_, err = fmt.Fprint(c.out, out)
+	return err
+}
+
+// rollback sets spec.rollbackTo, the operator restores the spec.
+func (c *command) rollback(ctx context.Context, to int64) error {
+	ws, revs, err := c.load(ctx)
+	if err != nil {
+		return err
+	}
+	if history.Find(revs, to) == nil {
+		return fmt.Errorf("WebService %s has no revision %d", c.key, to)
+	}
+	patch := client.MergeFrom(ws.DeepCopy())
+	ws.Spec.RollbackTo = &to
+	if err := c.Patch(ctx, ws, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "WebService %s is rolling back to revision %d\n", c.key, to)
+	return nil
+}
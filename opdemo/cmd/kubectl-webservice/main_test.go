@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/history"
+)
+
+// newCommand serves a WebService on nginx:1.25 whose revision 1 ran nginx.
+func newCommand(t *testing.T) (*command, *bytes.Buffer) {
+	t.Helper()
+	app := &appv1.WebService{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default", UID: "shop-uid"},
+		Spec: appv1.WebServiceSpec{
+			Webapp: &appv1.WebServiceWebappSpec{Name: "web", Image: "nginx"},
+		},
+	}
+	objs := []client.Object{app}
+	for n, image := range []string{"nginx", "nginx:1.25"} {
+		app.Spec.Webapp.Image = image
+		data, hash, err := history.Snapshot(&app.Spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rev := &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop-" + hash, Namespace: app.Namespace, Labels: history.Selector(app.Name)},
+			Data:       runtime.RawExtension{Raw: data},
+			Revision:   int64(n + 1),
+		}
+		objs = append(objs, rev)
+		app.Status.Revision = rev.Revision
+		app.Status.CurrentRevision = rev.Name
+	}
+	out := &bytes.Buffer{}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	return &command{Client: c, out: out, key: client.ObjectKeyFromObject(app)}, out
+}
+
+func TestList(t *testing.T) {
+	g := NewWithT(t)
+	cmd, out := newCommand(t)
+
+	g.Expect(cmd.list(context.Background())).To(Succeed())
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	g.Expect(lines).To(HaveLen(3))
+	g.Expect(lines[0]).To(HavePrefix("REVISION"))
+	g.Expect(lines[1]).To(HavePrefix("1 "))
+	g.Expect(lines[1]).NotTo(HaveSuffix("*"))
+	g.Expect(lines[2]).To(HavePrefix("2 "))
+	g.Expect(lines[2]).To(HaveSuffix("*"))
+}
+
+func TestDiff(t *testing.T) {
+	one, two, three := int64(1), int64(2), int64(3)
+	for name, tc := range map[string]struct {
+		from, to *int64
+		contains []string
+		err      string
+	}{
+		"current":        {contains: []string{"--- revision 1", "+++ revision 2", "-  image: nginx\n", "+  image: nginx:1.25\n"}},
+		"first revision": {to: &one, contains: []string{"--- /dev/null", "+++ revision 1", "+  image: nginx\n"}},
+		"from and to":    {from: &two, to: &one, contains: []string{"--- revision 2", "+++ revision 1", "-  image: nginx:1.25\n"}},
+		"same revision":  {from: &two, to: &two, contains: []string{"The specs are the same."}},
+		"unknown to":     {to: &three, err: "WebService default/shop has no revision 3"},
+		"unknown from":   {from: &three, err: "WebService default/shop has no revision 3"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			cmd, out := newCommand(t)
+
+			err := cmd.diff(context.Background(), tc.from, tc.to)
+			if tc.err != "" {
+				g.Expect(err).To(MatchError(tc.err))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			for _, s := range tc.contains {
+				g.Expect(out.String()).To(ContainSubstring(s))
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	for name, tc := range map[string]struct {
+		to  int64
+		err string
+	}{
+		"recorded": {to: 1},
+		"unknown":  {to: 3, err: "WebService default/shop has no revision 3"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			cmd, out := newCommand(t)
+
+			err := cmd.rollback(ctx, tc.to)
+			app := &appv1.WebService{}
+			g.Expect(cmd.Get(ctx, cmd.key, app)).To(Succeed())
+			if tc.err != "" {
+				g.Expect(err).To(MatchError(tc.err))
+				g.Expect(app.Spec.RollbackTo).To(BeNil())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(app.Spec.RollbackTo).To(HaveValue(Equal(tc.to)))
+			g.Expect(out.String()).To(Equal("WebService default/shop is rolling back to revision 1\n"))
+		})
+	}
+}
+
+func TestParseRevision(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(parseRevision("2")).To(HaveValue(Equal(int64(2))))
+	for _, s := range []string{"0", "-1", "latest"} {
+		_, err := parseRevision(s)
+		g.Expect(err).To(MatchError(ContainSubstring("is not a positive number")), s)
+	}
+}
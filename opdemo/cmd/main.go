@@ -32,7 +32,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
 	"gitee.enflame.cn/ModelOps/opdemo/internal/controller"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/mysqladmin"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/objectstore"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -52,11 +55,15 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var configFile string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&configFile, "config", "",
+		"Path to the operator config file, usually mounted from a ConfigMap. "+
+			"The file is reloaded when it changes.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -89,13 +96,52 @@ func main() {
 		os.Exit(1)
 	}
 
+	operatorConfig, err := config.NewStore(configFile, ctrl.Log.WithName("config"))
+	if err != nil {
+		setupLog.Error(err, "unable to load operator config", "config", configFile)
+		os.Exit(1)
+	}
+	if err := mgr.Add(operatorConfig); err != nil {
+		setupLog.Error(err, "unable to watch operator config")
+		os.Exit(1)
+	}
+
+	gatewayAPI := controller.HasGatewayAPI(mgr.GetRESTMapper())
+	setupLog.Info("Gateway API detection", "httpRoutes", gatewayAPI)
 	if err = (&controller.WebServiceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Log:        ctrl.Log.WithName("controllers").WithName("WebService"),
+		Config:     operatorConfig,
+		DialMysql:  mysqladmin.Dial,
+		GatewayAPI: gatewayAPI,
+		Recorder:   mgr.GetEventRecorderFor("webservice-controller"),
+		APIReader:  mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "WebService")
 		os.Exit(1)
 	}
+	if err = (&controller.WebServiceBackupReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Log:       ctrl.Log.WithName("controllers").WithName("WebServiceBackup"),
+		Config:    operatorConfig,
+		OpenStore: objectstore.Open,
+		Recorder:  mgr.GetEventRecorderFor("webservicebackup-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "WebServiceBackup")
+		os.Exit(1)
+	}
+	if err = (&controller.WebServiceRestoreReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Log:      ctrl.Log.WithName("controllers").WithName("WebServiceRestore"),
+		Config:   operatorConfig,
+		Recorder: mgr.GetEventRecorderFor("webservicerestore-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "WebServiceRestore")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
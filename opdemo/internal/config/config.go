@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	APIVersion = "config.enflame.cn/v1alpha1"
+	Kind       = "OperatorConfig"
+)
+
+// Component names used as keys for the per-component defaults.
+const (
+	ComponentMysql      = "mysql"
+	ComponentMariadb    = "mariadb"
+	ComponentPostgresql = "postgresql"
+	ComponentWebapp     = "webapp"
+	// ComponentXtrabackup is the image of Xtrabackup backup and restore
+	// Jobs. It must match the MySQL major version.
+	ComponentXtrabackup = "xtrabackup"
+	// ComponentS3Client uploads and downloads backups in S3 storage, it
+	// must provide the aws CLI.
+	ComponentS3Client = "s3-client"
+)
+
+// OperatorConfig holds the operator wide defaults. Values set in a
+// WebService spec always take precedence over the values in here.
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Images maps a component name to its default image.
+	Images map[string]string `json:"images,omitempty"`
+	// Registry rewrites image references, e.g. to go through a mirror.
+	Registry RegistryConfig `json:"registry,omitempty"`
+	// ImagePullSecrets are added to every pod the operator creates.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Resources maps a component name to its default resource requirements.
+	Resources map[string]corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Labels and Annotations are added to every object the operator creates.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	Mysql MysqlConfig `json:"mysql,omitempty"`
+
+	// Operator locates the manager pods, the generated NetworkPolicies let
+	// them reach the database to configure replication.
+	Operator OperatorPodsConfig `json:"operator,omitempty"`
+}
+
+// OperatorPodsConfig selects the pods of the operator manager.
+type OperatorPodsConfig struct {
+	// Namespace the manager runs in.
+	Namespace string `json:"namespace,omitempty"`
+	// PodLabels select the manager pods in Namespace.
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+}
+
+type RegistryConfig struct {
+	Mirrors []RegistryMirror `json:"mirrors,omitempty"`
+}
+
+// RegistryMirror replaces Prefix with Replacement on fully qualified image
+// references, e.g. "docker.io/" -> "xci-harbor.enflame.cn/docker.io/".
+type RegistryMirror struct {
+	Prefix      string `json:"prefix"`
+	Replacement string `json:"replacement"`
+}
+
+// MysqlConfig holds the database defaults of every engine, the name is
+// historical. Credentials are not configured here, every WebService gets
+// its own generated Secret.
+type MysqlConfig struct {
+	Database string `json:"database,omitempty"`
+}
+
+// Default returns the configuration used when no config file is given.
+func Default() *OperatorConfig {
+	return &OperatorConfig{
+		TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		Images: map[string]string{
+			ComponentMysql:      "mysql:5.7",
+			ComponentMariadb:    "mariadb:10.11",
+			ComponentPostgresql: "postgres:16",
+			ComponentWebapp:     "nginx",
+			ComponentXtrabackup: "percona/percona-xtrabackup:2.4",
+			ComponentS3Client:   "amazon/aws-cli:2.15.30",
+		},
+		Mysql: MysqlConfig{
+			Database: "webservice",
+		},
+		Operator: OperatorPodsConfig{
+			Namespace: "opdemo-system",
+			PodLabels: map[string]string{"control-plane": "controller-manager"},
+		},
+	}
+}
+
+// Load reads the config file at path. Fields missing from the file keep
+// their default value.
+func Load(path string) (*OperatorConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (*OperatorConfig, error) {
+	cfg := &OperatorConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("decode operator config: %w", err)
+	}
+	if cfg.APIVersion != APIVersion || cfg.Kind != Kind {
+		return nil, fmt.Errorf("unsupported operator config %s/%s, want %s/%s", cfg.APIVersion, cfg.Kind, APIVersion, Kind)
+	}
+
+	def := Default()
+	for component, image := range def.Images {
+		if cfg.Images == nil {
+			cfg.Images = map[string]string{}
+		}
+		if cfg.Images[component] == "" {
+			cfg.Images[component] = image
+		}
+	}
+	if cfg.Mysql.Database == "" {
+		cfg.Mysql.Database = def.Mysql.Database
+	}
+	if cfg.Operator.Namespace == "" {
+		cfg.Operator.Namespace = def.Operator.Namespace
+	}
+	if len(cfg.Operator.PodLabels) == 0 {
+		cfg.Operator.PodLabels = def.Operator.PodLabels
+	}
+	return cfg, nil
+}
+
+// Image returns override if set, the component default otherwise, with the
+// registry mirror rules applied.
+func (c *OperatorConfig) Image(component, override string) string {
+	image := override
+	if image == "" {
+		image = c.Images[component]
+	}
+	return c.Registry.Rewrite(image)
+}
+
+// Rewrite applies the first matching mirror rule to image.
+func (r RegistryConfig) Rewrite(image string) string {
+	if image == "" || len(r.Mirrors) == 0 {
+		return image
+	}
+	full := qualifyImage(image)
+	for _, m := range r.Mirrors {
+		if m.Prefix != "" && strings.HasPrefix(full, m.Prefix) {
+			return m.Replacement + strings.TrimPrefix(full, m.Prefix)
+		}
+	}
+	return image
+}
+
+// qualifyImage expands short docker hub references, "nginx" becomes
+// "docker.io/library/nginx" and "bitnami/redis" becomes "docker.io/bitnami/redis".
+func qualifyImage(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return "docker.io/library/" + image
+	}
+	if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return "docker.io/" + image
+	}
+	return image
+}
+
+// ResourcesFor returns override unless it is empty.
+func (c *OperatorConfig) ResourcesFor(component string, override corev1.ResourceRequirements) corev1.ResourceRequirements {
+	if len(override.Limits) > 0 || len(override.Requests) > 0 {
+		return override
+	}
+	res := c.Resources[component]
+	return *res.DeepCopy()
+}
+
+// WithLabels returns the default labels merged with labels, labels win.
+func (c *OperatorConfig) WithLabels(labels map[string]string) map[string]string {
+	return merge(c.Labels, labels)
+}
+
+// WithAnnotations returns the default annotations merged with annotations,
+// annotations win.
+func (c *OperatorConfig) WithAnnotations(annotations map[string]string) map[string]string {
+	return merge(c.Annotations, annotations)
+}
+
+func merge(defaults, values map[string]string) map[string]string {
+	if len(defaults) == 0 && len(values) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(defaults)+len(values))
+	for k, v := range defaults {
+		out[k] = v
+	}
+	for k, v := range values {
+		out[k] = v
+	}
+	return out
+}
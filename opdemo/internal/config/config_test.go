@@ -0,0 +1,106 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+var _ = Describe("OperatorConfig", func() {
+	It("keeps defaults for fields missing from the file", func() {
+		cfg, err := config.Parse([]byte(`
+apiVersion: config.enflame.cn/v1alpha1
+kind: OperatorConfig
+images:
+  webapp: httpd
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Image(config.ComponentWebapp, "")).To(Equal("httpd"))
+		Expect(cfg.Image(config.ComponentMysql, "")).To(Equal("mysql:5.7"))
+		Expect(cfg.Mysql.Database).To(Equal("webservice"))
+		Expect(cfg.Operator.Namespace).To(Equal("opdemo-system"))
+		Expect(cfg.Operator.PodLabels).To(Equal(map[string]string{"control-plane": "controller-manager"}))
+	})
+
+	It("rejects unknown config versions", func() {
+		_, err := config.Parse([]byte("apiVersion: config.enflame.cn/v2\nkind: OperatorConfig\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("prefers the spec image and rewrites it through the mirror", func() {
+		cfg := config.Default()
+		cfg.Registry.Mirrors = []config.RegistryMirror{{Prefix: "docker.io/", Replacement: "mirror.local/docker.io/"}}
+
+		Expect(cfg.Image(config.ComponentWebapp, "")).To(Equal("mirror.local/docker.io/library/nginx"))
+		Expect(cfg.Image(config.ComponentWebapp, "bitnami/nginx:1.25")).To(Equal("mirror.local/docker.io/bitnami/nginx:1.25"))
+		Expect(cfg.Image(config.ComponentWebapp, "quay.io/org/app")).To(Equal("quay.io/org/app"))
+		Expect(cfg.Image(config.ComponentWebapp, "localhost:5000/app")).To(Equal("localhost:5000/app"))
+	})
+
+	It("only uses default resources when the spec has none", func() {
+		cfg := config.Default()
+		cfg.Resources = map[string]corev1.ResourceRequirements{
+			config.ComponentMysql: {Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}},
+		}
+		override := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}}
+
+		Expect(cfg.ResourcesFor(config.ComponentMysql, corev1.ResourceRequirements{}).Requests).To(HaveKey(corev1.ResourceMemory))
+		Expect(cfg.ResourcesFor(config.ComponentMysql, override)).To(Equal(override))
+	})
+
+	It("lets object labels win over the default labels", func() {
+		cfg := config.Default()
+		cfg.Labels = map[string]string{"team": "modelops", "tier": "default"}
+
+		Expect(cfg.WithLabels(map[string]string{"tier": "mysql"})).To(Equal(map[string]string{"team": "modelops", "tier": "mysql"}))
+	})
+})
+
+var _ = Describe("Store", func() {
+	It("reloads the file and notifies listeners", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		write := func(image string) {
+			data := "apiVersion: config.enflame.cn/v1alpha1\nkind: OperatorConfig\nimages:\n  webapp: " + image + "\n"
+			Expect(os.WriteFile(path, []byte(data), 0o644)).To(Succeed())
+		}
+		write("nginx:1.24")
+
+		store, err := config.NewStore(path, zap.New(zap.WriteTo(GinkgoWriter)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Get().Image(config.ComponentWebapp, "")).To(Equal("nginx:1.24"))
+
+		changed := make(chan string, 1)
+		store.OnChange(func(_ context.Context, cfg *config.OperatorConfig) {
+			select {
+			case changed <- cfg.Images[config.ComponentWebapp]:
+			default:
+			}
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			defer GinkgoRecover()
+			Expect(store.Start(ctx)).To(Succeed())
+		}()
+
+		Eventually(func() string {
+			write("nginx:1.25")
+			return store.Get().Image(config.ComponentWebapp, "")
+		}, 5*time.Second, 100*time.Millisecond).Should(Equal("nginx:1.25"))
+		Eventually(changed).Should(Receive(Equal("nginx:1.25")))
+	})
+
+	It("returns the defaults when nil", func() {
+		var store *config.Store
+		Expect(store.Get().Mysql.Database).To(Equal("webservice"))
+	})
+})
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// Store holds the current OperatorConfig and reloads it when the file
+// changes on disk. A ConfigMap mounted as a volume is swapped through the
+// "..data" symlink, so the whole directory is watched instead of the file.
+type Store struct {
+	path string
+	log  logr.Logger
+
+	mu        sync.RWMutex
+	current   *OperatorConfig
+	listeners []func(context.Context, *OperatorConfig)
+}
+
+// NewStore loads the config at path. An empty path means defaults only.
+func NewStore(path string, log logr.Logger) (*Store, error) {
+	s := &Store{path: path, log: log, current: Default()}
+	if path == "" {
+		return s, nil
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	s.current = cfg
+	return s, nil
+}
+
+// Get returns the current config. A nil Store returns the defaults.
+func (s *Store) Get() *OperatorConfig {
+	if s == nil {
+		return Default()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// OnChange registers fn to be called after every successful reload.
+func (s *Store) OnChange(fn func(context.Context, *OperatorConfig)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Start implements manager.Runnable and watches the config file until ctx
+// is done.
+func (s *Store) Start(ctx context.Context) error {
+	if s.path == "" {
+		<-ctx.Done()
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			s.log.Error(err, "Config watch error", "path", s.path)
+		case <-watcher.Events:
+			s.reload(ctx)
+		}
+	}
+}
+
+func (s *Store) reload(ctx context.Context) {
+	cfg, err := Load(s.path)
+	if err != nil {
+		// Keep running with the last good config.
+		s.log.Error(err, "Failed to reload operator config", "path", s.path)
+		return
+	}
+
+	s.mu.Lock()
+	if reflect.DeepEqual(cfg, s.current) {
+		s.mu.Unlock()
+		return
+	}
+	s.current = cfg
+	listeners := append([]func(context.Context, *OperatorConfig){}, s.listeners...)
+	s.mu.Unlock()
+
+	s.log.Info("Operator config reloaded", "path", s.path)
+	for _, fn := range listeners {
+		fn(ctx, cfg)
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// adoptLabel marks an existing object as one the WebService named by its
+// value may adopt under the IfLabeled policy.
+const adoptLabel = "app.enflame.cn/adopt"
+
+const (
+	reasonAdopted         = "Adopted"
+	reasonAdoptionRefused = "AdoptionRefused"
+)
+
+// adoptionConflict is returned when desired cannot be applied because an
+// object of the same name belongs to someone else.
+type adoptionConflict struct {
+	kind, name, why string
+}
+
+func (e *adoptionConflict) Error() string {
+	return fmt.Sprintf("%s %s already exists and %s", e.kind, e.name, e.why)
+}
+
+// adopt makes sure desired can be applied for app. An object of the same
+// name that app does not control yet is adopted when spec.adoptionPolicy
+// allows it: it gets the controller reference and the labels of desired,
+// the apply that follows brings the rest of it to the desired state.
+// Otherwise the Conflict condition is set and an adoptionConflict
+// returned. Children without a controller reference are not checked.
+func (r *WebServiceReconciler) adopt(ctx context.Context, app *appv1.WebService, desired client.Object) error {
+	owner := metav1.GetControllerOf(desired)
+	if owner == nil {
+		return nil
+	}
+	gvk, err := apiutil.GVKForObject(desired, r.Scheme)
+	if err != nil {
+		return err
+	}
+	obj, err := r.Scheme.New(gvk)
+	if err != nil {
+		return err
+	}
+	existing := obj.(client.Object)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	current := metav1.GetControllerOf(existing)
+	if current != nil && current.UID == owner.UID {
+		return nil
+	}
+	var why string
+	switch {
+	case current != nil:
+		why = fmt.Sprintf("is controlled by %s %s", current.Kind, current.Name)
+	case app.Spec.AdoptionPolicy == appv1.AdoptionPolicyAlways:
+	case app.Spec.AdoptionPolicy == appv1.AdoptionPolicyIfLabeled && existing.GetLabels()[adoptLabel] == app.Name:
+	case app.Spec.AdoptionPolicy == appv1.AdoptionPolicyIfLabeled:
+		why = fmt.Sprintf("is not labelled %s=%s", adoptLabel, app.Name)
+	default:
+		why = fmt.Sprintf("adoptionPolicy is %s", appv1.AdoptionPolicyNever)
+	}
+	if why != "" {
+		conflict := &adoptionConflict{kind: gvk.Kind, name: existing.GetName(), why: why}
+		r.Recorder.Event(app, corev1.EventTypeWarning, reasonAdoptionRefused, conflict.Error())
+		return r.setConflict(ctx, app, conflict)
+	}
+
+	patch := client.MergeFrom(existing.DeepCopyObject().(client.Object))
+	existing.SetOwnerReferences(append(existing.GetOwnerReferences(), *owner))
+	labels := existing.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range desired.GetLabels() {
+		labels[k] = v
+	}
+	existing.SetLabels(labels)
+	if err := r.Patch(ctx, existing, patch); err != nil {
+		return err
+	}
+	r.Log.Info("Adopted existing object", "webservice", client.ObjectKeyFromObject(app), "kind", gvk.Kind, "name", existing.GetName())
+	r.Recorder.Eventf(app, corev1.EventTypeNormal, reasonAdopted, "Adopted %s %s", gvk.Kind, existing.GetName())
+	return nil
+}
+
+// setConflict reports conflict on app and returns it, the reconcile stops
+// and is retried with backoff until the object is removed, labelled or the
+// policy changed.
+func (r *WebServiceReconciler) setConflict(ctx context.Context, app *appv1.WebService, conflict *adoptionConflict) error {
+	patch := client.MergeFrom(app.DeepCopy())
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               appv1.ConditionConflict,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonAdoptionRefused,
+		Message:            conflict.Error(),
+		ObservedGeneration: app.Generation,
+	})
+	if err := r.Status().Patch(ctx, app, patch); err != nil {
+		return err
+	}
+	return conflict
+}
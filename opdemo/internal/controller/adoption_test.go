@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+func TestAdopt(t *testing.T) {
+	controller := true
+	foreign := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "other", UID: "other-uid", Controller: &controller}
+	for name, tc := range map[string]struct {
+		policy   appv1.AdoptionPolicy
+		label    string
+		owner    *metav1.OwnerReference
+		conflict string
+	}{
+		"never":               {conflict: "Deployment shop-web already exists and adoptionPolicy is Never"},
+		"labelled":            {policy: appv1.AdoptionPolicyIfLabeled, label: "shop"},
+		"labelled for others": {policy: appv1.AdoptionPolicyIfLabeled, label: "blog", conflict: "is not labelled app.enflame.cn/adopt=shop"},
+		"always":              {policy: appv1.AdoptionPolicyAlways},
+		"other controller":    {policy: appv1.AdoptionPolicyAlways, owner: &foreign, conflict: "is controlled by ReplicaSet other"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			app := testWebService("shop")
+			app.Spec.AdoptionPolicy = tc.policy
+			desired := NewDeploy(app, config.Default(), primaryComponent(app))
+			existing := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Name: desired.Name, Namespace: app.Namespace, Labels: map[string]string{"team": "shop"},
+			}}
+			if tc.label != "" {
+				existing.Labels[adoptLabel] = tc.label
+			}
+			if tc.owner != nil {
+				existing.OwnerReferences = []metav1.OwnerReference{*tc.owner}
+			}
+			r := newFakeReconciler(t, app, existing)
+			events := r.Recorder.(*record.FakeRecorder).Events
+
+			err := r.adopt(ctx, app, desired)
+			stored := &appsv1.Deployment{}
+			g.Expect(r.Get(ctx, key(app, desired.Name), stored)).To(Succeed())
+			if tc.conflict != "" {
+				g.Expect(err).To(BeAssignableToTypeOf(&adoptionConflict{}))
+				g.Expect(err).To(MatchError(ContainSubstring(tc.conflict)))
+				g.Expect(metav1.IsControlledBy(stored, app)).To(BeFalse())
+				g.Expect(r.Get(ctx, key(app, app.Name), app)).To(Succeed())
+				g.Expect(meta.FindStatusCondition(app.Status.Conditions, appv1.ConditionConflict)).To(And(
+					HaveField("Status", metav1.ConditionTrue),
+					HaveField("Reason", reasonAdoptionRefused),
+				))
+				g.Expect(events).To(Receive(HavePrefix("Warning " + reasonAdoptionRefused)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(metav1.IsControlledBy(stored, app)).To(BeTrue())
+			g.Expect(stored.Labels).To(HaveKeyWithValue("team", "shop"), "labels of the existing object are kept")
+			for k, v := range desired.Labels {
+				g.Expect(stored.Labels).To(HaveKeyWithValue(k, v))
+			}
+			g.Expect(events).To(Receive(HavePrefix("Normal " + reasonAdopted)))
+		})
+	}
+}
+
+func TestAdoptLeavesOwnAndMissingObjects(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	desired := NewDeploy(app, config.Default(), primaryComponent(app))
+	r := newFakeReconciler(t, app)
+
+	g.Expect(r.adopt(ctx, app, desired)).To(Succeed(), "nothing to adopt")
+
+	g.Expect(r.Create(ctx, desired.DeepCopy())).To(Succeed())
+	g.Expect(r.adopt(ctx, app, desired)).To(Succeed(), "already controlled by app")
+
+	// A child without controller reference, like a retained Secret, is not
+	// checked.
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "shop-retained", Namespace: app.Namespace}}
+	g.Expect(r.Create(ctx, secret.DeepCopy())).To(Succeed())
+	g.Expect(r.adopt(ctx, app, secret)).To(Succeed())
+	g.Expect(r.Recorder.(*record.FakeRecorder).Events).To(BeEmpty())
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// fieldOwner is the field manager of every child the operator applies.
+const fieldOwner = client.FieldOwner("opdemo")
+
+// legacySpecAnnotation is where older releases kept a copy of the last
+// reconciled spec.
+const legacySpecAnnotation = "spec"
+
+// legacyFieldManagers are the managers older releases wrote children with.
+// controller-runtime derives the name from the binary when no owner is set.
+var legacyFieldManagers = sets.New("manager")
+
+// apply server-side applies obj with ForceOwnership, so fields changed by
+// hand are reset on every reconcile. apiVersion and kind are required in an
+// apply patch and are filled in from the scheme.
+func (r *WebServiceReconciler) apply(ctx context.Context, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	return r.Patch(ctx, obj, client.Apply, fieldOwner, client.ForceOwnership)
+}
+
+// migrateLegacySpec moves a WebService reconciled by an older release over
+// to server-side apply. The children get their client-side managed fields
+// merged into fieldOwner, otherwise fields dropped from the builders would
+// never be pruned, then the "spec" annotation is removed.
+func (r *WebServiceReconciler) migrateLegacySpec(ctx context.Context, app *appv1.WebService) error {
+	if _, ok := app.Annotations[legacySpecAnnotation]; !ok {
+		return nil
+	}
+
+	// Older releases only created the children of the parts in the spec.
+	var children []client.Object
+	if db := app.Spec.DatabaseSpec(); db != nil {
+		children = append(children,
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name + "-mysql", Namespace: app.Namespace}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: db.Name, Namespace: app.Namespace}})
+	}
+	if app.Spec.Webapp != nil {
+		children = append(children,
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: app.Spec.Webapp.Name, Namespace: app.Namespace}})
+	}
+	for _, obj := range children {
+		if err := r.upgradeManagedFields(ctx, obj); err != nil {
+			return err
+		}
+	}
+
+	patch := client.MergeFrom(app.DeepCopy())
+	delete(app.Annotations, legacySpecAnnotation)
+	return r.Patch(ctx, app, patch)
+}
+
+func (r *WebServiceReconciler) upgradeManagedFields(ctx context.Context, obj client.Object) error {
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(obj, legacyFieldManagers, string(fieldOwner))
+	if err != nil || patch == nil {
+		return err
+	}
+	return r.Patch(ctx, obj, client.RawPatch(types.JSONPatchType, patch))
+}
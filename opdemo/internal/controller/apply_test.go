@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+func TestApply(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	r := newFakeReconciler(t)
+	var patches []client.PatchOptions
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			g.Expect(patch.Type()).To(Equal(types.ApplyPatchType))
+			po := client.PatchOptions{}
+			po.ApplyOptions(opts)
+			patches = append(patches, po)
+			return applyAsUpdate(ctx, c, obj, patch, opts...)
+		},
+	})
+
+	// A child read back from the cluster carries a resourceVersion and
+	// managed fields, neither belongs in an apply patch.
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: "default", ResourceVersion: "42",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}}},
+		Data: map[string]string{"mode": "a"},
+	}
+	g.Expect(r.apply(ctx, cm)).To(Succeed())
+	g.Expect(cm.GroupVersionKind()).To(Equal(corev1.SchemeGroupVersion.WithKind("ConfigMap")))
+
+	// Applying again replaces a value changed by hand.
+	stored := &corev1.ConfigMap{}
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(cm), stored)).To(Succeed())
+	stored.Data["mode"] = "edited"
+	g.Expect(r.Update(ctx, stored)).To(Succeed())
+	g.Expect(r.apply(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: "default"},
+		Data:       map[string]string{"mode": "a"},
+	})).To(Succeed())
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(cm), stored)).To(Succeed())
+	g.Expect(stored.Data).To(HaveKeyWithValue("mode", "a"))
+
+	g.Expect(patches).To(HaveLen(2))
+	for _, po := range patches {
+		g.Expect(po.FieldManager).To(Equal(string(fieldOwner)))
+		g.Expect(po.Force).To(HaveValue(BeTrue()))
+	}
+}
+
+func TestMigrateLegacySpec(t *testing.T) {
+	for name, tc := range map[string]struct {
+		mutate   func(app *appv1.WebService)
+		services []string
+	}{
+		"webapp and database": {mutate: func(*appv1.WebService) {}, services: []string{"mysql", "web"}},
+		"no database": {
+			mutate:   func(app *appv1.WebService) { app.Spec.Database, app.Spec.Mysql = nil, nil },
+			services: []string{"web"},
+		},
+		"no webapp": {
+			mutate:   func(app *appv1.WebService) { app.Spec.Webapp = nil },
+			services: []string{"mysql"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			app := testWebService("shop")
+			app.Annotations = map[string]string{legacySpecAnnotation: "{}"}
+			tc.mutate(app)
+			r := newFakeReconciler(t, app)
+			// Children as written by an older release, with client-side
+			// managed fields.
+			for _, svcName := range tc.services {
+				g.Expect(r.Create(ctx, &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+					Name:      svcName,
+					Namespace: app.Namespace,
+					ManagedFields: []metav1.ManagedFieldsEntry{{
+						Manager:    "manager",
+						Operation:  metav1.ManagedFieldsOperationUpdate,
+						APIVersion: "v1",
+						FieldsType: "FieldsV1",
+						FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:type":{}}}`)},
+					}},
+				}})).To(Succeed())
+			}
+
+			g.Expect(r.migrateLegacySpec(ctx, app)).To(Succeed())
+
+			g.Expect(r.Get(ctx, key(app, app.Name), app)).To(Succeed())
+			g.Expect(app.Annotations).NotTo(HaveKey(legacySpecAnnotation))
+			for _, svcName := range tc.services {
+				svc := &corev1.Service{}
+				g.Expect(r.Get(ctx, key(app, svcName), svc)).To(Succeed())
+				g.Expect(svc.ManagedFields).To(ConsistOf(And(
+					HaveField("Manager", string(fieldOwner)),
+					HaveField("Operation", metav1.ManagedFieldsOperationApply),
+				)), "Service %s", svcName)
+			}
+		})
+	}
+}
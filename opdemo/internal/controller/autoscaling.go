@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const defaultTargetCPUUtilization = 80
+
+// NewHPA scales the Deployment of component c within its autoscaling.
+func NewHPA(app *appv1.WebService, cfg *config.OperatorConfig, c component) *autoscalingv2.HorizontalPodAutoscaler {
+	spec := c.Autoscaling
+	metrics := []autoscalingv2.MetricSpec{}
+	resourceMetric := func(name corev1.ResourceName, utilization *int32) autoscalingv2.MetricSpec {
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name: name,
+				Target: autoscalingv2.MetricTarget{
+					Type:               autoscalingv2.UtilizationMetricType,
+					AverageUtilization: utilization,
+				},
+			},
+		}
+	}
+	if spec.TargetCPUUtilizationPercentage != nil {
+		metrics = append(metrics, resourceMetric(corev1.ResourceCPU, spec.TargetCPUUtilizationPercentage))
+	}
+	if spec.TargetMemoryUtilizationPercentage != nil {
+		metrics = append(metrics, resourceMetric(corev1.ResourceMemory, spec.TargetMemoryUtilizationPercentage))
+	}
+	metrics = append(metrics, spec.Metrics...)
+	if len(metrics) == 0 {
+		cpu := int32(defaultTargetCPUUtilization)
+		metrics = append(metrics, resourceMetric(corev1.ResourceCPU, &cpu))
+	}
+
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "HorizontalPodAutoscaler",
+			APIVersion: "autoscaling/v2",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        c.deployment,
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(c.objectLabels(app)),
+			Annotations: cfg.WithAnnotations(nil),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, schema.GroupVersionKind{
+					Group:   appv1.GroupVersion.Group,
+					Version: appv1.GroupVersion.Version,
+					Kind:    "WebService",
+				}),
+			},
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       c.deployment,
+			},
+			MinReplicas: spec.MinReplicas,
+			MaxReplicas: spec.MaxReplicas,
+			Metrics:     metrics,
+			Behavior:    spec.Behavior,
+		},
+	}
+}
+
+// reconcileAutoscaling applies the HPA of component c, or deletes it once
+// autoscaling is turned off.
+func (r *WebServiceReconciler) reconcileAutoscaling(ctx context.Context, app *appv1.WebService, c component) error {
+	if c.Autoscaling == nil {
+		key := types.NamespacedName{Name: c.deployment, Namespace: app.Namespace}
+		return r.deleteOwned(ctx, app, key, &autoscalingv2.HorizontalPodAutoscaler{})
+	}
+	return r.apply(ctx, NewHPA(app, r.Config.Get(), c))
+}
+
+// keepAutoscaledReplicas carries the replica count the HPA chose over into
+// deploy, so that applying it does not scale the component back to Size.
+// Leaving the field out would not do: server-side apply resets a field
+// its last owner stops setting. A new Deployment starts at minReplicas.
+func (r *WebServiceReconciler) keepAutoscaledReplicas(ctx context.Context, c component, deploy *appsv1.Deployment) error {
+	autoscaling := c.Autoscaling
+	if autoscaling == nil {
+		return nil
+	}
+	current := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: deploy.Name, Namespace: deploy.Namespace}, current)
+	switch {
+	case errors.IsNotFound(err):
+		deploy.Spec.Replicas = autoscaling.MinReplicas
+	case err != nil:
+		return err
+	default:
+		deploy.Spec.Replicas = current.Spec.Replicas
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func TestNewHPA(t *testing.T) {
+	utilization := func(name corev1.ResourceName, percent int32) autoscalingv2.MetricSpec {
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name:   name,
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &percent},
+			},
+		}
+	}
+	custom := autoscalingv2.MetricSpec{
+		Type: autoscalingv2.PodsMetricSourceType,
+		Pods: &autoscalingv2.PodsMetricSource{
+			Metric: autoscalingv2.MetricIdentifier{Name: "requests_per_second"},
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType},
+		},
+	}
+	for name, tc := range map[string]struct {
+		autoscaling appv1.AutoscalingSpec
+		metrics     []autoscalingv2.MetricSpec
+	}{
+		"no target": {
+			autoscaling: appv1.AutoscalingSpec{MaxReplicas: 5},
+			metrics:     []autoscalingv2.MetricSpec{utilization(corev1.ResourceCPU, defaultTargetCPUUtilization)},
+		},
+		"cpu and memory": {
+			autoscaling: appv1.AutoscalingSpec{MaxReplicas: 5, TargetCPUUtilizationPercentage: int32Ptr(60), TargetMemoryUtilizationPercentage: int32Ptr(70)},
+			metrics:     []autoscalingv2.MetricSpec{utilization(corev1.ResourceCPU, 60), utilization(corev1.ResourceMemory, 70)},
+		},
+		"custom metric only": {
+			autoscaling: appv1.AutoscalingSpec{MaxReplicas: 5, Metrics: []autoscalingv2.MetricSpec{custom}},
+			metrics:     []autoscalingv2.MetricSpec{custom},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			tc.autoscaling.MinReplicas = int32Ptr(2)
+			app.Spec.Webapp.Autoscaling = &tc.autoscaling
+			c := webServiceComponents(app)[0]
+
+			hpa := NewHPA(app, config.Default(), c)
+			g.Expect(hpa.Name).To(Equal(c.deployment))
+			g.Expect(metav1.IsControlledBy(hpa, app)).To(BeTrue())
+			g.Expect(hpa.Spec.ScaleTargetRef).To(Equal(autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1", Kind: "Deployment", Name: c.deployment,
+			}))
+			g.Expect(hpa.Spec.MinReplicas).To(HaveValue(Equal(int32(2))))
+			g.Expect(hpa.Spec.MaxReplicas).To(Equal(int32(5)))
+			g.Expect(hpa.Spec.Metrics).To(Equal(tc.metrics))
+		})
+	}
+}
+
+func TestReconcileAutoscaling(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	app.Spec.Webapp.Autoscaling = &appv1.AutoscalingSpec{MaxReplicas: 3}
+	r := newFakeReconciler(t, app)
+	c := webServiceComponents(app)[0]
+
+	g.Expect(r.reconcileAutoscaling(ctx, app, c)).To(Succeed())
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	g.Expect(r.Get(ctx, key(app, c.deployment), hpa)).To(Succeed())
+	g.Expect(hpa.Spec.MaxReplicas).To(Equal(int32(3)))
+
+	// Turning autoscaling off deletes the HPA.
+	c.Autoscaling = nil
+	g.Expect(r.reconcileAutoscaling(ctx, app, c)).To(Succeed())
+	g.Expect(errors.IsNotFound(r.Get(ctx, key(app, c.deployment), hpa))).To(BeTrue())
+}
+
+func TestKeepAutoscaledReplicas(t *testing.T) {
+	ctx := context.Background()
+	autoscaling := &appv1.AutoscalingSpec{MinReplicas: int32Ptr(2), MaxReplicas: 10}
+	for name, tc := range map[string]struct {
+		autoscaling *appv1.AutoscalingSpec
+		current     *int32
+		replicas    int32
+	}{
+		"not autoscaled":    {current: int32Ptr(7), replicas: 1},
+		"new deployment":    {autoscaling: autoscaling, replicas: 2},
+		"scaled by the HPA": {autoscaling: autoscaling, current: int32Ptr(7), replicas: 7},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.Webapp.Autoscaling = tc.autoscaling
+			c := webServiceComponents(app)[0]
+			var objs []client.Object
+			if tc.current != nil {
+				objs = append(objs, &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: c.deployment, Namespace: app.Namespace},
+					Spec:       appsv1.DeploymentSpec{Replicas: tc.current},
+				})
+			}
+			r := newFakeReconciler(t, objs...)
+
+			deploy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: c.deployment, Namespace: app.Namespace},
+				Spec:       appsv1.DeploymentSpec{Replicas: app.Spec.Webapp.Size},
+			}
+			g.Expect(r.keepAutoscaledReplicas(ctx, c, deploy)).To(Succeed())
+			g.Expect(deploy.Spec.Replicas).To(HaveValue(Equal(tc.replicas)))
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/objectstore"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// A backup is taken by a Job. The dump container writes the file to the
+// backup PVC, or to an emptyDir that a second container uploads to S3.
+// It reports size, checksum and binary log coordinates of the file as a
+// JSON termination message, which the operator copies into the status.
+
+const (
+	// webServiceLabel names the WebService of a backup. Backups are not
+	// owned by the WebService, the label is how it finds them.
+	webServiceLabel = "app.enflame.cn/webservice"
+	// scheduledBackupLabel marks the backups taken for
+	// spec.database.backup.schedule, only those are pruned by retention.
+	scheduledBackupLabel = "app.enflame.cn/scheduled"
+)
+
+const (
+	// backupContainer takes the dump, as init container when the file is
+	// uploaded to S3 afterwards.
+	backupContainer = "backup"
+	// transferContainer uploads or downloads the file with the aws CLI.
+	transferContainer = "transfer"
+	// scratchVolume holds the file while it is in transit to S3.
+	scratchVolume = "dump"
+	// mysqlUID owns the data directory in the official MySQL image.
+	mysqlUID = 999
+)
+
+const defaultBackupStorage = "5Gi"
+
+// resultScript reports $BACKUP_FILE as termination message. $COORDS holds
+// the binary log file and position, if known.
+const resultScript = `
+set -- $COORDS
+SIZE=$(wc -c < "$BACKUP_FILE" | tr -d ' ')
+SUM=$(sha256sum "$BACKUP_FILE" | cut -d ' ' -f 1)
+printf '{"sizeBytes":%s,"checksum":"sha256:%s","binlogFile":"%s","binlogPosition":%s}' "$SIZE" "$SUM" "$1" "${2:-0}" > /dev/termination-log`
+
+// mysqldumpScript writes a gzipped dump. --master-data=2 adds the binary
+// log coordinates as a comment, 8.0.26 and later spell it CHANGE
+// REPLICATION SOURCE TO. GTIDs are left out so the dump loads into any
+// server, including a clone that has its own GTID history.
+const mysqldumpScript = `set -e
+mkdir -p "$(dirname "$BACKUP_FILE")"
+mysqldump -h "$DB_HOST" -P "$DB_PORT" -uroot --all-databases --single-transaction --triggers --routines --events --flush-privileges --set-gtid-purged=OFF --master-data=2 > "${BACKUP_FILE%.gz}"
+gzip -f "${BACKUP_FILE%.gz}"
+COORDS=$(zcat "$BACKUP_FILE" | head -n 100 | sed -n "s/^-- CHANGE [A-Z ]* TO [A-Z]*_LOG_FILE='\([^']*\)', [A-Z]*_LOG_POS=\([0-9]*\);.*/\1 \2/p")` + resultScript
+
+// xtrabackupScript copies the data directory of the primary next to the
+// final file and packs it.
+const xtrabackupScript = `set -e
+DIR="${BACKUP_FILE%.tar.gz}.d"
+mkdir -p "$DIR"
+xtrabackup --backup --host="$DB_HOST" --port="$DB_PORT" --user=root --password="$MYSQL_PWD" --datadir=` + mysqlDataPath + ` --target-dir="$DIR"
+COORDS=$(cut -f 1,2 "$DIR/xtrabackup_binlog_info")
+tar czf "$BACKUP_FILE" -C "$DIR" .
+rm -rf "$DIR"` + resultScript
+
+const uploadScript = `aws --endpoint-url "$S3_ENDPOINT" s3 cp "$BACKUP_FILE" "$S3_URL"`
+
+const downloadScript = `mkdir -p "$(dirname "$BACKUP_FILE")" && aws --endpoint-url "$S3_ENDPOINT" s3 cp "$S3_URL" "$BACKUP_FILE"`
+
+// backupResult is the termination message of backupContainer.
+type backupResult struct {
+	SizeBytes      int64  `json:"sizeBytes"`
+	Checksum       string `json:"checksum"`
+	BinlogFile     string `json:"binlogFile"`
+	BinlogPosition int64  `json:"binlogPosition"`
+}
+
+func backupClaimName(backup *appv1.WebServiceBackup) string {
+	if pvc := backup.Spec.Storage.PVC; pvc != nil && pvc.ClaimName != "" {
+		return pvc.ClaimName
+	}
+	return backup.Spec.WebServiceName + "-backups"
+}
+
+// backupPath is the file of a backup, relative to the PVC root or as
+// object key.
+func backupPath(backup *appv1.WebServiceBackup) string {
+	ext := ".sql.gz"
+	if backup.Spec.Method == appv1.BackupMethodXtrabackup {
+		ext = ".tar.gz"
+	}
+	prefix := ""
+	if s3 := backup.Spec.Storage.S3; s3 != nil {
+		prefix = s3.Prefix
+	}
+	return path.Join(prefix, backup.Spec.WebServiceName, backup.Name+ext)
+}
+
+func backupLocation(backup *appv1.WebServiceBackup) string {
+	if s3 := backup.Spec.Storage.S3; s3 != nil {
+		return fmt.Sprintf("s3://%s/%s", s3.Bucket, backup.Status.Path)
+	}
+	return fmt.Sprintf("pvc://%s/%s", backupClaimName(backup), backup.Status.Path)
+}
+
+func backupCredentialsName(backup *appv1.WebServiceBackup) string {
+	return backup.Name + "-credentials"
+}
+
+// backupPVC is the PVC backups are written to. Like the dump PVCs it has no
+// owner.
+func backupPVC(cfg *config.OperatorConfig, app *appv1.WebService, backup *appv1.WebServiceBackup) *corev1.PersistentVolumeClaim {
+	size := resource.MustParse(defaultBackupStorage)
+	if pvc := backup.Spec.Storage.PVC; pvc != nil && !pvc.Size.IsZero() {
+		size = pvc.Size
+	}
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        backupClaimName(backup),
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(labels(app, "backup")),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+}
+
+// newBackupJob builds the Job that takes backup of app. The caller sets the
+// owner.
+func newBackupJob(cfg *config.OperatorConfig, app *appv1.WebService, backup *appv1.WebServiceBackup) *batchv1.Job {
+	storage := backup.Spec.Storage
+	claim := backupClaimName(backup)
+	if storage.S3 != nil {
+		claim = ""
+	}
+	file := path.Join(dumpMountPath, backup.Status.Path)
+
+	script := mysqldumpScript
+	if backup.Spec.Method == appv1.BackupMethodXtrabackup {
+		script = xtrabackupScript
+	}
+	job := newMysqlClientJob(cfg, app, backup.Name, "backup", claim, script,
+		corev1.EnvVar{Name: "BACKUP_FILE", Value: file})
+	job.Labels[webServiceLabel] = app.Name
+	pod := &job.Spec.Template.Spec
+	dump := &pod.Containers[0]
+	dump.Name = backupContainer
+	dump.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+
+	if backup.Spec.Method == appv1.BackupMethodXtrabackup {
+		// xtrabackup reads the data directory of the primary, the Job runs
+		// on its node and mounts its volume.
+		primary := databasePodName(app, 0)
+		dump.Image = cfg.Image(config.ComponentXtrabackup, "")
+		dump.SecurityContext = rootSecurityContext()
+		setEnv(dump, "DB_HOST", mysqlPodHost(app, 0))
+		setEnv(dump, "DB_PORT", fmt.Sprint(databaseContainerPort(app)))
+		dump.VolumeMounts = append(dump.VolumeMounts, corev1.VolumeMount{Name: databaseDataVolume, MountPath: mysqlDataPath, ReadOnly: true})
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: databaseDataVolume,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: databaseDataVolume + "-" + primary,
+					ReadOnly:  true,
+				},
+			},
+		})
+		pod.Affinity = &corev1.Affinity{
+			PodAffinity: &corev1.PodAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{appsv1.StatefulSetPodNameLabel: primary}},
+					TopologyKey:   corev1.LabelHostname,
+				}},
+			},
+		}
+	}
+
+	if storage.S3 != nil {
+		// The dump goes to scratch space first, then it is uploaded.
+		addScratchVolume(pod, dump)
+		pod.InitContainers = []corev1.Container{*dump}
+		pod.Containers = []corev1.Container{transferContainerFor(cfg, storage.S3, backup.Status.Path, file, uploadScript)}
+	}
+	return job
+}
+
+// rootSecurityContext lets xtrabackup read and write data directories
+// owned by the mysql user. It overrides the non-root pod defaults, so the
+// Job is refused in namespaces enforcing the restricted level.
+func rootSecurityContext() *corev1.SecurityContext {
+	root := int64(0)
+	runAsNonRoot := false
+	return &corev1.SecurityContext{RunAsUser: &root, RunAsNonRoot: &runAsNonRoot}
+}
+
+// setEnv replaces the value of the variable name in c.
+func setEnv(c *corev1.Container, name, value string) {
+	setEnvVar(c, corev1.EnvVar{Name: name, Value: value})
+}
+
+// setEnvVar replaces the variable of the same name in c with v.
+func setEnvVar(c *corev1.Container, v corev1.EnvVar) {
+	for i := range c.Env {
+		if c.Env[i].Name == v.Name {
+			c.Env[i] = v
+			return
+		}
+	}
+	c.Env = append(c.Env, v)
+}
+
+// addScratchVolume mounts an emptyDir at dumpMountPath into c.
+func addScratchVolume(pod *corev1.PodSpec, c *corev1.Container) {
+	pod.Volumes = append(pod.Volumes, corev1.Volume{
+		Name:         scratchVolume,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+	c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: scratchVolume, MountPath: dumpMountPath})
+}
+
+// transferContainerFor runs script with the aws CLI against the object
+// key of s3. The file is $BACKUP_FILE on the scratch volume.
+func transferContainerFor(cfg *config.OperatorConfig, s3 *appv1.S3BackupStorage, key, file, script string) corev1.Container {
+	secretKey := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: s3.CredentialsSecretRef,
+			Key:                  key,
+		}}
+	}
+	return corev1.Container{
+		Name:    transferContainer,
+		Image:   cfg.Image(config.ComponentS3Client, ""),
+		Command: []string{"sh", "-c", script},
+		Env: []corev1.EnvVar{
+			{Name: "BACKUP_FILE", Value: file},
+			{Name: "S3_ENDPOINT", Value: s3.Endpoint},
+			{Name: "S3_URL", Value: fmt.Sprintf("s3://%s/%s", s3.Bucket, key)},
+			{Name: "AWS_DEFAULT_REGION", Value: s3Region(s3)},
+			{Name: "AWS_ACCESS_KEY_ID", ValueFrom: secretKey(appv1.S3AccessKeyIDKey)},
+			{Name: "AWS_SECRET_ACCESS_KEY", ValueFrom: secretKey(appv1.S3SecretAccessKeyKey)},
+		},
+		VolumeMounts:    []corev1.VolumeMount{{Name: scratchVolume, MountPath: dumpMountPath}},
+		SecurityContext: restrictedSecurityContext(),
+	}
+}
+
+func s3Region(s3 *appv1.S3BackupStorage) string {
+	if s3.Region != "" {
+		return s3.Region
+	}
+	return "us-east-1"
+}
+
+// readBackupResult reads the termination message of the succeeded pod of
+// job.
+func readBackupResult(ctx context.Context, c client.Client, job *batchv1.Job) (*backupResult, error) {
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			if cs.Name != backupContainer || cs.State.Terminated == nil {
+				continue
+			}
+			result := &backupResult{}
+			if err := json.Unmarshal([]byte(cs.State.Terminated.Message), result); err != nil {
+				return nil, fmt.Errorf("reading the result of pod %s: %w", pod.Name, err)
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("no succeeded pod of Job %s has a backup result", job.Name)
+}
+
+// openBackupStore connects to the bucket of s3 with the credentials from
+// its Secret.
+func openBackupStore(ctx context.Context, c client.Client, open objectstore.OpenFunc, namespace string, s3 *appv1.S3BackupStorage) (objectstore.Store, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: s3.CredentialsSecretRef.Name, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+	if open == nil {
+		open = objectstore.Open
+	}
+	return open(objectstore.Config{
+		Endpoint:        s3.Endpoint,
+		Region:          s3Region(s3),
+		AccessKeyID:     string(secret.Data[appv1.S3AccessKeyIDKey]),
+		SecretAccessKey: string(secret.Data[appv1.S3SecretAccessKeyKey]),
+	})
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultBackupRetention = 7
+	// maxBackupHistory is the number of backups listed in the status.
+	maxBackupHistory = 10
+)
+
+const (
+	reasonScheduled       = "Scheduled"
+	reasonInvalidSchedule = "InvalidSchedule"
+)
+
+// reconcileBackups takes the scheduled backup that is due, prunes scheduled
+// backups beyond the retention and lists the newest backups in the status.
+// It returns the time until the next scheduled backup, 0 without a
+// schedule.
+func (r *WebServiceReconciler) reconcileBackups(ctx context.Context, app *appv1.WebService) (time.Duration, error) {
+	patch := client.MergeFrom(app.DeepCopy())
+	var next time.Duration
+
+	var spec *appv1.MysqlBackupSpec
+	if db := app.Spec.DatabaseSpec(); db != nil {
+		spec = db.Backup
+	}
+	if spec == nil {
+		meta.RemoveStatusCondition(&app.Status.Conditions, appv1.ConditionBackupScheduled)
+	} else if schedule, err := cron.ParseStandard(spec.Schedule); err != nil {
+		meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               appv1.ConditionBackupScheduled,
+			Status:             metav1.ConditionFalse,
+			Reason:             reasonInvalidSchedule,
+			Message:            fmt.Sprintf("Cannot parse schedule %q: %v", spec.Schedule, err),
+			ObservedGeneration: app.Generation,
+		})
+	} else {
+		meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+			Type:               appv1.ConditionBackupScheduled,
+			Status:             metav1.ConditionTrue,
+			Reason:             reasonScheduled,
+			Message:            fmt.Sprintf("Backups are taken on schedule %q", spec.Schedule),
+			ObservedGeneration: app.Generation,
+		})
+		now := time.Now()
+		if due := lastScheduleTime(schedule, app, now); !due.IsZero() {
+			if err := r.createScheduledBackup(ctx, app, due); err != nil {
+				return 0, err
+			}
+			app.Status.LastBackupScheduleTime = &metav1.Time{Time: due}
+		}
+		if err := r.pruneBackups(ctx, app); err != nil {
+			return 0, err
+		}
+		next = schedule.Next(now).Sub(now)
+	}
+
+	history, err := r.backupHistory(ctx, app)
+	if err != nil {
+		return 0, err
+	}
+	app.Status.Backups = history
+	return next, r.Status().Patch(ctx, app, patch)
+}
+
+// lastScheduleTime returns the latest run of schedule since the last
+// scheduled backup that is due at now, or the zero time. Only one backup is
+// taken for any number of missed runs.
+func lastScheduleTime(schedule cron.Schedule, app *appv1.WebService, now time.Time) time.Time {
+	since := app.CreationTimestamp.Time
+	if last := app.Status.LastBackupScheduleTime; last != nil {
+		since = last.Time
+	}
+	var due time.Time
+	for t := schedule.Next(since); !t.After(now); t = schedule.Next(t) {
+		due = t
+	}
+	return due
+}
+
+func (r *WebServiceReconciler) createScheduledBackup(ctx context.Context, app *appv1.WebService, due time.Time) error {
+	cfg := r.Config.Get()
+	spec := app.Spec.DatabaseSpec().Backup
+	backup := &appv1.WebServiceBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      childName(app, due.UTC().Format("20060102-1504")),
+			Namespace: app.Namespace,
+			Labels: cfg.WithLabels(map[string]string{
+				webServiceLabel:      app.Name,
+				scheduledBackupLabel: "true",
+			}),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Spec: appv1.WebServiceBackupSpec{
+			WebServiceName: app.Name,
+			Method:         spec.Method,
+			Storage:        *spec.Storage.DeepCopy(),
+		},
+	}
+	r.Log.Info("Taking scheduled backup", "webservice", client.ObjectKeyFromObject(app), "backup", backup.Name)
+	if err := r.Create(ctx, backup); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// pruneBackups deletes the oldest finished scheduled backups beyond the
+// retention. Running backups are not counted out.
+func (r *WebServiceReconciler) pruneBackups(ctx context.Context, app *appv1.WebService) error {
+	retention := int(app.Spec.DatabaseSpec().Backup.Retention)
+	if retention < 1 {
+		retention = defaultBackupRetention
+	}
+	var list appv1.WebServiceBackupList
+	if err := r.List(ctx, &list, client.InNamespace(app.Namespace),
+		client.MatchingLabels{webServiceLabel: app.Name, scheduledBackupLabel: "true"}); err != nil {
+		return err
+	}
+	backups := newestFirst(list.Items)
+	for i := retention; i < len(backups); i++ {
+		backup := &backups[i]
+		if backup.DeletionTimestamp != nil {
+			continue
+		}
+		if phase := backup.Status.Phase; phase != appv1.BackupCompleted && phase != appv1.BackupFailed {
+			continue
+		}
+		r.Log.Info("Deleting backup beyond retention", "webservice", client.ObjectKeyFromObject(app), "backup", backup.Name)
+		if err := r.Delete(ctx, backup); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupHistory lists the newest backups of app, scheduled or not.
+func (r *WebServiceReconciler) backupHistory(ctx context.Context, app *appv1.WebService) ([]appv1.BackupRecord, error) {
+	var list appv1.WebServiceBackupList
+	if err := r.List(ctx, &list, client.InNamespace(app.Namespace), client.MatchingLabels{webServiceLabel: app.Name}); err != nil {
+		return nil, err
+	}
+	var history []appv1.BackupRecord
+	for _, backup := range newestFirst(list.Items) {
+		if len(history) == maxBackupHistory {
+			break
+		}
+		if backup.DeletionTimestamp != nil {
+			continue
+		}
+		history = append(history, appv1.BackupRecord{
+			Name:           backup.Name,
+			Phase:          backup.Status.Phase,
+			CompletionTime: backup.Status.CompletionTime,
+			Location:       backup.Status.Location,
+			SizeBytes:      backup.Status.SizeBytes,
+			Checksum:       backup.Status.Checksum,
+		})
+	}
+	return history, nil
+}
+
+func newestFirst(backups []appv1.WebServiceBackup) []appv1.WebServiceBackup {
+	sort.Slice(backups, func(i, j int) bool {
+		ti, tj := backups[i].CreationTimestamp, backups[j].CreationTimestamp
+		if ti.Equal(&tj) {
+			return backups[i].Name > backups[j].Name
+		}
+		return tj.Before(&ti)
+	})
+	return backups
+}
+
+// webServiceOfBackup maps a WebServiceBackup to its WebService.
+func webServiceOfBackup(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetLabels()[webServiceLabel]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: name, Namespace: obj.GetNamespace()}}}
+}
+
+// minRequeue returns the shorter non-zero duration.
+func minRequeue(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,429 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+const backupBucket = "backups"
+
+// s3Fixture is an in-process S3 server standing in for MinIO, like in the
+// objectstore specs, with a bucket and the Secret to reach it.
+type s3Fixture struct {
+	backend *s3mem.Backend
+	storage appv1.BackupStorage
+	secret  *corev1.Secret
+}
+
+func newS3Fixture(t *testing.T) *s3Fixture {
+	t.Helper()
+	backend := s3mem.New()
+	if err := backend.CreateBucket(backupBucket); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(server.Close)
+	return &s3Fixture{
+		backend: backend,
+		storage: appv1.BackupStorage{S3: &appv1.S3BackupStorage{
+			Endpoint:             server.URL,
+			Bucket:               backupBucket,
+			CredentialsSecretRef: corev1.LocalObjectReference{Name: "s3"},
+		}},
+		secret: &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "s3", Namespace: "default"},
+			Data: map[string][]byte{
+				appv1.S3AccessKeyIDKey:     []byte("minio"),
+				appv1.S3SecretAccessKeyKey: []byte("minio123"),
+			},
+		},
+	}
+}
+
+// put stores an object as the upload of a backup Job would.
+func (f *s3Fixture) put(t *testing.T, key, content string) {
+	t.Helper()
+	meta := map[string]string{"Last-Modified": time.Now().UTC().Format(http.TimeFormat)}
+	if _, err := f.backend.PutObject(backupBucket, key, meta, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *s3Fixture) exists(key string) bool {
+	_, err := f.backend.HeadObject(backupBucket, key)
+	return err == nil
+}
+
+func newFakeBackupReconciler(t *testing.T, objs ...client.Object) *WebServiceBackupReconciler {
+	r := newFakeReconciler(t, objs...)
+	return &WebServiceBackupReconciler{Client: r.Client, Scheme: r.Scheme, Log: r.Log, Config: r.Config, Recorder: r.Recorder}
+}
+
+func newFakeRestoreReconciler(t *testing.T, objs ...client.Object) *WebServiceRestoreReconciler {
+	r := newFakeReconciler(t, objs...)
+	return &WebServiceRestoreReconciler{Client: r.Client, Scheme: r.Scheme, Log: r.Log, Config: r.Config, Recorder: r.Recorder}
+}
+
+func testBackup(name string, app *appv1.WebService, storage appv1.BackupStorage) *appv1.WebServiceBackup {
+	return &appv1.WebServiceBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: app.Namespace},
+		Spec:       appv1.WebServiceBackupSpec{WebServiceName: app.Name, Method: appv1.BackupMethodMysqldump, Storage: storage},
+	}
+}
+
+// readyPrimary is the first database pod of app, ready for backups.
+func readyPrimary(app *appv1.WebService) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: databasePodName(app, 0), Namespace: app.Namespace},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func testCredentials(app *appv1.WebService) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: generatedSecretName(app), Namespace: app.Namespace},
+		Data: map[string][]byte{
+			secretKeyRootPassword: []byte("root"),
+			secretKeyUsername:     []byte(mysqlUsername),
+			secretKeyPassword:     []byte("user"),
+		},
+	}
+}
+
+// finishJob marks the Job name as succeeded or failed, a succeeded backup
+// Job with the termination message of its dump container.
+func finishJob(t *testing.T, c client.Client, namespace, name string, succeeded bool, result string) {
+	t.Helper()
+	g := NewWithT(t)
+	ctx := context.Background()
+	job := &batchv1.Job{}
+	g.Expect(c.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, job)).To(Succeed())
+	if !succeeded {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+		g.Expect(c.Status().Update(ctx, job)).To(Succeed())
+		return
+	}
+	job.Status.Succeeded = 1
+	g.Expect(c.Status().Update(ctx, job)).To(Succeed())
+	if result == "" {
+		return
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-x7k2p", Namespace: namespace, Labels: map[string]string{"job-name": name}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodSucceeded,
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  backupContainer,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: result}},
+			}},
+		},
+	}
+	g.Expect(c.Create(ctx, pod)).To(Succeed())
+}
+
+func TestBackupToS3(t *testing.T) {
+	const dump = "-- dump of shop"
+	result := fmt.Sprintf(`{"sizeBytes":%d,"checksum":"sha256:0f1e","binlogFile":"binlog.000003","binlogPosition":157}`, len(dump))
+	for name, tc := range map[string]struct {
+		// upload is what the Job left in the bucket, nothing if empty.
+		upload string
+		phase  appv1.BackupPhase
+		msg    string
+	}{
+		"uploaded":     {upload: dump, phase: appv1.BackupCompleted},
+		"not uploaded": {phase: appv1.BackupFailed, msg: "s3://backups/shop/nightly.sql.gz was not uploaded"},
+		"truncated": {upload: dump[:4], phase: appv1.BackupFailed,
+			msg: fmt.Sprintf("s3://backups/shop/nightly.sql.gz has 4 bytes, the dump had %d", len(dump))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			s3 := newS3Fixture(t)
+			app := testWebService("shop")
+			backup := testBackup("nightly", app, s3.storage)
+			r := newFakeBackupReconciler(t, app, testCredentials(app), readyPrimary(app), s3.secret, backup)
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(backup)}
+
+			_, err := r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(r.Get(ctx, req.NamespacedName, backup)).To(Succeed())
+			g.Expect(backup.Finalizers).To(ContainElement(backupFinalizer))
+			g.Expect(backup.Labels).To(HaveKeyWithValue(webServiceLabel, app.Name))
+			g.Expect(backup.Status.Phase).To(Equal(appv1.BackupRunning))
+			g.Expect(backup.Status.Path).To(Equal("shop/nightly.sql.gz"))
+			g.Expect(backup.Status.Location).To(Equal("s3://backups/shop/nightly.sql.gz"))
+			credentials := &corev1.Secret{}
+			g.Expect(r.Get(ctx, key(app, backup.Status.CredentialsSecret), credentials)).To(Succeed())
+			g.Expect(credentials.Data).To(HaveKeyWithValue(secretKeyRootPassword, []byte("root")))
+			job := &batchv1.Job{}
+			g.Expect(r.Get(ctx, key(app, backup.Status.Job), job)).To(Succeed())
+			g.Expect(job.Spec.Template.Spec.InitContainers).To(ConsistOf(HaveField("Name", backupContainer)))
+			g.Expect(job.Spec.Template.Spec.Containers).To(ConsistOf(HaveField("Name", transferContainer)))
+
+			if tc.upload != "" {
+				s3.put(t, backup.Status.Path, tc.upload)
+			}
+			finishJob(t, r.Client, app.Namespace, job.Name, true, result)
+			_, err = r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(r.Get(ctx, req.NamespacedName, backup)).To(Succeed())
+			g.Expect(backup.Status.Phase).To(Equal(tc.phase))
+			g.Expect(backup.Status.Message).To(Equal(tc.msg))
+			g.Expect(backup.Status.CompletionTime).NotTo(BeNil())
+			if tc.phase == appv1.BackupCompleted {
+				g.Expect(backup.Status.SizeBytes).To(BeEquivalentTo(len(dump)))
+				g.Expect(backup.Status.Checksum).To(Equal("sha256:0f1e"))
+				g.Expect(backup.Status.BinlogFile).To(Equal("binlog.000003"))
+				g.Expect(backup.Status.BinlogPosition).To(BeEquivalentTo(157))
+			}
+		})
+	}
+}
+
+func TestBackupFailures(t *testing.T) {
+	postgres := testWebService("shop")
+	postgres.Spec.Database.Engine = appv1.DatabaseEnginePostgreSQL
+	for name, tc := range map[string]struct {
+		app *appv1.WebService
+		// job finishes the backup Job, which succeeded if result is set.
+		job    bool
+		result string
+		msg    string
+	}{
+		"WebService not found": {msg: "WebService shop not found"},
+		"not MySQL":            {app: postgres, msg: "WebService shop runs PostgreSQL, only MySQL can be backed up"},
+		"Job failed":           {app: testWebService("shop"), job: true, msg: "Job nightly failed, see its logs"},
+		"no result": {app: testWebService("shop"), job: true, result: "not json",
+			msg: "reading the result of pod nightly-x7k2p: invalid character 'o' in literal null (expecting 'u')"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			app := testWebService("shop")
+			backup := testBackup("nightly", app, appv1.BackupStorage{})
+			objs := []client.Object{backup}
+			if tc.app != nil {
+				objs = append(objs, tc.app, testCredentials(tc.app), readyPrimary(tc.app))
+			}
+			r := newFakeBackupReconciler(t, objs...)
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(backup)}
+
+			_, err := r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
+			if tc.job {
+				finishJob(t, r.Client, app.Namespace, backup.Name, tc.result != "", tc.result)
+				_, err = r.Reconcile(ctx, req)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(r.Get(ctx, req.NamespacedName, backup)).To(Succeed())
+			g.Expect(backup.Status.Phase).To(Equal(appv1.BackupFailed))
+			g.Expect(backup.Status.Message).To(Equal(tc.msg))
+			g.Expect(backup.Status.CompletionTime).NotTo(BeNil())
+
+			// A failed backup is final.
+			_, err = r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(r.Get(ctx, req.NamespacedName, backup)).To(Succeed())
+			g.Expect(backup.Status.Phase).To(Equal(appv1.BackupFailed))
+		})
+	}
+}
+
+func TestDeleteBackupFromS3(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	s3 := newS3Fixture(t)
+	app := testWebService("shop")
+	backup := testBackup("nightly", app, s3.storage)
+	backup.Finalizers = []string{backupFinalizer}
+	backup.Status = appv1.WebServiceBackupStatus{Phase: appv1.BackupCompleted, Path: "shop/nightly.sql.gz"}
+	s3.put(t, backup.Status.Path, "-- dump")
+	s3.put(t, "shop/other.sql.gz", "-- dump")
+	r := newFakeBackupReconciler(t, s3.secret, backup)
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(backup)}
+
+	g.Expect(r.Delete(ctx, backup)).To(Succeed())
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s3.exists("shop/nightly.sql.gz")).To(BeFalse())
+	g.Expect(s3.exists("shop/other.sql.gz")).To(BeTrue())
+	g.Expect(errors.IsNotFound(r.Get(ctx, req.NamespacedName, backup))).To(BeTrue(), "the finalizer is removed")
+}
+
+func TestRestoreFromS3(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	s3 := newS3Fixture(t)
+	app := testWebService("shop")
+	backup := testBackup("nightly", app, s3.storage)
+	backup.Status = appv1.WebServiceBackupStatus{
+		Phase:    appv1.BackupCompleted,
+		Path:     "shop/nightly.sql.gz",
+		Location: "s3://backups/shop/nightly.sql.gz",
+		Checksum: "sha256:0f1e",
+	}
+	restore := &appv1.WebServiceRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop-restore", Namespace: app.Namespace},
+		Spec:       appv1.WebServiceRestoreSpec{BackupName: backup.Name},
+	}
+	r := newFakeRestoreReconciler(t, app, readyPrimary(app), s3.secret, backup, restore)
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(restore)}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.Get(ctx, req.NamespacedName, restore)).To(Succeed())
+	g.Expect(restore.Status.Phase).To(Equal(appv1.BackupRunning))
+	g.Expect(restore.Status.TargetWebService).To(Equal(app.Name))
+	g.Expect(restore.Status.Message).To(Equal("Loading s3://backups/shop/nightly.sql.gz into WebService shop"))
+	job := &batchv1.Job{}
+	g.Expect(r.Get(ctx, key(app, restore.Status.Job), job)).To(Succeed())
+	g.Expect(metav1.IsControlledBy(job, restore)).To(BeTrue())
+	download := job.Spec.Template.Spec.InitContainers
+	g.Expect(download).To(ConsistOf(HaveField("Name", transferContainer)))
+	g.Expect(download[0].Env).To(ContainElement(corev1.EnvVar{Name: "S3_URL", Value: "s3://backups/shop/nightly.sql.gz"}))
+	g.Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "BACKUP_CHECKSUM", Value: "0f1e"}))
+
+	finishJob(t, r.Client, app.Namespace, job.Name, true, "")
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.Get(ctx, req.NamespacedName, restore)).To(Succeed())
+	g.Expect(restore.Status.Phase).To(Equal(appv1.BackupCompleted))
+	g.Expect(restore.Status.Message).To(Equal("Restored into WebService shop"))
+	g.Expect(restore.Status.CompletionTime).NotTo(BeNil())
+}
+
+func TestRestoreFailures(t *testing.T) {
+	for name, tc := range map[string]struct {
+		backup func(*appv1.WebServiceBackup)
+		// jobFailed fails the restore Job after the first reconcile.
+		jobFailed bool
+		phase     appv1.BackupPhase
+		msg       string
+	}{
+		"backup not found": {phase: appv1.BackupFailed, msg: "WebServiceBackup nightly not found"},
+		"backup running": {backup: func(b *appv1.WebServiceBackup) {
+			b.Status.Phase = appv1.BackupRunning
+		}, phase: appv1.BackupPending, msg: "Waiting for WebServiceBackup nightly to complete"},
+		"backup failed": {backup: func(b *appv1.WebServiceBackup) {
+			b.Status.Phase = appv1.BackupFailed
+		}, phase: appv1.BackupFailed, msg: "WebServiceBackup nightly failed"},
+		"xtrabackup in place": {backup: func(b *appv1.WebServiceBackup) {
+			b.Spec.Method = appv1.BackupMethodXtrabackup
+		}, phase: appv1.BackupFailed, msg: "Xtrabackup backups can only be restored into a new WebService, set spec.target"},
+		"Job failed": {backup: func(b *appv1.WebServiceBackup) {}, jobFailed: true,
+			phase: appv1.BackupFailed, msg: "Job shop-restore failed, see its logs"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			app := testWebService("shop")
+			restore := &appv1.WebServiceRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "shop-restore", Namespace: app.Namespace},
+				Spec:       appv1.WebServiceRestoreSpec{BackupName: "nightly"},
+			}
+			objs := []client.Object{app, readyPrimary(app), restore}
+			if tc.backup != nil {
+				backup := testBackup("nightly", app, appv1.BackupStorage{})
+				backup.Status = appv1.WebServiceBackupStatus{Phase: appv1.BackupCompleted, Path: "shop/nightly.sql.gz"}
+				tc.backup(backup)
+				objs = append(objs, backup)
+			}
+			r := newFakeRestoreReconciler(t, objs...)
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(restore)}
+
+			_, err := r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
+			if tc.jobFailed {
+				finishJob(t, r.Client, app.Namespace, restore.Name, false, "")
+				_, err = r.Reconcile(ctx, req)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(r.Get(ctx, req.NamespacedName, restore)).To(Succeed())
+			g.Expect(restore.Status.Phase).To(Equal(tc.phase))
+			g.Expect(restore.Status.Message).To(Equal(tc.msg))
+		})
+	}
+}
+
+func TestScheduledBackupRetention(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	now := time.Now()
+	app := testWebService("shop")
+	app.CreationTimestamp = metav1.NewTime(now.Add(-30 * 24 * time.Hour))
+	app.Spec.Database.Backup = &appv1.MysqlBackupSpec{Schedule: "0 3 * * *", Retention: 2}
+	last := metav1.NewTime(now.Add(-48 * time.Hour))
+	app.Status.LastBackupScheduleTime = &last
+
+	backup := func(name string, age int, phase appv1.BackupPhase, scheduled bool) *appv1.WebServiceBackup {
+		b := testBackup(name, app, appv1.BackupStorage{})
+		b.CreationTimestamp = metav1.NewTime(now.Add(-time.Duration(age) * 24 * time.Hour))
+		b.Labels = map[string]string{webServiceLabel: app.Name}
+		if scheduled {
+			b.Labels[scheduledBackupLabel] = "true"
+		}
+		b.Status.Phase = phase
+		return b
+	}
+	r := newFakeReconciler(t, app,
+		backup("day-7", 7, appv1.BackupCompleted, true),
+		backup("day-8", 8, appv1.BackupFailed, true),
+		backup("day-9", 9, appv1.BackupCompleted, true),
+		backup("day-10", 10, appv1.BackupCompleted, true),
+		backup("day-11", 11, appv1.BackupRunning, true),
+		backup("manual", 20, appv1.BackupCompleted, false),
+	)
+
+	next, err := r.reconcileBackups(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(next).To(BeNumerically(">", 0))
+	g.Expect(next).To(BeNumerically("<=", 24*time.Hour))
+
+	var list appv1.WebServiceBackupList
+	g.Expect(r.List(ctx, &list)).To(Succeed())
+	var names []string
+	for _, b := range list.Items {
+		names = append(names, b.Name)
+	}
+	due := app.Status.LastBackupScheduleTime
+	g.Expect(due).NotTo(BeNil())
+	g.Expect(due.After(last.Time)).To(BeTrue())
+	taken := childName(app, due.UTC().Format("20060102-1504"))
+	// The two newest finished scheduled backups stay, a running one is not
+	// counted out and manual backups are not pruned.
+	g.Expect(names).To(ConsistOf("day-7", "day-8", "day-11", "manual", taken))
+	g.Expect(app.Status.Backups).To(ContainElements(
+		HaveField("Name", "day-7"), HaveField("Name", "manual"), HaveField("Name", taken)))
+	g.Expect(app.Status.Conditions).To(ContainElement(And(
+		HaveField("Type", appv1.ConditionBackupScheduled), HaveField("Status", metav1.ConditionTrue))))
+
+	// A backup being deleted is no longer listed.
+	stored := &appv1.WebServiceBackup{}
+	g.Expect(r.Get(ctx, key(app, "day-7"), stored)).To(Succeed())
+	controllerutil.AddFinalizer(stored, backupFinalizer)
+	g.Expect(r.Update(ctx, stored)).To(Succeed())
+	g.Expect(r.Delete(ctx, stored)).To(Succeed())
+	_, err = r.reconcileBackups(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(app.Status.Backups).NotTo(ContainElement(HaveField("Name", "day-7")))
+}
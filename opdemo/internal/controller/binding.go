@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Keys of the binding Secret, see
+// https://github.com/servicebinding/spec#well-known-secret-entries
+const (
+	bindingKeyType     = "type"
+	bindingKeyProvider = "provider"
+	bindingKeyHost     = "host"
+	bindingKeyPort     = "port"
+	bindingKeyDatabase = "database"
+	bindingKeyUsername = "username"
+	bindingKeyPassword = "password"
+	bindingKeyURI      = "uri"
+)
+
+const (
+	bindingRoot     = "/bindings"
+	bindingVolume   = "database-binding"
+	bindingProvider = "opdemo"
+)
+
+// defaultBindingEnv maps binding keys to the variables injected in Env
+// mode. DSN mode only injects the uri.
+var defaultBindingEnv = []struct{ key, name string }{
+	{bindingKeyHost, "DB_HOST"},
+	{bindingKeyPort, "DB_PORT"},
+	{bindingKeyDatabase, "DB_NAME"},
+	{bindingKeyUsername, "DB_USER"},
+	{bindingKeyPassword, "DB_PASSWORD"},
+}
+
+const defaultDSNEnv = "DATABASE_URL"
+
+func databaseBinding(c component) appv1.DatabaseBindingSpec {
+	binding := appv1.DatabaseBindingSpec{Mode: appv1.DatabaseBindingEnv}
+	if c.DatabaseBinding != nil {
+		binding = *c.DatabaseBinding
+		if binding.Mode == "" {
+			binding.Mode = appv1.DatabaseBindingEnv
+		}
+	}
+	return binding
+}
+
+// databaseBindingSecret projects the database connection of app into a
+// servicebinding.io Secret of the provider's type. The credentials are
+// copied from the database Secret, generated or existing.
+func (r *WebServiceReconciler) databaseBindingSecret(ctx context.Context, app *appv1.WebService) (*corev1.Secret, error) {
+	cfg := r.Config.Get()
+	bindingType := databaseProvider(app).BindingType()
+	creds := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: databaseSecretName(app), Namespace: app.Namespace}, creds); err != nil {
+		return nil, err
+	}
+
+	host := databaseServiceName(app)
+	port := strconv.Itoa(int(databasePort(app)))
+	username := string(creds.Data[secretKeyUsername])
+	password := string(creds.Data[secretKeyPassword])
+	uri := url.URL{
+		Scheme: bindingType,
+		User:   url.UserPassword(username, password),
+		Host:   net.JoinHostPort(host, port),
+		Path:   "/" + cfg.Mysql.Database,
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        bindingSecretName(app),
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(databaseLabels(app)),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Type: corev1.SecretType("servicebinding.io/" + bindingType),
+		Data: map[string][]byte{
+			bindingKeyType:     []byte(bindingType),
+			bindingKeyProvider: []byte(bindingProvider),
+			bindingKeyHost:     []byte(host),
+			bindingKeyPort:     []byte(port),
+			bindingKeyDatabase: []byte(cfg.Mysql.Database),
+			bindingKeyUsername: []byte(username),
+			bindingKeyPassword: []byte(password),
+			bindingKeyURI:      []byte(uri.String()),
+		},
+	}
+	controllerutil.SetControllerReference(app, secret, r.Scheme)
+	return secret, nil
+}
+
+// bindingEnv returns the variables injected into the container of c. Host,
+// port and database are plain values, everything carrying a credential is a
+// secretKeyRef to the binding Secret.
+func bindingEnv(app *appv1.WebService, cfg *config.OperatorConfig, c component) []corev1.EnvVar {
+	binding := databaseBinding(c)
+	var env []corev1.EnvVar
+	if binding.Mount {
+		env = append(env, corev1.EnvVar{Name: "SERVICE_BINDING_ROOT", Value: bindingRoot})
+	}
+
+	envName := func(key, name string) string {
+		if renamed := binding.EnvNames[key]; renamed != "" {
+			return renamed
+		}
+		return name
+	}
+	fromSecret := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: bindingSecretName(app)},
+			Key:                  key,
+		}}
+	}
+
+	switch binding.Mode {
+	case appv1.DatabaseBindingEnv:
+		values := map[string]string{
+			bindingKeyHost:     databaseServiceName(app),
+			bindingKeyPort:     strconv.Itoa(int(databasePort(app))),
+			bindingKeyDatabase: cfg.Mysql.Database,
+		}
+		for _, e := range defaultBindingEnv {
+			v := corev1.EnvVar{Name: envName(e.key, e.name)}
+			if value, ok := values[e.key]; ok {
+				v.Value = value
+			} else {
+				v.ValueFrom = fromSecret(e.key)
+			}
+			env = append(env, v)
+		}
+	case appv1.DatabaseBindingDSN:
+		env = append(env, corev1.EnvVar{Name: envName(bindingKeyURI, defaultDSNEnv), ValueFrom: fromSecret(bindingKeyURI)})
+	}
+	return env
+}
+
+func bindingVolumes(app *appv1.WebService, c component) []corev1.Volume {
+	if !databaseBinding(c).Mount {
+		return nil
+	}
+	return []corev1.Volume{{
+		Name: bindingVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: bindingSecretName(app)},
+		},
+	}}
+}
+
+func bindingVolumeMounts(app *appv1.WebService, c component) []corev1.VolumeMount {
+	if !databaseBinding(c).Mount {
+		return nil
+	}
+	return []corev1.VolumeMount{{
+		Name:      bindingVolume,
+		MountPath: bindingRoot + "/" + databaseProvider(app).BindingType(),
+		ReadOnly:  true,
+	}}
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// configChecksumAnnotation on the pod template of a component is a hash of
+// the ConfigMaps and Secrets the pods read through env, envFrom and
+// volumes. Editing one of them changes the template and rolls the pods.
+const configChecksumAnnotation = "app.enflame.cn/config-checksum"
+
+// hotReloadAnnotation set to "true" on a ConfigMap or Secret leaves it out
+// of the checksum, for data the application picks up without a restart.
+const hotReloadAnnotation = "app.enflame.cn/hot-reload"
+
+// Field indexes of the WebServices by the names of the ConfigMaps and
+// Secrets their children read, see configRefs.
+const (
+	configMapRefIndex = "configMapRefs"
+	secretRefIndex    = "secretRefs"
+)
+
+// podConfigRefs returns the names of the ConfigMaps and Secrets spec reads.
+func podConfigRefs(spec *corev1.PodSpec) (configMaps, secrets sets.Set[string]) {
+	configMaps, secrets = sets.New[string](), sets.New[string]()
+	for _, c := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		for _, env := range c.Env {
+			if from := env.ValueFrom; from != nil && from.ConfigMapKeyRef != nil {
+				configMaps.Insert(from.ConfigMapKeyRef.Name)
+			} else if from != nil && from.SecretKeyRef != nil {
+				secrets.Insert(from.SecretKeyRef.Name)
+			}
+		}
+		for _, from := range c.EnvFrom {
+			if from.ConfigMapRef != nil {
+				configMaps.Insert(from.ConfigMapRef.Name)
+			}
+			if from.SecretRef != nil {
+				secrets.Insert(from.SecretRef.Name)
+			}
+		}
+	}
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil {
+			configMaps.Insert(v.ConfigMap.Name)
+		}
+		if v.Secret != nil {
+			secrets.Insert(v.Secret.SecretName)
+		}
+		if v.Projected == nil {
+			continue
+		}
+		for _, source := range v.Projected.Sources {
+			if source.ConfigMap != nil {
+				configMaps.Insert(source.ConfigMap.Name)
+			}
+			if source.Secret != nil {
+				secrets.Insert(source.Secret.Name)
+			}
+		}
+	}
+	return configMaps, secrets
+}
+
+// configRefs returns the ConfigMaps and Secrets the children of app read:
+// those of the component pods and the migrations ConfigMap.
+func (r *WebServiceReconciler) configRefs(app *appv1.WebService) (configMaps, secrets sets.Set[string]) {
+	configMaps, secrets = sets.New[string](), sets.New[string]()
+	cfg := r.Config.Get()
+	for _, c := range webServiceComponents(app) {
+		cms, ss := podConfigRefs(&NewDeploy(app, cfg, c).Spec.Template.Spec)
+		configMaps = configMaps.Union(cms)
+		secrets = secrets.Union(ss)
+	}
+	if m := app.Spec.Migrations; m != nil && m.ConfigMap != nil {
+		configMaps.Insert(m.ConfigMap.Name)
+	}
+	return configMaps, secrets
+}
+
+// setConfigChecksum annotates the pod template of deploy with the hash of
+// the data it reads. A missing ConfigMap or Secret hashes as absent, so
+// creating it later restarts the pods too.
+func (r *WebServiceReconciler) setConfigChecksum(ctx context.Context, deploy *appsv1.Deployment) error {
+	configMaps, secrets := podConfigRefs(&deploy.Spec.Template.Spec)
+	h := sha256.New()
+	for _, name := range sets.List(configMaps) {
+		cm := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: deploy.Namespace}, cm)
+		switch {
+		case client.IgnoreNotFound(err) != nil:
+			return err
+		case err != nil:
+			fmt.Fprintf(h, "configmap=%s absent\n", name)
+		case cm.Annotations[hotReloadAnnotation] != "true":
+			fmt.Fprintf(h, "configmap=%s\n", name)
+			hashData(h, cm.Data, cm.BinaryData)
+		}
+	}
+	for _, name := range sets.List(secrets) {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: deploy.Namespace}, secret)
+		switch {
+		case client.IgnoreNotFound(err) != nil:
+			return err
+		case err != nil:
+			fmt.Fprintf(h, "secret=%s absent\n", name)
+		case secret.Annotations[hotReloadAnnotation] != "true":
+			fmt.Fprintf(h, "secret=%s\n", name)
+			hashData(h, nil, secret.Data)
+		}
+	}
+
+	template := &deploy.Spec.Template
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[configChecksumAnnotation] = hex.EncodeToString(h.Sum(nil))[:16]
+	return nil
+}
+
+// hashData writes the entries of data and binary to h in key order.
+func hashData(h hash.Hash, data map[string]string, binary map[string][]byte) {
+	keys := make([]string, 0, len(data)+len(binary))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	for k := range binary {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v, ok := data[k]; ok {
+			fmt.Fprintf(h, "%s=%d:%s\n", k, len(v), v)
+		} else {
+			fmt.Fprintf(h, "%s=%d:%s\n", k, len(binary[k]), binary[k])
+		}
+	}
+}
+
+// webServicesReading maps a ConfigMap or Secret to the WebServices that
+// read it, looked up in the field index named index.
+func (r *WebServiceReconciler) webServicesReading(index string) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var list appv1.WebServiceList
+		if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace()), client.MatchingFields{index: obj.GetName()}); err != nil {
+			r.Log.Error(err, "Failed to list the WebServices reading an object", "index", index, "name", client.ObjectKeyFromObject(obj))
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(list.Items))
+		for _, ws := range list.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ws)})
+		}
+		return requests
+	}
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+func TestPodConfigRefs(t *testing.T) {
+	g := NewWithT(t)
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", EnvFrom: []corev1.EnvFromSource{
+			{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "init-env"}}},
+		}}},
+		Containers: []corev1.Container{{
+			Name: "web",
+			Env: []corev1.EnvVar{
+				{Name: "PLAIN", Value: "1"},
+				{Name: "LEVEL", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}, Key: "level"}}},
+				{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "web-token"}, Key: "token"}}},
+			},
+			EnvFrom: []corev1.EnvFromSource{
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-env"}}},
+			},
+		}},
+		Volumes: []corev1.Volume{
+			{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "web-files"}}}},
+			{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "web-tls"}}},
+			{Name: "projected", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+				{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "web-ca"}}},
+				{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "web-key"}}},
+			}}}},
+			{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+	}
+
+	configMaps, secrets := podConfigRefs(spec)
+	g.Expect(sets.List(configMaps)).To(Equal([]string{"init-env", "web-ca", "web-config", "web-files"}))
+	g.Expect(sets.List(secrets)).To(Equal([]string{"web-env", "web-key", "web-tls", "web-token"}))
+}
+
+func TestSetConfigChecksum(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	app.Spec.Webapp.Envs = []corev1.EnvVar{{Name: "LEVEL", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}, Key: "level"}}}}
+	r := newFakeReconciler(t)
+	checksum := func() string {
+		deploy := NewDeploy(app, config.Default(), primaryComponent(app))
+		g.Expect(r.setConfigChecksum(ctx, deploy)).To(Succeed())
+		g.Expect(deploy.Spec.Template.Annotations).To(HaveKey(configChecksumAnnotation))
+		return deploy.Spec.Template.Annotations[configChecksumAnnotation]
+	}
+
+	absent := checksum()
+	g.Expect(absent).To(HaveLen(16))
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: app.Namespace}, Data: map[string]string{"level": "info"}}
+	g.Expect(r.Create(ctx, cm)).To(Succeed())
+	created := checksum()
+	g.Expect(created).NotTo(Equal(absent), "creating a missing ConfigMap restarts the pods")
+	g.Expect(checksum()).To(Equal(created), "the checksum is stable")
+
+	cm.Data["level"] = "debug"
+	g.Expect(r.Update(ctx, cm)).To(Succeed())
+	edited := checksum()
+	g.Expect(edited).NotTo(Equal(created))
+
+	// A hot reloaded ConfigMap is left out, whatever its data.
+	cm.Annotations = map[string]string{hotReloadAnnotation: "true"}
+	g.Expect(r.Update(ctx, cm)).To(Succeed())
+	hotReload := checksum()
+	cm.Data["level"] = "warn"
+	g.Expect(r.Update(ctx, cm)).To(Succeed())
+	g.Expect(checksum()).To(Equal(hotReload))
+}
+
+func TestHashData(t *testing.T) {
+	g := NewWithT(t)
+	sum := func(data map[string]string, binary map[string][]byte) string {
+		h := sha256.New()
+		hashData(h, data, binary)
+		return string(h.Sum(nil))
+	}
+	g.Expect(sum(map[string]string{"a": "1", "b": "2"}, nil)).To(Equal(sum(map[string]string{"b": "2", "a": "1"}, nil)))
+	g.Expect(sum(map[string]string{"a": "1"}, nil)).To(Equal(sum(nil, map[string][]byte{"a": []byte("1")})))
+	g.Expect(sum(map[string]string{"a": "1=b=2"}, nil)).NotTo(Equal(sum(map[string]string{"a": "1", "b": "2"}, nil)),
+		"values are length prefixed")
+}
+
+func TestWebServicesReading(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	shop := testWebService("shop")
+	shop.Spec.Migrations = &appv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "shop-sql"}}
+	blog := testWebService("blog")
+	r := newFakeReconciler(t)
+	r.Client = fake.NewClientBuilder().
+		WithScheme(r.Scheme).
+		WithObjects(shop, blog).
+		WithIndex(&appv1.WebService{}, configMapRefIndex, func(obj client.Object) []string {
+			configMaps, _ := r.configRefs(obj.(*appv1.WebService))
+			return sets.List(configMaps)
+		}).
+		WithIndex(&appv1.WebService{}, secretRefIndex, func(obj client.Object) []string {
+			_, secrets := r.configRefs(obj.(*appv1.WebService))
+			return sets.List(secrets)
+		}).
+		Build()
+
+	sql := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "shop-sql", Namespace: "default"}}
+	g.Expect(r.webServicesReading(configMapRefIndex)(ctx, sql)).To(ConsistOf(HaveField("Name", "shop")))
+
+	// Both read their own database Secret.
+	_, secrets := r.configRefs(blog)
+	g.Expect(secrets.Len()).To(BeNumerically(">", 0))
+	for _, name := range sets.List(secrets) {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		g.Expect(r.webServicesReading(secretRefIndex)(ctx, secret)).To(ConsistOf(HaveField("Name", "blog")))
+	}
+
+	other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}}
+	g.Expect(r.webServicesReading(configMapRefIndex)(ctx, other)).To(BeEmpty())
+}
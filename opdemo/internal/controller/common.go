@@ -7,80 +7,56 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func (r *WebServiceReconciler) ensureDeployment(request reconcile.Request, instance *appv1.WebService, dep *appsv1.Deployment) (*reconcile.Result, error) {
-	// See if deployment already exists and create if it doesn't
-	found := &appsv1.Deployment{}
 	log := r.Log.WithValues("webservice", request.NamespacedName)
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: dep.Name, Namespace: instance.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		// Create the deployment
-		log.Info("Creating a new Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
-		err = r.Client.Create(context.TODO(), dep)
-		if err != nil {
-			// Deployment failed
-			log.Error(err, "Failed to create new Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
-			return &reconcile.Result{}, err
-		} else {
-			// Deployment was successful
-			return nil, nil
-		}
-	} else if err != nil {
-		// Error that isn't due to the deployment not existing
-		log.Error(err, "Failed to get Deployment")
+	if err := r.adopt(context.TODO(), instance, dep); err != nil {
+		log.Error(err, "Failed to adopt Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
 		return &reconcile.Result{}, err
 	}
+	if err := r.apply(context.TODO(), dep); err != nil {
+		log.Error(err, "Failed to apply Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		return &reconcile.Result{}, err
+	}
+	return nil, nil
+}
 
+func (r *WebServiceReconciler) ensureStatefulSet(request reconcile.Request, instance *appv1.WebService, sts *appsv1.StatefulSet) (*reconcile.Result, error) {
+	log := r.Log.WithValues("webservice", request.NamespacedName)
+	if err := r.adopt(context.TODO(), instance, sts); err != nil {
+		log.Error(err, "Failed to adopt StatefulSet", "StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
+		return &reconcile.Result{}, err
+	}
+	if err := r.apply(context.TODO(), sts); err != nil {
+		log.Error(err, "Failed to apply StatefulSet", "StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
+		return &reconcile.Result{}, err
+	}
 	return nil, nil
 }
 
 func (r *WebServiceReconciler) ensureService(request reconcile.Request, instance *appv1.WebService, s *corev1.Service) (*reconcile.Result, error) {
-	found := &corev1.Service{}
 	log := r.Log.WithValues("webservice", request.NamespacedName)
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: s.Name, Namespace: instance.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		// Create the service
-		log.Info("Creating a new Service", "Service.Namespace", s.Namespace, "Service.Name", s.Name)
-		err = r.Client.Create(context.TODO(), s)
-		if err != nil {
-			// Creation failed
-			log.Error(err, "Failed to create new Service", "Service.Namespace", s.Namespace, "Service.Name", s.Name)
-			return &reconcile.Result{}, err
-		} else {
-			// Creation was successful
-			return nil, nil
-		}
-	} else if err != nil {
-		// Error that isn't due to the service not existing
-		log.Error(err, "Failed to get Service")
+	if err := r.adopt(context.TODO(), instance, s); err != nil {
+		log.Error(err, "Failed to adopt Service", "Service.Namespace", s.Namespace, "Service.Name", s.Name)
+		return &reconcile.Result{}, err
+	}
+	if err := r.apply(context.TODO(), s); err != nil {
+		log.Error(err, "Failed to apply Service", "Service.Namespace", s.Namespace, "Service.Name", s.Name)
 		return &reconcile.Result{}, err
 	}
 	return nil, nil
 }
 
 func (r *WebServiceReconciler) ensureSecret(request reconcile.Request, instance *appv1.WebService, s *corev1.Secret) (*reconcile.Result, error) {
-	found := &corev1.Secret{}
 	log := r.Log.WithValues("webservice", request.NamespacedName)
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: s.Name, Namespace: instance.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		// Create the secret
-		log.Info("Creating a new secret", "Secret.Namespace", s.Namespace, "Secret.Name", s.Name)
-		err = r.Client.Create(context.TODO(), s)
-		if err != nil {
-			// Creation failed
-			log.Error(err, "Failed to create new Secret", "Secret.Namespace", s.Namespace, "Secret.Name", s.Name)
-			return &reconcile.Result{}, err
-		} else {
-			// Creation was successful
-			return nil, nil
-		}
-	} else if err != nil {
-		// Error that isn't due to the secret not existing
-		log.Error(err, "Failed to get Secret")
+	if err := r.adopt(context.TODO(), instance, s); err != nil {
+		log.Error(err, "Failed to adopt Secret", "Secret.Namespace", s.Namespace, "Secret.Name", s.Name)
+		return &reconcile.Result{}, err
+	}
+	if err := r.apply(context.TODO(), s); err != nil {
+		log.Error(err, "Failed to apply Secret", "Secret.Namespace", s.Namespace, "Secret.Name", s.Name)
 		return &reconcile.Result{}, err
 	}
 	return nil, nil
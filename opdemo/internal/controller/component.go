@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// componentLabel marks the children of a component with its name, the
+// children of removed components are found by it.
+const componentLabel = "component"
+
+// component is one application tier together with the names of its
+// children.
+type component struct {
+	*appv1.WebServiceWebappSpec
+	// deployment names the Deployment and the HPA.
+	deployment string
+	// service names the Service, the Ingress and the HTTPRoute.
+	service string
+	// pods is the value of the "app" label selecting the pods.
+	pods string
+}
+
+// podLabels select the pods of c.
+func (c component) podLabels(app *appv1.WebService) map[string]string {
+	return map[string]string{"app": c.pods}
+}
+
+// objectLabels are set on the children of c besides the pods.
+func (c component) objectLabels(app *appv1.WebService) map[string]string {
+	return map[string]string{"webservice_cr": app.Name, componentLabel: c.Name}
+}
+
+// webServiceComponents maps spec.webapp and spec.components into one list,
+// in spec order with the webapp first.
+func webServiceComponents(app *appv1.WebService) []component {
+	var components []component
+	if app.Spec.Webapp != nil {
+		components = append(components, newComponent(app, app.Spec.Webapp))
+	}
+	for i := range app.Spec.Components {
+		components = append(components, newComponent(app, &app.Spec.Components[i]))
+	}
+	return components
+}
+
+// primaryComponent is the component the schema migrations run for,
+// spec.webapp or else the first of spec.components.
+func primaryComponent(app *appv1.WebService) component {
+	return webServiceComponents(app)[0]
+}
+
+// orderComponents sorts components so that each one follows its
+// dependencies, otherwise keeping the spec order. Duplicate names, unknown
+// dependencies and cycles are errors.
+func orderComponents(components []component) ([]component, error) {
+	byName := map[string]component{}
+	for _, c := range components {
+		if _, ok := byName[c.Name]; ok {
+			return nil, fmt.Errorf("component %q is defined twice", c.Name)
+		}
+		if reservedComponentName(c.Name) {
+			return nil, fmt.Errorf("component name %q is reserved for the database children", c.Name)
+		}
+		byName[c.Name] = c
+	}
+	for _, c := range components {
+		for _, dep := range c.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("component %q depends on unknown component %q", c.Name, dep)
+			}
+		}
+	}
+
+	ordered := make([]component, 0, len(components))
+	placed := map[string]bool{}
+	for len(ordered) < len(components) {
+		progress := false
+		for _, c := range components {
+			if placed[c.Name] || !allPlaced(placed, c.DependsOn) {
+				continue
+			}
+			ordered = append(ordered, c)
+			placed[c.Name] = true
+			progress = true
+		}
+		if !progress {
+			var cycle []string
+			for _, c := range components {
+				if !placed[c.Name] {
+					cycle = append(cycle, c.Name)
+				}
+			}
+			return nil, fmt.Errorf("components %s depend on each other", strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func allPlaced(placed map[string]bool, names []string) bool {
+	for _, name := range names {
+		if !placed[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// isComponentRunning reports whether every desired pod of c is ready.
+func (r *WebServiceReconciler) isComponentRunning(ctx context.Context, app *appv1.WebService, c component) (bool, error) {
+	deploy := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: c.deployment, Namespace: app.Namespace}, deploy)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	desired := int32(1)
+	if deploy.Spec.Replicas != nil {
+		desired = *deploy.Spec.Replicas
+	}
+	return deploy.Status.ReadyReplicas > 0 && deploy.Status.ReadyReplicas >= desired, nil
+}
+
+// pruneComponents deletes the children of components that were removed
+// from the spec. Children of a renamed spec.webapp are found the same way.
+func (r *WebServiceReconciler) pruneComponents(ctx context.Context, app *appv1.WebService, components []component) error {
+	deployments, services := map[string]bool{}, map[string]bool{}
+	for _, c := range components {
+		deployments[c.deployment] = true
+		services[c.service] = true
+	}
+
+	type childKind struct {
+		list  client.ObjectList
+		names map[string]bool
+	}
+	kinds := []childKind{
+		{&appsv1.DeploymentList{}, deployments},
+		{&autoscalingv2.HorizontalPodAutoscalerList{}, deployments},
+		{&corev1.ServiceList{}, services},
+		{&networkingv1.NetworkPolicyList{}, deployments},
+		{&networkingv1.IngressList{}, services},
+	}
+	if r.GatewayAPI {
+		routes := &unstructured.UnstructuredList{}
+		routes.SetGroupVersionKind(httpRouteGVK.GroupVersion().WithKind(httpRouteGVK.Kind + "List"))
+		kinds = append(kinds, childKind{routes, services})
+	}
+
+	for _, kind := range kinds {
+		if err := r.List(ctx, kind.list, client.InNamespace(app.Namespace),
+			client.MatchingLabels{"webservice_cr": app.Name}, client.HasLabels{componentLabel}); err != nil {
+			return err
+		}
+		items, err := meta.ExtractList(kind.list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj := item.(client.Object)
+			if kind.names[obj.GetName()] || !metav1.IsControlledBy(obj, app) {
+				continue
+			}
+			r.Log.Info("Deleting child of a removed component", "kind", fmt.Sprintf("%T", obj),
+				"name", obj.GetName(), "component", obj.GetLabels()[componentLabel])
+			if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+// withComponents appends specs to spec.components of app.
+func withComponents(app *appv1.WebService, specs ...appv1.WebServiceWebappSpec) *appv1.WebService {
+	app.Spec.Components = append(app.Spec.Components, specs...)
+	return app
+}
+
+// componentSpec is a component that depends on dependsOn.
+func componentSpec(name string, dependsOn ...string) appv1.WebServiceWebappSpec {
+	one := int32(1)
+	return appv1.WebServiceWebappSpec{Name: name, Size: &one, Image: name, DependsOn: dependsOn}
+}
+
+func componentNames(components []component) []string {
+	var names []string
+	for _, c := range components {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestWebServiceComponents(t *testing.T) {
+	g := NewWithT(t)
+	app := withComponents(testWebService("shop"), componentSpec("api"), componentSpec("worker"))
+
+	components := webServiceComponents(app)
+	g.Expect(componentNames(components)).To(Equal([]string{"web", "api", "worker"}))
+	g.Expect(components[1].deployment).To(Equal("shop-api"))
+	g.Expect(components[1].service).To(Equal("shop-api"))
+	g.Expect(primaryComponent(app).Name).To(Equal("web"))
+
+	app.Spec.Webapp = nil
+	g.Expect(primaryComponent(app).Name).To(Equal("api"))
+}
+
+func TestOrderComponents(t *testing.T) {
+	for name, tc := range map[string]struct {
+		specs []appv1.WebServiceWebappSpec
+		order []string
+		err   string
+	}{
+		"spec order": {
+			specs: []appv1.WebServiceWebappSpec{componentSpec("web"), componentSpec("api"), componentSpec("worker")},
+			order: []string{"web", "api", "worker"},
+		},
+		"dependencies first": {
+			specs: []appv1.WebServiceWebappSpec{componentSpec("web", "api"), componentSpec("api", "cache"), componentSpec("worker"), componentSpec("cache")},
+			order: []string{"worker", "cache", "api", "web"},
+		},
+		"duplicate": {
+			specs: []appv1.WebServiceWebappSpec{componentSpec("web"), componentSpec("web")},
+			err:   `component "web" is defined twice`,
+		},
+		"unknown dependency": {
+			specs: []appv1.WebServiceWebappSpec{componentSpec("web", "api")},
+			err:   `component "web" depends on unknown component "api"`,
+		},
+		"database name": {
+			specs: []appv1.WebServiceWebappSpec{componentSpec("web"), componentSpec("mysql")},
+			err:   `component name "mysql" is reserved for the database children`,
+		},
+		"database Service name": {
+			specs: []appv1.WebServiceWebappSpec{componentSpec("postgresql-read")},
+			err:   `component name "postgresql-read" is reserved for the database children`,
+		},
+		"cycle": {
+			specs: []appv1.WebServiceWebappSpec{componentSpec("worker"), componentSpec("web", "api"), componentSpec("api", "web")},
+			err:   "components web, api depend on each other",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.Webapp = nil
+			withComponents(app, tc.specs...)
+
+			ordered, err := orderComponents(webServiceComponents(app))
+			if tc.err != "" {
+				g.Expect(err).To(MatchError(tc.err))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(componentNames(ordered)).To(Equal(tc.order))
+		})
+	}
+}
+
+// The reserved names are exactly what follows the base in the names of the
+// database Services, so no component can take one of them.
+func TestReservedComponentNames(t *testing.T) {
+	g := NewWithT(t)
+	for engine := range databaseProviders {
+		app := testWebService("shop")
+		app.Spec.Database.Engine = engine
+		for _, name := range []string{databaseServiceName(app), databaseHeadlessServiceName(app), mysqlReadServiceName(app)} {
+			g.Expect(reservedComponentName(strings.TrimPrefix(name, "shop-"))).To(BeTrue(), "%s: %s", engine, name)
+		}
+	}
+	g.Expect(reservedComponentName("mysql-api")).To(BeFalse())
+	g.Expect(reservedComponentName("web")).To(BeFalse())
+}
+
+func TestIsComponentRunning(t *testing.T) {
+	three := int32(3)
+	for name, tc := range map[string]struct {
+		deploy  *appsv1.Deployment
+		running bool
+	}{
+		"missing":       {},
+		"no ready pods": {deploy: &appsv1.Deployment{}},
+		"partly ready": {deploy: &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{Replicas: &three}, Status: appsv1.DeploymentStatus{ReadyReplicas: 2},
+		}},
+		"all ready": {deploy: &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{Replicas: &three}, Status: appsv1.DeploymentStatus{ReadyReplicas: 3},
+		}, running: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			c := primaryComponent(app)
+			var objs []client.Object
+			if tc.deploy != nil {
+				tc.deploy.ObjectMeta = metav1.ObjectMeta{Name: c.deployment, Namespace: app.Namespace}
+				objs = append(objs, tc.deploy)
+			}
+			r := newFakeReconciler(t, objs...)
+
+			g.Expect(r.isComponentRunning(context.Background(), app, c)).To(Equal(tc.running))
+		})
+	}
+}
+
+func TestPruneComponents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := withComponents(testWebService("shop"), componentSpec("api"))
+	removed := newComponent(app, &appv1.WebServiceWebappSpec{Name: "worker"})
+	child := func(obj client.Object, name string, owned bool) client.Object {
+		obj.SetName(name)
+		obj.SetNamespace(app.Namespace)
+		obj.SetLabels(removed.objectLabels(app))
+		if owned {
+			obj.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(app, appv1.GroupVersion.WithKind("WebService"))})
+		}
+		return obj
+	}
+	r := newFakeReconciler(t, app,
+		child(&appsv1.Deployment{}, "shop-api", true),
+		child(&appsv1.Deployment{}, removed.deployment, true),
+		child(&corev1.Service{}, removed.service, true),
+		child(&appsv1.Deployment{}, "shop-cron", false),
+	)
+
+	g.Expect(r.pruneComponents(ctx, app, webServiceComponents(app))).To(Succeed())
+	g.Expect(errors.IsNotFound(r.Get(ctx, key(app, removed.deployment), &appsv1.Deployment{}))).To(BeTrue())
+	g.Expect(errors.IsNotFound(r.Get(ctx, key(app, removed.service), &corev1.Service{}))).To(BeTrue())
+	g.Expect(r.Get(ctx, key(app, "shop-api"), &appsv1.Deployment{})).To(Succeed(), "a current component is kept")
+	g.Expect(r.Get(ctx, key(app, "shop-cron"), &appsv1.Deployment{})).To(Succeed(), "a child the WebService does not control is kept")
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Keys of the database credentials Secret. A Secret referenced through
+// spec.database.auth.existingSecretRef must provide the keys the engine
+// uses, see DatabaseProvider.SecretKeys.
+const (
+	secretKeyRootPassword = "root-password"
+	secretKeyUsername     = "username"
+	secretKeyPassword     = "password"
+	// secretKeyReplicationPassword is only required once there are
+	// replicas.
+	secretKeyReplicationPassword = "replication-password"
+)
+
+const (
+	mysqlUsername    = "demo"
+	passwordLength   = 24
+	passwordAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// usesExistingSecret reports whether the credentials come from a Secret the
+// user manages.
+func usesExistingSecret(app *appv1.WebService) bool {
+	db := app.Spec.DatabaseSpec()
+	return db != nil && db.Auth != nil && db.Auth.ExistingSecretRef != nil && db.Auth.ExistingSecretRef.Name != ""
+}
+
+func databaseSecretName(app *appv1.WebService) string {
+	if usesExistingSecret(app) {
+		return app.Spec.DatabaseSpec().Auth.ExistingSecretRef.Name
+	}
+	return generatedSecretName(app)
+}
+
+// databaseAuthSecret builds the generated credentials Secret. Values already
+// in the cluster are carried over, so the passwords are created once and
+// every later apply writes them back unchanged. The current Secret is read
+// from the API server: a reconcile that runs before the cache has seen it
+// would otherwise generate new passwords and apply them over the ones the
+// database was initialised with.
+func (r *WebServiceReconciler) databaseAuthSecret(ctx context.Context, app *appv1.WebService) (*corev1.Secret, error) {
+	cfg := r.Config.Get()
+	name := databaseSecretName(app)
+
+	current := &corev1.Secret{}
+	err := r.apiReader().Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, current)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	data, err := databaseProvider(app).SecretData(app, current.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(databaseLabels(app)),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	controllerutil.SetControllerReference(app, secret, r.Scheme)
+	return secret, nil
+}
+
+// apiReader returns the uncached reader, the client when none is set.
+func (r *WebServiceReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// checkExistingSecret makes sure the referenced Secret is usable before
+// the database is started with it.
+func (r *WebServiceReconciler) checkExistingSecret(ctx context.Context, app *appv1.WebService) error {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: databaseSecretName(app), Namespace: app.Namespace}, secret); err != nil {
+		return err
+	}
+	for _, key := range databaseProvider(app).SecretKeys(app) {
+		if len(secret.Data[key]) == 0 {
+			return fmt.Errorf("secret %s/%s has no %q key", secret.Namespace, secret.Name, key)
+		}
+	}
+	return nil
+}
+
+func databaseSecretKeyRef(app *appv1.WebService, key string) *corev1.EnvVarSource {
+	return &corev1.EnvVarSource{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: databaseSecretName(app)},
+			Key:                  key,
+		},
+	}
+}
+
+// generateSecretData returns the username and the passwords keys of
+// current, generating the missing ones.
+func generateSecretData(current map[string][]byte, passwords ...string) (map[string][]byte, error) {
+	data := map[string][]byte{secretKeyUsername: []byte(mysqlUsername)}
+	if v := current[secretKeyUsername]; len(v) > 0 {
+		data[secretKeyUsername] = v
+	}
+	for _, key := range passwords {
+		if v := current[key]; len(v) > 0 {
+			data[key] = v
+			continue
+		}
+		password, err := randomPassword()
+		if err != nil {
+			return nil, err
+		}
+		data[key] = []byte(password)
+	}
+	return data, nil
+}
+
+func randomPassword() (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	b := make([]byte, passwordLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+func TestDatabaseAuthSecretKeepsPasswords(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	r := newFakeReconciler(t, app)
+
+	secret, err := r.databaseAuthSecret(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Name).To(Equal(generatedSecretName(app)))
+	g.Expect(metav1.IsControlledBy(secret, app)).To(BeTrue())
+	g.Expect(secret.Data).To(HaveKeyWithValue(secretKeyUsername, []byte(mysqlUsername)))
+	for _, key := range []string{secretKeyRootPassword, secretKeyPassword, secretKeyReplicationPassword} {
+		g.Expect(secret.Data).To(HaveKeyWithValue(key, HaveLen(passwordLength)), "key %s", key)
+	}
+	g.Expect(secret.Data[secretKeyRootPassword]).NotTo(Equal(secret.Data[secretKeyPassword]))
+
+	// The next reconcile writes the same passwords back; a key removed by
+	// hand is generated again.
+	g.Expect(r.apply(ctx, secret)).To(Succeed())
+	generated := secret.Data
+	stored := &corev1.Secret{}
+	g.Expect(r.Get(ctx, key(app, secret.Name), stored)).To(Succeed())
+	delete(stored.Data, secretKeyReplicationPassword)
+	g.Expect(r.Update(ctx, stored)).To(Succeed())
+
+	secret, err = r.databaseAuthSecret(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Data[secretKeyRootPassword]).To(Equal(generated[secretKeyRootPassword]))
+	g.Expect(secret.Data[secretKeyPassword]).To(Equal(generated[secretKeyPassword]))
+	g.Expect(secret.Data[secretKeyReplicationPassword]).To(HaveLen(passwordLength))
+}
+
+func TestCheckExistingSecret(t *testing.T) {
+	complete := map[string][]byte{
+		secretKeyRootPassword: []byte("root"),
+		secretKeyUsername:     []byte("shop"),
+		secretKeyPassword:     []byte("shop"),
+	}
+	withReplicas := func(app *appv1.WebService) {
+		replicas := int32(1)
+		app.Spec.DatabaseSpec().Replicas = &replicas
+	}
+	for name, tc := range map[string]struct {
+		data    map[string][]byte
+		engine  appv1.DatabaseEngine
+		mutate  func(*appv1.WebService)
+		missing string
+	}{
+		"complete":                      {data: complete},
+		"no root password":              {data: withoutKey(complete, secretKeyRootPassword), missing: secretKeyRootPassword},
+		"empty password":                {data: withKey(complete, secretKeyPassword, ""), missing: secretKeyPassword},
+		"replicas need replication":     {data: complete, mutate: withReplicas, missing: secretKeyReplicationPassword},
+		"replicas with replication":     {data: withKey(complete, secretKeyReplicationPassword, "repl"), mutate: withReplicas},
+		"postgresql without root":       {data: withoutKey(complete, secretKeyRootPassword), engine: appv1.DatabaseEnginePostgreSQL},
+		"postgresql without username":   {data: withoutKey(complete, secretKeyUsername), engine: appv1.DatabaseEnginePostgreSQL, missing: secretKeyUsername},
+		"mariadb without root password": {data: withoutKey(complete, secretKeyRootPassword), engine: appv1.DatabaseEngineMariaDB, missing: secretKeyRootPassword},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.DatabaseSpec().Engine = tc.engine
+			app.Spec.DatabaseSpec().Auth = &appv1.MysqlAuthSpec{ExistingSecretRef: &corev1.LocalObjectReference{Name: "shop-db"}}
+			if tc.mutate != nil {
+				tc.mutate(app)
+			}
+			r := newFakeReconciler(t, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "shop-db", Namespace: app.Namespace},
+				Data:       tc.data,
+			})
+
+			g.Expect(databaseSecretName(app)).To(Equal("shop-db"))
+			err := r.checkExistingSecret(context.Background(), app)
+			if tc.missing == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(`no "` + tc.missing + `" key`)))
+			}
+		})
+	}
+}
+
+func TestCheckExistingSecretNotFound(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+	app.Spec.DatabaseSpec().Auth = &appv1.MysqlAuthSpec{ExistingSecretRef: &corev1.LocalObjectReference{Name: "shop-db"}}
+	r := newFakeReconciler(t)
+
+	g.Expect(r.checkExistingSecret(context.Background(), app)).To(MatchError(ContainSubstring("not found")))
+}
+
+func withKey(data map[string][]byte, key, value string) map[string][]byte {
+	out := make(map[string][]byte, len(data)+1)
+	for k, v := range data {
+		out[k] = v
+	}
+	out[key] = []byte(value)
+	return out
+}
+
+func withoutKey(data map[string][]byte, key string) map[string][]byte {
+	out := withKey(data, key, "")
+	delete(out, key)
+	return out
+}
+
+func TestDatabaseAuthSecretReadsPastTheCache(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	stored := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: generatedSecretName(app), Namespace: app.Namespace},
+		Data: map[string][]byte{
+			secretKeyUsername:            []byte(mysqlUsername),
+			secretKeyRootPassword:        []byte("root-from-the-cluster"),
+			secretKeyPassword:            []byte("user-from-the-cluster"),
+			secretKeyReplicationPassword: []byte("replication-from-the-cluster"),
+		},
+	}
+	// The cache has not seen the Secret yet, the API server has it.
+	r := newFakeReconciler(t, app)
+	r.APIReader = newFakeReconciler(t, app, stored).Client
+
+	secret, err := r.databaseAuthSecret(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Data).To(Equal(stored.Data))
+}
@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// DatabaseProvider renders the database of a WebService for one engine.
+// The objects it returns have no owner, the reconciler sets it before they
+// are applied.
+type DatabaseProvider interface {
+	// Component is the suffix of the workload and Secret names and the
+	// tier label of every database object.
+	Component() string
+	// Image is the image of the database server and its client Jobs.
+	Image(app *appv1.WebService, cfg *config.OperatorConfig) string
+	// DefaultPort is used when spec.database.ports is empty.
+	DefaultPort() int32
+	// StatefulSet runs the database, pod 0 is the primary.
+	StatefulSet(app *appv1.WebService, cfg *config.OperatorConfig) *appsv1.StatefulSet
+	// Services are the headless Service of the StatefulSet followed by the
+	// Services clients connect to.
+	Services(app *appv1.WebService, cfg *config.OperatorConfig) []*corev1.Service
+	// SecretData completes the generated credentials. Values in current
+	// are kept so that passwords are only generated once.
+	SecretData(app *appv1.WebService, current map[string][]byte) (map[string][]byte, error)
+	// SecretKeys are the keys an existing credentials Secret must have.
+	SecretKeys(app *appv1.WebService) []string
+	// Ready reports whether the primary pod accepts connections.
+	Ready(pod *corev1.Pod) bool
+	// BindingType is the servicebinding.io type and URI scheme of the
+	// connection injected into the webapp.
+	BindingType() string
+	// Validate rejects spec features the engine does not support.
+	Validate(app *appv1.WebService) error
+}
+
+var databaseProviders = map[appv1.DatabaseEngine]DatabaseProvider{
+	appv1.DatabaseEngineMySQL:      mysqlProvider{},
+	appv1.DatabaseEngineMariaDB:    mariadbProvider{},
+	appv1.DatabaseEnginePostgreSQL: postgresqlProvider{},
+}
+
+// databaseProvider returns the provider of the engine of app. An empty
+// engine, from WebServices created before it existed, is MySQL, and so is
+// a missing database spec, which validateDatabase refuses.
+func databaseProvider(app *appv1.WebService) DatabaseProvider {
+	if db := app.Spec.DatabaseSpec(); db != nil {
+		if p, ok := databaseProviders[db.Engine]; ok {
+			return p
+		}
+	}
+	return mysqlProvider{}
+}
+
+// mysqlCompatible reports whether the engine of app works with the mysql
+// client tools, which the snapshot and SQL migration Jobs run.
+func mysqlCompatible(app *appv1.WebService) bool {
+	switch databaseProvider(app).(type) {
+	case mysqlProvider, mariadbProvider:
+		return true
+	}
+	return false
+}
+
+// validateDatabase returns the first spec feature the engine of app cannot
+// provide.
+func validateDatabase(app *appv1.WebService) error {
+	db := app.Spec.DatabaseSpec()
+	if db == nil {
+		return fmt.Errorf("spec.database is required")
+	}
+	engine := db.Engine
+	if !mysqlCompatible(app) {
+		switch {
+		case app.Spec.DeletionPolicy == appv1.DeletionPolicySnapshot:
+			return fmt.Errorf("engine %s does not support the Snapshot deletion policy", engine)
+		case app.Spec.Migrations != nil && app.Spec.Migrations.ConfigMap != nil:
+			return fmt.Errorf("engine %s does not support SQL migrations from a ConfigMap", engine)
+		}
+	}
+	return databaseProvider(app).Validate(app)
+}
+
+func databaseLabels(app *appv1.WebService) map[string]string {
+	return labels(app, databaseProvider(app).Component())
+}
+
+// databasePort is the port of the database Service.
+func databasePort(app *appv1.WebService) int32 {
+	if db := app.Spec.DatabaseSpec(); db != nil && len(db.Ports) > 0 {
+		return db.Ports[0].Port
+	}
+	return databaseProvider(app).DefaultPort()
+}
+
+// databaseContainerPort is the port the database listens on in its pod.
+func databaseContainerPort(app *appv1.WebService) int32 {
+	if db := app.Spec.DatabaseSpec(); db != nil && len(db.Ports) > 0 && db.Ports[0].TargetPort.IntVal != 0 {
+		return db.Ports[0].TargetPort.IntVal
+	}
+	return databaseProvider(app).DefaultPort()
+}
+
+func databaseStorageSize(app *appv1.WebService) resource.Quantity {
+	if db := app.Spec.DatabaseSpec(); db != nil && db.Storage != nil && !db.Storage.Size.IsZero() {
+		return db.Storage.Size
+	}
+	return resource.MustParse(defaultDatabaseStorage)
+}
+
+// databaseStatefulSet is the StatefulSet of the provider owned by app.
+func (r *WebServiceReconciler) databaseStatefulSet(app *appv1.WebService) *appsv1.StatefulSet {
+	sts := databaseProvider(app).StatefulSet(app, r.Config.Get())
+	// Like for the components, a podTemplate that does not merge was
+	// refused by validatePodTemplates.
+	spec := app.Spec.DatabaseSpec()
+	sts.Spec.Template, _ = mergePodTemplate(sts.Spec.Template, spec.PodTemplate, spec.Name)
+	controllerutil.SetControllerReference(app, sts, r.Scheme)
+	return sts
+}
+
+// databaseServices are the Services of the provider owned by app.
+func (r *WebServiceReconciler) databaseServices(app *appv1.WebService) []*corev1.Service {
+	services := databaseProvider(app).Services(app, r.Config.Get())
+	for _, s := range services {
+		controllerutil.SetControllerReference(app, s, r.Scheme)
+	}
+	return services
+}
+
+// isDatabaseUp reports whether the primary, the first pod of the database
+// StatefulSet, is ready.
+func (r *WebServiceReconciler) isDatabaseUp(app *appv1.WebService) bool {
+	pod := &corev1.Pod{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: databasePodName(app, 0), Namespace: app.Namespace}, pod)
+	if err != nil {
+		return false
+	}
+	return databaseProvider(app).Ready(pod)
+}
+
+// newDatabaseStatefulSet is the StatefulSet shape shared by the engines:
+// container runs the server, the data volume claim template is mounted at
+// dataPath and probes are the engine defaults under spec.database.probes.
+func newDatabaseStatefulSet(app *appv1.WebService, cfg *config.OperatorConfig, replicas int32, container corev1.Container, dataPath string, probes appv1.ProbesSpec) *appsv1.StatefulSet {
+	labels := databaseLabels(app)
+	spec := app.Spec.DatabaseSpec()
+
+	var storageClass *string
+	if spec.Storage != nil {
+		storageClass = spec.Storage.StorageClassName
+	}
+	container.Name = spec.Name
+	container.Ports = []corev1.ContainerPort{{
+		ContainerPort: databaseContainerPort(app),
+		Name:          databaseProvider(app).Component(),
+	}}
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: databaseDataVolume, MountPath: dataPath})
+	setProbes(&container, probes, spec.Probes)
+	podSecurity, security := databaseSecurityContexts(app)
+	container.SecurityContext = security
+
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        databaseStatefulSetName(app),
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(labels),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			ServiceName: databaseHeadlessServiceName(app),
+			Replicas:    &replicas,
+			Selector:    &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      cfg.WithLabels(labels),
+					Annotations: cfg.WithAnnotations(nil),
+				},
+				Spec: corev1.PodSpec{
+					SecurityContext:  podSecurity,
+					ImagePullSecrets: cfg.ImagePullSecrets,
+					Containers:       []corev1.Container{container},
+				},
+			},
+			// The PVCs carry the database tier labels, the finalizer
+			// selects them by label for the deletion policy.
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: databaseDataVolume, Labels: labels},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					StorageClassName: storageClass,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: databaseStorageSize(app)},
+					},
+				},
+			}},
+		},
+	}
+}
+
+// newDatabaseHeadlessService gives every database pod a stable DNS name.
+func newDatabaseHeadlessService(app *appv1.WebService, cfg *config.OperatorConfig) *corev1.Service {
+	labels := databaseLabels(app)
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        databaseHeadlessServiceName(app),
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(labels),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                corev1.ClusterIPNone,
+			Selector:                 labels,
+			PublishNotReadyAddresses: true,
+			Ports: []corev1.ServicePort{{
+				Name: databaseProvider(app).Component(),
+				Port: databaseContainerPort(app),
+			}},
+		},
+	}
+}
+
+func newDatabaseClusterService(app *appv1.WebService, cfg *config.OperatorConfig, name string, selector map[string]string, ports []corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(databaseLabels(app)),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: selector,
+			Ports:    ports,
+		},
+	}
+}
+
+// primarySelector selects pod 0 of the database StatefulSet.
+func primarySelector(app *appv1.WebService) map[string]string {
+	selector := databaseLabels(app)
+	selector[appsv1.StatefulSetPodNameLabel] = databasePodName(app, 0)
+	return selector
+}
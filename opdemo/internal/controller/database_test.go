@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+func TestDatabaseServicesDefaultPorts(t *testing.T) {
+	for _, tc := range []struct {
+		engine appv1.DatabaseEngine
+		port   int32
+	}{
+		{appv1.DatabaseEngineMySQL, 3306},
+		{appv1.DatabaseEngineMariaDB, 3306},
+		{appv1.DatabaseEnginePostgreSQL, 5432},
+	} {
+		t.Run(string(tc.engine), func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.DatabaseSpec().Engine = tc.engine
+			app.Spec.DatabaseSpec().Ports = nil
+
+			services := databaseProvider(app).Services(app, &config.OperatorConfig{})
+			g.Expect(services).NotTo(BeEmpty())
+			for _, svc := range services {
+				g.Expect(svc.Spec.Ports).To(HaveLen(1), "Service %s", svc.Name)
+				g.Expect(svc.Spec.Ports[0].Port).To(Equal(tc.port), "Service %s", svc.Name)
+				if svc.Spec.ClusterIP != corev1.ClusterIPNone {
+					g.Expect(svc.Spec.Ports[0].TargetPort).To(Equal(intstr.FromInt(int(tc.port))), "Service %s", svc.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDatabaseServicesKeepPorts(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+	app.Spec.DatabaseSpec().Ports = []corev1.ServicePort{{Name: "sql", Port: 13306, TargetPort: intstr.FromInt(3306)}}
+
+	for _, svc := range databaseProvider(app).Services(app, &config.OperatorConfig{}) {
+		if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			g.Expect(svc.Spec.Ports[0].Port).To(Equal(int32(3306)))
+			continue
+		}
+		g.Expect(svc.Spec.Ports).To(Equal(app.Spec.DatabaseSpec().Ports), "Service %s", svc.Name)
+	}
+}
+
+func TestDatabaseStatefulSetPerEngine(t *testing.T) {
+	for _, tc := range []struct {
+		engine   appv1.DatabaseEngine
+		name     string
+		image    string
+		password string
+	}{
+		{"", "shop-mysql", "mysql:5.7", "MYSQL_ROOT_PASSWORD"},
+		{appv1.DatabaseEngineMySQL, "shop-mysql", "mysql:5.7", "MYSQL_ROOT_PASSWORD"},
+		{appv1.DatabaseEngineMariaDB, "shop-mariadb", "mariadb:10.11", "MARIADB_ROOT_PASSWORD"},
+		{appv1.DatabaseEnginePostgreSQL, "shop-postgresql", "postgres:16", "POSTGRES_PASSWORD"},
+	} {
+		t.Run(fmt.Sprintf("engine %q", tc.engine), func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.DatabaseSpec().Engine = tc.engine
+			app.Spec.DatabaseSpec().Image = ""
+			r := newFakeReconciler(t)
+
+			sts := r.databaseStatefulSet(app)
+			g.Expect(sts.Name).To(Equal(tc.name))
+			g.Expect(sts.Spec.Template.Labels).To(HaveKeyWithValue("tier", databaseProvider(app).Component()))
+			container := sts.Spec.Template.Spec.Containers[0]
+			g.Expect(container.Image).To(HaveSuffix(tc.image))
+			g.Expect(container.Env).To(ContainElement(And(
+				HaveField("Name", tc.password),
+				HaveField("ValueFrom.SecretKeyRef.Name", databaseSecretName(app)),
+			)))
+		})
+	}
+}
+
+func TestValidateDatabase(t *testing.T) {
+	replicas := int32(1)
+	for name, tc := range map[string]struct {
+		engine appv1.DatabaseEngine
+		mutate func(*appv1.WebService)
+		err    string
+	}{
+		"no database": {
+			mutate: func(app *appv1.WebService) { app.Spec.Database, app.Spec.Mysql = nil, nil },
+			err:    "spec.database is required",
+		},
+		"mysql replicas": {
+			engine: appv1.DatabaseEngineMySQL,
+			mutate: func(app *appv1.WebService) { app.Spec.DatabaseSpec().Replicas = &replicas },
+		},
+		"mariadb replicas": {
+			engine: appv1.DatabaseEngineMariaDB,
+			mutate: func(app *appv1.WebService) { app.Spec.DatabaseSpec().Replicas = &replicas },
+			err:    "does not support read replicas",
+		},
+		"mariadb snapshot": {
+			engine: appv1.DatabaseEngineMariaDB,
+			mutate: func(app *appv1.WebService) { app.Spec.DeletionPolicy = appv1.DeletionPolicySnapshot },
+		},
+		"postgresql snapshot": {
+			engine: appv1.DatabaseEnginePostgreSQL,
+			mutate: func(app *appv1.WebService) { app.Spec.DeletionPolicy = appv1.DeletionPolicySnapshot },
+			err:    "does not support the Snapshot deletion policy",
+		},
+		"postgresql SQL migrations": {
+			engine: appv1.DatabaseEnginePostgreSQL,
+			mutate: func(app *appv1.WebService) {
+				app.Spec.Migrations = &appv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "sql"}}
+			},
+			err: "does not support SQL migrations",
+		},
+		"postgresql image migrations": {
+			engine: appv1.DatabaseEnginePostgreSQL,
+			mutate: func(app *appv1.WebService) { app.Spec.Migrations = &appv1.MigrationsSpec{Image: "migrate"} },
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.DatabaseSpec().Engine = tc.engine
+			tc.mutate(app)
+
+			err := validateDatabase(app)
+			if tc.err == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.err)))
+			}
+		})
+	}
+}
+
+// A WebService stored before spec.database was required is refused, not
+// dereferenced: the reconcile, the config index and the teardown all run.
+func TestWithoutDatabaseSpec(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	app.Spec.Database = nil
+	r := newFakeReconciler(t, app)
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key(app, "shop")})
+	g.Expect(err).To(MatchError(ContainSubstring("spec.database is required")))
+
+	g.Expect(r.Get(ctx, key(app, "shop"), app)).To(Succeed())
+	g.Expect(func() { r.configRefs(app) }).NotTo(Panic())
+
+	app.Spec.DeletionPolicy = appv1.DeletionPolicySnapshot
+	g.Expect(r.Update(ctx, app)).To(Succeed())
+	g.Expect(r.Delete(ctx, app)).To(Succeed())
+	for i := 0; i < 3; i++ {
+		g.Expect(r.Get(ctx, key(app, "shop"), app)).To(Succeed())
+		_, err := r.finalize(ctx, app)
+		g.Expect(err).NotTo(HaveOccurred())
+		if errors.IsNotFound(r.Get(ctx, key(app, "shop"), app)) {
+			return
+		}
+	}
+	t.Fatal("the finalizer was not removed")
+}
+
+func TestCloneOfDatabase(t *testing.T) {
+	legacy := func(app *appv1.WebService) {
+		app.Spec.Mysql, app.Spec.Database = app.Spec.Database, nil
+	}
+	for name, tc := range map[string]struct {
+		mutate func(*appv1.WebService)
+		msg    string
+	}{
+		"spec.database": {mutate: func(*appv1.WebService) {}},
+		"spec.mysql":    {mutate: legacy},
+		"no database": {mutate: func(app *appv1.WebService) {
+			app.Spec.Database = nil
+		}, msg: "the target spec needs a database and a webapp or components"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			source := testWebService("shop")
+			source.Spec.Database.Auth = &appv1.MysqlAuthSpec{ExistingSecretRef: &corev1.LocalObjectReference{Name: "shop-credentials"}}
+			source.Spec.Database.Backup = &appv1.MysqlBackupSpec{Schedule: "0 3 * * *"}
+			tc.mutate(source)
+			restore := &appv1.WebServiceRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "shop-restore", Namespace: source.Namespace},
+				Spec:       appv1.WebServiceRestoreSpec{BackupName: "shop-nightly", Target: &appv1.RestoreTarget{Name: "shop-clone"}},
+			}
+
+			clone, msg := cloneOf(restore, source)
+			g.Expect(msg).To(Equal(tc.msg))
+			if tc.msg != "" {
+				return
+			}
+			g.Expect(clone.Name).To(Equal("shop-clone"))
+			db := clone.Spec.DatabaseSpec()
+			g.Expect(db).NotTo(BeNil())
+			g.Expect(db.Auth).To(BeNil(), "the clone gets its own credentials Secret")
+			g.Expect(db.Backup).To(BeNil(), "the clone has no backup schedule")
+			g.Expect(source.Spec.DatabaseSpec().Auth).NotTo(BeNil(), "the source is left alone")
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"fmt"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// dumpMountPath is where mysqlClientJob mounts its PVC.
+const dumpMountPath = "/dump"
+
+const defaultDumpStorage = "1Gi"
+
+// dumpStorageSize sizes a dump PVC like the data volume it is a dump of,
+// defaultDumpStorage when spec.database.storage has no size. A dump is
+// not larger than the data directory it comes from.
+func dumpStorageSize(app *appv1.WebService) resource.Quantity {
+	if db := app.Spec.DatabaseSpec(); db != nil && db.Storage != nil && !db.Storage.Size.IsZero() {
+		return db.Storage.Size
+	}
+	return resource.MustParse(defaultDumpStorage)
+}
+
+// dumpPVC is a PVC for SQL dumps. It has no owner, dumps are meant to
+// outlive the WebService.
+func (r *WebServiceReconciler) dumpPVC(app *appv1.WebService, name, tier string) *corev1.PersistentVolumeClaim {
+	cfg := r.Config.Get()
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(labels(app, tier)),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: dumpStorageSize(app)},
+			},
+		},
+	}
+}
+
+// mysqlClientJob is a newMysqlClientJob owned by app.
+func (r *WebServiceReconciler) mysqlClientJob(app *appv1.WebService, name, tier, claim, script string, env ...corev1.EnvVar) *batchv1.Job {
+	job := newMysqlClientJob(r.Config.Get(), app, name, tier, claim, script, env...)
+	controllerutil.SetControllerReference(app, job, r.Scheme)
+	return job
+}
+
+// newMysqlClientJob runs script with the mysql client tools of the
+// database image against the Service of a MySQL compatible app as root.
+// DB_HOST, DB_PORT and MYSQL_PWD are set, claim is mounted at dumpMountPath
+// unless it is empty. The Job has no owner.
+func newMysqlClientJob(cfg *config.OperatorConfig, app *appv1.WebService, name, tier, claim, script string, env ...corev1.EnvVar) *batchv1.Job {
+	backoffLimit := int32(2)
+	// The database user owns the dump files like the data directory.
+	user := databaseUser
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(labels(app, tier)),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: cfg.WithLabels(labels(app, tier))},
+				Spec: corev1.PodSpec{
+					RestartPolicy:    corev1.RestartPolicyNever,
+					SecurityContext:  restrictedPodSecurityContext(&user),
+					ImagePullSecrets: cfg.ImagePullSecrets,
+					Containers: []corev1.Container{{
+						Name:            tier,
+						Image:           databaseProvider(app).Image(app, cfg),
+						Command:         []string{"sh", "-c", script},
+						SecurityContext: restrictedSecurityContext(),
+						Env: append([]corev1.EnvVar{
+							{Name: "DB_HOST", Value: databaseServiceName(app)},
+							{Name: "DB_PORT", Value: fmt.Sprint(databasePort(app))},
+							{Name: "MYSQL_PWD", ValueFrom: databaseSecretKeyRef(app, secretKeyRootPassword)},
+						}, env...),
+						VolumeMounts: []corev1.VolumeMount{{Name: "dump", MountPath: dumpMountPath}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "dump",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+						},
+					}},
+				},
+			},
+		},
+	}
+	if claim == "" {
+		job.Spec.Template.Spec.Volumes = nil
+		job.Spec.Template.Spec.Containers[0].VolumeMounts = nil
+	}
+	return job
+}
+
+func jobFailed(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
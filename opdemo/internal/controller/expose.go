@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The Gateway API is not vendored, HTTPRoutes are built as unstructured
+// objects of this kind.
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+const (
+	reasonExposed               = "Exposed"
+	reasonGatewayAPIUnavailable = "GatewayAPIUnavailable"
+	reasonNoServicePort         = "NoServicePort"
+)
+
+// HasGatewayAPI reports whether the cluster serves HTTPRoutes. It is
+// called once at startup, installing the Gateway API later needs a restart
+// of the operator.
+func HasGatewayAPI(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version)
+	return err == nil
+}
+
+func newHTTPRoute() *unstructured.Unstructured {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+	return route
+}
+
+func exposePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// NewIngress routes expose.ingress of component c to its Service.
+func NewIngress(app *appv1.WebService, cfg *config.OperatorConfig, c component) *networkingv1.Ingress {
+	spec := c.Expose.Ingress
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Ingress",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        c.service,
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(c.objectLabels(app)),
+			Annotations: cfg.WithAnnotations(spec.Annotations),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, schema.GroupVersionKind{
+					Group:   appv1.GroupVersion.Group,
+					Version: appv1.GroupVersion.Version,
+					Kind:    "WebService",
+				}),
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: spec.IngressClassName,
+			Rules: []networkingv1.IngressRule{{
+				Host: spec.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     exposePath(spec.Path),
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: c.service,
+							Port: networkingv1.ServiceBackendPort{Number: c.Ports[0].Port},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{spec.Host}, SecretName: spec.TLSSecretName}}
+	}
+	return ingress
+}
+
+// NewHTTPRoute attaches the Service of component c to the Gateways of its
+// expose.httpRoute.
+func NewHTTPRoute(app *appv1.WebService, cfg *config.OperatorConfig, c component) *unstructured.Unstructured {
+	spec := c.Expose.HTTPRoute
+	parentRefs := []interface{}{}
+	for _, ref := range spec.ParentRefs {
+		parent := map[string]interface{}{"name": ref.Name}
+		if ref.Namespace != "" {
+			parent["namespace"] = ref.Namespace
+		}
+		if ref.SectionName != "" {
+			parent["sectionName"] = ref.SectionName
+		}
+		parentRefs = append(parentRefs, parent)
+	}
+	routeSpec := map[string]interface{}{
+		"parentRefs": parentRefs,
+		"rules": []interface{}{map[string]interface{}{
+			"matches": []interface{}{map[string]interface{}{
+				"path": map[string]interface{}{"type": "PathPrefix", "value": exposePath(spec.Path)},
+			}},
+			"backendRefs": []interface{}{map[string]interface{}{
+				"name": c.service,
+				"port": int64(c.Ports[0].Port),
+			}},
+		}},
+	}
+	if len(spec.Hostnames) > 0 {
+		hostnames := []interface{}{}
+		for _, h := range spec.Hostnames {
+			hostnames = append(hostnames, h)
+		}
+		routeSpec["hostnames"] = hostnames
+	}
+
+	route := newHTTPRoute()
+	route.SetName(c.service)
+	route.SetNamespace(app.Namespace)
+	route.SetLabels(cfg.WithLabels(c.objectLabels(app)))
+	route.SetAnnotations(cfg.WithAnnotations(nil))
+	route.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(app, schema.GroupVersionKind{
+			Group:   appv1.GroupVersion.Group,
+			Version: appv1.GroupVersion.Version,
+			Kind:    "WebService",
+		}),
+	})
+	route.Object["spec"] = routeSpec
+	return route
+}
+
+// reconcileExposure applies the Ingress and the HTTPRoute asked for in
+// expose of component c and deletes the ones no longer asked for. What
+// cannot be applied is reported by exposedCondition.
+func (r *WebServiceReconciler) reconcileExposure(ctx context.Context, app *appv1.WebService, c component) error {
+	expose := c.Expose
+	routable := expose != nil && len(c.Ports) > 0
+	key := types.NamespacedName{Name: c.service, Namespace: app.Namespace}
+
+	if routable && expose.Ingress != nil {
+		if err := r.apply(ctx, NewIngress(app, r.Config.Get(), c)); err != nil {
+			return err
+		}
+	} else if err := r.deleteOwned(ctx, app, key, &networkingv1.Ingress{}); err != nil {
+		return err
+	}
+
+	if !r.GatewayAPI {
+		return nil
+	}
+	if routable && expose.HTTPRoute != nil {
+		return r.apply(ctx, NewHTTPRoute(app, r.Config.Get(), c))
+	}
+	return r.deleteOwned(ctx, app, key, newHTTPRoute())
+}
+
+// deleteOwned deletes obj unless it is missing or not controlled by app,
+// an Ingress of the same name created by hand is left alone.
+func (r *WebServiceReconciler) deleteOwned(ctx context.Context, app *appv1.WebService, key types.NamespacedName, obj client.Object) error {
+	if err := r.Get(ctx, key, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, app) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, obj))
+}
+
+// exposedCondition reports the exposure of every component that sets
+// expose, the first failing one decides the status. It is nil when no
+// component sets expose.
+func (r *WebServiceReconciler) exposedCondition(app *appv1.WebService) *metav1.Condition {
+	var exposed *metav1.Condition
+	for _, c := range webServiceComponents(app) {
+		condition := r.componentExposedCondition(app, c)
+		if condition == nil {
+			continue
+		}
+		if app.Spec.Webapp == nil || len(app.Spec.Components) > 0 {
+			condition.Message = c.Name + ": " + condition.Message
+		}
+		if exposed == nil || (exposed.Status == metav1.ConditionTrue && condition.Status == metav1.ConditionFalse) {
+			exposed = condition
+		}
+	}
+	return exposed
+}
+
+func (r *WebServiceReconciler) componentExposedCondition(app *appv1.WebService, c component) *metav1.Condition {
+	expose := c.Expose
+	if expose == nil {
+		return nil
+	}
+	condition := &metav1.Condition{
+		Type:               appv1.ConditionExposed,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonExposed,
+		Message:            fmt.Sprintf("Service of type %s", componentServiceType(c)),
+		ObservedGeneration: app.Generation,
+	}
+	switch {
+	case (expose.Ingress != nil || expose.HTTPRoute != nil) && len(c.Ports) == 0:
+		condition.Status, condition.Reason = metav1.ConditionFalse, reasonNoServicePort
+		condition.Message = "ports is empty, there is no port to route to"
+	case expose.HTTPRoute != nil && !r.GatewayAPI:
+		condition.Status, condition.Reason = metav1.ConditionFalse, reasonGatewayAPIUnavailable
+		condition.Message = "the cluster has no gateway.networking.k8s.io/v1 HTTPRoute, install the Gateway API and restart the operator"
+	}
+	return condition
+}
+
+// exposedURL picks the external URL of the first component that has one,
+// see WebServiceStatus.URL.
+func (r *WebServiceReconciler) exposedURL(ctx context.Context, app *appv1.WebService) (string, error) {
+	for _, c := range webServiceComponents(app) {
+		url, err := r.componentURL(ctx, app, c)
+		if err != nil || url != "" {
+			return url, err
+		}
+	}
+	return "", nil
+}
+
+func (r *WebServiceReconciler) componentURL(ctx context.Context, app *appv1.WebService, c component) (string, error) {
+	expose := c.Expose
+	if expose == nil || len(c.Ports) == 0 {
+		return "", nil
+	}
+	if ingress := expose.Ingress; ingress != nil {
+		scheme := "http"
+		if ingress.TLSSecretName != "" {
+			scheme = "https"
+		}
+		return scheme + "://" + ingress.Host + exposePath(ingress.Path), nil
+	}
+	if route := expose.HTTPRoute; route != nil && r.GatewayAPI && len(route.Hostnames) > 0 {
+		return "http://" + route.Hostnames[0] + exposePath(route.Path), nil
+	}
+	if componentServiceType(c) != corev1.ServiceTypeLoadBalancer {
+		return "", nil
+	}
+
+	svc := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Name: c.service, Namespace: app.Namespace}, svc)
+	if errors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	for _, lb := range svc.Status.LoadBalancer.Ingress {
+		host := lb.IP
+		if lb.Hostname != "" {
+			host = lb.Hostname
+		}
+		if host != "" {
+			port := strconv.Itoa(int(c.Ports[0].Port))
+			return "http://" + net.JoinHostPort(host, port), nil
+		}
+	}
+	return "", nil
+}
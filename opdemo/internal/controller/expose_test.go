@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+func TestNewIngress(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+	app.Spec.Webapp.Expose = &appv1.ExposeSpec{Ingress: &appv1.IngressSpec{
+		Host:          "shop.example.com",
+		TLSSecretName: "shop-tls",
+		Annotations:   map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "8m"},
+	}}
+	c := webServiceComponents(app)[0]
+
+	ingress := NewIngress(app, config.Default(), c)
+	g.Expect(ingress.Name).To(Equal(c.service))
+	g.Expect(ingress.Annotations).To(HaveKey("nginx.ingress.kubernetes.io/proxy-body-size"))
+	g.Expect(ingress.Spec.TLS).To(ConsistOf(networkingv1.IngressTLS{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"}))
+	g.Expect(ingress.Spec.Rules).To(HaveLen(1))
+	g.Expect(ingress.Spec.Rules[0].Host).To(Equal("shop.example.com"))
+	path := ingress.Spec.Rules[0].HTTP.Paths[0]
+	g.Expect(path.Path).To(Equal("/"))
+	g.Expect(path.Backend.Service.Name).To(Equal(c.service))
+	g.Expect(path.Backend.Service.Port.Number).To(Equal(int32(80)))
+}
+
+func TestNewHTTPRoute(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+	app.Spec.Webapp.Expose = &appv1.ExposeSpec{HTTPRoute: &appv1.HTTPRouteSpec{
+		ParentRefs: []appv1.GatewayParentRef{{Name: "public", Namespace: "gateways", SectionName: "https"}},
+		Hostnames:  []string{"shop.example.com"},
+		Path:       "/api",
+	}}
+	c := webServiceComponents(app)[0]
+
+	route := NewHTTPRoute(app, config.Default(), c)
+	g.Expect(route.GroupVersionKind()).To(Equal(httpRouteGVK))
+	parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	g.Expect(parents).To(ConsistOf(map[string]interface{}{"name": "public", "namespace": "gateways", "sectionName": "https"}))
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	g.Expect(hostnames).To(Equal([]string{"shop.example.com"}))
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	g.Expect(rules).To(HaveLen(1))
+	rule := rules[0].(map[string]interface{})
+	g.Expect(rule["matches"]).To(ConsistOf(HaveKeyWithValue("path", map[string]interface{}{"type": "PathPrefix", "value": "/api"})))
+	g.Expect(rule["backendRefs"]).To(ConsistOf(map[string]interface{}{"name": c.service, "port": int64(80)}))
+}
+
+func TestReconcileExposureIngress(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	app.Spec.Webapp.Expose = &appv1.ExposeSpec{Ingress: &appv1.IngressSpec{Host: "shop.example.com"}}
+	r := newFakeReconciler(t, app)
+	c := webServiceComponents(app)[0]
+
+	g.Expect(r.reconcileExposure(ctx, app, c)).To(Succeed())
+	ingress := &networkingv1.Ingress{}
+	g.Expect(r.Get(ctx, key(app, c.service), ingress)).To(Succeed())
+	g.Expect(metav1.IsControlledBy(ingress, app)).To(BeTrue())
+
+	// Dropping expose.ingress deletes it.
+	c.Expose = &appv1.ExposeSpec{}
+	g.Expect(r.reconcileExposure(ctx, app, c)).To(Succeed())
+	g.Expect(errors.IsNotFound(r.Get(ctx, key(app, c.service), ingress))).To(BeTrue())
+
+	// An Ingress of that name made by hand is not the WebService's to
+	// delete.
+	handMade := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: c.service, Namespace: app.Namespace}}
+	g.Expect(r.Create(ctx, handMade)).To(Succeed())
+	g.Expect(r.reconcileExposure(ctx, app, c)).To(Succeed())
+	g.Expect(r.Get(ctx, key(app, c.service), ingress)).To(Succeed())
+}
+
+func TestExposedCondition(t *testing.T) {
+	route := &appv1.HTTPRouteSpec{ParentRefs: []appv1.GatewayParentRef{{Name: "public"}}}
+	for name, tc := range map[string]struct {
+		expose     *appv1.ExposeSpec
+		noPorts    bool
+		gatewayAPI bool
+		status     metav1.ConditionStatus
+		reason     string
+	}{
+		"load balancer":         {expose: &appv1.ExposeSpec{ServiceType: corev1.ServiceTypeLoadBalancer}, status: metav1.ConditionTrue, reason: reasonExposed},
+		"route with gateway":    {expose: &appv1.ExposeSpec{HTTPRoute: route}, gatewayAPI: true, status: metav1.ConditionTrue, reason: reasonExposed},
+		"route without gateway": {expose: &appv1.ExposeSpec{HTTPRoute: route}, status: metav1.ConditionFalse, reason: reasonGatewayAPIUnavailable},
+		"ingress without port": {
+			expose:  &appv1.ExposeSpec{Ingress: &appv1.IngressSpec{Host: "shop.example.com"}},
+			noPorts: true, status: metav1.ConditionFalse, reason: reasonNoServicePort,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.Webapp.Expose = tc.expose
+			if tc.noPorts {
+				app.Spec.Webapp.Ports = nil
+			}
+			r := newFakeReconciler(t)
+			r.GatewayAPI = tc.gatewayAPI
+
+			g.Expect(r.exposedCondition(app)).To(And(
+				HaveField("Status", tc.status),
+				HaveField("Reason", tc.reason),
+			))
+		})
+	}
+
+	t.Run("not exposed", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(newFakeReconciler(t).exposedCondition(testWebService("shop"))).To(BeNil())
+	})
+}
+
+func TestExposedURL(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expose *appv1.ExposeSpec
+		lb     []corev1.LoadBalancerIngress
+		url    string
+	}{
+		"ingress with TLS": {
+			expose: &appv1.ExposeSpec{Ingress: &appv1.IngressSpec{Host: "shop.example.com", Path: "/shop", TLSSecretName: "shop-tls"}},
+			url:    "https://shop.example.com/shop",
+		},
+		"route": {
+			expose: &appv1.ExposeSpec{HTTPRoute: &appv1.HTTPRouteSpec{Hostnames: []string{"shop.example.com"}}},
+			url:    "http://shop.example.com/",
+		},
+		"load balancer hostname": {
+			expose: &appv1.ExposeSpec{ServiceType: corev1.ServiceTypeLoadBalancer},
+			lb:     []corev1.LoadBalancerIngress{{IP: "10.0.0.1", Hostname: "lb.example.com"}},
+			url:    "http://lb.example.com:80",
+		},
+		"load balancer pending": {
+			expose: &appv1.ExposeSpec{ServiceType: corev1.ServiceTypeLoadBalancer},
+		},
+		"node port": {
+			expose: &appv1.ExposeSpec{},
+			lb:     []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.Webapp.Expose = tc.expose
+			c := webServiceComponents(app)[0]
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: c.service, Namespace: app.Namespace}}
+			svc.Status.LoadBalancer.Ingress = tc.lb
+			r := newFakeReconciler(t, svc)
+			r.GatewayAPI = true
+
+			url, err := r.exposedURL(context.Background(), app)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(url).To(Equal(tc.url))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+// The tests of this file and its neighbours run against the fake client
+// and need no API server, unlike the envtest suite.
+
+// newFakeReconciler returns a reconciler with the default operator config
+// on a fake client holding objs.
+func newFakeReconciler(t *testing.T, objs ...client.Object) *WebServiceReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(appv1.AddToScheme(scheme))
+	store, err := config.NewStore("", logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&appv1.WebService{}, &appv1.WebServiceBackup{}, &appv1.WebServiceRestore{}, &appsv1.Deployment{}, &appsv1.StatefulSet{}, &batchv1.Job{}).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: applyAsUpdate}).
+		Build()
+	return &WebServiceReconciler{
+		Client:   c,
+		Scheme:   scheme,
+		Log:      logr.Discard(),
+		Config:   store,
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+// applyAsUpdate stands in for server-side apply, which the fake client
+// does not implement: an apply patch creates obj or replaces the stored
+// object, leaving its status alone. Other patches go through.
+func applyAsUpdate(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	existing := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if errors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	obj.SetUID(existing.GetUID())
+	obj.SetCreationTimestamp(existing.GetCreationTimestamp())
+	return c.Update(ctx, obj)
+}
+
+// testWebService is a WebService shaped like the samples of the first
+// releases: a webapp and a MySQL database.
+func testWebService(name string) *appv1.WebService {
+	size := int32(1)
+	return &appv1.WebService{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid")},
+		Spec: appv1.WebServiceSpec{
+			Database: &appv1.WebServiceDbSpec{
+				Name:  "mysql",
+				Image: "mysql:5.7",
+				Ports: []corev1.ServicePort{{Port: 3306, TargetPort: intstr.FromInt(3306)}},
+			},
+			Webapp: &appv1.WebServiceWebappSpec{
+				Name:  "web",
+				Image: "nginx",
+				Size:  &size,
+				Ports: []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(80)}},
+			},
+		},
+	}
+}
+
+func key(app *appv1.WebService, name string) types.NamespacedName {
+	return types.NamespacedName{Name: name, Namespace: app.Namespace}
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// webServiceFinalizer holds the WebService until its children are torn
+// down in order: webapp, optional database snapshot, MySQL.
+const webServiceFinalizer = "app.enflame.cn/teardown"
+
+// Reasons of the Terminating condition, one per teardown step.
+const (
+	reasonDeletingWebapp       = "DeletingWebapp"
+	reasonSnapshottingDatabase = "SnapshottingDatabase"
+	reasonSnapshotFailed       = "SnapshotFailed"
+	reasonDeletingDatabase     = "DeletingDatabase"
+)
+
+// finalize runs one step of the teardown per call. Every step waits for
+// the objects it deletes to be gone; their delete events requeue the
+// WebService through the owned watches.
+func (r *WebServiceReconciler) finalize(ctx context.Context, app *appv1.WebService) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(app, webServiceFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	log := r.Log.WithValues("webservice", client.ObjectKeyFromObject(app))
+
+	var appObjects []client.Object
+	for _, c := range webServiceComponents(app) {
+		appObjects = append(appObjects,
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: c.deployment, Namespace: app.Namespace}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: c.service, Namespace: app.Namespace}},
+		)
+	}
+	gone, err := r.deleteAll(ctx, app, appObjects...)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !gone {
+		log.Info("Waiting for the webapp to be deleted")
+		return ctrl.Result{}, r.setTerminating(ctx, app, reasonDeletingWebapp, "Waiting for the webapp to be deleted")
+	}
+
+	policy := app.Spec.DeletionPolicy
+	// Without a database spec there was never a database to snapshot.
+	if policy == appv1.DeletionPolicySnapshot && app.Spec.DatabaseSpec() != nil {
+		done, err := r.snapshotDatabase(ctx, app)
+		if err != nil || !done {
+			return ctrl.Result{}, err
+		}
+	}
+
+	dbObjects := []client.Object{
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: databaseStatefulSetName(app), Namespace: app.Namespace}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: legacyMysqlDeploymentName(app), Namespace: app.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: databaseServiceName(app), Namespace: app.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: databaseHeadlessServiceName(app), Namespace: app.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: mysqlReadServiceName(app), Namespace: app.Namespace}},
+	}
+	if policy == appv1.DeletionPolicyRetain || policy == appv1.DeletionPolicySnapshot {
+		if err := r.retainDatabase(ctx, app); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else {
+		if !usesExistingSecret(app) {
+			dbObjects = append(dbObjects, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: databaseSecretName(app), Namespace: app.Namespace}})
+		}
+		if err := r.DeleteAllOf(ctx, &corev1.PersistentVolumeClaim{},
+			client.InNamespace(app.Namespace), client.MatchingLabels(databaseLabels(app))); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	gone, err = r.deleteAll(ctx, app, dbObjects...)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !gone {
+		log.Info("Waiting for MySQL to be deleted")
+		return ctrl.Result{}, r.setTerminating(ctx, app, reasonDeletingDatabase, "Waiting for MySQL to be deleted")
+	}
+
+	log.Info("Teardown finished, removing finalizer")
+	controllerutil.RemoveFinalizer(app, webServiceFinalizer)
+	return ctrl.Result{}, r.Update(ctx, app)
+}
+
+// deleteAll deletes the objs app controls in the foreground and reports
+// whether all of them are gone. An object of the same name that app has not
+// adopted, see spec.adoptionPolicy, is left alone and counts as gone.
+func (r *WebServiceReconciler) deleteAll(ctx context.Context, app *appv1.WebService, objs ...client.Object) (bool, error) {
+	gone := true
+	for _, obj := range objs {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if !metav1.IsControlledBy(obj, app) {
+			continue
+		}
+		gone = false
+		if obj.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationForeground)); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+	}
+	return gone, nil
+}
+
+// retainDatabase drops the owner reference to app from the MySQL Secret and
+// PVCs, so garbage collection leaves them behind. An existing Secret from
+// spec.database.auth is not owned and left untouched.
+func (r *WebServiceReconciler) retainDatabase(ctx context.Context, app *appv1.WebService) error {
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(app.Namespace), client.MatchingLabels(databaseLabels(app))); err != nil {
+		return err
+	}
+	objs := []client.Object{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: databaseSecretName(app), Namespace: app.Namespace}}}
+	for i := range pvcs.Items {
+		objs = append(objs, &pvcs.Items[i])
+	}
+
+	for _, obj := range objs {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		var refs []metav1.OwnerReference
+		for _, ref := range obj.GetOwnerReferences() {
+			if ref.UID != app.UID {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) == len(obj.GetOwnerReferences()) {
+			continue
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		obj.SetOwnerReferences(refs)
+		if err := r.Patch(ctx, obj, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotDatabase dumps all databases into the snapshot PVC with a Job and
+// reports whether the dump has completed. A failed dump stops the teardown
+// until the Job is deleted to retry, or the policy is changed.
+func (r *WebServiceReconciler) snapshotDatabase(ctx context.Context, app *appv1.WebService) (bool, error) {
+	name := childName(app, "mysql", "snapshot")
+	pvc := r.dumpPVC(app, name, "snapshot")
+	if err := r.Create(ctx, pvc); err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{Name: name, Namespace: app.Namespace}, job)
+	if errors.IsNotFound(err) {
+		file := fmt.Sprintf("%s-%d.sql", app.Name, app.DeletionTimestamp.Unix())
+		job = r.mysqlClientJob(app, name, "snapshot", name,
+			`mysqldump -h "$DB_HOST" -P "$DB_PORT" -uroot --all-databases --single-transaction > "`+dumpMountPath+`/$SNAPSHOT_FILE"`,
+			corev1.EnvVar{Name: "SNAPSHOT_FILE", Value: file})
+		if err := r.Create(ctx, job); err != nil {
+			return false, err
+		}
+	} else if err != nil {
+		return false, err
+	}
+
+	switch {
+	case job.Status.Succeeded > 0:
+		return true, nil
+	case jobFailed(job):
+		msg := fmt.Sprintf("Job %s failed, delete it to retry or change spec.deletionPolicy", job.Name)
+		return false, r.setTerminating(ctx, app, reasonSnapshotFailed, msg)
+	default:
+		msg := fmt.Sprintf("Dumping the database to PVC %s", pvc.Name)
+		return false, r.setTerminating(ctx, app, reasonSnapshottingDatabase, msg)
+	}
+}
+
+// setTerminating reports the current teardown step.
+func (r *WebServiceReconciler) setTerminating(ctx context.Context, app *appv1.WebService, reason, message string) error {
+	patch := client.MergeFrom(app.DeepCopy())
+	app.Status.Phase = appv1.PhaseTerminating
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               appv1.ConditionTerminating,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.Generation,
+	})
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               appv1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             string(appv1.PhaseTerminating),
+		Message:            "WebService is being deleted",
+		ObservedGeneration: app.Generation,
+	})
+	return r.Status().Patch(ctx, app, patch)
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+// deletedWebService is a WebService being deleted, with its finalizer.
+func deletedWebService(policy appv1.DeletionPolicy) *appv1.WebService {
+	app := testWebService("shop")
+	app.Spec.DeletionPolicy = policy
+	app.Finalizers = []string{webServiceFinalizer}
+	now := metav1.Now()
+	app.DeletionTimestamp = &now
+	return app
+}
+
+// createChildren creates the webapp and MySQL children of app, controlled
+// by it, and returns them.
+func createChildren(t *testing.T, r *WebServiceReconciler, app *appv1.WebService) []client.Object {
+	t.Helper()
+	c := webServiceComponents(app)[0]
+	children := []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: c.deployment, Namespace: app.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: c.service, Namespace: app.Namespace}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: databaseStatefulSetName(app), Namespace: app.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: databaseServiceName(app), Namespace: app.Namespace}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: databaseSecretName(app), Namespace: app.Namespace}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name: "data-" + databasePodName(app, 0), Namespace: app.Namespace, Labels: databaseLabels(app),
+		}},
+	}
+	for _, obj := range children {
+		if err := controllerutil.SetControllerReference(app, obj, r.Scheme); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Create(context.Background(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return children
+}
+
+func terminatingReason(g *WithT, r *WebServiceReconciler, app *appv1.WebService) string {
+	g.Expect(r.Get(context.Background(), client.ObjectKeyFromObject(app), app)).To(Succeed())
+	g.Expect(app.Status.Phase).To(Equal(appv1.PhaseTerminating))
+	cond := meta.FindStatusCondition(app.Status.Conditions, appv1.ConditionTerminating)
+	g.Expect(cond).NotTo(BeNil())
+	return cond.Reason
+}
+
+func TestFinalizeDeletesWebappBeforeDatabase(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := deletedWebService(appv1.DeletionPolicyDelete)
+	r := newFakeReconciler(t, app)
+	children := createChildren(t, r, app)
+	// A Service of the same name the WebService has not adopted.
+	foreign := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: mysqlReadServiceName(app), Namespace: app.Namespace}}
+	g.Expect(r.Create(ctx, foreign)).To(Succeed())
+
+	_, err := r.finalize(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(terminatingReason(g, r, app)).To(Equal(reasonDeletingWebapp))
+	for i, obj := range children {
+		err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		if i < 2 {
+			g.Expect(errors.IsNotFound(err)).To(BeTrue(), "webapp child %s", obj.GetName())
+		} else {
+			g.Expect(err).NotTo(HaveOccurred(), "database child %s", obj.GetName())
+		}
+	}
+
+	_, err = r.finalize(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(terminatingReason(g, r, app)).To(Equal(reasonDeletingDatabase))
+	for _, obj := range children {
+		g.Expect(errors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(obj), obj))).To(BeTrue(), "child %s", obj.GetName())
+	}
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(foreign), foreign)).To(Succeed())
+
+	// Everything is gone, the finalizer goes and the WebService with it.
+	_, err = r.finalize(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(errors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(app), app))).To(BeTrue())
+}
+
+func TestFinalizeRetainsDatabaseData(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := deletedWebService(appv1.DeletionPolicyRetain)
+	r := newFakeReconciler(t, app)
+	children := createChildren(t, r, app)
+
+	for i := 0; i < 3; i++ {
+		_, err := r.finalize(ctx, app)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(errors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(app), app))).To(BeTrue())
+
+	// The Secret and the PVC stay, without the owner reference that would
+	// have the garbage collector delete them.
+	for _, obj := range children[4:] {
+		g.Expect(r.Get(ctx, client.ObjectKeyFromObject(obj), obj)).To(Succeed(), "child %s", obj.GetName())
+		g.Expect(obj.GetOwnerReferences()).To(BeEmpty(), "child %s", obj.GetName())
+	}
+	sts := children[2]
+	g.Expect(errors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(sts), sts))).To(BeTrue())
+}
+
+func TestFinalizeWaitsForSnapshot(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := deletedWebService(appv1.DeletionPolicySnapshot)
+	r := newFakeReconciler(t, app)
+	children := createChildren(t, r, app)
+
+	_, err := r.finalize(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = r.finalize(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(terminatingReason(g, r, app)).To(Equal(reasonSnapshottingDatabase))
+	job := &batchv1.Job{}
+	g.Expect(r.Get(ctx, key(app, childName(app, "mysql", "snapshot")), job)).To(Succeed())
+	sts := children[2]
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(sts), sts)).To(Succeed())
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	_, err = r.finalize(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(terminatingReason(g, r, app)).To(Equal(reasonSnapshotFailed))
+
+	job.Status.Conditions = nil
+	job.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	_, err = r.finalize(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(terminatingReason(g, r, app)).To(Equal(reasonDeletingDatabase))
+	g.Expect(errors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(sts), sts))).To(BeTrue())
+}
+
+func TestDumpPVCSize(t *testing.T) {
+	for name, tc := range map[string]struct {
+		storage *appv1.MysqlStorageSpec
+		want    string
+	}{
+		"default":          {want: defaultDumpStorage},
+		"no size":          {storage: &appv1.MysqlStorageSpec{}, want: defaultDumpStorage},
+		"data volume size": {storage: &appv1.MysqlStorageSpec{Size: resource.MustParse("20Gi")}, want: "20Gi"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.DatabaseSpec().Storage = tc.storage
+			r := newFakeReconciler(t, app)
+			for _, pvc := range []*corev1.PersistentVolumeClaim{
+				r.dumpPVC(app, childName(app, "mysql", "snapshot"), "snapshot"),
+				r.dumpPVC(app, migrationPVCName(app), "migration"),
+			} {
+				g.Expect(pvc.Spec.Resources.Requests[corev1.ResourceStorage]).To(Equal(resource.MustParse(tc.want)), pvc.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/graph"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Nodes of the reconcile graph, see webServiceGraph. The components are
+// componentNode(name).
+const (
+	nodeCredentials     = "credentials"
+	nodeDatabaseMove    = "database-move"
+	nodeDatabaseStorage = "database-storage"
+	nodeDatabase        = "database"
+	nodeDatabaseRestore = "database-restore"
+	nodeReplication     = "replication"
+	nodeBinding         = "binding"
+	nodeSchema          = "schema"
+	nodeBackups         = "backups"
+)
+
+func componentNode(name string) string {
+	return "component/" + name
+}
+
+// webServiceGraph lays out the children of app as a reconcile graph: the
+// credentials, the database and its migrations, the binding Secret, the
+// schema and every component after the components it depends on. A new
+// kind of child is a new node here, Reconcile runs whatever the graph
+// holds. Replication and backups lower requeue to when they want to be
+// checked again.
+func (r *WebServiceReconciler) webServiceGraph(req ctrl.Request, app *appv1.WebService, components []component, requeue *time.Duration) *graph.Graph {
+	log := r.Log.WithValues("webservice", req.NamespacedName)
+	sts := r.databaseStatefulSet(app)
+
+	var g graph.Graph
+	g.Add(
+		graph.Node{
+			Name: nodeCredentials,
+			Apply: func(ctx context.Context) error {
+				if usesExistingSecret(app) {
+					if err := r.checkExistingSecret(ctx, app); err != nil {
+						log.Error(err, "Database credentials Secret is not usable")
+						return err
+					}
+					return nil
+				}
+				secret, err := r.databaseAuthSecret(ctx, app)
+				if err != nil {
+					return err
+				}
+				_, err = r.ensureSecret(req, app, secret)
+				return err
+			},
+		},
+		graph.Task(nodeDatabaseMove, "Moving MySQL from a Deployment to a StatefulSet", func(ctx context.Context) (bool, error) {
+			return r.migrateMysqlDeployment(ctx, app)
+		}, nodeCredentials),
+		graph.Task(nodeDatabaseStorage, "Recreating the StatefulSet with the new volume size", func(ctx context.Context) (bool, error) {
+			return r.reconcileDatabaseStorage(ctx, app, sts)
+		}, nodeDatabaseMove),
+		graph.Node{
+			Name:      nodeDatabase,
+			DependsOn: []string{nodeDatabaseStorage},
+			Apply: func(ctx context.Context) error {
+				if _, err := r.ensureStatefulSet(req, app, sts); err != nil {
+					return err
+				}
+				for _, svc := range r.databaseServices(app) {
+					if _, err := r.ensureService(req, app, svc); err != nil {
+						return err
+					}
+				}
+				return r.reconcileDatabaseNetworkPolicy(ctx, app)
+			},
+			// The database StatefulSet is watched, the ready replica update
+			// triggers the next reconcile.
+			Ready: func(context.Context) (bool, string, error) {
+				return r.isDatabaseUp(app), "Waiting for the primary to become ready", nil
+			},
+		},
+		graph.Task(nodeDatabaseRestore, "Restoring the dump of the MySQL Deployment", func(ctx context.Context) (bool, error) {
+			return r.restoreMysqlDump(ctx, app)
+		}, nodeDatabase),
+		graph.Node{
+			Name:      nodeReplication,
+			DependsOn: []string{nodeDatabaseRestore},
+			Apply: func(ctx context.Context) error {
+				resync, err := r.reconcileReplication(ctx, app)
+				if err != nil {
+					log.Error(err, "Failed to reconcile MySQL replication")
+					return err
+				}
+				*requeue = minRequeue(*requeue, resync)
+				return nil
+			},
+		},
+		graph.Node{
+			Name:      nodeBinding,
+			DependsOn: []string{nodeDatabaseRestore},
+			Apply: func(ctx context.Context) error {
+				binding, err := r.databaseBindingSecret(ctx, app)
+				if err != nil {
+					return err
+				}
+				_, err = r.ensureSecret(req, app, binding)
+				return err
+			},
+		},
+		// A failed or running migration holds every component at its
+		// current state, the Job completion triggers the next reconcile.
+		graph.Task(nodeSchema, "Waiting for the schema migration Job", func(ctx context.Context) (bool, error) {
+			migrated, err := r.migrateSchema(ctx, app)
+			if err != nil {
+				log.Error(err, "Failed to migrate the schema")
+			}
+			return migrated, err
+		}, nodeBinding),
+	)
+
+	dependedOn := map[string]bool{}
+	for _, c := range components {
+		for _, dep := range c.DependsOn {
+			dependedOn[dep] = true
+		}
+	}
+	for _, c := range components {
+		c := c
+		n := graph.Node{
+			Name:      componentNode(c.Name),
+			DependsOn: []string{nodeSchema},
+			Apply: func(ctx context.Context) error {
+				if err := r.reconcileComponent(ctx, req, app, c); err != nil {
+					log.Error(err, "Failed to reconcile component", "component", c.Name)
+					return err
+				}
+				return nil
+			},
+		}
+		for _, dep := range c.DependsOn {
+			n.DependsOn = append(n.DependsOn, componentNode(dep))
+		}
+		// Like the database: the Deployments are watched, a dependency
+		// turning ready triggers the next reconcile.
+		if dependedOn[c.Name] {
+			n.Ready = func(ctx context.Context) (bool, string, error) {
+				running, err := r.isComponentRunning(ctx, app, c)
+				return running, fmt.Sprintf("Waiting for the pods of Deployment %s to become ready", c.deployment), err
+			}
+		}
+		g.Add(n)
+	}
+
+	g.Add(graph.Node{
+		Name:      nodeBackups,
+		DependsOn: []string{nodeDatabaseRestore},
+		Apply: func(ctx context.Context) error {
+			next, err := r.reconcileBackups(ctx, app)
+			if err != nil {
+				log.Error(err, "Failed to reconcile MySQL backups")
+				return err
+			}
+			*requeue = minRequeue(*requeue, next)
+			return nil
+		},
+	})
+	return &g
+}
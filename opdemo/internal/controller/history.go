@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/history"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// rollbackAnnotation requests a rollback like spec.rollbackTo, for tools
+// that only edit metadata. Its value is the revision number.
+const rollbackAnnotation = "app.enflame.cn/rollback-to"
+
+const (
+	reasonRolledBack      = "RolledBack"
+	reasonRollbackRefused = "RollbackRefused"
+)
+
+// revisions returns the ControllerRevisions of app, oldest first.
+func (r *WebServiceReconciler) revisions(ctx context.Context, app *appv1.WebService) ([]appsv1.ControllerRevision, error) {
+	var list appsv1.ControllerRevisionList
+	if err := r.List(ctx, &list, client.InNamespace(app.Namespace), history.Selector(app.Name)); err != nil {
+		return nil, err
+	}
+	revs := make([]appsv1.ControllerRevision, 0, len(list.Items))
+	for _, rev := range list.Items {
+		if metav1.IsControlledBy(&rev, app) {
+			revs = append(revs, rev)
+		}
+	}
+	history.Sort(revs)
+	return revs, nil
+}
+
+// recordRevision stores the spec the children of app were synced to and
+// points the status at it. A spec recorded before, the target of a
+// rollback say, is renumbered as the newest revision instead of copied.
+// The oldest revisions beyond spec.revisionHistoryLimit are deleted.
+func (r *WebServiceReconciler) recordRevision(ctx context.Context, app *appv1.WebService) error {
+	data, hash, err := history.Snapshot(&app.Spec)
+	if err != nil {
+		return err
+	}
+	revs, err := r.revisions(ctx, app)
+	if err != nil {
+		return err
+	}
+	next := int64(1)
+	if n := len(revs); n > 0 {
+		next = revs[n-1].Revision + 1
+	}
+
+	name := childName(app, hash)
+	var recorded *appsv1.ControllerRevision
+	for i := range revs {
+		if revs[i].Name == name {
+			recorded = &revs[i]
+		}
+	}
+	switch {
+	case recorded == nil:
+		rev := &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: app.Namespace, Labels: labels(app, history.Tier)},
+			Data:       runtime.RawExtension{Raw: data},
+			Revision:   next,
+		}
+		if err := controllerutil.SetControllerReference(app, rev, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, rev); client.IgnoreAlreadyExists(err) != nil {
+			return err
+		}
+		revs = append(revs, *rev)
+		r.Log.Info("Recorded spec revision", "webservice", client.ObjectKeyFromObject(app), "revision", next)
+	case recorded.Revision != next-1:
+		patch := client.MergeFrom(recorded.DeepCopy())
+		recorded.Revision = next
+		if err := r.Patch(ctx, recorded, patch); err != nil {
+			return err
+		}
+		history.Sort(revs)
+		r.Log.Info("Renumbered spec revision", "webservice", client.ObjectKeyFromObject(app), "revision", next)
+	default:
+		next = recorded.Revision
+	}
+	app.Status.Revision = next
+	app.Status.CurrentRevision = name
+
+	limit := history.DefaultLimit
+	if app.Spec.RevisionHistoryLimit != nil {
+		limit = int(*app.Spec.RevisionHistoryLimit)
+	}
+	if len(revs) <= limit {
+		return nil
+	}
+	for _, rev := range revs[:len(revs)-limit] {
+		if err := r.Delete(ctx, &rev); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback replaces the spec of app with the revision requested through
+// spec.rollbackTo or the rollback annotation, and drops the request. It
+// reports whether app was written, the update triggers the next reconcile.
+// A revision that is not in the history is refused with an event and the
+// spec left as it is.
+func (r *WebServiceReconciler) rollback(ctx context.Context, app *appv1.WebService) (bool, error) {
+	var target int64
+	requested, annotated := app.Annotations[rollbackAnnotation]
+	switch {
+	case app.Spec.RollbackTo != nil:
+		target = *app.Spec.RollbackTo
+		requested = strconv.FormatInt(target, 10)
+	case annotated:
+		// An unparsable value stays 0, which no revision has.
+		target, _ = strconv.ParseInt(requested, 10, 64)
+	default:
+		return false, nil
+	}
+	revs, err := r.revisions(ctx, app)
+	if err != nil {
+		return false, err
+	}
+
+	patch := client.MergeFrom(app.DeepCopy())
+	delete(app.Annotations, rollbackAnnotation)
+	app.Spec.RollbackTo = nil
+	rev := history.Find(revs, target)
+	if rev == nil {
+		r.Recorder.Eventf(app, corev1.EventTypeWarning, reasonRollbackRefused,
+			"Revision %q is not in the history, the spec is left as it is", requested)
+		return true, r.Patch(ctx, app, patch)
+	}
+	spec, err := history.Spec(rev)
+	if err != nil {
+		return false, err
+	}
+	spec.RevisionHistoryLimit = app.Spec.RevisionHistoryLimit
+	app.Spec = *spec
+	if err := r.Patch(ctx, app, patch); err != nil {
+		return false, err
+	}
+	r.Log.Info("Rolled back spec", "webservice", client.ObjectKeyFromObject(app), "revision", target)
+	r.Recorder.Eventf(app, corev1.EventTypeNormal, reasonRolledBack, "Rolled back to revision %d", target)
+	return true, nil
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/tools/record"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/history"
+)
+
+func storedRevisions(t *testing.T, r *WebServiceReconciler, app *appv1.WebService) []appsv1.ControllerRevision {
+	t.Helper()
+	revs, err := r.revisions(context.Background(), app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revs
+}
+
+func TestRecordRevision(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	r := newFakeReconciler(t, app)
+
+	g.Expect(r.recordRevision(ctx, app)).To(Succeed())
+	revs := storedRevisions(t, r, app)
+	g.Expect(revs).To(HaveLen(1))
+	g.Expect(app.Status.Revision).To(Equal(int64(1)))
+	g.Expect(app.Status.CurrentRevision).To(Equal(revs[0].Name))
+
+	// The same spec is not recorded twice.
+	g.Expect(r.recordRevision(ctx, app)).To(Succeed())
+	g.Expect(storedRevisions(t, r, app)).To(HaveLen(1))
+	g.Expect(app.Status.Revision).To(Equal(int64(1)))
+
+	app.Spec.Webapp.Image = "nginx:1.25"
+	g.Expect(r.recordRevision(ctx, app)).To(Succeed())
+	revs = storedRevisions(t, r, app)
+	g.Expect(revs).To(HaveLen(2))
+	g.Expect(revs[1].Revision).To(Equal(int64(2)))
+	g.Expect(app.Status.Revision).To(Equal(int64(2)))
+	g.Expect(app.Status.CurrentRevision).To(Equal(revs[1].Name))
+	spec, err := history.Spec(&revs[1])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(spec.Webapp.Image).To(Equal("nginx:1.25"))
+
+	// Returning to the first spec renumbers its revision.
+	app.Spec.Webapp.Image = "nginx"
+	g.Expect(r.recordRevision(ctx, app)).To(Succeed())
+	revs = storedRevisions(t, r, app)
+	g.Expect(revs).To(HaveLen(2))
+	g.Expect(revs[1].Revision).To(Equal(int64(3)))
+	g.Expect(app.Status.CurrentRevision).To(Equal(revs[1].Name))
+}
+
+func TestRevisionHistoryLimit(t *testing.T) {
+	two := int32(2)
+	for name, tc := range map[string]struct {
+		limit *int32
+		kept  []int64
+	}{
+		"default":              {kept: []int64{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+		"revisionHistoryLimit": {limit: &two, kept: []int64{11, 12}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			app := testWebService("shop")
+			app.Spec.RevisionHistoryLimit = tc.limit
+			r := newFakeReconciler(t, app)
+
+			for i := 1; i <= 12; i++ {
+				app.Spec.Webapp.Ports[0].Port = int32(8000 + i)
+				g.Expect(r.recordRevision(ctx, app)).To(Succeed())
+			}
+			var kept []int64
+			for _, rev := range storedRevisions(t, r, app) {
+				kept = append(kept, rev.Revision)
+			}
+			g.Expect(kept).To(Equal(tc.kept))
+			g.Expect(app.Status.Revision).To(Equal(int64(12)))
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	first, missing := int64(1), int64(5)
+	for name, tc := range map[string]struct {
+		rollbackTo *int64
+		annotation string
+		restored   bool
+		event      string
+	}{
+		"rollbackTo":           {rollbackTo: &first, restored: true, event: "Normal RolledBack Rolled back to revision 1"},
+		"annotation":           {annotation: "1", restored: true, event: "Normal RolledBack Rolled back to revision 1"},
+		"unknown revision":     {rollbackTo: &missing, event: `Warning RollbackRefused Revision "5" is not in the history, the spec is left as it is`},
+		"malformed annotation": {annotation: "latest", event: `Warning RollbackRefused Revision "latest" is not in the history, the spec is left as it is`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			app := testWebService("shop")
+			limit := int32(5)
+			app.Spec.RevisionHistoryLimit = &limit
+			r := newFakeReconciler(t, app)
+			g.Expect(r.recordRevision(ctx, app)).To(Succeed())
+
+			g.Expect(r.Get(ctx, key(app, app.Name), app)).To(Succeed())
+			app.Spec.Webapp.Image = "nginx:1.25"
+			app.Spec.RollbackTo = tc.rollbackTo
+			if tc.annotation != "" {
+				app.Annotations = map[string]string{rollbackAnnotation: tc.annotation}
+			}
+			g.Expect(r.Update(ctx, app)).To(Succeed())
+
+			written, err := r.rollback(ctx, app)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(written).To(BeTrue())
+
+			stored := &appv1.WebService{}
+			g.Expect(r.Get(ctx, key(app, app.Name), stored)).To(Succeed())
+			g.Expect(stored.Spec.RollbackTo).To(BeNil())
+			g.Expect(stored.Annotations).NotTo(HaveKey(rollbackAnnotation))
+			g.Expect(stored.Spec.RevisionHistoryLimit).To(HaveValue(Equal(limit)))
+			if tc.restored {
+				g.Expect(stored.Spec.Webapp.Image).To(Equal("nginx"))
+			} else {
+				g.Expect(stored.Spec.Webapp.Image).To(Equal("nginx:1.25"))
+			}
+			g.Expect(r.Recorder.(*record.FakeRecorder).Events).To(Receive(Equal(tc.event)))
+		})
+	}
+
+	t.Run("not requested", func(t *testing.T) {
+		g := NewWithT(t)
+		app := testWebService("shop")
+		r := newFakeReconciler(t, app)
+
+		written, err := r.rollback(context.Background(), app)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(written).To(BeFalse())
+	})
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Older releases ran MySQL as a Deployment without a volume. Such an
+// instance is moved to the StatefulSet in two phases:
+//
+//  1. a Job dumps the databases of the running Deployment into the
+//     "<name>-mysql-migration" PVC, then the Deployment is deleted,
+//  2. once the StatefulSet is ready a second Job loads the dump.
+//
+// The dump Job is the marker for phase 2 and is deleted when the restore
+// has succeeded. The migration PVC is kept as a safety copy.
+
+const (
+	reasonDumpingLegacyDatabase = "DumpingLegacyDatabase"
+	reasonRemovingDeployment    = "RemovingDeployment"
+	reasonRestoringDatabase     = "RestoringDatabase"
+	reasonMigrationFailed       = "MigrationFailed"
+	reasonMigrated              = "Migrated"
+)
+
+const legacyDumpFile = dumpMountPath + "/legacy.sql"
+
+// legacyDumpScript dumps the databases of the application only. The system
+// schemas hold the accounts of the Deployment, loading them would replace
+// the generated credentials the StatefulSet was initialised with.
+const legacyDumpScript = `set -e
+dbs=$(mysql -h "$DB_HOST" -P "$DB_PORT" -uroot -N -e 'SHOW DATABASES' | grep -Ev '^(mysql|information_schema|performance_schema|sys)$' || true)
+if [ -z "$dbs" ]; then
+  : > ` + legacyDumpFile + `
+  exit 0
+fi
+mysqldump -h "$DB_HOST" -P "$DB_PORT" -uroot --databases $dbs --single-transaction --routines --events > ` + legacyDumpFile + `
+`
+
+func legacyMysqlDeploymentName(app *appv1.WebService) string {
+	return app.Name + "-mysql"
+}
+
+func migrationPVCName(app *appv1.WebService) string {
+	return app.Name + "-mysql-migration"
+}
+
+// migrateMysqlDeployment runs phase 1 and reports whether the StatefulSet
+// may be applied.
+func (r *WebServiceReconciler) migrateMysqlDeployment(ctx context.Context, app *appv1.WebService) (bool, error) {
+	legacy := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: legacyMysqlDeploymentName(app), Namespace: app.Namespace}, legacy)
+	if errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if legacy.DeletionTimestamp != nil {
+		return false, nil
+	}
+
+	// Without a ready pod there is nothing to dump, the data of a
+	// Deployment without a volume does not survive a container restart.
+	if legacy.Status.ReadyReplicas > 0 {
+		done, err := r.dumpLegacyDatabase(ctx, app, legacy)
+		if err != nil || !done {
+			return false, err
+		}
+	}
+
+	r.Log.Info("Deleting the legacy MySQL Deployment", "Deployment.Namespace", legacy.Namespace, "Deployment.Name", legacy.Name)
+	if err := r.Delete(ctx, legacy, client.PropagationPolicy(metav1.DeletePropagationForeground)); client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+	return false, r.setStorageMigrated(ctx, app, metav1.ConditionFalse, reasonRemovingDeployment,
+		"Waiting for the MySQL Deployment to be deleted")
+}
+
+// legacyRootPassword is MYSQL_PWD for the Job dumping legacy: the
+// MYSQL_ROOT_PASSWORD the Deployment runs with, not the generated Secret of
+// the StatefulSet. That is a literal in the Deployments of older releases
+// and a secretKeyRef in those already switched to generated credentials;
+// the Deployment itself is never updated again, so it keeps whichever
+// password its pod was started with until it has been dumped.
+func legacyRootPassword(legacy *appsv1.Deployment) corev1.EnvVar {
+	pwd := corev1.EnvVar{Name: "MYSQL_PWD"}
+	for _, c := range legacy.Spec.Template.Spec.Containers {
+		for _, env := range c.Env {
+			if env.Name == "MYSQL_ROOT_PASSWORD" {
+				pwd.Value, pwd.ValueFrom = env.Value, env.ValueFrom
+				return pwd
+			}
+		}
+	}
+	return pwd
+}
+
+func (r *WebServiceReconciler) dumpLegacyDatabase(ctx context.Context, app *appv1.WebService, legacy *appsv1.Deployment) (bool, error) {
+	name := app.Name + "-mysql-migrate-dump"
+	if err := r.Create(ctx, r.dumpPVC(app, migrationPVCName(app), "migration")); err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, job)
+	if errors.IsNotFound(err) {
+		job = r.mysqlClientJob(app, name, "migration", migrationPVCName(app), legacyDumpScript)
+		setEnvVar(&job.Spec.Template.Spec.Containers[0], legacyRootPassword(legacy))
+		if err := r.Create(ctx, job); err != nil {
+			return false, err
+		}
+	} else if err != nil {
+		return false, err
+	}
+
+	switch {
+	case job.Status.Succeeded > 0:
+		return true, nil
+	case jobFailed(job):
+		return false, r.setStorageMigrated(ctx, app, metav1.ConditionFalse, reasonMigrationFailed,
+			fmt.Sprintf("Job %s failed to dump the MySQL Deployment, delete the Job to retry", name))
+	default:
+		return false, r.setStorageMigrated(ctx, app, metav1.ConditionFalse, reasonDumpingLegacyDatabase,
+			fmt.Sprintf("Dumping the MySQL Deployment into PVC %s", migrationPVCName(app)))
+	}
+}
+
+// restoreMysqlDump runs phase 2 against the ready StatefulSet and reports
+// whether the migration, if any, has finished.
+func (r *WebServiceReconciler) restoreMysqlDump(ctx context.Context, app *appv1.WebService) (bool, error) {
+	dump := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: app.Name + "-mysql-migrate-dump", Namespace: app.Namespace}, dump)
+	if errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if dump.DeletionTimestamp != nil || dump.Status.Succeeded == 0 {
+		return true, nil
+	}
+
+	name := app.Name + "-mysql-migrate-restore"
+	job := &batchv1.Job{}
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, job)
+	if errors.IsNotFound(err) {
+		job = r.mysqlClientJob(app, name, "migration", migrationPVCName(app),
+			`mysql -h "$DB_HOST" -P "$DB_PORT" -uroot < `+legacyDumpFile)
+		if err := r.Create(ctx, job); err != nil {
+			return false, err
+		}
+	} else if err != nil {
+		return false, err
+	}
+
+	switch {
+	case job.Status.Succeeded > 0:
+		// Removing the dump Job ends the migration, the restore must not
+		// run a second time.
+		if err := r.Delete(ctx, dump, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+		return true, r.setStorageMigrated(ctx, app, metav1.ConditionTrue, reasonMigrated,
+			fmt.Sprintf("Data restored into the StatefulSet, PVC %s can be deleted", migrationPVCName(app)))
+	case jobFailed(job):
+		return false, r.setStorageMigrated(ctx, app, metav1.ConditionFalse, reasonMigrationFailed,
+			fmt.Sprintf("Job %s failed to restore the dump, delete the Job to retry", name))
+	default:
+		return false, r.setStorageMigrated(ctx, app, metav1.ConditionFalse, reasonRestoringDatabase,
+			fmt.Sprintf("Restoring the dump from PVC %s", migrationPVCName(app)))
+	}
+}
+
+func (r *WebServiceReconciler) setStorageMigrated(ctx context.Context, app *appv1.WebService, status metav1.ConditionStatus, reason, message string) error {
+	patch := client.MergeFrom(app.DeepCopy())
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               appv1.ConditionStorageMigrated,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.Generation,
+	})
+	return r.Status().Patch(ctx, app, patch)
+}
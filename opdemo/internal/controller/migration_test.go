@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+// baselineMysqlDeployment is the MySQL Deployment of the first release,
+// with its pod running.
+func baselineMysqlDeployment(r *WebServiceReconciler, app *appv1.WebService) *appsv1.Deployment {
+	labels := labels(app, "mysql")
+	credential := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "mysql-auth"}, Key: key}}
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: app.Name + "-mysql", Namespace: app.Namespace},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:  "mysql",
+					Image: "mysql:5.7",
+					Env: []corev1.EnvVar{
+						{Name: "MYSQL_ROOT_PASSWORD", Value: "password"},
+						{Name: "MYSQL_DATABASE", Value: "webservice"},
+						{Name: "MYSQL_USER", ValueFrom: credential("username")},
+						{Name: "MYSQL_PASSWORD", ValueFrom: credential("password")},
+					},
+				}}},
+			},
+		},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	controllerutil.SetControllerReference(app, deploy, r.Scheme)
+	return deploy
+}
+
+func jobEnv(job *batchv1.Job, name string) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	for _, e := range job.Spec.Template.Spec.Containers[0].Env {
+		if e.Name == name {
+			env = append(env, e)
+		}
+	}
+	return env
+}
+
+func TestMigrateBaselineMysqlDeployment(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	r := newFakeReconciler(t, app)
+	legacy := baselineMysqlDeployment(r, app)
+	g.Expect(r.Create(ctx, legacy)).To(Succeed())
+
+	// Phase 1 dumps with the root password the Deployment runs with.
+	migrated, err := r.migrateMysqlDeployment(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(migrated).To(BeFalse())
+	dump := &batchv1.Job{}
+	g.Expect(r.Get(ctx, key(app, "shop-mysql-migrate-dump"), dump)).To(Succeed())
+	g.Expect(jobEnv(dump, "MYSQL_PWD")).To(Equal([]corev1.EnvVar{{Name: "MYSQL_PWD", Value: "password"}}))
+	g.Expect(r.Get(ctx, key(app, legacy.Name), &appsv1.Deployment{})).To(Succeed())
+
+	// The finished dump lets the Deployment go.
+	dump.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, dump)).To(Succeed())
+	migrated, err = r.migrateMysqlDeployment(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(migrated).To(BeFalse())
+	err = r.Get(ctx, key(app, legacy.Name), &appsv1.Deployment{})
+	g.Expect(errors.IsNotFound(err)).To(BeTrue(), "legacy Deployment still exists: %v", err)
+
+	migrated, err = r.migrateMysqlDeployment(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(migrated).To(BeTrue())
+
+	// Phase 2 loads the dump into the StatefulSet, which runs with the
+	// generated credentials.
+	restored, err := r.restoreMysqlDump(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(restored).To(BeFalse())
+	restore := &batchv1.Job{}
+	g.Expect(r.Get(ctx, key(app, "shop-mysql-migrate-restore"), restore)).To(Succeed())
+	g.Expect(jobEnv(restore, "MYSQL_PWD")).To(Equal([]corev1.EnvVar{
+		{Name: "MYSQL_PWD", ValueFrom: databaseSecretKeyRef(app, secretKeyRootPassword)},
+	}))
+
+	restore.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, restore)).To(Succeed())
+	restored, err = r.restoreMysqlDump(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(restored).To(BeTrue())
+	g.Expect(r.Get(ctx, key(app, app.Name), app)).To(Succeed())
+	g.Expect(app.Status.Conditions).To(ContainElement(And(
+		HaveField("Type", appv1.ConditionStorageMigrated),
+		HaveField("Status", metav1.ConditionTrue),
+	)))
+}
+
+func TestMigrateSkipsADeploymentWithoutReadyPods(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	r := newFakeReconciler(t, app)
+	legacy := baselineMysqlDeployment(r, app)
+	legacy.Status.ReadyReplicas = 0
+	g.Expect(r.Create(ctx, legacy)).To(Succeed())
+
+	_, err := r.migrateMysqlDeployment(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(errors.IsNotFound(r.Get(ctx, key(app, "shop-mysql-migrate-dump"), &batchv1.Job{}))).To(BeTrue())
+	g.Expect(errors.IsNotFound(r.Get(ctx, key(app, legacy.Name), &appsv1.Deployment{}))).To(BeTrue())
+}
+
+func TestMigrateDeploymentWithGeneratedCredentials(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	r := newFakeReconciler(t, app)
+	// A Deployment switched to the generated Secret keeps it until dumped.
+	legacy := baselineMysqlDeployment(r, app)
+	rootPassword := databaseSecretKeyRef(app, secretKeyRootPassword)
+	legacy.Spec.Template.Spec.Containers[0].Env[0] = corev1.EnvVar{Name: "MYSQL_ROOT_PASSWORD", ValueFrom: rootPassword}
+	g.Expect(r.Create(ctx, legacy)).To(Succeed())
+
+	_, err := r.migrateMysqlDeployment(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	dump := &batchv1.Job{}
+	g.Expect(r.Get(ctx, key(app, "shop-mysql-migrate-dump"), dump)).To(Succeed())
+	g.Expect(jobEnv(dump, "MYSQL_PWD")).To(Equal([]corev1.EnvVar{{Name: "MYSQL_PWD", ValueFrom: rootPassword}}))
+
+	// The accounts of the Deployment must not replace those of the
+	// StatefulSet.
+	script := dump.Spec.Template.Spec.Containers[0].Command[2]
+	g.Expect(script).NotTo(ContainSubstring("--all-databases"))
+	g.Expect(script).To(ContainSubstring("grep -Ev '^(mysql|information_schema|performance_schema|sys)$'"))
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"fmt"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	mysqlDataPath    = "/var/lib/mysql"
+	defaultMysqlPort = 3306
+)
+
+// mysqlProbes ping the server over TCP, which it only listens on once the
+// data directory is initialized. mysqladmin ping needs no password and is
+// in the MariaDB image as well.
+var mysqlProbes = databaseProbes("mysqladmin", "ping", "-h", "127.0.0.1")
+
+// mysqldCommand starts every pod with GTIDs and a binary log so any of them
+// can act as a replication source. The server id is the pod ordinal plus
+// one, 0 is not allowed on a replica.
+const mysqldCommand = `exec docker-entrypoint.sh mysqld --server-id=$((${HOSTNAME##*-} + 1)) ` +
+	`--log-bin=mysql-bin --log-slave-updates=ON --gtid-mode=ON --enforce-gtid-consistency=ON`
+
+// mysqlProvider runs MySQL with GTID read replicas.
+type mysqlProvider struct{}
+
+func (mysqlProvider) Component() string { return "mysql" }
+
+func (mysqlProvider) Image(app *appv1.WebService, cfg *config.OperatorConfig) string {
+	return cfg.Image(config.ComponentMysql, app.Spec.DatabaseSpec().Image)
+}
+
+func (mysqlProvider) DefaultPort() int32 { return defaultMysqlPort }
+
+func (p mysqlProvider) StatefulSet(app *appv1.WebService, cfg *config.OperatorConfig) *appsv1.StatefulSet {
+	// One primary plus the read replicas.
+	return newDatabaseStatefulSet(app, cfg, 1+mysqlReadReplicas(app), corev1.Container{
+		Image:     p.Image(app, cfg),
+		Command:   []string{"sh", "-c", mysqldCommand},
+		Resources: cfg.ResourcesFor(config.ComponentMysql, app.Spec.DatabaseSpec().Resources),
+		Env:       mysqlServerEnv(app, cfg, "MYSQL_"),
+	}, mysqlDataPath, mysqlProbes)
+}
+
+// Services are the headless Service, the write Service, which only
+// selects the primary, and the read Service. Empty spec.database.ports
+// default to 3306, for MariaDB as well.
+func (mysqlProvider) Services(app *appv1.WebService, cfg *config.OperatorConfig) []*corev1.Service {
+	ports := app.Spec.DatabaseSpec().Ports
+	if len(ports) == 0 {
+		ports = []corev1.ServicePort{{
+			Name:       "mysql",
+			Port:       defaultMysqlPort,
+			TargetPort: intstr.FromInt(defaultMysqlPort),
+		}}
+	}
+	return []*corev1.Service{
+		newDatabaseHeadlessService(app, cfg),
+		newDatabaseClusterService(app, cfg, databaseServiceName(app), primarySelector(app), ports),
+		newDatabaseClusterService(app, cfg, mysqlReadServiceName(app), mysqlReadSelector(app), ports),
+	}
+}
+
+// mysqlReadSelector selects the replicas that are replicating. Without
+// replicas it falls back to the primary, so readers always have a target.
+func mysqlReadSelector(app *appv1.WebService) map[string]string {
+	if mysqlReadReplicas(app) == 0 {
+		return primarySelector(app)
+	}
+	selector := databaseLabels(app)
+	selector[mysqlRoleLabel] = mysqlRoleReplica
+	return selector
+}
+
+func (mysqlProvider) SecretData(app *appv1.WebService, current map[string][]byte) (map[string][]byte, error) {
+	return generateSecretData(current, secretKeyRootPassword, secretKeyPassword, secretKeyReplicationPassword)
+}
+
+func (mysqlProvider) SecretKeys(app *appv1.WebService) []string {
+	keys := []string{secretKeyRootPassword, secretKeyUsername, secretKeyPassword}
+	if mysqlReadReplicas(app) > 0 {
+		keys = append(keys, secretKeyReplicationPassword)
+	}
+	return keys
+}
+
+func (mysqlProvider) Ready(pod *corev1.Pod) bool { return podReady(pod) }
+
+func (mysqlProvider) BindingType() string { return "mysql" }
+
+func (mysqlProvider) Validate(app *appv1.WebService) error { return nil }
+
+// mysqlServerEnv configures the official MySQL and MariaDB images, prefix
+// is the variable prefix of the image.
+func mysqlServerEnv(app *appv1.WebService, cfg *config.OperatorConfig, prefix string) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: prefix + "ROOT_PASSWORD", ValueFrom: databaseSecretKeyRef(app, secretKeyRootPassword)},
+		{Name: prefix + "DATABASE", Value: cfg.Mysql.Database},
+		{Name: prefix + "USER", ValueFrom: databaseSecretKeyRef(app, secretKeyUsername)},
+		{Name: prefix + "PASSWORD", ValueFrom: databaseSecretKeyRef(app, secretKeyPassword)},
+	}
+}
+
+// mariadbProvider runs a single MariaDB server. It shares the Services and
+// client Jobs of MySQL; replication and backups rely on MySQL specific
+// options and are not available.
+type mariadbProvider struct {
+	mysqlProvider
+}
+
+func (mariadbProvider) Component() string { return "mariadb" }
+
+func (mariadbProvider) Image(app *appv1.WebService, cfg *config.OperatorConfig) string {
+	return cfg.Image(config.ComponentMariadb, app.Spec.DatabaseSpec().Image)
+}
+
+func (p mariadbProvider) StatefulSet(app *appv1.WebService, cfg *config.OperatorConfig) *appsv1.StatefulSet {
+	return newDatabaseStatefulSet(app, cfg, 1, corev1.Container{
+		Image:     p.Image(app, cfg),
+		Resources: cfg.ResourcesFor(config.ComponentMariadb, app.Spec.DatabaseSpec().Resources),
+		Env:       mysqlServerEnv(app, cfg, "MARIADB_"),
+	}, mysqlDataPath, mysqlProbes)
+}
+
+func (mariadbProvider) SecretData(app *appv1.WebService, current map[string][]byte) (map[string][]byte, error) {
+	return generateSecretData(current, secretKeyRootPassword, secretKeyPassword)
+}
+
+func (mariadbProvider) SecretKeys(app *appv1.WebService) []string {
+	return []string{secretKeyRootPassword, secretKeyUsername, secretKeyPassword}
+}
+
+func (mariadbProvider) BindingType() string { return "mariadb" }
+
+func (mariadbProvider) Validate(app *appv1.WebService) error {
+	if mysqlReadReplicas(app) > 0 {
+		return fmt.Errorf("engine MariaDB does not support read replicas")
+	}
+	if app.Spec.DatabaseSpec().Backup != nil {
+		return fmt.Errorf("engine MariaDB does not support spec.database.backup")
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/naming"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Every child of a WebService is named here, after spec.nameOverride or
+// the WebService name followed by the component:
+//
+//	<base>-<component>               Deployment, Service, HPA, Ingress, HTTPRoute, NetworkPolicy
+//	<base>-<engine>                  database StatefulSet, Service and NetworkPolicy
+//	<base>-<engine>-headless|read    further database Services
+//	<base>-<engine>-auth             generated credentials Secret
+//	<base>-db-binding                binding Secret
+//
+// WebServices created before this scheme keep the names their children
+// already have, see adoptNamingScheme.
+
+// namingSchemeAnnotation records the naming scheme of a WebService. It is
+// set on the first reconcile and never changed, renaming a Service would
+// change the host clients connect to.
+const namingSchemeAnnotation = "app.enflame.cn/naming-scheme"
+
+const (
+	// namingSchemeLegacy names the webapp Deployment after the WebService,
+	// the webapp Service after spec.webapp.name and the database Services
+	// after spec.database.name.
+	namingSchemeLegacy = "legacy"
+	namingSchemeV1     = "v1"
+)
+
+func legacyNames(app *appv1.WebService) bool {
+	return app.Annotations[namingSchemeAnnotation] == namingSchemeLegacy
+}
+
+// nameBase starts the name of every child of app.
+func nameBase(app *appv1.WebService) string {
+	if app.Spec.NameOverride != "" {
+		return app.Spec.NameOverride
+	}
+	return app.Name
+}
+
+// childName is the name of a child of app, a valid DNS label.
+func childName(app *appv1.WebService, parts ...string) string {
+	return naming.Join(naming.MaxLength, append([]string{nameBase(app)}, parts...)...)
+}
+
+// reservedComponentName reports whether a component named name would share
+// the names of database children, "<base>-<engine>" and its headless and
+// read Services. The CRD refuses these names, WebServices stored before
+// that are refused here.
+func reservedComponentName(name string) bool {
+	for _, p := range databaseProviders {
+		switch name {
+		case p.Component(), p.Component() + "-headless", p.Component() + "-read":
+			return true
+		}
+	}
+	return false
+}
+
+// newComponent names the children of spec, spec.webapp or an entry of
+// spec.components.
+func newComponent(app *appv1.WebService, spec *appv1.WebServiceWebappSpec) component {
+	name := childName(app, spec.Name)
+	c := component{WebServiceWebappSpec: spec, deployment: name, service: name, pods: name}
+	if legacyNames(app) {
+		// The pod label is part of the immutable Deployment selector.
+		c.pods = app.Name + "-" + spec.Name
+		if spec == app.Spec.Webapp {
+			c.deployment, c.service = app.Name, spec.Name
+		}
+	}
+	return c
+}
+
+func databaseStatefulSetName(app *appv1.WebService) string {
+	return naming.Join(naming.MaxStatefulSetLength, nameBase(app), databaseProvider(app).Component())
+}
+
+func databasePodName(app *appv1.WebService, ordinal int32) string {
+	return fmt.Sprintf("%s-%d", databaseStatefulSetName(app), ordinal)
+}
+
+func databaseHeadlessServiceName(app *appv1.WebService) string {
+	return childName(app, databaseProvider(app).Component(), "headless")
+}
+
+// databaseServiceName is the Service clients connect to, the host of the
+// binding.
+func databaseServiceName(app *appv1.WebService) string {
+	if db := app.Spec.DatabaseSpec(); legacyNames(app) && db != nil {
+		return db.Name
+	}
+	return childName(app, databaseProvider(app).Component())
+}
+
+// mysqlReadServiceName is the Service in front of the read replicas.
+func mysqlReadServiceName(app *appv1.WebService) string {
+	if db := app.Spec.DatabaseSpec(); legacyNames(app) && db != nil {
+		return db.Name + "-read"
+	}
+	return childName(app, databaseProvider(app).Component(), "read")
+}
+
+// generatedSecretName is the credentials Secret the operator creates when
+// no existing Secret is referenced.
+func generatedSecretName(app *appv1.WebService) string {
+	return childName(app, databaseProvider(app).Component(), "auth")
+}
+
+func bindingSecretName(app *appv1.WebService) string {
+	return childName(app, "db-binding")
+}
+
+// adoptNamingScheme decides on the first reconcile of app how its children
+// are named. When children from before the naming scheme exist under their
+// old names the WebService keeps these names, otherwise it uses the
+// current scheme.
+func (r *WebServiceReconciler) adoptNamingScheme(ctx context.Context, app *appv1.WebService) error {
+	if _, ok := app.Annotations[namingSchemeAnnotation]; ok {
+		return nil
+	}
+
+	scheme := namingSchemeV1
+	legacy := []client.Object{}
+	if db := app.Spec.DatabaseSpec(); db != nil && db.Name != "" {
+		legacy = append(legacy, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: db.Name}})
+	}
+	if app.Spec.Webapp != nil {
+		legacy = append(legacy,
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: app.Spec.Webapp.Name}})
+	}
+	for _, obj := range legacy {
+		err := r.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: app.Namespace}, obj)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err == nil && metav1.IsControlledBy(obj, app) {
+			scheme = namingSchemeLegacy
+			break
+		}
+	}
+
+	r.Log.Info("Adopting naming scheme", "webservice", client.ObjectKeyFromObject(app), "scheme", scheme)
+	patch := client.MergeFrom(app.DeepCopy())
+	if app.Annotations == nil {
+		app.Annotations = map[string]string{}
+	}
+	app.Annotations[namingSchemeAnnotation] = scheme
+	return r.Patch(ctx, app, patch)
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// webServicePodLabels select every pod labelled by labels for app, whatever
+// the tier: the database pods, which replicate from each other, and the
+// client Jobs.
+func webServicePodLabels(app *appv1.WebService) map[string]string {
+	selector := labels(app, "")
+	delete(selector, "tier")
+	return selector
+}
+
+func webServiceControllerRef(app *appv1.WebService) metav1.OwnerReference {
+	return *metav1.NewControllerRef(app, schema.GroupVersionKind{
+		Group:   appv1.GroupVersion.Group,
+		Version: appv1.GroupVersion.Version,
+		Kind:    "WebService",
+	})
+}
+
+// NewDatabaseNetworkPolicy only lets the components of app, the pods of
+// its own tiers and the operator reach the database port.
+func NewDatabaseNetworkPolicy(app *appv1.WebService, cfg *config.OperatorConfig) *networkingv1.NetworkPolicy {
+	port := intstr.FromInt(int(databaseContainerPort(app)))
+	from := []networkingv1.NetworkPolicyPeer{
+		{PodSelector: &metav1.LabelSelector{MatchLabels: webServicePodLabels(app)}},
+		{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{corev1.LabelMetadataName: cfg.Operator.Namespace},
+			},
+			PodSelector: &metav1.LabelSelector{MatchLabels: cfg.Operator.PodLabels},
+		},
+	}
+	for _, c := range webServiceComponents(app) {
+		from = append(from, networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{MatchLabels: c.podLabels(app)},
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            databaseStatefulSetName(app),
+			Namespace:       app.Namespace,
+			Labels:          cfg.WithLabels(databaseLabels(app)),
+			Annotations:     cfg.WithAnnotations(nil),
+			OwnerReferences: []metav1.OwnerReference{webServiceControllerRef(app)},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: databaseLabels(app)},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{{Port: &port}},
+				From:  from,
+			}},
+		},
+	}
+}
+
+// NewComponentNetworkPolicy admits traffic from anywhere to the target
+// ports of component c, and nothing else. A component without ports
+// accepts no connections at all.
+func NewComponentNetworkPolicy(app *appv1.WebService, cfg *config.OperatorConfig, c component) *networkingv1.NetworkPolicy {
+	var ingress []networkingv1.NetworkPolicyIngressRule
+	if len(c.Ports) > 0 {
+		ports := make([]networkingv1.NetworkPolicyPort, 0, len(c.Ports))
+		for _, p := range c.Ports {
+			port := p.TargetPort
+			if port.IntVal == 0 && port.StrVal == "" {
+				port = intstr.FromInt(int(p.Port))
+			}
+			protocol := p.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			ports = append(ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port})
+		}
+		ingress = []networkingv1.NetworkPolicyIngressRule{{Ports: ports}}
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            c.deployment,
+			Namespace:       app.Namespace,
+			Labels:          cfg.WithLabels(c.objectLabels(app)),
+			Annotations:     cfg.WithAnnotations(nil),
+			OwnerReferences: []metav1.OwnerReference{webServiceControllerRef(app)},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: c.podLabels(app)},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     ingress,
+		},
+	}
+}
+
+// reconcileDatabaseNetworkPolicy applies the NetworkPolicy of the database,
+// or deletes it when spec.networkPolicy is disabled.
+func (r *WebServiceReconciler) reconcileDatabaseNetworkPolicy(ctx context.Context, app *appv1.WebService) error {
+	if !app.Spec.NetworkPolicyEnabled() {
+		key := types.NamespacedName{Name: databaseStatefulSetName(app), Namespace: app.Namespace}
+		return r.deleteOwned(ctx, app, key, &networkingv1.NetworkPolicy{})
+	}
+	return r.apply(ctx, NewDatabaseNetworkPolicy(app, r.Config.Get()))
+}
+
+// reconcileComponentNetworkPolicy is reconcileDatabaseNetworkPolicy for
+// component c.
+func (r *WebServiceReconciler) reconcileComponentNetworkPolicy(ctx context.Context, app *appv1.WebService, c component) error {
+	if !app.Spec.NetworkPolicyEnabled() {
+		key := types.NamespacedName{Name: c.deployment, Namespace: app.Namespace}
+		return r.deleteOwned(ctx, app, key, &networkingv1.NetworkPolicy{})
+	}
+	return r.apply(ctx, NewComponentNetworkPolicy(app, r.Config.Get(), c))
+}
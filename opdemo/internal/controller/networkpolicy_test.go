@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+func TestNetworkPolicies(t *testing.T) {
+	disabled := false
+	api := componentSpec("api")
+	api.Ports = []corev1.ServicePort{{Port: 8080, TargetPort: intstr.FromString("http")}}
+	for name, tc := range map[string]struct {
+		mutate func(*appv1.WebService)
+		// clients are the components admitted to the database.
+		clients []string
+		// ports are the ports each component accepts connections on.
+		ports   map[string][]intstr.IntOrString
+		deleted bool
+	}{
+		"default": {
+			mutate:  func(app *appv1.WebService) {},
+			clients: []string{"web"},
+			ports:   map[string][]intstr.IntOrString{"web": {intstr.FromInt(80)}},
+		},
+		"components": {
+			mutate: func(app *appv1.WebService) {
+				withComponents(app, api, componentSpec("worker"))
+			},
+			clients: []string{"web", "api", "worker"},
+			ports: map[string][]intstr.IntOrString{
+				"web":    {intstr.FromInt(80)},
+				"api":    {intstr.FromString("http")},
+				"worker": nil,
+			},
+		},
+		"disabled": {
+			mutate: func(app *appv1.WebService) {
+				app.Spec.NetworkPolicy = &appv1.NetworkPolicySpec{Enabled: &disabled}
+			},
+			deleted: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			app := testWebService("shop")
+			r := newFakeReconciler(t, app)
+			reconcile := func(app *appv1.WebService) {
+				g.Expect(r.reconcileDatabaseNetworkPolicy(ctx, app)).To(Succeed())
+				for _, c := range webServiceComponents(app) {
+					g.Expect(r.reconcileComponentNetworkPolicy(ctx, app, c)).To(Succeed())
+				}
+			}
+			// The policies of an earlier reconcile, which the current one
+			// updates or deletes.
+			reconcile(app)
+
+			tc.mutate(app)
+			reconcile(app)
+			db := &networkingv1.NetworkPolicy{}
+			err := r.Get(ctx, key(app, databaseStatefulSetName(app)), db)
+			if tc.deleted {
+				g.Expect(errors.IsNotFound(err)).To(BeTrue())
+				for _, c := range webServiceComponents(app) {
+					g.Expect(errors.IsNotFound(r.Get(ctx, key(app, c.deployment), &networkingv1.NetworkPolicy{}))).To(BeTrue(), c.Name)
+				}
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(db.Spec.PodSelector.MatchLabels).To(Equal(databaseLabels(app)))
+			g.Expect(db.Spec.Ingress).To(HaveLen(1))
+			from := db.Spec.Ingress[0].From
+			g.Expect(from).To(ContainElement(HaveField("PodSelector", Equal(&metav1.LabelSelector{MatchLabels: webServicePodLabels(app)}))), "the own pods and Jobs")
+			g.Expect(from).To(ContainElement(HaveField("NamespaceSelector", Not(BeNil()))), "the operator")
+			g.Expect(from).To(HaveLen(2 + len(tc.clients)))
+
+			for _, c := range webServiceComponents(app) {
+				g.Expect(from).To(ContainElement(HaveField("PodSelector", Equal(&metav1.LabelSelector{MatchLabels: c.podLabels(app)}))), c.Name)
+				policy := &networkingv1.NetworkPolicy{}
+				g.Expect(r.Get(ctx, key(app, c.deployment), policy)).To(Succeed())
+				g.Expect(policy.Spec.PodSelector.MatchLabels).To(Equal(c.podLabels(app)))
+				var ports []intstr.IntOrString
+				for _, rule := range policy.Spec.Ingress {
+					g.Expect(rule.From).To(BeEmpty(), "%s accepts every client on its ports", c.Name)
+					for _, p := range rule.Ports {
+						ports = append(ports, *p.Port)
+					}
+				}
+				g.Expect(ports).To(Equal(tc.ports[c.Name]), c.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// databaseUser is the uid and gid of the server user in the official MySQL,
+// MariaDB and PostgreSQL images.
+const databaseUser int64 = 999
+
+// podSecurityEnforceLabel sets the Pod Security Standard a namespace
+// enforces: privileged, baseline or restricted.
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+const (
+	podSecurityBaseline   = "baseline"
+	podSecurityRestricted = "restricted"
+)
+
+// restrictedPodSecurityContext is the pod part of the restricted Pod
+// Security Standard. With user the pod runs as that uid and gid, and
+// volumes are group owned by it; without, the image user is kept and must
+// not be root.
+func restrictedPodSecurityContext(user *int64) *corev1.PodSecurityContext {
+	runAsNonRoot := true
+	sc := &corev1.PodSecurityContext{
+		RunAsNonRoot:   &runAsNonRoot,
+		SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	if user != nil {
+		// Only a fresh volume is chowned, not every file on each start.
+		changePolicy := corev1.FSGroupChangeOnRootMismatch
+		sc.RunAsUser, sc.RunAsGroup, sc.FSGroup = user, user, user
+		sc.FSGroupChangePolicy = &changePolicy
+	}
+	return sc
+}
+
+// restrictedSecurityContext is the container part of the restricted Pod
+// Security Standard.
+func restrictedSecurityContext() *corev1.SecurityContext {
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+}
+
+// overrideSecurityContext merges override into defaults, fields set in
+// override win.
+func overrideSecurityContext[T corev1.PodSecurityContext | corev1.SecurityContext](defaults, override *T) *T {
+	if override == nil {
+		return defaults
+	}
+	original, err := json.Marshal(defaults)
+	if err != nil {
+		return override
+	}
+	patch, err := json.Marshal(override)
+	if err != nil {
+		return override
+	}
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, *new(T))
+	if err != nil {
+		return override
+	}
+	sc := new(T)
+	if err := json.Unmarshal(merged, sc); err != nil {
+		return override
+	}
+	return sc
+}
+
+// componentSecurityContexts are the pod and container security contexts
+// of c.
+func componentSecurityContexts(c component) (*corev1.PodSecurityContext, *corev1.SecurityContext) {
+	return overrideSecurityContext(restrictedPodSecurityContext(nil), c.PodSecurityContext),
+		overrideSecurityContext(restrictedSecurityContext(), c.SecurityContext)
+}
+
+// databaseSecurityContexts are the pod and container security contexts of
+// the database server.
+func databaseSecurityContexts(app *appv1.WebService) (*corev1.PodSecurityContext, *corev1.SecurityContext) {
+	user := databaseUser
+	pod, container := restrictedPodSecurityContext(&user), restrictedSecurityContext()
+	if spec := app.Spec.DatabaseSpec(); spec != nil {
+		pod = overrideSecurityContext(pod, spec.PodSecurityContext)
+		container = overrideSecurityContext(container, spec.SecurityContext)
+	}
+	return pod, container
+}
+
+// podSecurityCondition checks the pods of app against the level enforced
+// on its namespace. Admission rejects violating pods, so the condition
+// names the fields responsible.
+func (r *WebServiceReconciler) podSecurityCondition(ctx context.Context, app *appv1.WebService) (metav1.Condition, error) {
+	cond := metav1.Condition{
+		Type:               appv1.ConditionPodSecurityCompliant,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: app.Generation,
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: app.Namespace}, ns); err != nil {
+		return cond, err
+	}
+	level := ns.Labels[podSecurityEnforceLabel]
+	if level != podSecurityBaseline && level != podSecurityRestricted {
+		cond.Reason = "NotEnforced"
+		cond.Message = fmt.Sprintf("Namespace %s enforces no Pod Security level", app.Namespace)
+		return cond, nil
+	}
+
+	cfg := r.Config.Get()
+	var violations []string
+	for _, c := range webServiceComponents(app) {
+		template := NewDeploy(app, cfg, c).Spec.Template
+		for _, v := range podSecurityViolations(level, &template.Spec) {
+			violations = append(violations, "component "+c.Name+": "+v)
+		}
+	}
+	template := r.databaseStatefulSet(app).Spec.Template
+	for _, v := range podSecurityViolations(level, &template.Spec) {
+		violations = append(violations, "database: "+v)
+	}
+
+	if len(violations) == 0 {
+		cond.Reason = "Compliant"
+		cond.Message = fmt.Sprintf("Pods satisfy the %s Pod Security Standard", level)
+		return cond, nil
+	}
+	r.Log.Info("Pods violate the Pod Security level of the namespace", "webservice", app.Name,
+		"level", level, "violations", violations)
+	cond.Status = metav1.ConditionFalse
+	cond.Reason = "Violation"
+	cond.Message = fmt.Sprintf("Namespace %s enforces %s, pods will be rejected: %s",
+		app.Namespace, level, strings.Join(violations, "; "))
+	return cond, nil
+}
+
+// baselineCapabilities may be added under the baseline level.
+var baselineCapabilities = []corev1.Capability{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// restrictedVolumeType reports whether v is of a type the restricted level
+// allows.
+func restrictedVolumeType(v corev1.Volume) bool {
+	s := v.VolumeSource
+	return s.ConfigMap != nil || s.CSI != nil || s.DownwardAPI != nil || s.EmptyDir != nil ||
+		s.Ephemeral != nil || s.PersistentVolumeClaim != nil || s.Projected != nil || s.Secret != nil
+}
+
+// podSecurityViolations lists how spec breaks level. It covers the
+// controls of the Pod Security Standards that a securityContext, a volume
+// or a container of an override can fail, not the AppArmor, SELinux,
+// procMount and sysctl ones.
+func podSecurityViolations(level string, spec *corev1.PodSpec) []string {
+	var violations []string
+	if spec.HostNetwork || spec.HostPID || spec.HostIPC {
+		violations = append(violations, "host namespaces must not be shared")
+	}
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			violations = append(violations, "volume "+v.Name+": hostPath is not allowed")
+		} else if level == podSecurityRestricted && !restrictedVolumeType(v) {
+			violations = append(violations, "volume "+v.Name+": volume type is not allowed")
+		}
+	}
+
+	pod := spec.SecurityContext
+	if pod == nil {
+		pod = &corev1.PodSecurityContext{}
+	}
+	containers := append(append([]corev1.Container(nil), spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		sc := c.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		prefix := "container " + c.Name + ": "
+		if sc.Privileged != nil && *sc.Privileged {
+			violations = append(violations, prefix+"privileged is not allowed")
+		}
+		for _, port := range c.Ports {
+			if port.HostPort != 0 {
+				violations = append(violations, prefix+"hostPort is not allowed")
+				break
+			}
+		}
+		seccomp := sc.SeccompProfile
+		if seccomp == nil {
+			seccomp = pod.SeccompProfile
+		}
+		if seccomp != nil && seccomp.Type == corev1.SeccompProfileTypeUnconfined {
+			violations = append(violations, prefix+"seccompProfile must not be Unconfined")
+		}
+		var added []corev1.Capability
+		if sc.Capabilities != nil {
+			added = sc.Capabilities.Add
+		}
+		for _, capability := range added {
+			if (level == podSecurityRestricted && capability != "NET_BIND_SERVICE") ||
+				!contains(baselineCapabilities, capability) {
+				violations = append(violations, prefix+"capability "+string(capability)+" must not be added")
+			}
+		}
+		if level != podSecurityRestricted {
+			continue
+		}
+
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violations = append(violations, prefix+"allowPrivilegeEscalation must be false")
+		}
+		runAsNonRoot := sc.RunAsNonRoot
+		if runAsNonRoot == nil {
+			runAsNonRoot = pod.RunAsNonRoot
+		}
+		if runAsNonRoot == nil || !*runAsNonRoot {
+			violations = append(violations, prefix+"runAsNonRoot must be true")
+		}
+		runAsUser := sc.RunAsUser
+		if runAsUser == nil {
+			runAsUser = pod.RunAsUser
+		}
+		if runAsUser != nil && *runAsUser == 0 {
+			violations = append(violations, prefix+"runAsUser must not be 0")
+		}
+		if seccomp == nil {
+			violations = append(violations, prefix+"seccompProfile must be RuntimeDefault or Localhost")
+		}
+		if sc.Capabilities == nil || !contains(sc.Capabilities.Drop, "ALL") {
+			violations = append(violations, prefix+"capabilities must drop ALL")
+		}
+	}
+	return violations
+}
+
+// contains reports whether values holds value.
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSecurityContexts(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+
+	pod, container := componentSecurityContexts(primaryComponent(app))
+	g.Expect(pod.RunAsNonRoot).To(HaveValue(BeTrue()))
+	g.Expect(pod.RunAsUser).To(BeNil(), "components keep the image user")
+	g.Expect(pod.SeccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+	g.Expect(container.AllowPrivilegeEscalation).To(HaveValue(BeFalse()))
+	g.Expect(container.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+
+	pod, _ = databaseSecurityContexts(app)
+	g.Expect(pod.RunAsUser).To(HaveValue(Equal(databaseUser)))
+	g.Expect(pod.FSGroup).To(HaveValue(Equal(databaseUser)))
+	g.Expect(pod.FSGroupChangePolicy).To(HaveValue(Equal(corev1.FSGroupChangeOnRootMismatch)))
+
+	// Overrides are merged field by field.
+	user := int64(1000)
+	app.Spec.Webapp.PodSecurityContext = &corev1.PodSecurityContext{RunAsUser: &user}
+	app.Spec.Webapp.SecurityContext = &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_BIND_SERVICE"}}}
+	pod, container = componentSecurityContexts(primaryComponent(app))
+	g.Expect(pod.RunAsUser).To(HaveValue(Equal(user)))
+	g.Expect(pod.RunAsNonRoot).To(HaveValue(BeTrue()))
+	g.Expect(pod.SeccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+	g.Expect(container.Capabilities.Add).To(ConsistOf(corev1.Capability("NET_BIND_SERVICE")))
+	g.Expect(container.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+	g.Expect(container.AllowPrivilegeEscalation).To(HaveValue(BeFalse()))
+}
+
+func TestPodSecurityViolations(t *testing.T) {
+	yes, no := true, false
+	root := int64(0)
+	restricted := func() corev1.PodSpec {
+		return corev1.PodSpec{
+			SecurityContext: restrictedPodSecurityContext(nil),
+			Containers:      []corev1.Container{{Name: "web", SecurityContext: restrictedSecurityContext()}},
+		}
+	}
+	for name, tc := range map[string]struct {
+		level      string
+		mutate     func(*corev1.PodSpec)
+		violations []string
+	}{
+		"restricted defaults": {level: podSecurityRestricted, mutate: func(*corev1.PodSpec) {}},
+		"host network": {level: podSecurityBaseline, mutate: func(s *corev1.PodSpec) { s.HostNetwork = true },
+			violations: []string{"host namespaces must not be shared"}},
+		"hostPath": {level: podSecurityBaseline, mutate: func(s *corev1.PodSpec) {
+			s.Volumes = []corev1.Volume{{Name: "logs", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/log"}}}}
+		}, violations: []string{"volume logs: hostPath is not allowed"}},
+		"nfs under baseline": {level: podSecurityBaseline, mutate: func(s *corev1.PodSpec) {
+			s.Volumes = []corev1.Volume{{Name: "share", VolumeSource: corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{Server: "nfs", Path: "/"}}}}
+		}},
+		"nfs under restricted": {level: podSecurityRestricted, mutate: func(s *corev1.PodSpec) {
+			s.Volumes = []corev1.Volume{{Name: "share", VolumeSource: corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{Server: "nfs", Path: "/"}}}}
+		}, violations: []string{"volume share: volume type is not allowed"}},
+		"privileged sidecar": {level: podSecurityBaseline, mutate: func(s *corev1.PodSpec) {
+			s.Containers = append(s.Containers, corev1.Container{Name: "proxy", SecurityContext: &corev1.SecurityContext{Privileged: &yes}})
+		}, violations: []string{"container proxy: privileged is not allowed"}},
+		"host port": {level: podSecurityBaseline, mutate: func(s *corev1.PodSpec) {
+			s.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 80}}
+		}, violations: []string{"container web: hostPort is not allowed"}},
+		"unconfined seccomp": {level: podSecurityBaseline, mutate: func(s *corev1.PodSpec) {
+			s.SecurityContext.SeccompProfile.Type = corev1.SeccompProfileTypeUnconfined
+		}, violations: []string{"container web: seccompProfile must not be Unconfined"}},
+		"baseline capability under restricted": {level: podSecurityRestricted, mutate: func(s *corev1.PodSpec) {
+			s.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"NET_BIND_SERVICE", "CHOWN"}
+		}, violations: []string{"container web: capability CHOWN must not be added"}},
+		"SYS_ADMIN": {level: podSecurityBaseline, mutate: func(s *corev1.PodSpec) {
+			s.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"CHOWN", "SYS_ADMIN"}
+		}, violations: []string{"container web: capability SYS_ADMIN must not be added"}},
+		"root init container": {level: podSecurityRestricted, mutate: func(s *corev1.PodSpec) {
+			sc := restrictedSecurityContext()
+			sc.RunAsUser = &root
+			s.InitContainers = []corev1.Container{{Name: "init", SecurityContext: sc}}
+		}, violations: []string{"container init: runAsUser must not be 0"}},
+		"no security context": {level: podSecurityRestricted, mutate: func(s *corev1.PodSpec) {
+			s.SecurityContext = nil
+			s.Containers[0].SecurityContext = &corev1.SecurityContext{AllowPrivilegeEscalation: &no, RunAsNonRoot: &yes}
+		}, violations: []string{
+			"container web: seccompProfile must be RuntimeDefault or Localhost",
+			"container web: capabilities must drop ALL",
+		}},
+		"no security context under baseline": {level: podSecurityBaseline, mutate: func(s *corev1.PodSpec) {
+			s.SecurityContext = nil
+			s.Containers[0].SecurityContext = nil
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			spec := restricted()
+			tc.mutate(&spec)
+			g.Expect(podSecurityViolations(tc.level, &spec)).To(Equal(tc.violations))
+		})
+	}
+}
+
+func TestPodSecurityCondition(t *testing.T) {
+	for name, tc := range map[string]struct {
+		level   string
+		mutate  func(*corev1.PodSpec)
+		overlay string
+		status  metav1.ConditionStatus
+		reason  string
+		message string
+	}{
+		"not enforced": {status: metav1.ConditionTrue, reason: "NotEnforced"},
+		"privileged":   {level: "privileged", status: metav1.ConditionTrue, reason: "NotEnforced"},
+		"restricted":   {level: podSecurityRestricted, status: metav1.ConditionTrue, reason: "Compliant"},
+		"override violates": {
+			level:   podSecurityRestricted,
+			overlay: `{"spec":{"containers":[{"name":"web","securityContext":{"allowPrivilegeEscalation":true}}]}}`,
+			status:  metav1.ConditionFalse, reason: "Violation",
+			message: "component web: container web: allowPrivilegeEscalation must be false",
+		},
+		"hostPath volume": {
+			level:   podSecurityBaseline,
+			overlay: `{"spec":{"volumes":[{"name":"logs","hostPath":{"path":"/var/log"}}]}}`,
+			status:  metav1.ConditionFalse, reason: "Violation",
+			message: "component web: volume logs: hostPath is not allowed",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			if tc.overlay != "" {
+				app.Spec.Webapp.PodTemplate = &runtime.RawExtension{Raw: []byte(tc.overlay)}
+			}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: app.Namespace}}
+			if tc.level != "" {
+				ns.Labels = map[string]string{podSecurityEnforceLabel: tc.level}
+			}
+			r := newFakeReconciler(t, ns)
+
+			cond, err := r.podSecurityCondition(context.Background(), app)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(cond.Status).To(Equal(tc.status))
+			g.Expect(cond.Reason).To(Equal(tc.reason))
+			g.Expect(cond.Message).To(ContainSubstring(tc.message))
+		})
+	}
+}
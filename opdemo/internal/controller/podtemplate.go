@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// mergePodTemplate strategically merges overlay into the generated
+// template. main is the container the operator builds from the spec, its
+// image, ports, resources and volume mounts are owned by the operator like
+// the pod labels and the generated volumes; an overlay changing any of them
+// is refused.
+func mergePodTemplate(template corev1.PodTemplateSpec, overlay *runtime.RawExtension, main string) (corev1.PodTemplateSpec, error) {
+	if overlay == nil || len(overlay.Raw) == 0 {
+		return template, nil
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return template, err
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, overlay.Raw, corev1.PodTemplateSpec{})
+	if err != nil {
+		return template, fmt.Errorf("podTemplate cannot be merged: %w", err)
+	}
+	merged := corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(patched, &merged); err != nil {
+		return template, fmt.Errorf("podTemplate is not a pod template: %w", err)
+	}
+	if owned := ownedFieldsChanged(template, merged, main); len(owned) > 0 {
+		return template, fmt.Errorf("podTemplate overrides %s, which the operator owns", strings.Join(owned, ", "))
+	}
+	return merged, nil
+}
+
+// ownedFieldsChanged lists the operator owned fields that differ between
+// the generated and the merged template.
+func ownedFieldsChanged(generated, merged corev1.PodTemplateSpec, main string) []string {
+	var owned []string
+	for k, v := range generated.Labels {
+		if merged.Labels[k] != v {
+			owned = append(owned, "metadata.labels."+k)
+		}
+	}
+	for _, want := range generated.Spec.Volumes {
+		if got := findVolume(merged.Spec.Volumes, want.Name); got == nil || !equality.Semantic.DeepEqual(*got, want) {
+			owned = append(owned, "spec.volumes["+want.Name+"]")
+		}
+	}
+
+	want, got := findContainer(generated.Spec.Containers, main), findContainer(merged.Spec.Containers, main)
+	switch {
+	case want == nil:
+	case got == nil:
+		owned = append(owned, "spec.containers["+main+"]")
+	default:
+		path := "spec.containers[" + main + "]."
+		if got.Image != want.Image {
+			owned = append(owned, path+"image")
+		}
+		if !equality.Semantic.DeepEqual(got.Ports, want.Ports) {
+			owned = append(owned, path+"ports")
+		}
+		if !equality.Semantic.DeepEqual(got.Resources, want.Resources) {
+			owned = append(owned, path+"resources")
+		}
+		for _, mount := range want.VolumeMounts {
+			if !contains(got.VolumeMounts, mount) {
+				owned = append(owned, path+"volumeMounts["+mount.Name+"]")
+			}
+		}
+	}
+	return owned
+}
+
+func findContainer(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+func findVolume(volumes []corev1.Volume, name string) *corev1.Volume {
+	for i := range volumes {
+		if volumes[i].Name == name {
+			return &volumes[i]
+		}
+	}
+	return nil
+}
+
+// validatePodTemplates merges every podTemplate of app once, so that a
+// broken overlay is reported before anything is applied.
+func validatePodTemplates(app *appv1.WebService, cfg *config.OperatorConfig) error {
+	for _, c := range webServiceComponents(app) {
+		if c.PodTemplate == nil {
+			continue
+		}
+		generated := newComponentPodTemplate(app, cfg, c)
+		if _, err := mergePodTemplate(generated, c.PodTemplate, c.Name); err != nil {
+			return fmt.Errorf("component %s: %w", c.Name, err)
+		}
+	}
+	if db := app.Spec.DatabaseSpec(); db != nil && db.PodTemplate != nil {
+		generated := databaseProvider(app).StatefulSet(app, cfg).Spec.Template
+		if _, err := mergePodTemplate(generated, db.PodTemplate, db.Name); err != nil {
+			return fmt.Errorf("database: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+func TestMergePodTemplate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		overlay string
+		err     string
+		check   func(g *WithT, template corev1.PodTemplateSpec)
+	}{
+		"sidecar and service account": {
+			overlay: `{"spec":{"serviceAccountName":"shop","containers":[{"name":"proxy","image":"envoy"}]}}`,
+			check: func(g *WithT, template corev1.PodTemplateSpec) {
+				g.Expect(template.Spec.ServiceAccountName).To(Equal("shop"))
+				g.Expect(template.Spec.Containers).To(HaveLen(2))
+				g.Expect(template.Spec.Containers).To(ContainElement(And(HaveField("Name", "web"), HaveField("Image", "nginx"))))
+				g.Expect(template.Spec.Containers).To(ContainElement(And(HaveField("Name", "proxy"), HaveField("Image", "envoy"))))
+			},
+		},
+		"env merged by name": {
+			overlay: `{"spec":{"containers":[{"name":"web","env":[{"name":"LOG_LEVEL","value":"debug"}]}]}}`,
+			check: func(g *WithT, template corev1.PodTemplateSpec) {
+				g.Expect(template.Spec.Containers).To(HaveLen(1))
+				g.Expect(template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+				g.Expect(template.Spec.Containers[0].Env).To(ContainElement(HaveField("Name", "DB_HOST")), "the generated env is kept")
+			},
+		},
+		"extra pod label": {
+			overlay: `{"metadata":{"labels":{"team":"shop"}}}`,
+			check: func(g *WithT, template corev1.PodTemplateSpec) {
+				g.Expect(template.Labels).To(HaveKeyWithValue("team", "shop"))
+				g.Expect(template.Labels).To(HaveKeyWithValue("app", "shop-web"))
+			},
+		},
+		"image":          {overlay: `{"spec":{"containers":[{"name":"web","image":"httpd"}]}}`, err: "podTemplate overrides spec.containers[web].image, which the operator owns"},
+		"resources":      {overlay: `{"spec":{"containers":[{"name":"web","resources":{"limits":{"cpu":"1"}}}]}}`, err: "spec.containers[web].resources"},
+		"selector label": {overlay: `{"metadata":{"labels":{"app":"other"}}}`, err: "metadata.labels.app"},
+		"main container removed": {
+			overlay: `{"spec":{"containers":[{"name":"web","$patch":"delete"}]}}`,
+			err:     "spec.containers[web]",
+		},
+		"not a pod template": {overlay: `{"spec":{"containers":"web"}}`, err: "podTemplate"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			c := primaryComponent(app)
+			generated := newComponentPodTemplate(app, config.Default(), c)
+
+			merged, err := mergePodTemplate(generated, &runtime.RawExtension{Raw: []byte(tc.overlay)}, c.Name)
+			if tc.err != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.err)))
+				g.Expect(merged).To(Equal(generated), "the generated template is the fallback")
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			tc.check(g, merged)
+		})
+	}
+
+	t.Run("no overlay", func(t *testing.T) {
+		g := NewWithT(t)
+		app := testWebService("shop")
+		generated := newComponentPodTemplate(app, config.Default(), primaryComponent(app))
+		g.Expect(mergePodTemplate(generated, nil, "web")).To(Equal(generated))
+	})
+}
+
+func TestValidatePodTemplates(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+	app.Spec.Webapp.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"spec":{"serviceAccountName":"shop"}}`)}
+	g.Expect(validatePodTemplates(app, config.Default())).To(Succeed())
+	g.Expect(NewDeploy(app, config.Default(), primaryComponent(app)).Spec.Template.Spec.ServiceAccountName).To(Equal("shop"))
+
+	app.Spec.Webapp.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"web","image":"httpd"}]}}`)}
+	g.Expect(validatePodTemplates(app, config.Default())).To(MatchError(HavePrefix("component web: ")))
+
+	app.Spec.Webapp.PodTemplate = nil
+	app.Spec.Database.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"mysql","image":"mariadb"}]}}`)}
+	g.Expect(validatePodTemplates(app, config.Default())).To(MatchError(HavePrefix("database: ")))
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	postgresqlDataPath    = "/var/lib/postgresql/data"
+	defaultPostgresqlPort = 5432
+)
+
+// postgresqlProvider runs a single PostgreSQL server with the official
+// image. The generated user owns the database and is the superuser, so the
+// Secret has no root password.
+type postgresqlProvider struct{}
+
+func (postgresqlProvider) Component() string { return "postgresql" }
+
+func (postgresqlProvider) Image(app *appv1.WebService, cfg *config.OperatorConfig) string {
+	return cfg.Image(config.ComponentPostgresql, app.Spec.DatabaseSpec().Image)
+}
+
+func (postgresqlProvider) DefaultPort() int32 { return defaultPostgresqlPort }
+
+func (p postgresqlProvider) StatefulSet(app *appv1.WebService, cfg *config.OperatorConfig) *appsv1.StatefulSet {
+	port := strconv.Itoa(int(databaseContainerPort(app)))
+	return newDatabaseStatefulSet(app, cfg, 1, corev1.Container{
+		Image:     p.Image(app, cfg),
+		Args:      []string{"postgres", "-p", port},
+		Resources: cfg.ResourcesFor(config.ComponentPostgresql, app.Spec.DatabaseSpec().Resources),
+		Env: []corev1.EnvVar{
+			{Name: "POSTGRES_DB", Value: cfg.Mysql.Database},
+			{Name: "POSTGRES_USER", ValueFrom: databaseSecretKeyRef(app, secretKeyUsername)},
+			{Name: "POSTGRES_PASSWORD", ValueFrom: databaseSecretKeyRef(app, secretKeyPassword)},
+			// initdb refuses a non-empty directory, the volume root may
+			// hold lost+found.
+			{Name: "PGDATA", Value: postgresqlDataPath + "/pgdata"},
+		},
+	}, postgresqlDataPath, databaseProbes(
+		"sh", "-c", `pg_isready -h 127.0.0.1 -p `+port+` -U "$POSTGRES_USER" -d "$POSTGRES_DB"`,
+	))
+}
+
+// Services are the headless Service and the Service of the primary. Empty
+// spec.database.ports default to 5432.
+func (postgresqlProvider) Services(app *appv1.WebService, cfg *config.OperatorConfig) []*corev1.Service {
+	ports := app.Spec.DatabaseSpec().Ports
+	if len(ports) == 0 {
+		ports = []corev1.ServicePort{{
+			Name:       "postgresql",
+			Port:       defaultPostgresqlPort,
+			TargetPort: intstr.FromInt(defaultPostgresqlPort),
+		}}
+	}
+	return []*corev1.Service{
+		newDatabaseHeadlessService(app, cfg),
+		newDatabaseClusterService(app, cfg, databaseServiceName(app), primarySelector(app), ports),
+	}
+}
+
+func (postgresqlProvider) SecretData(app *appv1.WebService, current map[string][]byte) (map[string][]byte, error) {
+	return generateSecretData(current, secretKeyPassword)
+}
+
+func (postgresqlProvider) SecretKeys(app *appv1.WebService) []string {
+	return []string{secretKeyUsername, secretKeyPassword}
+}
+
+// Ready relies on the pg_isready readiness probe of the container.
+func (postgresqlProvider) Ready(pod *corev1.Pod) bool { return podReady(pod) }
+
+func (postgresqlProvider) BindingType() string { return "postgresql" }
+
+func (postgresqlProvider) Validate(app *appv1.WebService) error {
+	if mysqlReadReplicas(app) > 0 {
+		return fmt.Errorf("engine PostgreSQL does not support read replicas")
+	}
+	if app.Spec.DatabaseSpec().Backup != nil {
+		return fmt.Errorf("engine PostgreSQL does not support spec.database.backup")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Predicates for the owned children. Create and delete events always pass,
+// a deleted child is re-created by the next reconcile. Updates only pass
+// when something the operator applies has changed, or, for Deployments,
+// when the ready replica count moved, so that status-only churn such as
+// observedGeneration or condition heartbeats does not requeue the owner.
+
+var deploymentPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldDeploy, ok := e.ObjectOld.(*appsv1.Deployment)
+		newDeploy, ok2 := e.ObjectNew.(*appsv1.Deployment)
+		if !ok || !ok2 {
+			return true
+		}
+		return oldDeploy.Generation != newDeploy.Generation ||
+			oldDeploy.Status.ReadyReplicas != newDeploy.Status.ReadyReplicas ||
+			metadataChanged(oldDeploy, newDeploy)
+	},
+}
+
+var statefulSetPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSts, ok := e.ObjectOld.(*appsv1.StatefulSet)
+		newSts, ok2 := e.ObjectNew.(*appsv1.StatefulSet)
+		if !ok || !ok2 {
+			return true
+		}
+		return oldSts.Generation != newSts.Generation ||
+			oldSts.Status.ReadyReplicas != newSts.Status.ReadyReplicas ||
+			metadataChanged(oldSts, newSts)
+	},
+}
+
+// hpaPredicate skips the status updates an autoscaler writes on every sync.
+var hpaPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldHPA, ok := e.ObjectOld.(*autoscalingv2.HorizontalPodAutoscaler)
+		newHPA, ok2 := e.ObjectNew.(*autoscalingv2.HorizontalPodAutoscaler)
+		if !ok || !ok2 {
+			return true
+		}
+		return !equality.Semantic.DeepEqual(oldHPA.Spec, newHPA.Spec) || metadataChanged(oldHPA, newHPA)
+	},
+}
+
+var servicePredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSvc, ok := e.ObjectOld.(*corev1.Service)
+		newSvc, ok2 := e.ObjectNew.(*corev1.Service)
+		if !ok || !ok2 {
+			return true
+		}
+		// The LoadBalancer address feeds status.url.
+		return !equality.Semantic.DeepEqual(oldSvc.Spec, newSvc.Spec) ||
+			!equality.Semantic.DeepEqual(oldSvc.Status.LoadBalancer, newSvc.Status.LoadBalancer) ||
+			metadataChanged(oldSvc, newSvc)
+	},
+}
+
+var secretPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSecret, ok := e.ObjectOld.(*corev1.Secret)
+		newSecret, ok2 := e.ObjectNew.(*corev1.Secret)
+		if !ok || !ok2 {
+			return true
+		}
+		return oldSecret.Type != newSecret.Type ||
+			!equality.Semantic.DeepEqual(oldSecret.Data, newSecret.Data) ||
+			metadataChanged(oldSecret, newSecret)
+	},
+}
+
+// configMapPredicate passes the edits that change the config checksum.
+var configMapPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldCM, ok := e.ObjectOld.(*corev1.ConfigMap)
+		newCM, ok2 := e.ObjectNew.(*corev1.ConfigMap)
+		if !ok || !ok2 {
+			return true
+		}
+		return !equality.Semantic.DeepEqual(oldCM.Data, newCM.Data) ||
+			!equality.Semantic.DeepEqual(oldCM.BinaryData, newCM.BinaryData) ||
+			oldCM.Annotations[hotReloadAnnotation] != newCM.Annotations[hotReloadAnnotation]
+	},
+}
+
+// metadataChanged reports changes to the metadata the operator applies.
+func metadataChanged(oldObj, newObj client.Object) bool {
+	return !equality.Semantic.DeepEqual(oldObj.GetLabels(), newObj.GetLabels()) ||
+		!equality.Semantic.DeepEqual(oldObj.GetAnnotations(), newObj.GetAnnotations()) ||
+		!equality.Semantic.DeepEqual(oldObj.GetOwnerReferences(), newObj.GetOwnerReferences())
+}
+
+// jobPredicate passes Job completion and failure, which move the snapshot
+// step of the teardown forward.
+var jobPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldJob, ok := e.ObjectOld.(*batchv1.Job)
+		newJob, ok2 := e.ObjectNew.(*batchv1.Job)
+		if !ok || !ok2 {
+			return true
+		}
+		return oldJob.Status.Succeeded != newJob.Status.Succeeded ||
+			oldJob.Status.Failed != newJob.Status.Failed ||
+			jobFailed(oldJob) != jobFailed(newJob)
+	},
+}
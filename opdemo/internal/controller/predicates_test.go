@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+func TestChildPredicates(t *testing.T) {
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Generation: 1, ResourceVersion: "1"}}
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web"}, Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "web-mysql"}, Data: map[string][]byte{"password": []byte("a")}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web-config"}, Data: map[string]string{"a": "1"}}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "web-snapshot"}}
+
+	for name, tc := range map[string]struct {
+		predicate predicate.Funcs
+		old       client.Object
+		mutate    func(client.Object)
+		want      bool
+	}{
+		"deployment rolled": {deploymentPredicate, deploy, func(o client.Object) {
+			o.(*appsv1.Deployment).Generation++
+		}, true},
+		"deployment replica ready": {deploymentPredicate, deploy, func(o client.Object) {
+			o.(*appsv1.Deployment).Status.ReadyReplicas = 1
+		}, true},
+		"deployment status churn": {deploymentPredicate, deploy, func(o client.Object) {
+			o.(*appsv1.Deployment).Status.ObservedGeneration = 1
+			o.SetResourceVersion("2")
+		}, false},
+		"deployment relabeled": {deploymentPredicate, deploy, func(o client.Object) {
+			o.SetLabels(map[string]string{"app": "web"})
+		}, true},
+		"service load balancer address": {servicePredicate, svc, func(o client.Object) {
+			o.(*corev1.Service).Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+		}, true},
+		"service managed fields": {servicePredicate, svc, func(o client.Object) {
+			o.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+		}, false},
+		"secret data": {secretPredicate, secret, func(o client.Object) {
+			o.(*corev1.Secret).Data["password"] = []byte("b")
+		}, true},
+		"configmap data": {configMapPredicate, cm, func(o client.Object) {
+			o.(*corev1.ConfigMap).Data["a"] = "2"
+		}, true},
+		"configmap hot reload": {configMapPredicate, cm, func(o client.Object) {
+			o.SetAnnotations(map[string]string{hotReloadAnnotation: "true"})
+		}, true},
+		"configmap other annotation": {configMapPredicate, cm, func(o client.Object) {
+			o.SetAnnotations(map[string]string{"owner": "team-a"})
+		}, false},
+		"job succeeded": {jobPredicate, job, func(o client.Object) {
+			o.(*batchv1.Job).Status.Succeeded = 1
+		}, true},
+		"job pod started": {jobPredicate, job, func(o client.Object) {
+			o.(*batchv1.Job).Status.Active = 1
+		}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			newObj := tc.old.DeepCopyObject().(client.Object)
+			tc.mutate(newObj)
+			g.Expect(tc.predicate.Update(event.UpdateEvent{ObjectOld: tc.old, ObjectNew: newObj})).To(Equal(tc.want))
+			g.Expect(tc.predicate.Create(event.CreateEvent{Object: newObj})).To(BeTrue())
+			g.Expect(tc.predicate.Delete(event.DeleteEvent{Object: newObj})).To(BeTrue())
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"strings"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// Startup budgets before the liveness probe takes over. A database may
+	// have to initialize its data directory first.
+	componentStartupSeconds = 120
+	databaseStartupSeconds  = 300
+
+	probePeriodSeconds = 5
+)
+
+// defaultProbes runs check as startup, liveness and readiness probe. The
+// readiness probe runs twice as often as the liveness probe.
+func defaultProbes(check corev1.ProbeHandler, startupSeconds int32) appv1.ProbesSpec {
+	return appv1.ProbesSpec{
+		Startup: &corev1.Probe{
+			ProbeHandler:     *check.DeepCopy(),
+			PeriodSeconds:    probePeriodSeconds,
+			TimeoutSeconds:   probePeriodSeconds,
+			FailureThreshold: startupSeconds / probePeriodSeconds,
+		},
+		Liveness: &corev1.Probe{
+			ProbeHandler:     *check.DeepCopy(),
+			PeriodSeconds:    2 * probePeriodSeconds,
+			TimeoutSeconds:   probePeriodSeconds,
+			FailureThreshold: 3,
+		},
+		Readiness: &corev1.Probe{
+			ProbeHandler:     *check.DeepCopy(),
+			PeriodSeconds:    probePeriodSeconds,
+			TimeoutSeconds:   probePeriodSeconds,
+			FailureThreshold: 3,
+		},
+	}
+}
+
+// setProbes sets the probes of container from defaults and the overrides.
+func setProbes(container *corev1.Container, defaults appv1.ProbesSpec, overrides *appv1.ProbesSpec) {
+	if overrides == nil {
+		overrides = &appv1.ProbesSpec{}
+	}
+	container.StartupProbe = overrideProbe(defaults.Startup, overrides.Startup)
+	container.LivenessProbe = overrideProbe(defaults.Liveness, overrides.Liveness)
+	container.ReadinessProbe = overrideProbe(defaults.Readiness, overrides.Readiness)
+}
+
+// overrideProbe fills the unset fields of override from def. The result is
+// nil when neither of them has a handler.
+func overrideProbe(def, override *corev1.Probe) *corev1.Probe {
+	if override == nil {
+		return def.DeepCopy()
+	}
+	probe := override.DeepCopy()
+	if def == nil {
+		def = &corev1.Probe{}
+	}
+	if probe.ProbeHandler == (corev1.ProbeHandler{}) {
+		probe.ProbeHandler = *def.ProbeHandler.DeepCopy()
+	}
+	if probe.ProbeHandler == (corev1.ProbeHandler{}) {
+		return nil
+	}
+	for _, field := range []struct{ got, def *int32 }{
+		{&probe.InitialDelaySeconds, &def.InitialDelaySeconds},
+		{&probe.TimeoutSeconds, &def.TimeoutSeconds},
+		{&probe.PeriodSeconds, &def.PeriodSeconds},
+		{&probe.SuccessThreshold, &def.SuccessThreshold},
+		{&probe.FailureThreshold, &def.FailureThreshold},
+	} {
+		if *field.got == 0 {
+			*field.got = *field.def
+		}
+	}
+	if probe.TerminationGracePeriodSeconds == nil {
+		probe.TerminationGracePeriodSeconds = def.TerminationGracePeriodSeconds
+	}
+	return probe
+}
+
+// componentProbes check the first port of c: an HTTP GET of / when the
+// appProtocol or the name of the port is http or https, optionally followed
+// by "-suffix", a TCP connect otherwise. Without ports there are no default
+// probes.
+func componentProbes(c component) appv1.ProbesSpec {
+	if len(c.Ports) == 0 {
+		return appv1.ProbesSpec{}
+	}
+	port := c.Ports[0]
+	protocol := port.Name
+	if port.AppProtocol != nil {
+		protocol = *port.AppProtocol
+	}
+	target := intstr.FromInt(int(port.TargetPort.IntVal))
+
+	check := corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: target}}
+	for _, scheme := range []corev1.URIScheme{corev1.URISchemeHTTPS, corev1.URISchemeHTTP} {
+		prefix := strings.ToLower(string(scheme))
+		if protocol == prefix || strings.HasPrefix(protocol, prefix+"-") {
+			check = corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: target, Scheme: scheme}}
+			break
+		}
+	}
+	return defaultProbes(check, componentStartupSeconds)
+}
+
+// databaseProbes run command in the database container.
+func databaseProbes(command ...string) appv1.ProbesSpec {
+	return defaultProbes(corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: command}}, databaseStartupSeconds)
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+func TestComponentProbes(t *testing.T) {
+	https := "https"
+	for name, tc := range map[string]struct {
+		port    *corev1.ServicePort
+		handler corev1.ProbeHandler
+	}{
+		"tcp": {
+			port:    &corev1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)},
+			handler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8080)}},
+		},
+		"http port name": {
+			port:    &corev1.ServicePort{Name: "http-web", Port: 80, TargetPort: intstr.FromInt(8080)},
+			handler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(8080), Scheme: corev1.URISchemeHTTP}},
+		},
+		"https app protocol": {
+			port:    &corev1.ServicePort{Name: "web", AppProtocol: &https, Port: 443, TargetPort: intstr.FromInt(8443)},
+			handler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(8443), Scheme: corev1.URISchemeHTTPS}},
+		},
+		"name only starting with http": {
+			port:    &corev1.ServicePort{Name: "httpbin", Port: 80, TargetPort: intstr.FromInt(80)},
+			handler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(80)}},
+		},
+		"no ports": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.Webapp.Ports = nil
+			if tc.port != nil {
+				app.Spec.Webapp.Ports = []corev1.ServicePort{*tc.port}
+			}
+
+			probes := componentProbes(primaryComponent(app))
+			if tc.port == nil {
+				g.Expect(probes).To(Equal(appv1.ProbesSpec{}))
+				return
+			}
+			for _, probe := range []*corev1.Probe{probes.Startup, probes.Liveness, probes.Readiness} {
+				g.Expect(probe.ProbeHandler).To(Equal(tc.handler))
+			}
+			g.Expect(probes.Startup.FailureThreshold * probes.Startup.PeriodSeconds).To(BeEquivalentTo(componentStartupSeconds))
+			g.Expect(probes.Liveness.PeriodSeconds).To(Equal(2 * probes.Readiness.PeriodSeconds))
+		})
+	}
+}
+
+func TestOverrideProbe(t *testing.T) {
+	exec := corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}
+	httpGet := corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(8080)}}
+	def := &corev1.Probe{ProbeHandler: exec, PeriodSeconds: 5, TimeoutSeconds: 5, FailureThreshold: 3}
+	for name, tc := range map[string]struct {
+		def, override, want *corev1.Probe
+	}{
+		"default":  {def: def, want: def},
+		"no probe": {},
+		"threshold only": {
+			def: def, override: &corev1.Probe{FailureThreshold: 10},
+			want: &corev1.Probe{ProbeHandler: exec, PeriodSeconds: 5, TimeoutSeconds: 5, FailureThreshold: 10},
+		},
+		"handler replaced": {
+			def: def, override: &corev1.Probe{ProbeHandler: httpGet, InitialDelaySeconds: 30},
+			want: &corev1.Probe{ProbeHandler: httpGet, InitialDelaySeconds: 30, PeriodSeconds: 5, TimeoutSeconds: 5, FailureThreshold: 3},
+		},
+		"handler added": {
+			override: &corev1.Probe{ProbeHandler: httpGet},
+			want:     &corev1.Probe{ProbeHandler: httpGet},
+		},
+		"nothing to run": {override: &corev1.Probe{PeriodSeconds: 10}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(overrideProbe(tc.def, tc.override)).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestProbesOverriddenPerSpec(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+	app.Spec.Webapp.Probes = &appv1.ProbesSpec{Readiness: &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/ready", Port: intstr.FromInt(80)}},
+	}}
+	app.Spec.Database.Probes = &appv1.ProbesSpec{Startup: &corev1.Probe{FailureThreshold: 120}}
+
+	container := NewDeploy(app, config.Default(), primaryComponent(app)).Spec.Template.Spec.Containers[0]
+	g.Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/ready"))
+	g.Expect(container.ReadinessProbe.PeriodSeconds).To(BeEquivalentTo(probePeriodSeconds))
+	g.Expect(container.LivenessProbe.TCPSocket).NotTo(BeNil())
+
+	db := newFakeReconciler(t).databaseStatefulSet(app).Spec.Template.Spec.Containers[0]
+	g.Expect(db.StartupProbe.Exec.Command).To(HaveExactElements("mysqladmin", "ping", "-h", "127.0.0.1"))
+	g.Expect(db.StartupProbe.FailureThreshold).To(BeEquivalentTo(120))
+	g.Expect(db.LivenessProbe.Exec).NotTo(BeNil())
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/mysqladmin"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MySQL runs as one primary, pod 0 of the StatefulSet, plus read replicas.
+// A new replica is seeded with a dump of the primary by a Job, then pointed
+// at the primary with GTID auto-positioning. Replicas that replicate carry
+// mysqlRoleLabel and are selected by the read Service.
+
+const (
+	replicationUser  = "replication"
+	mysqlRoleLabel   = "app.enflame.cn/mysql-role"
+	mysqlRoleReplica = "replica"
+	// replicationResync refreshes the replica lag, replication progress
+	// does not show up as an event on any watched object.
+	replicationResync = 30 * time.Second
+)
+
+const (
+	reasonReplicasReady    = "ReplicasReady"
+	reasonReplicasNotReady = "ReplicasNotReady"
+)
+
+// seedScript resets the GTID state of the replica and loads a dump of the
+// primary, which sets gtid_purged so auto-positioning resumes after it.
+// RESET BINARY LOGS AND GTIDS replaces RESET MASTER in MySQL 8.4.
+const seedScript = `set -e
+mysql -h "$REPLICA_HOST" -P "$REPLICA_PORT" -uroot -e 'SET GLOBAL read_only = OFF'
+mysql -h "$REPLICA_HOST" -P "$REPLICA_PORT" -uroot -e 'RESET MASTER' 2>/dev/null ||
+  mysql -h "$REPLICA_HOST" -P "$REPLICA_PORT" -uroot -e 'RESET BINARY LOGS AND GTIDS'
+mysqldump -h "$DB_HOST" -P "$DB_PORT" -uroot --all-databases --single-transaction --triggers --routines --events --set-gtid-purged=ON > /tmp/seed.sql
+mysql -h "$REPLICA_HOST" -P "$REPLICA_PORT" -uroot < /tmp/seed.sql`
+
+// mysqlReadReplicas is spec.database.replicas, or size-1 for specs written
+// before replicas existed.
+func mysqlReadReplicas(app *appv1.WebService) int32 {
+	db := app.Spec.DatabaseSpec()
+	if db == nil {
+		return 0
+	}
+	if db.Replicas != nil {
+		return *db.Replicas
+	}
+	if db.Size != nil && *db.Size > 1 {
+		return *db.Size - 1
+	}
+	return 0
+}
+
+// mysqlPodHost is the stable DNS name of a pod behind the headless Service.
+func mysqlPodHost(app *appv1.WebService, ordinal int32) string {
+	return fmt.Sprintf("%s.%s.%s.svc", databasePodName(app, ordinal), databaseHeadlessServiceName(app), app.Namespace)
+}
+
+func (r *WebServiceReconciler) dialMysql(ctx context.Context, ep mysqladmin.Endpoint) (mysqladmin.Admin, error) {
+	if r.DialMysql == nil {
+		return mysqladmin.Dial(ctx, ep)
+	}
+	return r.DialMysql(ctx, ep)
+}
+
+// reconcileReplication configures the primary and every replica and
+// reports the topology in the status. Without replicas no SQL is run. It
+// returns how long to wait before the lag is refreshed.
+func (r *WebServiceReconciler) reconcileReplication(ctx context.Context, app *appv1.WebService) (time.Duration, error) {
+	replicas := mysqlReadReplicas(app)
+	topology := &appv1.MysqlTopologyStatus{Primary: databasePodName(app, 0)}
+	if replicas == 0 {
+		return 0, r.setTopology(ctx, app, topology)
+	}
+
+	creds := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: databaseSecretName(app), Namespace: app.Namespace}, creds); err != nil {
+		return 0, err
+	}
+	endpoint := func(ordinal int32) mysqladmin.Endpoint {
+		return mysqladmin.Endpoint{
+			Host:     mysqlPodHost(app, ordinal),
+			Port:     databaseContainerPort(app),
+			User:     "root",
+			Password: string(creds.Data[secretKeyRootPassword]),
+		}
+	}
+	source := endpoint(0)
+	source.User, source.Password = replicationUser, string(creds.Data[secretKeyReplicationPassword])
+
+	primary, err := r.dialMysql(ctx, endpoint(0))
+	if err != nil {
+		return 0, fmt.Errorf("connecting to the MySQL primary: %w", err)
+	}
+	defer primary.Close()
+	if err := primary.SetReadOnly(ctx, false); err != nil {
+		return 0, err
+	}
+	if err := primary.EnsureReplicationUser(ctx, source.User, source.Password); err != nil {
+		return 0, err
+	}
+
+	for ordinal := int32(1); ordinal <= replicas; ordinal++ {
+		replica, err := r.reconcileReplica(ctx, app, ordinal, endpoint(ordinal), source)
+		if err != nil {
+			return 0, err
+		}
+		topology.Replicas = append(topology.Replicas, replica)
+	}
+	return replicationResync, r.setTopology(ctx, app, topology)
+}
+
+func (r *WebServiceReconciler) reconcileReplica(ctx context.Context, app *appv1.WebService, ordinal int32, ep, source mysqladmin.Endpoint) (appv1.MysqlReplicaStatus, error) {
+	log := r.Log.WithValues("webservice", client.ObjectKeyFromObject(app), "ordinal", ordinal)
+	status := appv1.MysqlReplicaStatus{Pod: databasePodName(app, ordinal), State: appv1.MysqlReplicaPending}
+
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, types.NamespacedName{Name: status.Pod, Namespace: app.Namespace}, pod); err != nil {
+		return status, client.IgnoreNotFound(err)
+	}
+	if !podReady(pod) {
+		return status, nil
+	}
+
+	admin, err := r.dialMysql(ctx, ep)
+	if err != nil {
+		return status, fmt.Errorf("connecting to MySQL replica %s: %w", status.Pod, err)
+	}
+	defer admin.Close()
+
+	replica, err := admin.ReplicaStatus(ctx)
+	if err != nil {
+		return status, err
+	}
+	if replica == nil {
+		job, err := r.seedReplica(ctx, app, ordinal)
+		if err != nil {
+			return status, err
+		}
+		switch {
+		case jobFailed(job):
+			status.State = appv1.MysqlReplicaFailed
+			status.LastError = fmt.Sprintf("Job %s failed to seed the replica, delete it to retry", job.Name)
+			return status, nil
+		case job.Status.Succeeded == 0:
+			status.State = appv1.MysqlReplicaSeeding
+			return status, nil
+		}
+
+		log.Info("Starting replication", "source", source.Host)
+		if err := admin.StartReplica(ctx, source); err != nil {
+			return status, err
+		}
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return status, err
+		}
+		if replica, err = admin.ReplicaStatus(ctx); err != nil {
+			return status, err
+		}
+	}
+	// read_only is not persisted, a restarted replica needs it again.
+	if err := admin.SetReadOnly(ctx, true); err != nil {
+		return status, err
+	}
+
+	role := ""
+	status.State = appv1.MysqlReplicaFailed
+	if replica.Running() {
+		role = mysqlRoleReplica
+		status.State = appv1.MysqlReplicaReplicating
+	}
+	status.LagSeconds = replica.LagSeconds
+	status.LastError = replica.LastError
+	return status, r.setMysqlRole(ctx, pod, role)
+}
+
+// seedReplica returns the seed Job of a replica, creating it if needed.
+func (r *WebServiceReconciler) seedReplica(ctx context.Context, app *appv1.WebService, ordinal int32) (*batchv1.Job, error) {
+	name := fmt.Sprintf("%s-seed-%d", databaseStatefulSetName(app), ordinal)
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, job)
+	if errors.IsNotFound(err) {
+		job = r.mysqlClientJob(app, name, "seed", "", seedScript,
+			corev1.EnvVar{Name: "REPLICA_HOST", Value: mysqlPodHost(app, ordinal)},
+			corev1.EnvVar{Name: "REPLICA_PORT", Value: fmt.Sprint(databaseContainerPort(app))})
+		return job, r.Create(ctx, job)
+	}
+	return job, err
+}
+
+// setMysqlRole puts the role label on a MySQL pod, an empty role removes
+// it. The StatefulSet controller leaves labels outside the template alone.
+func (r *WebServiceReconciler) setMysqlRole(ctx context.Context, pod *corev1.Pod, role string) error {
+	if pod.Labels[mysqlRoleLabel] == role {
+		return nil
+	}
+	patch := client.MergeFrom(pod.DeepCopy())
+	if role == "" {
+		delete(pod.Labels, mysqlRoleLabel)
+	} else {
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[mysqlRoleLabel] = role
+	}
+	return r.Patch(ctx, pod, patch)
+}
+
+func (r *WebServiceReconciler) setTopology(ctx context.Context, app *appv1.WebService, topology *appv1.MysqlTopologyStatus) error {
+	patch := client.MergeFrom(app.DeepCopy())
+	app.Status.Topology = topology
+	if len(topology.Replicas) == 0 {
+		meta.RemoveStatusCondition(&app.Status.Conditions, appv1.ConditionReplicationReady)
+		return r.Status().Patch(ctx, app, patch)
+	}
+
+	replicating := 0
+	for _, replica := range topology.Replicas {
+		if replica.State == appv1.MysqlReplicaReplicating {
+			replicating++
+		}
+	}
+	condition := metav1.Condition{
+		Type:               appv1.ConditionReplicationReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reasonReplicasNotReady,
+		Message:            fmt.Sprintf("%d/%d replicas replicating", replicating, len(topology.Replicas)),
+		ObservedGeneration: app.Generation,
+	}
+	if replicating == len(topology.Replicas) {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonReplicasReady
+	}
+	meta.SetStatusCondition(&app.Status.Conditions, condition)
+	return r.Status().Patch(ctx, app, patch)
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
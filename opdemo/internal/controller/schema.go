@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	schemaMigrationTier = "schema-migration"
+	migrationsVolume    = "migrations"
+	migrationsMountPath = "/migrations"
+)
+
+const (
+	reasonMigrating         = "Migrating"
+	reasonInvalidMigrations = "InvalidMigrations"
+)
+
+// sqlMigrationsScript applies the .sql files of the mounted ConfigMap that
+// are not in schema_migrations yet, in the same order as sqlMigrations.
+const sqlMigrationsScript = `set -e
+run() { mysql -h "$DB_HOST" -P "$DB_PORT" -uroot "$DB_NAME" "$@"; }
+run -e 'CREATE TABLE IF NOT EXISTS schema_migrations (version VARCHAR(255) NOT NULL PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)'
+for file in $(ls ` + migrationsMountPath + ` | grep '\.sql$' | LC_ALL=C sort); do
+  version="${file%.sql}"
+  if [ -n "$(run -N -e "SELECT 1 FROM schema_migrations WHERE version = '$version'")" ]; then
+    continue
+  fi
+  echo "Applying $file"
+  run < "` + migrationsMountPath + `/$file"
+  run -e "INSERT INTO schema_migrations (version) VALUES ('$version')"
+done
+`
+
+// schemaMigration is one resolved run of spec.migrations.
+type schemaMigration struct {
+	version     string
+	webappImage string
+	hash        string
+}
+
+// migrateSchema runs spec.migrations for the webapp image about to be
+// rolled out and reports whether the webapp Deployment may be updated. A
+// failed Job stays in place, the webapp keeps its current Deployment until
+// the migrations are fixed or the Job is deleted.
+func (r *WebServiceReconciler) migrateSchema(ctx context.Context, app *appv1.WebService) (bool, error) {
+	if app.Spec.Migrations == nil {
+		if meta.FindStatusCondition(app.Status.Conditions, appv1.ConditionSchemaMigrated) == nil {
+			return true, nil
+		}
+		patch := client.MergeFrom(app.DeepCopy())
+		meta.RemoveStatusCondition(&app.Status.Conditions, appv1.ConditionSchemaMigrated)
+		return true, r.Status().Patch(ctx, app, patch)
+	}
+
+	cfg := r.Config.Get()
+	migration, err := r.resolveMigrations(ctx, app, cfg)
+	if err != nil {
+		return false, r.setSchemaMigrated(ctx, app, metav1.ConditionFalse, reasonInvalidMigrations, err.Error())
+	}
+	if last := app.Status.Migrations; last != nil && last.Hash == migration.hash {
+		if meta.IsStatusConditionTrue(app.Status.Conditions, appv1.ConditionSchemaMigrated) {
+			return true, nil
+		}
+		return true, r.setSchemaMigrated(ctx, app, metav1.ConditionTrue, reasonMigrated,
+			fmt.Sprintf("Schema is at %s for webapp image %s", last.AppliedVersion, last.WebappImage))
+	}
+
+	name := childName(app, "migrate", migration.hash)
+	job := &batchv1.Job{}
+	err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, job)
+	if errors.IsNotFound(err) {
+		job = r.schemaMigrationJob(app, cfg, name)
+		r.Log.Info("Running schema migrations", "webservice", client.ObjectKeyFromObject(app), "job", name, "version", migration.version)
+		if err := r.Create(ctx, job); err != nil {
+			return false, err
+		}
+	} else if err != nil {
+		return false, err
+	}
+
+	switch {
+	case job.Status.Succeeded > 0:
+		patch := client.MergeFrom(app.DeepCopy())
+		app.Status.Migrations = &appv1.MigrationStatus{
+			AppliedVersion: migration.version,
+			WebappImage:    migration.webappImage,
+			Hash:           migration.hash,
+			Job:            name,
+			AppliedTime:    job.Status.CompletionTime,
+		}
+		setSchemaMigratedCondition(app, metav1.ConditionTrue, reasonMigrated,
+			fmt.Sprintf("Schema is at %s for webapp image %s", migration.version, migration.webappImage))
+		if err := r.Status().Patch(ctx, app, patch); err != nil {
+			return false, err
+		}
+		return true, r.deleteSupersededMigrations(ctx, app, name)
+	case jobFailed(job):
+		return false, r.setSchemaMigrated(ctx, app, metav1.ConditionFalse, reasonMigrationFailed,
+			fmt.Sprintf("Job %s failed to migrate the schema to %s, the webapp is not updated; fix the migrations or delete the Job to retry", name, migration.version))
+	default:
+		return false, r.setSchemaMigrated(ctx, app, metav1.ConditionFalse, reasonMigrating,
+			fmt.Sprintf("Job %s is migrating the schema to %s", name, migration.version))
+	}
+}
+
+// resolveMigrations validates spec.migrations and computes the version and
+// hash of the run the current webapp image needs.
+func (r *WebServiceReconciler) resolveMigrations(ctx context.Context, app *appv1.WebService, cfg *config.OperatorConfig) (*schemaMigration, error) {
+	spec := app.Spec.Migrations
+	migration := &schemaMigration{webappImage: cfg.Image(config.ComponentWebapp, primaryComponent(app).Image)}
+	h := sha256.New()
+	fmt.Fprintf(h, "webapp=%s\n", migration.webappImage)
+
+	switch {
+	case spec.ConfigMap != nil && (spec.Image != "" || len(spec.Command) > 0 || len(spec.Args) > 0):
+		return nil, fmt.Errorf("spec.migrations sets both a ConfigMap and an image")
+	case spec.ConfigMap != nil:
+		files, err := r.sqlMigrations(ctx, app)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			fmt.Fprintf(h, "file=%s\n%s\n", f.name, f.content)
+		}
+		migration.version = strings.TrimSuffix(files[len(files)-1].name, ".sql")
+	case spec.Image == "" && len(spec.Command) == 0:
+		return nil, fmt.Errorf("spec.migrations needs a ConfigMap, an image or a command")
+	default:
+		migration.version = migrationImage(app, cfg)
+		fmt.Fprintf(h, "image=%s\ncommand=%q\nargs=%q\n", migration.version, spec.Command, spec.Args)
+	}
+	migration.hash = hex.EncodeToString(h.Sum(nil))[:10]
+	return migration, nil
+}
+
+type sqlFile struct {
+	name, content string
+}
+
+// sqlMigrations returns the .sql files of the migrations ConfigMap in the
+// order they are applied.
+func (r *WebServiceReconciler) sqlMigrations(ctx context.Context, app *appv1.WebService) ([]sqlFile, error) {
+	name := app.Spec.Migrations.ConfigMap.Name
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, cm); errors.IsNotFound(err) {
+		return nil, fmt.Errorf("migrations ConfigMap %s not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+	var files []sqlFile
+	for key, content := range cm.Data {
+		if strings.HasSuffix(key, ".sql") {
+			files = append(files, sqlFile{name: key, content: content})
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("migrations ConfigMap %s has no .sql file", name)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+func migrationImage(app *appv1.WebService, cfg *config.OperatorConfig) string {
+	if image := app.Spec.Migrations.Image; image != "" {
+		return image
+	}
+	return cfg.Image(config.ComponentWebapp, primaryComponent(app).Image)
+}
+
+// schemaMigrationJob applies the SQL files with the mysql client, or runs the
+// migration image with the database variables of the webapp.
+func (r *WebServiceReconciler) schemaMigrationJob(app *appv1.WebService, cfg *config.OperatorConfig, name string) *batchv1.Job {
+	spec := app.Spec.Migrations
+	job := r.mysqlClientJob(app, name, schemaMigrationTier, "", sqlMigrationsScript,
+		corev1.EnvVar{Name: "DB_NAME", Value: cfg.Mysql.Database})
+	pod := &job.Spec.Template.Spec
+	container := &pod.Containers[0]
+
+	if spec.ConfigMap != nil {
+		pod.Volumes = []corev1.Volume{{
+			Name: migrationsVolume,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: *spec.ConfigMap},
+			},
+		}}
+		container.VolumeMounts = []corev1.VolumeMount{{Name: migrationsVolume, MountPath: migrationsMountPath, ReadOnly: true}}
+		return job
+	}
+
+	container.Image = migrationImage(app, cfg)
+	container.Command = spec.Command
+	container.Args = spec.Args
+	primary := primaryComponent(app)
+	container.Env = append(bindingEnv(app, cfg, primary), primary.Envs...)
+	container.VolumeMounts = bindingVolumeMounts(app, primary)
+	pod.Volumes = bindingVolumes(app, primary)
+	// The migration image runs under the same user as the component.
+	pod.SecurityContext, container.SecurityContext = componentSecurityContexts(primary)
+	return job
+}
+
+// deleteSupersededMigrations removes the migration Jobs other than keep.
+func (r *WebServiceReconciler) deleteSupersededMigrations(ctx context.Context, app *appv1.WebService, keep string) error {
+	var jobs batchv1.JobList
+	if err := r.List(ctx, &jobs, client.InNamespace(app.Namespace), client.MatchingLabels(labels(app, schemaMigrationTier))); err != nil {
+		return err
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if job.Name == keep || job.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *WebServiceReconciler) setSchemaMigrated(ctx context.Context, app *appv1.WebService, status metav1.ConditionStatus, reason, message string) error {
+	patch := client.MergeFrom(app.DeepCopy())
+	setSchemaMigratedCondition(app, status, reason, message)
+	return r.Status().Patch(ctx, app, patch)
+}
+
+func setSchemaMigratedCondition(app *appv1.WebService, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               appv1.ConditionSchemaMigrated,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: app.Generation,
+	})
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+func schemaMigratedReason(app *appv1.WebService) string {
+	cond := meta.FindStatusCondition(app.Status.Conditions, appv1.ConditionSchemaMigrated)
+	if cond == nil {
+		return ""
+	}
+	return cond.Reason
+}
+
+// migrationJobs lists the schema migration Jobs of app by name.
+func migrationJobs(g *WithT, r *WebServiceReconciler, app *appv1.WebService) []string {
+	var jobs batchv1.JobList
+	g.Expect(r.List(context.Background(), &jobs, client.InNamespace(app.Namespace),
+		client.MatchingLabels(labels(app, schemaMigrationTier)))).To(Succeed())
+	var names []string
+	for _, job := range jobs.Items {
+		names = append(names, job.Name)
+	}
+	return names
+}
+
+func TestMigrateSchemaGatesTheWebapp(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	app.Spec.Migrations = &appv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "shop-sql"}}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop-sql", Namespace: app.Namespace},
+		Data: map[string]string{
+			"002_orders.sql": "CREATE TABLE orders (id INT);",
+			"001_init.sql":   "CREATE TABLE users (id INT);",
+			"README":         "not a migration",
+		},
+	}
+	r := newFakeReconciler(t, app, cm)
+
+	ok, err := r.migrateSchema(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(schemaMigratedReason(app)).To(Equal(reasonMigrating))
+	jobs := migrationJobs(g, r, app)
+	g.Expect(jobs).To(HaveLen(1))
+	job := &batchv1.Job{}
+	g.Expect(r.Get(ctx, key(app, jobs[0]), job)).To(Succeed())
+	g.Expect(job.Spec.Template.Spec.Volumes).To(ConsistOf(HaveField("ConfigMap.Name", "shop-sql")))
+
+	// A failed Job holds the webapp back until it is deleted.
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	ok, err = r.migrateSchema(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(schemaMigratedReason(app)).To(Equal(reasonMigrationFailed))
+
+	job.Status.Conditions = nil
+	job.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	ok, err = r.migrateSchema(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(schemaMigratedReason(app)).To(Equal(reasonMigrated))
+	g.Expect(app.Status.Migrations).To(HaveField("AppliedVersion", "002_orders"))
+	g.Expect(app.Status.Migrations).To(HaveField("Job", job.Name))
+
+	// A new file is a new run, the Job of the previous run goes once it
+	// has succeeded.
+	cm.Data["003_index.sql"] = "CREATE INDEX orders_id ON orders (id);"
+	g.Expect(r.Update(ctx, cm)).To(Succeed())
+	ok, err = r.migrateSchema(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	jobs = migrationJobs(g, r, app)
+	g.Expect(jobs).To(HaveLen(2))
+	next := &batchv1.Job{}
+	for _, name := range jobs {
+		if name != job.Name {
+			g.Expect(r.Get(ctx, key(app, name), next)).To(Succeed())
+		}
+	}
+	next.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, next)).To(Succeed())
+	ok, err = r.migrateSchema(ctx, app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(app.Status.Migrations).To(HaveField("AppliedVersion", "003_index"))
+	g.Expect(migrationJobs(g, r, app)).To(ConsistOf(next.Name))
+}
+
+func TestMigrateSchemaRejectsInvalidSpec(t *testing.T) {
+	for name, spec := range map[string]*appv1.MigrationsSpec{
+		"missing ConfigMap":   {ConfigMap: &corev1.LocalObjectReference{Name: "absent"}},
+		"ConfigMap and image": {ConfigMap: &corev1.LocalObjectReference{Name: "absent"}, Image: "migrate:1"},
+		"nothing to run":      {Args: []string{"up"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			app := testWebService("shop")
+			app.Spec.Migrations = spec
+			r := newFakeReconciler(t, app)
+
+			ok, err := r.migrateSchema(context.Background(), app)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(ok).To(BeFalse())
+			g.Expect(schemaMigratedReason(app)).To(Equal(reasonInvalidMigrations))
+			g.Expect(migrationJobs(g, r, app)).To(BeEmpty())
+		})
+	}
+}
+
+func TestMigrateSchemaImageUsesBinding(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+	app.Spec.Migrations = &appv1.MigrationsSpec{Image: "shop-migrate:1", Command: []string{"migrate", "up"}}
+	r := newFakeReconciler(t, app)
+
+	job := r.schemaMigrationJob(app, r.Config.Get(), "shop-migrate")
+	container := job.Spec.Template.Spec.Containers[0]
+	g.Expect(container.Image).To(HaveSuffix("shop-migrate:1"))
+	g.Expect(container.Command).To(Equal([]string{"migrate", "up"}))
+	g.Expect(container.Env).To(ContainElement(HaveField("Name", "DB_PASSWORD")))
+}
+
+func TestMigrateSchemaRemovedClearsCondition(t *testing.T) {
+	g := NewWithT(t)
+	app := testWebService("shop")
+	setSchemaMigratedCondition(app, metav1.ConditionTrue, reasonMigrated, "")
+	r := newFakeReconciler(t, app)
+
+	ok, err := r.migrateSchema(context.Background(), app)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(r.Get(context.Background(), key(app, app.Name), app)).To(Succeed())
+	g.Expect(app.Status.Conditions).To(BeEmpty())
+}
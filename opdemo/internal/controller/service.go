@@ -2,21 +2,42 @@ package controller
 
 import (
 	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func NewService(app *appv1.WebService) *corev1.Service {
+// componentServiceType is expose.serviceType of c, NodePort when unset.
+func componentServiceType(c component) corev1.ServiceType {
+	if expose := c.Expose; expose != nil && expose.ServiceType != "" {
+		return expose.ServiceType
+	}
+	return corev1.ServiceTypeNodePort
+}
+
+// NewService is the Service in front of the pods of component c.
+func NewService(app *appv1.WebService, cfg *config.OperatorConfig, c component) *corev1.Service {
+	serviceType := componentServiceType(c)
+	ports := c.Ports
+	if serviceType == corev1.ServiceTypeClusterIP {
+		// A ClusterIP Service rejects node ports left over in the spec.
+		ports = make([]corev1.ServicePort, len(c.Ports))
+		for i, port := range c.Ports {
+			port.NodePort = 0
+			ports[i] = port
+		}
+	}
 	return &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Service",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      app.Spec.Webapp.Name,
-			Namespace: app.Namespace,
+			Name:        c.service,
+			Namespace:   app.Namespace,
+			Labels:      cfg.WithLabels(c.objectLabels(app)),
+			Annotations: cfg.WithAnnotations(nil),
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(app, schema.GroupVersionKind{
 					Group:   appv1.GroupVersion.Group,
@@ -26,29 +47,9 @@ func NewService(app *appv1.WebService) *corev1.Service {
 			},
 		},
 		Spec: corev1.ServiceSpec{
-			Type:  corev1.ServiceTypeNodePort,
-			Ports: app.Spec.Webapp.Ports,
-			Selector: map[string]string{
-				"app": app.Name + "-" + app.Spec.Webapp.Name,
-			},
+			Type:     serviceType,
+			Ports:    ports,
+			Selector: c.podLabels(app),
 		},
 	}
 }
-
-func (r *WebServiceReconciler) mysqlService(app *appv1.WebService) *corev1.Service {
-	labels := labels(app, "mysql")
-	s := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      app.Spec.Mysql.Name,
-			Namespace: app.Namespace,
-		},
-		Spec: corev1.ServiceSpec{
-			Type:     corev1.ServiceTypeClusterIP,
-			Selector: labels,
-			Ports:    app.Spec.Mysql.Ports,
-		},
-	}
-
-	controllerutil.SetControllerReference(app, s, r.Scheme)
-	return s
-}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	databaseDataVolume     = "data"
+	defaultDatabaseStorage = "1Gi"
+)
+
+// reconcileDatabaseStorage reconciles the volume claim template of desired with
+// the running StatefulSet. The template is immutable, so a larger size is
+// rolled out by expanding the PVCs in place and deleting the StatefulSet
+// with orphaned pods; the next reconcile creates it again with the new
+// template and adopts the pods. Other template changes are reverted in
+// desired. It returns false while the StatefulSet is being recreated.
+func (r *WebServiceReconciler) reconcileDatabaseStorage(ctx context.Context, app *appv1.WebService, desired *appsv1.StatefulSet) (bool, error) {
+	log := r.Log.WithValues("webservice", client.ObjectKeyFromObject(app))
+
+	current := &appsv1.StatefulSet{}
+	err := r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, current)
+	if errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if current.DeletionTimestamp != nil {
+		return false, nil
+	}
+	if len(current.Spec.VolumeClaimTemplates) == 0 {
+		return true, nil
+	}
+
+	have := current.Spec.VolumeClaimTemplates[0].Spec
+	want := &desired.Spec.VolumeClaimTemplates[0].Spec
+	want.StorageClassName = have.StorageClassName
+	haveSize := have.Resources.Requests[corev1.ResourceStorage]
+	wantSize := want.Resources.Requests[corev1.ResourceStorage]
+
+	switch wantSize.Cmp(haveSize) {
+	case 0:
+		return true, nil
+	case -1:
+		log.Info("Shrinking database storage is not supported, keeping the current size", "size", haveSize.String())
+		want.Resources.Requests[corev1.ResourceStorage] = haveSize
+		return true, nil
+	}
+
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(app.Namespace), client.MatchingLabels(databaseLabels(app))); err != nil {
+		return false, err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if size.Cmp(wantSize) >= 0 {
+			continue
+		}
+		log.Info("Expanding database PVC", "PVC.Name", pvc.Name, "from", size.String(), "to", wantSize.String())
+		patch := client.MergeFrom(pvc.DeepCopy())
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = wantSize
+		if err := r.Patch(ctx, pvc, patch); err != nil {
+			return false, err
+		}
+	}
+
+	log.Info("Recreating the database StatefulSet for the new volume size, pods are kept")
+	if err := r.Delete(ctx, current, client.PropagationPolicy(metav1.DeletePropagationOrphan)); client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// databaseStatefulSet is a StatefulSet called name with a volume claim
+// template requesting size of class.
+func databaseStatefulSet(name, size, class string) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appsv1.StatefulSetSpec{
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: "data"},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &class,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func requestedStorage(sts *appsv1.StatefulSet) string {
+	size := sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage]
+	return size.String()
+}
+
+func TestReconcileDatabaseStorage(t *testing.T) {
+	ctx := context.Background()
+	app := testWebService("shop")
+	name := databaseStatefulSetName(app)
+
+	t.Run("new StatefulSet", func(t *testing.T) {
+		g := NewWithT(t)
+		r := newFakeReconciler(t)
+		ok, err := r.reconcileDatabaseStorage(ctx, app, databaseStatefulSet(name, "1Gi", "standard"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+	})
+
+	t.Run("shrink and class change are dropped", func(t *testing.T) {
+		g := NewWithT(t)
+		r := newFakeReconciler(t, databaseStatefulSet(name, "10Gi", "standard"))
+		desired := databaseStatefulSet(name, "5Gi", "fast")
+		ok, err := r.reconcileDatabaseStorage(ctx, app, desired)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(requestedStorage(desired)).To(Equal("10Gi"))
+		g.Expect(desired.Spec.VolumeClaimTemplates[0].Spec.StorageClassName).To(HaveValue(Equal("standard")))
+	})
+
+	t.Run("growth expands the PVCs and recreates the StatefulSet", func(t *testing.T) {
+		g := NewWithT(t)
+		pvc := func(name, size string) *corev1.PersistentVolumeClaim {
+			return &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: app.Namespace, Labels: databaseLabels(app)},
+				Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+				}},
+			}
+		}
+		current := databaseStatefulSet(name, "1Gi", "standard")
+		r := newFakeReconciler(t, current, pvc("data-"+databasePodName(app, 0), "1Gi"), pvc("data-"+databasePodName(app, 1), "20Gi"))
+
+		ok, err := r.reconcileDatabaseStorage(ctx, app, databaseStatefulSet(name, "5Gi", "standard"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		var pvcs corev1.PersistentVolumeClaimList
+		g.Expect(r.List(ctx, &pvcs, client.InNamespace(app.Namespace))).To(Succeed())
+		g.Expect(pvcs.Items).To(ConsistOf(
+			HaveField("Spec.Resources.Requests", HaveKeyWithValue(corev1.ResourceStorage, resource.MustParse("5Gi"))),
+			HaveField("Spec.Resources.Requests", HaveKeyWithValue(corev1.ResourceStorage, resource.MustParse("20Gi"))),
+		))
+		g.Expect(errors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(current), current))).To(BeTrue())
+	})
+
+	t.Run("waits for the old StatefulSet to go", func(t *testing.T) {
+		g := NewWithT(t)
+		current := databaseStatefulSet(name, "1Gi", "standard")
+		current.Finalizers = []string{"orphan"}
+		now := metav1.Now()
+		current.DeletionTimestamp = &now
+		r := newFakeReconciler(t, current)
+		ok, err := r.reconcileDatabaseStorage(ctx, app, databaseStatefulSet(name, "5Gi", "standard"))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+	})
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/graph"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// updateStatus refreshes the component status from the owned Deployments.
+// blocked is the node the reconcile graph waits for; only without one do
+// the children reflect the current spec and is ObservedGeneration moved
+// forward.
+func (r *WebServiceReconciler) updateStatus(ctx context.Context, app *appv1.WebService, blocked *graph.Blocked) error {
+	patch := client.MergeFrom(app.DeepCopy())
+
+	mysqlSts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: databaseStatefulSetName(app), Namespace: app.Namespace}}
+	mysql, err := r.componentStatus(ctx, app, mysqlSts, databaseServiceName(app))
+	if err != nil {
+		return err
+	}
+	components := webServiceComponents(app)
+	if ordered, err := orderComponents(components); err == nil {
+		components = ordered
+	}
+	var webapp *appv1.ComponentStatus
+	var statuses []appv1.ComponentStatus
+	var notReady []string
+	all := make([]*appv1.ComponentStatus, 0, len(components))
+	for _, c := range components {
+		deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: c.deployment, Namespace: app.Namespace}}
+		cs, err := r.componentStatus(ctx, app, deploy, c.service)
+		if err != nil {
+			return err
+		}
+		all = append(all, cs)
+		if c.WebServiceWebappSpec == app.Spec.Webapp {
+			webapp = cs
+			continue
+		}
+		if cs != nil {
+			statuses = append(statuses, *cs)
+		}
+		if !componentReady(cs) {
+			notReady = append(notReady, c.Name)
+		}
+	}
+
+	status := &app.Status
+	status.Mysql = mysql
+	status.Webapp = webapp
+	status.Components = statuses
+	status.DatabaseSecret = databaseSecretName(app)
+	status.Binding = &corev1.LocalObjectReference{Name: bindingSecretName(app)}
+	if status.URL, err = r.exposedURL(ctx, app); err != nil {
+		return err
+	}
+	if exposed := r.exposedCondition(app); exposed != nil {
+		meta.SetStatusCondition(&status.Conditions, *exposed)
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, appv1.ConditionExposed)
+	}
+	podSecurity, err := r.podSecurityCondition(ctx, app)
+	if err != nil {
+		return err
+	}
+	meta.SetStatusCondition(&status.Conditions, podSecurity)
+	status.Phase = nextPhase(status.Phase, mysql, all)
+	setBlockedOn(status, blocked)
+	if blocked == nil {
+		if err := r.recordRevision(ctx, app); err != nil {
+			return err
+		}
+		status.ObservedGeneration = app.Generation
+		meta.RemoveStatusCondition(&status.Conditions, appv1.ConditionConflict)
+	}
+
+	setComponentCondition(app, appv1.ConditionDatabaseReady, mysql, "MysqlReady", "MysqlNotReady")
+	if app.Spec.Webapp != nil {
+		setComponentCondition(app, appv1.ConditionWebappReady, webapp, "WebappReady", "WebappNotReady")
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, appv1.ConditionWebappReady)
+	}
+	setComponentsCondition(app, notReady)
+	ready := metav1.Condition{
+		Type:               appv1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             string(status.Phase),
+		Message:            fmt.Sprintf("WebService is %s", status.Phase),
+		ObservedGeneration: app.Generation,
+	}
+	if status.Phase == appv1.PhaseReady {
+		ready.Status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+
+	return r.Status().Patch(ctx, app, patch)
+}
+
+// setBlockedOn reports blocked in status. Since is kept while the graph
+// waits for the same node, the requeue backoff counts from it.
+func setBlockedOn(status *appv1.WebServiceStatus, blocked *graph.Blocked) {
+	switch prev := status.BlockedOn; {
+	case blocked == nil:
+		status.BlockedOn = nil
+	case prev == nil || prev.Node != blocked.Node:
+		status.BlockedOn = &appv1.BlockedNode{Node: blocked.Node, Reason: blocked.Reason, Since: metav1.Now()}
+	default:
+		prev.Reason = blocked.Reason
+	}
+}
+
+// componentStatus reads the workload, a Deployment or a StatefulSet, and
+// the Service svcName. It returns nil when the workload does not exist yet.
+func (r *WebServiceReconciler) componentStatus(ctx context.Context, app *appv1.WebService, workload client.Object, svcName string) (*appv1.ComponentStatus, error) {
+	err := r.Get(ctx, client.ObjectKeyFromObject(workload), workload)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !metav1.IsControlledBy(workload, app) {
+		// An object of that name the WebService has not adopted.
+		return nil, nil
+	}
+
+	var replicas *int32
+	var template corev1.PodTemplateSpec
+	cs := &appv1.ComponentStatus{Name: workload.GetName()}
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		replicas, template, cs.ReadyReplicas = w.Spec.Replicas, w.Spec.Template, w.Status.ReadyReplicas
+	case *appsv1.StatefulSet:
+		replicas, template, cs.ReadyReplicas = w.Spec.Replicas, w.Spec.Template, w.Status.ReadyReplicas
+	}
+	if replicas != nil {
+		cs.DesiredReplicas = *replicas
+	}
+	if containers := template.Spec.Containers; len(containers) > 0 {
+		cs.Image = containers[0].Image
+	}
+
+	svc := &corev1.Service{}
+	err = r.Get(ctx, types.NamespacedName{Name: svcName, Namespace: app.Namespace}, svc)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil && len(svc.Spec.Ports) > 0 {
+		cs.Endpoint = fmt.Sprintf("%s.%s.svc:%d", svc.Name, svc.Namespace, svc.Spec.Ports[0].Port)
+	}
+	return cs, nil
+}
+
+func componentReady(cs *appv1.ComponentStatus) bool {
+	return cs != nil && cs.ReadyReplicas > 0 && cs.ReadyReplicas >= cs.DesiredReplicas
+}
+
+// nextPhase derives the phase from the database and the application
+// components, nil for a component not created yet. Once a WebService has
+// been Ready, losing replicas makes it Degraded instead of going back to
+// one of the provisioning phases.
+func nextPhase(prev appv1.WebServicePhase, mysql *appv1.ComponentStatus, components []*appv1.ComponentStatus) appv1.WebServicePhase {
+	wasReady := prev == appv1.PhaseReady || prev == appv1.PhaseDegraded
+	componentsReady := len(components) > 0
+	for _, cs := range components {
+		componentsReady = componentsReady && componentReady(cs)
+	}
+	switch {
+	case componentReady(mysql) && componentsReady:
+		return appv1.PhaseReady
+	case wasReady:
+		return appv1.PhaseDegraded
+	case mysql == nil:
+		return appv1.PhasePending
+	case !componentReady(mysql):
+		return appv1.PhaseDatabaseProvisioning
+	default:
+		return appv1.PhaseDeploying
+	}
+}
+
+func setComponentCondition(app *appv1.WebService, condType string, cs *appv1.ComponentStatus, readyReason, notReadyReason string) {
+	cond := metav1.Condition{
+		Type:               condType,
+		Status:             metav1.ConditionFalse,
+		Reason:             notReadyReason,
+		ObservedGeneration: app.Generation,
+	}
+	switch {
+	case cs == nil:
+		cond.Message = "Deployment not created yet"
+	case componentReady(cs):
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = readyReason
+		cond.Message = fmt.Sprintf("%d/%d replicas ready", cs.ReadyReplicas, cs.DesiredReplicas)
+	default:
+		cond.Message = fmt.Sprintf("%d/%d replicas ready", cs.ReadyReplicas, cs.DesiredReplicas)
+	}
+	meta.SetStatusCondition(&app.Status.Conditions, cond)
+}
+
+// setComponentsCondition sums up spec.components, notReady are the names of
+// the ones without all replicas ready.
+func setComponentsCondition(app *appv1.WebService, notReady []string) {
+	if len(app.Spec.Components) == 0 {
+		meta.RemoveStatusCondition(&app.Status.Conditions, appv1.ConditionComponentsReady)
+		return
+	}
+	cond := metav1.Condition{
+		Type:               appv1.ConditionComponentsReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ComponentsReady",
+		Message:            fmt.Sprintf("%d/%d components ready", len(app.Spec.Components), len(app.Spec.Components)),
+		ObservedGeneration: app.Generation,
+	}
+	if len(notReady) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ComponentsNotReady"
+		cond.Message = fmt.Sprintf("%d/%d components ready, waiting for %s",
+			len(app.Spec.Components)-len(notReady), len(app.Spec.Components), strings.Join(notReady, ", "))
+	}
+	meta.SetStatusCondition(&app.Status.Conditions, cond)
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/graph"
+)
+
+func TestNextPhase(t *testing.T) {
+	ready := &appv1.ComponentStatus{DesiredReplicas: 1, ReadyReplicas: 1}
+	starting := &appv1.ComponentStatus{DesiredReplicas: 2, ReadyReplicas: 1}
+	for name, tc := range map[string]struct {
+		prev       appv1.WebServicePhase
+		mysql      *appv1.ComponentStatus
+		components []*appv1.ComponentStatus
+		want       appv1.WebServicePhase
+	}{
+		"nothing created":           {mysql: nil, components: []*appv1.ComponentStatus{nil}, want: appv1.PhasePending},
+		"database starting":         {mysql: starting, components: []*appv1.ComponentStatus{nil}, want: appv1.PhaseDatabaseProvisioning},
+		"webapp starting":           {mysql: ready, components: []*appv1.ComponentStatus{starting}, want: appv1.PhaseDeploying},
+		"one component missing":     {mysql: ready, components: []*appv1.ComponentStatus{ready, nil}, want: appv1.PhaseDeploying},
+		"all ready":                 {mysql: ready, components: []*appv1.ComponentStatus{ready, ready}, want: appv1.PhaseReady},
+		"no components":             {mysql: ready, want: appv1.PhaseDeploying},
+		"ready loses a replica":     {prev: appv1.PhaseReady, mysql: ready, components: []*appv1.ComponentStatus{starting}, want: appv1.PhaseDegraded},
+		"degraded loses database":   {prev: appv1.PhaseDegraded, mysql: nil, components: []*appv1.ComponentStatus{ready}, want: appv1.PhaseDegraded},
+		"degraded recovers":         {prev: appv1.PhaseDegraded, mysql: ready, components: []*appv1.ComponentStatus{ready}, want: appv1.PhaseReady},
+		"deploying stays deploying": {prev: appv1.PhaseDeploying, mysql: ready, components: []*appv1.ComponentStatus{starting}, want: appv1.PhaseDeploying},
+	} {
+		t.Run(name, func(t *testing.T) {
+			NewWithT(t).Expect(nextPhase(tc.prev, tc.mysql, tc.components)).To(Equal(tc.want))
+		})
+	}
+}
+
+// statusFixture holds the children updateStatus reads, owned by app.
+func statusFixture(t *testing.T, app *appv1.WebService) (*WebServiceReconciler, *appsv1.StatefulSet, *appsv1.Deployment) {
+	t.Helper()
+	one := int32(1)
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: databaseStatefulSetName(app), Namespace: app.Namespace},
+		Spec: appsv1.StatefulSetSpec{Replicas: &one, Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "mysql", Image: "mysql:5.7"}}},
+		}},
+	}
+	web := webServiceComponents(app)[0]
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: web.deployment, Namespace: app.Namespace},
+		Spec: appsv1.DeploymentSpec{Replicas: &one, Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx"}}},
+		}},
+	}
+	dbService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: databaseServiceName(app), Namespace: app.Namespace},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 3306}}},
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: app.Namespace}}
+	r := newFakeReconciler(t, app, ns)
+	for _, obj := range []client.Object{sts, deploy, dbService} {
+		controllerutil.SetControllerReference(app, obj, r.Scheme)
+		if err := r.Create(context.Background(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r, sts, deploy
+}
+
+func TestUpdateStatusReportsComponents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	app.Generation = 3
+	r, sts, deploy := statusFixture(t, app)
+
+	sts.Status.ReadyReplicas = 1
+	g.Expect(r.Status().Update(ctx, sts)).To(Succeed())
+	g.Expect(r.updateStatus(ctx, app, &graph.Blocked{Node: "webapp", Reason: "waiting"})).To(Succeed())
+
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(app), app)).To(Succeed())
+	g.Expect(app.Status.Phase).To(Equal(appv1.PhaseDeploying))
+	g.Expect(app.Status.Mysql).To(HaveValue(Equal(appv1.ComponentStatus{
+		Name: sts.Name, Image: "mysql:5.7", DesiredReplicas: 1, ReadyReplicas: 1,
+		Endpoint: databaseServiceName(app) + ".default.svc:3306",
+	})))
+	g.Expect(app.Status.Webapp).To(HaveValue(HaveField("ReadyReplicas", int32(0))))
+	g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, appv1.ConditionDatabaseReady)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionFalse(app.Status.Conditions, appv1.ConditionWebappReady)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(app.Status.Conditions, appv1.ConditionReady)).To(HaveValue(And(
+		HaveField("Status", metav1.ConditionFalse),
+		HaveField("Reason", string(appv1.PhaseDeploying)),
+		HaveField("ObservedGeneration", int64(3)),
+	)))
+	// The children do not reflect generation 3 while the graph is blocked.
+	g.Expect(app.Status.ObservedGeneration).To(BeZero())
+	g.Expect(app.Status.BlockedOn).To(HaveValue(HaveField("Node", "webapp")))
+
+	deploy.Status.ReadyReplicas = 1
+	g.Expect(r.Status().Update(ctx, deploy)).To(Succeed())
+	g.Expect(r.updateStatus(ctx, app, nil)).To(Succeed())
+
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(app), app)).To(Succeed())
+	g.Expect(app.Status.Phase).To(Equal(appv1.PhaseReady))
+	g.Expect(app.Status.ObservedGeneration).To(Equal(int64(3)))
+	g.Expect(app.Status.BlockedOn).To(BeNil())
+	g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, appv1.ConditionReady)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, appv1.ConditionWebappReady)).To(BeTrue())
+
+	// A Ready WebService that loses its webapp pods is Degraded.
+	deploy.Status.ReadyReplicas = 0
+	g.Expect(r.Status().Update(ctx, deploy)).To(Succeed())
+	g.Expect(r.updateStatus(ctx, app, nil)).To(Succeed())
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(app), app)).To(Succeed())
+	g.Expect(app.Status.Phase).To(Equal(appv1.PhaseDegraded))
+	g.Expect(meta.IsStatusConditionFalse(app.Status.Conditions, appv1.ConditionReady)).To(BeTrue())
+}
+
+func TestUpdateStatusIgnoresUnownedWorkloads(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	app := testWebService("shop")
+	r, sts, _ := statusFixture(t, app)
+
+	sts.OwnerReferences = nil
+	g.Expect(r.Update(ctx, sts)).To(Succeed())
+	g.Expect(r.updateStatus(ctx, app, nil)).To(Succeed())
+
+	g.Expect(r.Get(ctx, client.ObjectKeyFromObject(app), app)).To(Succeed())
+	g.Expect(app.Status.Mysql).To(BeNil())
+	g.Expect(app.Status.Phase).To(Equal(appv1.PhasePending))
+	g.Expect(meta.FindStatusCondition(app.Status.Conditions, appv1.ConditionDatabaseReady)).To(HaveValue(
+		HaveField("Message", "Deployment not created yet")))
+}
@@ -18,21 +18,30 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"reflect"
 	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/graph"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/mysqladmin"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	networkingv1 "k8s.io/api/networking/v1"
 )
 
 // WebServiceReconciler reconciles a WebService object
@@ -40,20 +49,41 @@ type WebServiceReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	Log    logr.Logger
+	Config *config.Store
+	// DialMysql opens the SQL connections for replication, mysqladmin.Dial
+	// when nil.
+	DialMysql mysqladmin.DialFunc
+	// GatewayAPI enables HTTPRoutes, see HasGatewayAPI.
+	GatewayAPI bool
+	// Recorder emits the events of adoptions and rollbacks.
+	Recorder record.EventRecorder
+	// APIReader reads past the informer cache, see apiReader.
+	APIReader client.Reader
 }
 
 //+kubebuilder:rbac:groups=app.enflame.cn,resources=webservices,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=app.enflame.cn,resources=webservices/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=app.enflame.cn,resources=webservices/finalizers,verbs=update
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservicebackups,verbs=get;list;watch;create;delete
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=replicasets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete;deletecollection
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -86,105 +116,163 @@ func (r *WebServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	log.Info("fetch webservice objects", "webservice", webService)
 
 	v := &webService
-	var result *ctrl.Result
 
-	// == MySQL ==========
-	result, err = r.ensureSecret(req, v, r.mysqlAuthSecret(v))
-	if result != nil {
-		return *result, err
+	if !v.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, v)
 	}
-
-	result, err = r.ensureDeployment(req, v, r.mysqlDeployment(v))
-	if result != nil {
-		return *result, err
+	if controllerutil.AddFinalizer(v, webServiceFinalizer) {
+		if err := r.Update(ctx, v); err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
-	result, err = r.ensureService(req, v, r.mysqlService(v))
-	if result != nil {
-		return *result, err
+	if err := r.migrateLegacySpec(ctx, v); err != nil {
+		return ctrl.Result{}, err
 	}
-
-	mysqlRunning := r.isMysqlUp(v)
-	if !mysqlRunning {
-		// If MySQL isn't running yet, requeue the ctrl
-		// to run again after a delay
-		delay := time.Second * time.Duration(5)
-		log.Info(fmt.Sprintf("MySQL isn't running, waiting for %s", delay))
-		return ctrl.Result{RequeueAfter: delay}, nil
+	if err := r.adoptNamingScheme(ctx, v); err != nil {
+		return ctrl.Result{}, err
 	}
-
-	// 如果不存在,则创建关联资源
-	// 如果存在,判断是否需要更新
-	// 如果需要更新,则直接更新
-	// 如果不需要更新,则正常返回
-	deploy := &appsv1.Deployment{}
-	if err := r.Get(ctx, req.NamespacedName, deploy); err != nil && errors.IsNotFound(err) {
-		// 1. 关联 Annotations
-		data, _ := json.Marshal(webService.Spec)
-		if webService.Annotations != nil {
-			webService.Annotations["spec"] = string(data)
-		} else {
-			webService.Annotations = map[string]string{"spec": string(data)}
-		}
-		if err := r.Client.Update(ctx, &webService); err != nil {
-			return ctrl.Result{}, err
-		}
-
-		// 2. 创建 Deployment
-		deploy := NewDeploy(&webService)
-		if err := r.Client.Create(ctx, deploy); err != nil {
-			return ctrl.Result{}, err
-		}
-
-		// 3. 创建 Service
-		service := NewService(&webService)
-		if err := r.Create(ctx, service); err != nil {
-			return ctrl.Result{}, err
-		}
-
-		return ctrl.Result{}, nil
+	if rolledBack, err := r.rollback(ctx, v); err != nil || rolledBack {
+		return ctrl.Result{}, err
+	}
+	// Children are applied on every pass to undo drift, the generation only
+	// tells whether the spec itself moved since the last successful pass.
+	if v.Generation != v.Status.ObservedGeneration {
+		log.Info("spec changed", "generation", v.Generation, "observedGeneration", v.Status.ObservedGeneration)
 	}
 
-	oldspec := appv1.WebServiceSpec{}
-	if err := json.Unmarshal([]byte(webService.Annotations["spec"]), &oldspec); err != nil {
+	if err := validateDatabase(v); err != nil {
+		log.Error(err, "Database spec is not supported by the engine")
+		return ctrl.Result{}, err
+	}
+	components, err := orderComponents(webServiceComponents(v))
+	if err != nil {
+		log.Error(err, "Components cannot be ordered by their dependencies")
+		return ctrl.Result{}, err
+	}
+	if err := validatePodTemplates(v, r.Config.Get()); err != nil {
+		log.Error(err, "podTemplate overlay is refused")
 		return ctrl.Result{}, err
 	}
 
-	// 当前规范与旧的对象不一致，则需要更新
-	if !reflect.DeepEqual(webService.Spec, oldspec) {
-		// 更新关联资源
-		newDeploy := NewDeploy(&webService)
-		oldDeploy := &appsv1.Deployment{}
-		if err := r.Get(ctx, req.NamespacedName, oldDeploy); err != nil {
-			return ctrl.Result{}, err
-		}
-
-		oldDeploy.Spec = newDeploy.Spec
-		if err := r.Client.Update(ctx, oldDeploy); err != nil {
-			return ctrl.Result{}, err
-		}
-
-		newService := NewService(&webService)
-		oldService := &corev1.Service{}
-		if err := r.Get(ctx, req.NamespacedName, oldService); err != nil {
-			return ctrl.Result{}, err
-		}
+	var requeue time.Duration
+	result, err := r.webServiceGraph(req, v, components, &requeue).Run(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if result.Blocked != nil {
+		log.Info("Waiting for a child before applying the ones depending on it",
+			"node", result.Blocked.Node, "reason", result.Blocked.Reason, "waiting", result.Waiting)
+	}
+	// Removed components are no node of the graph, they are pruned on
+	// every pass.
+	if err := r.pruneComponents(ctx, v, components); err != nil {
+		return ctrl.Result{}, err
+	}
 
-		// 需要指定 ClusterIP 为之前的，不然更新会报错
-		newService.Spec.ClusterIP = oldService.Spec.ClusterIP
-		oldService.Spec = newService.Spec
-		if err := r.Client.Update(ctx, oldService); err != nil {
-			return ctrl.Result{}, err
-		}
-		return ctrl.Result{}, nil
+	if err := r.updateStatus(ctx, v, result.Blocked); err != nil {
+		return ctrl.Result{}, err
+	}
+	if blocked := v.Status.BlockedOn; blocked != nil {
+		requeue = minRequeue(requeue, graph.Backoff(time.Since(blocked.Since.Time)))
 	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
 
-	return ctrl.Result{}, nil
+// reconcileComponent applies the Deployment, the autoscaler, the Service,
+// the NetworkPolicy and the exposure of c. Its node in the reconcile graph
+// waits for the schema migration and the components c depends on.
+func (r *WebServiceReconciler) reconcileComponent(ctx context.Context, req ctrl.Request, app *appv1.WebService, c component) error {
+	cfg := r.Config.Get()
+	deploy := NewDeploy(app, cfg, c)
+	if err := r.setConfigChecksum(ctx, deploy); err != nil {
+		return err
+	}
+	if err := r.keepAutoscaledReplicas(ctx, c, deploy); err != nil {
+		return err
+	}
+	if _, err := r.ensureDeployment(req, app, deploy); err != nil {
+		return err
+	}
+	if err := r.reconcileAutoscaling(ctx, app, c); err != nil {
+		return err
+	}
+	if _, err := r.ensureService(req, app, NewService(app, cfg, c)); err != nil {
+		return err
+	}
+	if err := r.reconcileComponentNetworkPolicy(ctx, app, c); err != nil {
+		return err
+	}
+	return r.reconcileExposure(ctx, app, c)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WebServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	indexer := mgr.GetFieldIndexer()
+	if err := indexer.IndexField(context.Background(), &appv1.WebService{}, configMapRefIndex, func(obj client.Object) []string {
+		configMaps, _ := r.configRefs(obj.(*appv1.WebService))
+		return sets.List(configMaps)
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(context.Background(), &appv1.WebService{}, secretRefIndex, func(obj client.Object) []string {
+		_, secrets := r.configRefs(obj.(*appv1.WebService))
+		return sets.List(secrets)
+	}); err != nil {
+		return err
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&appv1.WebService{}).
-		Complete(r)
+		// Children are watched so that deleted ones are re-created and
+		// edited ones are applied again. Deployment readiness also feeds
+		// the component status and the MySQL gate.
+		Owns(&appsv1.Deployment{}, builder.WithPredicates(deploymentPredicate)).
+		Owns(&appsv1.StatefulSet{}, builder.WithPredicates(statefulSetPredicate)).
+		Owns(&corev1.Service{}, builder.WithPredicates(servicePredicate)).
+		Owns(&corev1.Secret{}, builder.WithPredicates(secretPredicate)).
+		Owns(&batchv1.Job{}, builder.WithPredicates(jobPredicate)).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}, builder.WithPredicates(hpaPredicate)).
+		// Backups are not owned, they are found by label and feed the
+		// backup history.
+		Watches(&appv1.WebServiceBackup{}, handler.EnqueueRequestsFromMapFunc(webServiceOfBackup)).
+		// ConfigMaps and Secrets read by the components are found through
+		// the field indexes: an edit updates the config checksum and rolls
+		// the pods, editing the SQL files of spec.migrations runs them
+		// again.
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.webServicesReading(configMapRefIndex)),
+			builder.WithPredicates(configMapPredicate)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.webServicesReading(secretRefIndex)),
+			builder.WithPredicates(secretPredicate))
+
+	if r.GatewayAPI {
+		b = b.Owns(newHTTPRoute())
+	}
+
+	if r.Config != nil {
+		// Re-reconcile every WebService when the operator config is reloaded,
+		// so new defaults reach the existing children.
+		events := make(chan event.GenericEvent)
+		r.Config.OnChange(func(ctx context.Context, _ *config.OperatorConfig) {
+			var list appv1.WebServiceList
+			if err := r.List(ctx, &list); err != nil {
+				r.Log.Error(err, "Failed to list WebServices after config reload")
+				return
+			}
+			for i := range list.Items {
+				// The listener runs on the watch loop of the config file and
+				// must not outlive the manager.
+				select {
+				case events <- event.GenericEvent{Object: &list.Items[i]}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+		b = b.WatchesRawSource(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{})
+	}
+
+	return b.Complete(r)
 }
@@ -0,0 +1,350 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/objectstore"
+)
+
+// backupFinalizer deletes the backup file together with the
+// WebServiceBackup.
+const backupFinalizer = "app.enflame.cn/backup-cleanup"
+
+// backupResync polls for the MySQL primary, which is not watched by the
+// backup controllers.
+const backupResync = 10 * time.Second
+
+// WebServiceBackupReconciler takes WebServiceBackups.
+type WebServiceBackupReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+	Config *config.Store
+	// OpenStore connects to S3 storage, objectstore.Open when nil.
+	OpenStore objectstore.OpenFunc
+	Recorder  record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservicebackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservicebackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservicebackups/finalizers,verbs=update
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservices,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile runs the backup Job once and records its result. Completed and
+// failed backups are left alone until they are deleted.
+func (r *WebServiceBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("webservicebackup", req.NamespacedName)
+
+	backup := &appv1.WebServiceBackup{}
+	if err := r.Get(ctx, req.NamespacedName, backup); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !backup.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.deleteBackupFile(ctx, backup)
+	}
+	if controllerutil.AddFinalizer(backup, backupFinalizer) {
+		if err := r.Update(ctx, backup); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if backup.Status.Phase == appv1.BackupCompleted || backup.Status.Phase == appv1.BackupFailed {
+		return ctrl.Result{}, nil
+	}
+
+	app := &appv1.WebService{}
+	err := r.Get(ctx, types.NamespacedName{Name: backup.Spec.WebServiceName, Namespace: backup.Namespace}, app)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, r.setBackupPhase(ctx, backup, appv1.BackupFailed,
+			fmt.Sprintf("WebService %s not found", backup.Spec.WebServiceName))
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	if app.Spec.DatabaseSpec() == nil {
+		return ctrl.Result{}, r.setBackupPhase(ctx, backup, appv1.BackupFailed,
+			fmt.Sprintf("WebService %s has no database", app.Name))
+	}
+	if _, ok := databaseProvider(app).(mysqlProvider); !ok {
+		return ctrl.Result{}, r.setBackupPhase(ctx, backup, appv1.BackupFailed,
+			fmt.Sprintf("WebService %s runs %s, only MySQL can be backed up", app.Name, app.Spec.DatabaseSpec().Engine))
+	}
+	if backup.Labels[webServiceLabel] != app.Name {
+		patch := client.MergeFrom(backup.DeepCopy())
+		if backup.Labels == nil {
+			backup.Labels = map[string]string{}
+		}
+		backup.Labels[webServiceLabel] = app.Name
+		if err := r.Patch(ctx, backup, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	ws := r.webServices()
+	cfg := r.Config.Get()
+	secret, err := r.credentialsSnapshot(ctx, app, backup)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	result, err := ws.ensureSecret(req, app, secret)
+	if result != nil {
+		return *result, err
+	}
+	if backup.Spec.Storage.S3 == nil {
+		if err := r.Create(ctx, backupPVC(cfg, app, backup)); err != nil && !errors.IsAlreadyExists(err) {
+			return ctrl.Result{}, err
+		}
+	}
+
+	job := &batchv1.Job{}
+	err = r.Get(ctx, types.NamespacedName{Name: backup.Name, Namespace: backup.Namespace}, job)
+	if errors.IsNotFound(err) {
+		if !ws.isDatabaseUp(app) {
+			return ctrl.Result{RequeueAfter: backupResync},
+				r.setBackupPhase(ctx, backup, appv1.BackupPending, "Waiting for the MySQL primary to become ready")
+		}
+		// The status goes first, a Job without a path in the status would
+		// leave its file behind on deletion.
+		base := backup.DeepCopy()
+		now := metav1.Now()
+		backup.Status.Path = backupPath(backup)
+		backup.Status.Location = backupLocation(backup)
+		backup.Status.CredentialsSecret = secret.Name
+		backup.Status.Job = backup.Name
+		backup.Status.StartTime = &now
+		backup.Status.Phase = appv1.BackupRunning
+		backup.Status.Message = ""
+		if err := r.Status().Patch(ctx, backup, client.MergeFrom(base)); err != nil {
+			return ctrl.Result{}, err
+		}
+		job = newBackupJob(cfg, app, backup)
+		if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info("Starting backup", "Job.Name", job.Name, "location", backup.Status.Location)
+		return ctrl.Result{}, r.Create(ctx, job)
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case jobFailed(job):
+		return ctrl.Result{}, r.setBackupPhase(ctx, backup, appv1.BackupFailed,
+			fmt.Sprintf("Job %s failed, see its logs", job.Name))
+	case job.Status.Succeeded == 0:
+		return ctrl.Result{}, nil
+	}
+
+	res, err := readBackupResult(ctx, r.Client, job)
+	if err != nil {
+		return ctrl.Result{}, r.setBackupPhase(ctx, backup, appv1.BackupFailed, err.Error())
+	}
+	if backup.Spec.Storage.S3 != nil {
+		msg, err := r.verifyUpload(ctx, backup, res)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if msg != "" {
+			return ctrl.Result{}, r.setBackupPhase(ctx, backup, appv1.BackupFailed, msg)
+		}
+	}
+
+	log.Info("Backup completed", "location", backup.Status.Location, "size", res.SizeBytes)
+	base := backup.DeepCopy()
+	now := metav1.Now()
+	backup.Status.CompletionTime = &now
+	backup.Status.SizeBytes = res.SizeBytes
+	backup.Status.Checksum = res.Checksum
+	backup.Status.BinlogFile = res.BinlogFile
+	backup.Status.BinlogPosition = res.BinlogPosition
+	backup.Status.Phase = appv1.BackupCompleted
+	backup.Status.Message = ""
+	return ctrl.Result{}, r.Status().Patch(ctx, backup, client.MergeFrom(base))
+}
+
+// verifyUpload checks that the uploaded object has the size the dump
+// reported. It returns a message if it does not.
+func (r *WebServiceBackupReconciler) verifyUpload(ctx context.Context, backup *appv1.WebServiceBackup, res *backupResult) (string, error) {
+	s3 := backup.Spec.Storage.S3
+	store, err := openBackupStore(ctx, r.Client, r.OpenStore, backup.Namespace, s3)
+	if err != nil {
+		return "", err
+	}
+	info, err := store.Stat(ctx, s3.Bucket, backup.Status.Path)
+	if err == objectstore.ErrNotFound {
+		return fmt.Sprintf("%s was not uploaded", backup.Status.Location), nil
+	} else if err != nil {
+		return "", err
+	}
+	if info.Size != res.SizeBytes {
+		return fmt.Sprintf("%s has %d bytes, the dump had %d", backup.Status.Location, info.Size, res.SizeBytes), nil
+	}
+	return "", nil
+}
+
+// credentialsSnapshot copies the MySQL credentials of app into a Secret
+// owned by backup.
+func (r *WebServiceBackupReconciler) credentialsSnapshot(ctx context.Context, app *appv1.WebService, backup *appv1.WebServiceBackup) (*corev1.Secret, error) {
+	cfg := r.Config.Get()
+	current := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: databaseSecretName(app), Namespace: app.Namespace}, current); err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{}
+	for _, key := range []string{secretKeyRootPassword, secretKeyUsername, secretKeyPassword, secretKeyReplicationPassword} {
+		if v := current.Data[key]; len(v) > 0 {
+			data[key] = v
+		}
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        backupCredentialsName(backup),
+			Namespace:   backup.Namespace,
+			Labels:      cfg.WithLabels(labels(app, "backup")),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	return secret, controllerutil.SetControllerReference(backup, secret, r.Scheme)
+}
+
+// deleteBackupFile removes the file of a deleted backup, then the
+// finalizer. Files on a PVC are removed by a Job.
+func (r *WebServiceBackupReconciler) deleteBackupFile(ctx context.Context, backup *appv1.WebServiceBackup) error {
+	if !controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+		return nil
+	}
+	log := r.Log.WithValues("webservicebackup", client.ObjectKeyFromObject(backup))
+
+	if backup.Status.Path != "" {
+		if s3 := backup.Spec.Storage.S3; s3 != nil {
+			store, err := openBackupStore(ctx, r.Client, r.OpenStore, backup.Namespace, s3)
+			if errors.IsNotFound(err) {
+				log.Info("S3 credentials are gone, leaving the backup file behind", "location", backup.Status.Location)
+			} else if err != nil {
+				return err
+			} else if err := store.Delete(ctx, s3.Bucket, backup.Status.Path); err != nil {
+				return err
+			}
+		} else {
+			done, err := r.runCleanupJob(ctx, backup)
+			if err != nil || !done {
+				return err
+			}
+		}
+	}
+
+	log.Info("Backup file deleted, removing finalizer", "location", backup.Status.Location)
+	controllerutil.RemoveFinalizer(backup, backupFinalizer)
+	return r.Update(ctx, backup)
+}
+
+// runCleanupJob deletes the backup file from the PVC and reports whether
+// the Job is done. A failed Job is logged and not retried.
+func (r *WebServiceBackupReconciler) runCleanupJob(ctx context.Context, backup *appv1.WebServiceBackup) (bool, error) {
+	name := backup.Name + "-cleanup"
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: backup.Namespace}, job)
+	if errors.IsNotFound(err) {
+		job = r.cleanupJob(backup, name)
+		return false, r.Create(ctx, job)
+	} else if err != nil {
+		return false, err
+	}
+	if jobFailed(job) {
+		r.Log.Info("Failed to delete the backup file, leaving it behind", "location", backup.Status.Location, "Job.Name", job.Name)
+		return true, nil
+	}
+	return job.Status.Succeeded > 0, nil
+}
+
+func (r *WebServiceBackupReconciler) cleanupJob(backup *appv1.WebServiceBackup, name string) *batchv1.Job {
+	cfg := r.Config.Get()
+	backoffLimit := int32(2)
+	// The WebService may be gone, the labels only need its name.
+	tierLabels := labels(&appv1.WebService{ObjectMeta: metav1.ObjectMeta{Name: backup.Spec.WebServiceName}}, "backup")
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   backup.Namespace,
+			Labels:      cfg.WithLabels(tierLabels),
+			Annotations: cfg.WithAnnotations(nil),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: cfg.WithLabels(tierLabels)},
+				Spec: corev1.PodSpec{
+					RestartPolicy:    corev1.RestartPolicyNever,
+					ImagePullSecrets: cfg.ImagePullSecrets,
+					Containers: []corev1.Container{{
+						Name:    "cleanup",
+						Image:   cfg.Image(config.ComponentMysql, ""),
+						Command: []string{"sh", "-c", `rm -rf "$BACKUP_FILE" "${BACKUP_FILE%.*.gz}.d" "${BACKUP_FILE%.gz}"`},
+						Env: []corev1.EnvVar{
+							{Name: "BACKUP_FILE", Value: path.Join(dumpMountPath, backup.Status.Path)},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "dump", MountPath: dumpMountPath}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "dump",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: backupClaimName(backup)},
+						},
+					}},
+				},
+			},
+		},
+	}
+	controllerutil.SetControllerReference(backup, job, r.Scheme)
+	return job
+}
+
+func (r *WebServiceBackupReconciler) setBackupPhase(ctx context.Context, backup *appv1.WebServiceBackup, phase appv1.BackupPhase, message string) error {
+	if backup.Status.Phase == phase && backup.Status.Message == message {
+		return nil
+	}
+	patch := client.MergeFrom(backup.DeepCopy())
+	backup.Status.Phase = phase
+	backup.Status.Message = message
+	if phase == appv1.BackupFailed {
+		now := metav1.Now()
+		backup.Status.CompletionTime = &now
+	}
+	return r.Status().Patch(ctx, backup, patch)
+}
+
+// webServices gives access to the builders and ensure helpers of the
+// WebService controller.
+func (r *WebServiceBackupReconciler) webServices() *WebServiceReconciler {
+	return &WebServiceReconciler{Client: r.Client, Scheme: r.Scheme, Log: r.Log, Config: r.Config, Recorder: r.Recorder}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WebServiceBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appv1.WebServiceBackup{}).
+		Owns(&batchv1.Job{}, builder.WithPredicates(jobPredicate)).
+		Complete(r)
+}
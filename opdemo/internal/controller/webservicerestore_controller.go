@@ -0,0 +1,432 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/config"
+)
+
+// restoredFromAnnotation marks a WebService created by a restore with the
+// restore's name.
+const restoredFromAnnotation = "app.enflame.cn/restored-from"
+
+// mysqldumpRestoreScript verifies and loads a dump, then optionally replays
+// the binary log of the source primary from the dump's coordinates up to
+// $STOP_DATETIME. The replayed events get new GTIDs on the target.
+const mysqldumpRestoreScript = `set -e
+echo "$BACKUP_CHECKSUM  $BACKUP_FILE" | sha256sum -c -
+gunzip -t "$BACKUP_FILE"
+gunzip -c "$BACKUP_FILE" | mysql -h "$DB_HOST" -P "$DB_PORT" -uroot
+if [ -n "$STOP_DATETIME" ]; then
+  mysqlbinlog --read-from-remote-server --host="$SOURCE_HOST" --port="$SOURCE_PORT" --user=root --password="$SOURCE_PWD" --skip-gtids --start-position="$BINLOG_POSITION" --stop-datetime="$STOP_DATETIME" --to-last-log "$BINLOG_FILE" > /tmp/replay.sql
+  mysql -h "$DB_HOST" -P "$DB_PORT" -uroot < /tmp/replay.sql
+fi`
+
+// xtrabackupRestoreScript unpacks and prepares a data directory on the
+// empty volume of the first pod of the clone. auto.cnf is removed so the
+// clone gets its own server UUID.
+var xtrabackupRestoreScript = fmt.Sprintf(`set -e
+echo "$BACKUP_CHECKSUM  $BACKUP_FILE" | sha256sum -c -
+find %[1]s -mindepth 1 -delete
+tar xzf "$BACKUP_FILE" -C %[1]s
+xtrabackup --prepare --target-dir=%[1]s
+rm -f %[1]s/auto.cnf
+chown -R %[2]d:%[2]d %[1]s`, mysqlDataPath, mysqlUID)
+
+// WebServiceRestoreReconciler loads WebServiceBackups into WebServices.
+type WebServiceRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Log      logr.Logger
+	Config   *config.Store
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservicerestores,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservicerestores/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservicerestores/finalizers,verbs=update
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservicebackups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=app.enflame.cn,resources=webservices,verbs=get;list;watch;create
+
+// Reconcile runs a restore once. A clone is created after its credentials
+// and, for Xtrabackup, its data directory are in place; a dump is loaded
+// once the primary of the target is ready.
+func (r *WebServiceRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("webservicerestore", req.NamespacedName)
+
+	restore := &appv1.WebServiceRestore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if restore.Status.Phase == appv1.BackupCompleted || restore.Status.Phase == appv1.BackupFailed {
+		return ctrl.Result{}, nil
+	}
+
+	backup := &appv1.WebServiceBackup{}
+	err := r.Get(ctx, types.NamespacedName{Name: restore.Spec.BackupName, Namespace: restore.Namespace}, backup)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, r.failRestore(ctx, restore, fmt.Sprintf("WebServiceBackup %s not found", restore.Spec.BackupName))
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	switch backup.Status.Phase {
+	case appv1.BackupCompleted:
+	case appv1.BackupFailed:
+		return ctrl.Result{}, r.failRestore(ctx, restore, fmt.Sprintf("WebServiceBackup %s failed", backup.Name))
+	default:
+		// The backup is watched, its completion triggers the restore.
+		return ctrl.Result{}, r.setRestorePhase(ctx, restore, appv1.BackupPending,
+			fmt.Sprintf("Waiting for WebServiceBackup %s to complete", backup.Name))
+	}
+	if msg := checkRestore(restore, backup); msg != "" {
+		return ctrl.Result{}, r.failRestore(ctx, restore, msg)
+	}
+
+	var source *appv1.WebService
+	app := &appv1.WebService{}
+	err = r.Get(ctx, types.NamespacedName{Name: backup.Spec.WebServiceName, Namespace: restore.Namespace}, app)
+	if err == nil {
+		source = app
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if source == nil && (restore.Spec.Target == nil || restore.Spec.PointInTime != nil) {
+		return ctrl.Result{}, r.failRestore(ctx, restore, fmt.Sprintf("WebService %s not found", backup.Spec.WebServiceName))
+	}
+
+	target := source
+	if restore.Spec.Target != nil {
+		target = &appv1.WebService{}
+		err := r.Get(ctx, types.NamespacedName{Name: restore.Spec.Target.Name, Namespace: restore.Namespace}, target)
+		switch {
+		case errors.IsNotFound(err):
+			clone, msg := cloneOf(restore, source)
+			if msg != "" {
+				return ctrl.Result{}, r.failRestore(ctx, restore, msg)
+			}
+			ready, err := r.prepareClone(ctx, req, restore, backup, clone)
+			if err != nil || !ready {
+				return ctrl.Result{}, err
+			}
+			log.Info("Creating WebService from backup", "target", clone.Name, "backup", backup.Name)
+			if err := r.Create(ctx, clone); err != nil {
+				return ctrl.Result{}, err
+			}
+			target = clone
+		case err != nil:
+			return ctrl.Result{}, err
+		case target.Annotations[restoredFromAnnotation] != restore.Name:
+			return ctrl.Result{}, r.failRestore(ctx, restore, fmt.Sprintf("WebService %s already exists", target.Name))
+		}
+	}
+	if restore.Status.TargetWebService != target.Name {
+		patch := client.MergeFrom(restore.DeepCopy())
+		restore.Status.TargetWebService = target.Name
+		if err := r.Status().Patch(ctx, restore, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	ws := r.webServices()
+	if !ws.isDatabaseUp(target) {
+		return ctrl.Result{RequeueAfter: backupResync}, r.setRestorePhase(ctx, restore, appv1.BackupRunning,
+			fmt.Sprintf("Waiting for the MySQL primary of WebService %s", target.Name))
+	}
+	if backup.Spec.Method == appv1.BackupMethodXtrabackup {
+		// The data directory was restored before the clone started.
+		return ctrl.Result{}, r.completeRestore(ctx, restore)
+	}
+
+	job := &batchv1.Job{}
+	err = r.Get(ctx, types.NamespacedName{Name: restore.Name, Namespace: restore.Namespace}, job)
+	if errors.IsNotFound(err) {
+		job = newRestoreJob(r.Config.Get(), restore, backup, source, target)
+		if err := controllerutil.SetControllerReference(restore, job, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info("Loading backup", "backup", backup.Name, "target", target.Name, "pointInTime", restore.Spec.PointInTime)
+		if err := r.Create(ctx, job); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.setRestorePhase(ctx, restore, appv1.BackupRunning,
+			fmt.Sprintf("Loading %s into WebService %s", backup.Status.Location, target.Name))
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.finishWithJob(ctx, restore, job)
+}
+
+// checkRestore returns why restore cannot be done with backup, or "".
+func checkRestore(restore *appv1.WebServiceRestore, backup *appv1.WebServiceBackup) string {
+	pit := restore.Spec.PointInTime
+	if backup.Spec.Method == appv1.BackupMethodXtrabackup {
+		if restore.Spec.Target == nil {
+			return "Xtrabackup backups can only be restored into a new WebService, set spec.target"
+		}
+		if pit != nil {
+			return "point in time restores need a Mysqldump backup"
+		}
+	}
+	if pit == nil {
+		return ""
+	}
+	switch {
+	case backup.Status.BinlogFile == "":
+		return fmt.Sprintf("WebServiceBackup %s has no binary log coordinates", backup.Name)
+	case backup.Status.StartTime != nil && pit.Before(backup.Status.StartTime):
+		return fmt.Sprintf("pointInTime is before WebServiceBackup %s was taken", backup.Name)
+	case pit.After(time.Now()):
+		return "pointInTime is in the future"
+	}
+	return ""
+}
+
+// cloneOf builds the WebService of restore.Spec.Target. Without a spec the
+// source spec is copied, the children of the clone are named after the
+// clone so both can run side by side. The clone gets its own credentials
+// Secret and no backup schedule.
+func cloneOf(restore *appv1.WebServiceRestore, source *appv1.WebService) (*appv1.WebService, string) {
+	target := restore.Spec.Target
+	var spec *appv1.WebServiceSpec
+	switch {
+	case target.Spec != nil:
+		spec = target.Spec.DeepCopy()
+	case source == nil:
+		return nil, fmt.Sprintf("the source WebService is gone, set spec.target.spec of %s", restore.Name)
+	default:
+		spec = source.Spec.DeepCopy()
+		spec.NameOverride = ""
+		spec.RollbackTo = nil
+		if db := spec.DatabaseSpec(); db != nil {
+			db.Auth = nil
+			db.Backup = nil
+		}
+	}
+	if spec.DatabaseSpec() == nil || (spec.Webapp == nil && len(spec.Components) == 0) {
+		return nil, "the target spec needs a database and a webapp or components"
+	}
+	return &appv1.WebService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      target.Name,
+			Namespace: restore.Namespace,
+			Annotations: map[string]string{
+				restoredFromAnnotation: restore.Name,
+				namingSchemeAnnotation: namingSchemeV1,
+			},
+		},
+		Spec: *spec,
+	}, ""
+}
+
+// prepareClone gives clone the credentials of the backup and, for
+// Xtrabackup, restores the data directory of its first pod. It reports
+// whether the clone can be created.
+func (r *WebServiceRestoreReconciler) prepareClone(ctx context.Context, req ctrl.Request, restore *appv1.WebServiceRestore, backup *appv1.WebServiceBackup, clone *appv1.WebService) (bool, error) {
+	cfg := r.Config.Get()
+	ws := r.webServices()
+
+	// The backup holds the MySQL accounts of the source, the clone must
+	// start with the same passwords. The WebService controller adopts the
+	// Secret and keeps its values.
+	if !usesExistingSecret(clone) {
+		creds := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: backup.Status.CredentialsSecret, Namespace: backup.Namespace}, creds)
+		if errors.IsNotFound(err) {
+			return false, r.failRestore(ctx, restore, fmt.Sprintf("credentials Secret %s of the backup not found", backup.Status.CredentialsSecret))
+		} else if err != nil {
+			return false, err
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        databaseSecretName(clone),
+				Namespace:   clone.Namespace,
+				Labels:      cfg.WithLabels(databaseLabels(clone)),
+				Annotations: cfg.WithAnnotations(nil),
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: creds.Data,
+		}
+		result, err := ws.ensureSecret(req, clone, secret)
+		if result != nil {
+			return false, err
+		}
+	}
+	if backup.Spec.Method != appv1.BackupMethodXtrabackup {
+		return true, nil
+	}
+
+	// The StatefulSet adopts the PVC of its first pod by name.
+	data := ws.databaseStatefulSet(clone).Spec.VolumeClaimTemplates[0]
+	data.Name = databaseDataVolume + "-" + databasePodName(clone, 0)
+	data.Namespace = clone.Namespace
+	data.Labels = cfg.WithLabels(data.Labels)
+	if err := r.Create(ctx, &data); err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: restore.Name, Namespace: restore.Namespace}, job)
+	if errors.IsNotFound(err) {
+		job = newRestoreJob(cfg, restore, backup, nil, clone)
+		if err := controllerutil.SetControllerReference(restore, job, r.Scheme); err != nil {
+			return false, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return false, err
+		}
+	} else if err != nil {
+		return false, err
+	}
+	switch {
+	case jobFailed(job):
+		return false, r.failRestore(ctx, restore, fmt.Sprintf("Job %s failed, see its logs", job.Name))
+	case job.Status.Succeeded == 0:
+		return false, r.setRestorePhase(ctx, restore, appv1.BackupRunning,
+			fmt.Sprintf("Restoring the data directory of WebService %s from %s", clone.Name, backup.Status.Location))
+	}
+	return true, nil
+}
+
+// newRestoreJob loads backup into target. source is only needed for a
+// point in time restore.
+func newRestoreJob(cfg *config.OperatorConfig, restore *appv1.WebServiceRestore, backup *appv1.WebServiceBackup, source, target *appv1.WebService) *batchv1.Job {
+	s3 := backup.Spec.Storage.S3
+	claim := backupClaimName(backup)
+	if s3 != nil {
+		claim = ""
+	}
+	file := path.Join(dumpMountPath, backup.Status.Path)
+	env := []corev1.EnvVar{
+		{Name: "BACKUP_FILE", Value: file},
+		{Name: "BACKUP_CHECKSUM", Value: strings.TrimPrefix(backup.Status.Checksum, "sha256:")},
+	}
+
+	script := mysqldumpRestoreScript
+	if backup.Spec.Method == appv1.BackupMethodXtrabackup {
+		script = xtrabackupRestoreScript
+	}
+	if pit := restore.Spec.PointInTime; pit != nil {
+		// mysqlbinlog reads the time in the local time zone.
+		env = append(env,
+			corev1.EnvVar{Name: "TZ", Value: "UTC"},
+			corev1.EnvVar{Name: "STOP_DATETIME", Value: pit.UTC().Format(time.DateTime)},
+			corev1.EnvVar{Name: "BINLOG_FILE", Value: backup.Status.BinlogFile},
+			corev1.EnvVar{Name: "BINLOG_POSITION", Value: fmt.Sprint(backup.Status.BinlogPosition)},
+			corev1.EnvVar{Name: "SOURCE_HOST", Value: mysqlPodHost(source, 0)},
+			corev1.EnvVar{Name: "SOURCE_PORT", Value: fmt.Sprint(databaseContainerPort(source))},
+			corev1.EnvVar{Name: "SOURCE_PWD", ValueFrom: databaseSecretKeyRef(source, secretKeyRootPassword)},
+		)
+	}
+
+	job := newMysqlClientJob(cfg, target, restore.Name, "restore", claim, script, env...)
+	pod := &job.Spec.Template.Spec
+	c := &pod.Containers[0]
+	if backup.Spec.Method == appv1.BackupMethodXtrabackup {
+		c.Image = cfg.Image(config.ComponentXtrabackup, "")
+		c.SecurityContext = rootSecurityContext()
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: databaseDataVolume, MountPath: mysqlDataPath})
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: databaseDataVolume,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: databaseDataVolume + "-" + databasePodName(target, 0),
+				},
+			},
+		})
+	}
+	if s3 != nil {
+		addScratchVolume(pod, c)
+		pod.InitContainers = []corev1.Container{transferContainerFor(cfg, s3, backup.Status.Path, file, downloadScript)}
+	}
+	return job
+}
+
+// finishWithJob moves restore to the outcome of its Job.
+func (r *WebServiceRestoreReconciler) finishWithJob(ctx context.Context, restore *appv1.WebServiceRestore, job *batchv1.Job) error {
+	switch {
+	case jobFailed(job):
+		return r.failRestore(ctx, restore, fmt.Sprintf("Job %s failed, see its logs", job.Name))
+	case job.Status.Succeeded > 0:
+		return r.completeRestore(ctx, restore)
+	}
+	return nil
+}
+
+func (r *WebServiceRestoreReconciler) setRestorePhase(ctx context.Context, restore *appv1.WebServiceRestore, phase appv1.BackupPhase, message string) error {
+	if restore.Status.Phase == phase && restore.Status.Message == message {
+		return nil
+	}
+	patch := client.MergeFrom(restore.DeepCopy())
+	restore.Status.Phase = phase
+	restore.Status.Message = message
+	restore.Status.Job = ""
+	if phase != appv1.BackupPending {
+		restore.Status.Job = restore.Name
+	}
+	if phase == appv1.BackupCompleted || phase == appv1.BackupFailed {
+		now := metav1.Now()
+		restore.Status.CompletionTime = &now
+	}
+	return r.Status().Patch(ctx, restore, patch)
+}
+
+func (r *WebServiceRestoreReconciler) failRestore(ctx context.Context, restore *appv1.WebServiceRestore, message string) error {
+	r.Log.Info("Restore failed", "webservicerestore", client.ObjectKeyFromObject(restore), "reason", message)
+	return r.setRestorePhase(ctx, restore, appv1.BackupFailed, message)
+}
+
+func (r *WebServiceRestoreReconciler) completeRestore(ctx context.Context, restore *appv1.WebServiceRestore) error {
+	return r.setRestorePhase(ctx, restore, appv1.BackupCompleted,
+		fmt.Sprintf("Restored into WebService %s", restore.Status.TargetWebService))
+}
+
+// webServices gives access to the builders and ensure helpers of the
+// WebService controller.
+func (r *WebServiceRestoreReconciler) webServices() *WebServiceReconciler {
+	return &WebServiceReconciler{Client: r.Client, Scheme: r.Scheme, Log: r.Log, Config: r.Config, Recorder: r.Recorder}
+}
+
+// restoresOfBackup maps a WebServiceBackup to the restores waiting for it.
+func (r *WebServiceRestoreReconciler) restoresOfBackup(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list appv1.WebServiceRestoreList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "Failed to list WebServiceRestores")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, restore := range list.Items {
+		if restore.Spec.BackupName == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&restore)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WebServiceRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appv1.WebServiceRestore{}).
+		Owns(&batchv1.Job{}, builder.WithPredicates(jobPredicate)).
+		Watches(&appv1.WebServiceBackup{}, handler.EnqueueRequestsFromMapFunc(r.restoresOfBackup)).
+		Complete(r)
+}
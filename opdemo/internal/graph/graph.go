@@ -0,0 +1,190 @@
+// Package graph runs a reconcile as a graph of nodes. A node applies a
+// child, or a few children that belong together, names the nodes it depends
+// on and may have a readiness predicate. Run applies every node whose
+// dependencies are ready in dependency order and reports the first node
+// holding the rest back, so that the controller can show it in the status
+// and requeue with a backoff instead of hand coding each gate.
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Requeue delays of a blocked reconcile, see Backoff.
+const (
+	MinBackoff = 5 * time.Second
+	MaxBackoff = 5 * time.Minute
+)
+
+// Predicate reports whether a node is ready, and when it is not, what it
+// waits for.
+type Predicate func(ctx context.Context) (ready bool, reason string, err error)
+
+// Node is a step of the reconcile.
+type Node struct {
+	Name string
+	// DependsOn names the nodes that must be ready before this one is
+	// applied.
+	DependsOn []string
+	// Apply brings the children of the node to their desired state, nil
+	// for a node that only gates.
+	Apply func(ctx context.Context) error
+	// Ready is asked after Apply, nil means ready once applied. Only the
+	// readiness of nodes something depends on holds the reconcile back.
+	Ready Predicate
+}
+
+// Task is a node for a step that applies and reports completion in one
+// call, such as a migration that takes several passes. It is ready once fn
+// returned true and reports reason until then.
+func Task(name, reason string, fn func(ctx context.Context) (bool, error), dependsOn ...string) Node {
+	var done bool
+	return Node{
+		Name:      name,
+		DependsOn: dependsOn,
+		Apply: func(ctx context.Context) (err error) {
+			done, err = fn(ctx)
+			return err
+		},
+		Ready: func(context.Context) (bool, string, error) {
+			return done, reason, nil
+		},
+	}
+}
+
+// Graph is a set of nodes. The zero value is empty and ready to use.
+type Graph struct {
+	nodes []Node
+}
+
+// Add appends nodes to g. Nodes may depend on nodes added later.
+func (g *Graph) Add(nodes ...Node) {
+	g.nodes = append(g.nodes, nodes...)
+}
+
+// Order returns the nodes in dependency order; nodes that do not depend on
+// each other keep the order they were added in. It fails on duplicate
+// names, unknown dependencies and cycles.
+func (g *Graph) Order() ([]Node, error) {
+	index := make(map[string]int, len(g.nodes))
+	for i, n := range g.nodes {
+		if _, ok := index[n.Name]; ok {
+			return nil, fmt.Errorf("node %s is added twice", n.Name)
+		}
+		index[n.Name] = i
+	}
+	pending := make([]int, len(g.nodes))
+	dependents := make([][]int, len(g.nodes))
+	for i, n := range g.nodes {
+		for _, dep := range n.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("node %s depends on unknown node %s", n.Name, dep)
+			}
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	order := make([]Node, 0, len(g.nodes))
+	done := make([]bool, len(g.nodes))
+	for len(order) < len(g.nodes) {
+		next := -1
+		for i := range g.nodes {
+			if !done[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i, n := range g.nodes {
+				if !done[i] {
+					cycle = append(cycle, n.Name)
+				}
+			}
+			return nil, fmt.Errorf("nodes %v depend on each other", cycle)
+		}
+		done[next] = true
+		order = append(order, g.nodes[next])
+		for _, i := range dependents[next] {
+			pending[i]--
+		}
+	}
+	return order, nil
+}
+
+// Blocked is a node that is not ready.
+type Blocked struct {
+	Node   string
+	Reason string
+}
+
+// Result tells what a Run did.
+type Result struct {
+	// Applied lists the nodes applied, in order.
+	Applied []string
+	// Waiting lists the nodes left out because a dependency is not ready.
+	Waiting []string
+	// Blocked is the first node found not ready, nil when all are.
+	Blocked *Blocked
+}
+
+// Run applies the nodes of g in dependency order. A node whose
+// dependencies are not all ready is left out, together with the nodes
+// depending on it, while the other nodes go on. The first error stops the
+// run and is returned with the name of its node.
+func (g *Graph) Run(ctx context.Context) (*Result, error) {
+	order, err := g.Order()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	ready := make(map[string]bool, len(order))
+	for _, n := range order {
+		waiting := false
+		for _, dep := range n.DependsOn {
+			waiting = waiting || !ready[dep]
+		}
+		if waiting {
+			result.Waiting = append(result.Waiting, n.Name)
+			continue
+		}
+
+		if n.Apply != nil {
+			if err := n.Apply(ctx); err != nil {
+				return result, fmt.Errorf("%s: %w", n.Name, err)
+			}
+		}
+		result.Applied = append(result.Applied, n.Name)
+		ready[n.Name] = true
+		if n.Ready == nil {
+			continue
+		}
+		ok, reason, err := n.Ready(ctx)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", n.Name, err)
+		}
+		ready[n.Name] = ok
+		if !ok && result.Blocked == nil {
+			result.Blocked = &Blocked{Node: n.Name, Reason: reason}
+		}
+	}
+	return result, nil
+}
+
+// Backoff is the requeue delay of a reconcile that has been blocked for
+// blockedFor: as long again, at least MinBackoff and at most MaxBackoff.
+// The checks thin out while the wait goes on, watches on the children
+// still requeue as soon as something changes.
+func Backoff(blockedFor time.Duration) time.Duration {
+	switch {
+	case blockedFor < MinBackoff:
+		return MinBackoff
+	case blockedFor > MaxBackoff:
+		return MaxBackoff
+	}
+	return blockedFor
+}
@@ -0,0 +1,169 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"gitee.enflame.cn/ModelOps/opdemo/internal/graph"
+)
+
+// node is a node that logs its name to applied and is ready unless its
+// name is in unready.
+func node(name string, applied *[]string, unready map[string]string, deps ...string) graph.Node {
+	return graph.Node{
+		Name:      name,
+		DependsOn: deps,
+		Apply: func(context.Context) error {
+			*applied = append(*applied, name)
+			return nil
+		},
+		Ready: func(context.Context) (bool, string, error) {
+			reason, ok := unready[name]
+			return !ok, reason, nil
+		},
+	}
+}
+
+func names(nodes []graph.Node) []string {
+	var out []string
+	for _, n := range nodes {
+		out = append(out, n.Name)
+	}
+	return out
+}
+
+var _ = Describe("Order", func() {
+	It("puts dependencies first and keeps the order of the rest", func() {
+		var g graph.Graph
+		g.Add(
+			graph.Node{Name: "web", DependsOn: []string{"schema"}},
+			graph.Node{Name: "database"},
+			graph.Node{Name: "schema", DependsOn: []string{"database"}},
+			graph.Node{Name: "cache"},
+		)
+		order, err := g.Order()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(order)).To(Equal([]string{"database", "schema", "web", "cache"}))
+	})
+
+	It("rejects unknown dependencies, duplicates and cycles", func() {
+		var unknown graph.Graph
+		unknown.Add(graph.Node{Name: "web", DependsOn: []string{"database"}})
+		_, err := unknown.Order()
+		Expect(err).To(MatchError(ContainSubstring("unknown node database")))
+
+		var twice graph.Graph
+		twice.Add(graph.Node{Name: "web"}, graph.Node{Name: "web"})
+		_, err = twice.Order()
+		Expect(err).To(MatchError(ContainSubstring("added twice")))
+
+		var cycle graph.Graph
+		cycle.Add(
+			graph.Node{Name: "a", DependsOn: []string{"b"}},
+			graph.Node{Name: "b", DependsOn: []string{"a"}},
+			graph.Node{Name: "c"},
+		)
+		_, err = cycle.Order()
+		Expect(err).To(MatchError("nodes [a b] depend on each other"))
+	})
+})
+
+var _ = Describe("Run", func() {
+	var (
+		ctx     context.Context
+		applied []string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		applied = nil
+	})
+
+	It("applies every node when all are ready", func() {
+		var g graph.Graph
+		g.Add(
+			node("database", &applied, nil),
+			node("web", &applied, nil, "database"),
+		)
+		result, err := g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(Equal([]string{"database", "web"}))
+		Expect(result.Applied).To(Equal(applied))
+		Expect(result.Waiting).To(BeEmpty())
+		Expect(result.Blocked).To(BeNil())
+	})
+
+	It("holds back the dependents of a node that is not ready", func() {
+		unready := map[string]string{"database": "0/1 replicas ready"}
+		var g graph.Graph
+		g.Add(
+			node("database", &applied, unready),
+			node("schema", &applied, nil, "database"),
+			node("web", &applied, nil, "schema"),
+			node("cache", &applied, nil),
+		)
+		result, err := g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(Equal([]string{"database", "cache"}))
+		Expect(result.Waiting).To(Equal([]string{"schema", "web"}))
+		Expect(result.Blocked).To(Equal(&graph.Blocked{Node: "database", Reason: "0/1 replicas ready"}))
+	})
+
+	It("reports the first node that is not ready", func() {
+		unready := map[string]string{"api": "starting", "worker": "starting"}
+		var g graph.Graph
+		g.Add(
+			node("api", &applied, unready),
+			node("worker", &applied, unready),
+			node("web", &applied, nil, "api", "worker"),
+		)
+		result, err := g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Blocked.Node).To(Equal("api"))
+		Expect(result.Waiting).To(Equal([]string{"web"}))
+	})
+
+	It("stops at the first error", func() {
+		boom := errors.New("boom")
+		var g graph.Graph
+		g.Add(
+			graph.Node{Name: "database", Apply: func(context.Context) error { return boom }},
+			node("cache", &applied, nil),
+		)
+		_, err := g.Run(ctx)
+		Expect(err).To(MatchError(boom))
+		Expect(err).To(MatchError(ContainSubstring("database: boom")))
+		Expect(applied).To(BeEmpty())
+	})
+
+	It("gates on a task until it is done", func() {
+		done := false
+		var g graph.Graph
+		g.Add(
+			graph.Task("schema", "migrating", func(context.Context) (bool, error) { return done, nil }),
+			node("web", &applied, nil, "schema"),
+		)
+		result, err := g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Blocked).To(Equal(&graph.Blocked{Node: "schema", Reason: "migrating"}))
+		Expect(applied).To(BeEmpty())
+
+		done = true
+		result, err = g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Blocked).To(BeNil())
+		Expect(applied).To(Equal([]string{"web"}))
+	})
+})
+
+var _ = Describe("Backoff", func() {
+	It("grows with the wait within its bounds", func() {
+		Expect(graph.Backoff(0)).To(Equal(graph.MinBackoff))
+		Expect(graph.Backoff(time.Minute)).To(Equal(time.Minute))
+		Expect(graph.Backoff(time.Hour)).To(Equal(graph.MaxBackoff))
+	})
+})
@@ -0,0 +1,103 @@
+// Package history reads and writes the spec history of a WebService. Every
+// spec the children were synced to is kept as a ControllerRevision owned by
+// the WebService and numbered like the revisions of a Deployment, so that
+// older specs can be listed, compared and rolled back to.
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pmezard/go-difflib/difflib"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+)
+
+// Tier is the tier label of the revisions.
+const Tier = "revision"
+
+// DefaultLimit is the number of revisions kept when
+// spec.revisionHistoryLimit is unset.
+const DefaultLimit = 10
+
+// Selector matches the revisions of the WebService named webService.
+func Selector(webService string) client.MatchingLabels {
+	return client.MatchingLabels{"webservice_cr": webService, "tier": Tier}
+}
+
+// Snapshot returns the recorded form of spec and a hash of it, the same
+// spec always gives the same hash. rollbackTo and revisionHistoryLimit are
+// left out, they steer the history instead of the children.
+func Snapshot(spec *appv1.WebServiceSpec) ([]byte, string, error) {
+	s := spec.DeepCopy()
+	s.RollbackTo = nil
+	s.RevisionHistoryLimit = nil
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:])[:10], nil
+}
+
+// Spec decodes the spec recorded in rev.
+func Spec(rev *appsv1.ControllerRevision) (*appv1.WebServiceSpec, error) {
+	spec := &appv1.WebServiceSpec{}
+	if err := json.Unmarshal(rev.Data.Raw, spec); err != nil {
+		return nil, fmt.Errorf("revision %d of %s: %w", rev.Revision, rev.Name, err)
+	}
+	return spec, nil
+}
+
+// Sort orders revs oldest first.
+func Sort(revs []appsv1.ControllerRevision) {
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Revision < revs[j].Revision })
+}
+
+// Find returns the revision numbered n, nil if revs has none.
+func Find(revs []appsv1.ControllerRevision, n int64) *appsv1.ControllerRevision {
+	for i := range revs {
+		if revs[i].Revision == n {
+			return &revs[i]
+		}
+	}
+	return nil
+}
+
+// Diff is a unified diff of the specs recorded in from and to, as YAML. It
+// is empty when both hold the same spec; a nil from shows all of to as
+// added.
+func Diff(from, to *appsv1.ControllerRevision) (string, error) {
+	a, fromName, err := specYAML(from)
+	if err != nil {
+		return "", err
+	}
+	b, toName, err := specYAML(to)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+}
+
+func specYAML(rev *appsv1.ControllerRevision) (string, string, error) {
+	if rev == nil {
+		return "", "/dev/null", nil
+	}
+	data, err := yaml.JSONToYAML(rev.Data.Raw)
+	if err != nil {
+		return "", "", fmt.Errorf("revision %d of %s: %w", rev.Revision, rev.Name, err)
+	}
+	return string(data), fmt.Sprintf("revision %d (%s)", rev.Revision, rev.Name), nil
+}
@@ -0,0 +1,97 @@
+package history_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	appv1 "gitee.enflame.cn/ModelOps/opdemo/api/v1"
+	"gitee.enflame.cn/ModelOps/opdemo/internal/history"
+)
+
+func revision(n int64, spec *appv1.WebServiceSpec) appsv1.ControllerRevision {
+	data, hash, err := history.Snapshot(spec)
+	Expect(err).NotTo(HaveOccurred())
+	return appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop-" + hash},
+		Data:       runtime.RawExtension{Raw: data},
+		Revision:   n,
+	}
+}
+
+func webapp(image string) *appv1.WebServiceSpec {
+	return &appv1.WebServiceSpec{Webapp: &appv1.WebServiceWebappSpec{Name: "web", Image: image}}
+}
+
+var _ = Describe("Snapshot", func() {
+	It("hashes equal specs alike", func() {
+		_, first, err := history.Snapshot(webapp("shop:1"))
+		Expect(err).NotTo(HaveOccurred())
+		_, second, err := history.Snapshot(webapp("shop:1"))
+		Expect(err).NotTo(HaveOccurred())
+		_, other, err := history.Snapshot(webapp("shop:2"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(first).To(HaveLen(10))
+		Expect(second).To(Equal(first))
+		Expect(other).NotTo(Equal(first))
+	})
+
+	It("leaves out the history fields", func() {
+		spec := webapp("shop:1")
+		_, plain, err := history.Snapshot(spec)
+		Expect(err).NotTo(HaveOccurred())
+
+		limit, to := int32(3), int64(2)
+		spec.RevisionHistoryLimit, spec.RollbackTo = &limit, &to
+		data, hash, err := history.Snapshot(spec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hash).To(Equal(plain))
+		Expect(string(data)).NotTo(ContainSubstring("rollbackTo"))
+		Expect(spec.RollbackTo).NotTo(BeNil())
+	})
+
+	It("round-trips through Spec", func() {
+		rev := revision(1, webapp("shop:1"))
+		spec, err := history.Spec(&rev)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal(webapp("shop:1")))
+	})
+})
+
+var _ = Describe("Sort and Find", func() {
+	It("orders revisions oldest first", func() {
+		revs := []appsv1.ControllerRevision{revision(3, webapp("c")), revision(1, webapp("a")), revision(2, webapp("b"))}
+		history.Sort(revs)
+		Expect([]int64{revs[0].Revision, revs[1].Revision, revs[2].Revision}).To(Equal([]int64{1, 2, 3}))
+		Expect(history.Find(revs, 2).Name).To(Equal(revs[1].Name))
+		Expect(history.Find(revs, 4)).To(BeNil())
+	})
+})
+
+var _ = Describe("Diff", func() {
+	It("shows the changed lines", func() {
+		from, to := revision(1, webapp("shop:1")), revision(2, webapp("shop:2"))
+		out, err := history.Diff(&from, &to)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring("--- revision 1 (" + from.Name + ")"))
+		Expect(out).To(ContainSubstring("+++ revision 2 (" + to.Name + ")"))
+		Expect(out).To(ContainSubstring("-  image: shop:1\n"))
+		Expect(out).To(ContainSubstring("+  image: shop:2\n"))
+	})
+
+	It("is empty for the same spec", func() {
+		rev := revision(1, webapp("shop:1"))
+		Expect(history.Diff(&rev, &rev)).To(BeEmpty())
+	})
+
+	It("shows a first revision as added", func() {
+		rev := revision(1, webapp("shop:1"))
+		out, err := history.Diff(nil, &rev)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(ContainSubstring("--- /dev/null"))
+		Expect(out).To(ContainSubstring("+webapp:\n"))
+	})
+})
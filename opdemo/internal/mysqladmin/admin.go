@@ -0,0 +1,227 @@
+// Package mysqladmin holds the SQL the operator runs against the MySQL
+// servers of a WebService. The controller only sees the Admin interface,
+// Dial is the implementation on top of go-sql-driver/mysql.
+package mysqladmin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// Endpoint is a server and the account used to talk to it.
+type Endpoint struct {
+	Host     string
+	Port     int32
+	User     string
+	Password string
+}
+
+// ReplicaStatus is the subset of SHOW REPLICA STATUS the operator reports.
+type ReplicaStatus struct {
+	SourceHost string
+	IORunning  bool
+	SQLRunning bool
+	// LagSeconds is Seconds_Behind_Source, nil while it is unknown.
+	LagSeconds *int64
+	// LastError is the last IO or SQL thread error, empty if there is none.
+	LastError string
+}
+
+// Running reports whether both replication threads are running.
+func (s *ReplicaStatus) Running() bool {
+	return s.IORunning && s.SQLRunning
+}
+
+// Admin is an administrative connection to one MySQL server.
+type Admin interface {
+	// SetReadOnly switches super_read_only on, or read_only off.
+	SetReadOnly(ctx context.Context, readOnly bool) error
+	// EnsureReplicationUser creates user@'%' with REPLICATION SLAVE if it
+	// does not exist. An existing user is left alone, so nothing is written
+	// to the binary log on later calls.
+	EnsureReplicationUser(ctx context.Context, user, password string) error
+	// ReplicaStatus returns nil if the server is not configured as a replica.
+	ReplicaStatus(ctx context.Context) (*ReplicaStatus, error)
+	// StartReplica points the server at source with GTID auto-positioning
+	// and starts replication.
+	StartReplica(ctx context.Context, source Endpoint) error
+	Close() error
+}
+
+// DialFunc opens an Admin for an endpoint.
+type DialFunc func(ctx context.Context, ep Endpoint) (Admin, error)
+
+var _ DialFunc = Dial
+
+const dialTimeout = 5 * time.Second
+
+// Dial connects to ep. Servers older than 8.0.23 get the MASTER/SLAVE
+// statements, newer ones the SOURCE/REPLICA statements.
+func Dial(ctx context.Context, ep Endpoint) (Admin, error) {
+	cfg := driver.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(ep.Host, strconv.Itoa(int(ep.Port)))
+	cfg.User = ep.User
+	cfg.Passwd = ep.Password
+	cfg.Timeout = dialTimeout
+	// CHANGE ... TO cannot be prepared, placeholders are interpolated by
+	// the driver instead.
+	cfg.InterpolateParams = true
+
+	connector, err := driver.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(1)
+	return newAdmin(ctx, db)
+}
+
+// newAdmin picks the statements for the server version of db. db is closed
+// if that fails.
+func newAdmin(ctx context.Context, db *sql.DB) (Admin, error) {
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlAdmin{db: db, legacy: !atLeast(version, 8, 0, 23)}, nil
+}
+
+type sqlAdmin struct {
+	db     *sql.DB
+	legacy bool
+}
+
+func (a *sqlAdmin) SetReadOnly(ctx context.Context, readOnly bool) error {
+	// super_read_only implies read_only, and clearing read_only clears
+	// super_read_only.
+	stmt := "SET GLOBAL read_only = OFF"
+	if readOnly {
+		stmt = "SET GLOBAL super_read_only = ON"
+	}
+	_, err := a.db.ExecContext(ctx, stmt)
+	return err
+}
+
+func (a *sqlAdmin) EnsureReplicationUser(ctx context.Context, user, password string) error {
+	var n int
+	if err := a.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mysql.user WHERE user = ? AND host = '%'", user).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := a.db.ExecContext(ctx, "CREATE USER ?@'%' IDENTIFIED BY ?", user, password); err != nil {
+		return err
+	}
+	_, err := a.db.ExecContext(ctx, "GRANT REPLICATION SLAVE ON *.* TO ?@'%'", user)
+	return err
+}
+
+func (a *sqlAdmin) ReplicaStatus(ctx context.Context) (*ReplicaStatus, error) {
+	stmt := "SHOW REPLICA STATUS"
+	if a.legacy {
+		stmt = "SHOW SLAVE STATUS"
+	}
+	rows, err := a.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	// The columns are named after the statement flavour, e.g. Source_Host
+	// and Master_Host.
+	column := func(names ...string) sql.NullString {
+		for i, col := range cols {
+			for _, name := range names {
+				if strings.EqualFold(col, name) {
+					return values[i]
+				}
+			}
+		}
+		return sql.NullString{}
+	}
+
+	status := &ReplicaStatus{
+		SourceHost: column("Source_Host", "Master_Host").String,
+		IORunning:  column("Replica_IO_Running", "Slave_IO_Running").String == "Yes",
+		SQLRunning: column("Replica_SQL_Running", "Slave_SQL_Running").String == "Yes",
+	}
+	if lag := column("Seconds_Behind_Source", "Seconds_Behind_Master"); lag.Valid && lag.String != "" {
+		seconds, err := strconv.ParseInt(lag.String, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing replica lag %q: %w", lag.String, err)
+		}
+		status.LagSeconds = &seconds
+	}
+	if msg := column("Last_IO_Error").String; msg != "" {
+		status.LastError = msg
+	} else {
+		status.LastError = column("Last_SQL_Error").String
+	}
+	return status, nil
+}
+
+func (a *sqlAdmin) StartReplica(ctx context.Context, source Endpoint) error {
+	change, option, start := "CHANGE REPLICATION SOURCE TO", "SOURCE", "START REPLICA"
+	if a.legacy {
+		change, option, start = "CHANGE MASTER TO", "MASTER", "START SLAVE"
+	}
+	// SSL lets the replication user authenticate with
+	// caching_sha2_password without exchanging RSA keys.
+	stmt := fmt.Sprintf("%s %[2]s_HOST = ?, %[2]s_PORT = ?, %[2]s_USER = ?, %[2]s_PASSWORD = ?, %[2]s_AUTO_POSITION = 1, %[2]s_SSL = 1",
+		change, option)
+	if _, err := a.db.ExecContext(ctx, stmt, source.Host, source.Port, source.User, source.Password); err != nil {
+		return err
+	}
+	_, err := a.db.ExecContext(ctx, start)
+	return err
+}
+
+func (a *sqlAdmin) Close() error {
+	return a.db.Close()
+}
+
+// atLeast compares a VERSION() string such as "5.7.44-log" with
+// major.minor.patch.
+func atLeast(version string, major, minor, patch int) bool {
+	want := []int{major, minor, patch}
+	parts := strings.SplitN(strings.SplitN(version, "-", 2)[0], ".", 3)
+	for i, w := range want {
+		if i >= len(parts) {
+			return false
+		}
+		got, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return false
+		}
+		if got != w {
+			return got > w
+		}
+	}
+	return true
+}
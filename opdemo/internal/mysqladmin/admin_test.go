@@ -0,0 +1,72 @@
+package mysqladmin_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"gitee.enflame.cn/ModelOps/opdemo/internal/mysqladmin"
+)
+
+// The servertest module runs the specs against an in-process server. It
+// only parses the SOURCE/REPLICA statements, so the MASTER/SLAVE ones of
+// older servers are checked against a mock here.
+var _ = Describe("Admin", func() {
+	var (
+		ctx   context.Context
+		mock  sqlmock.Sqlmock
+		admin mysqladmin.Admin
+	)
+
+	// dial opens an Admin on a server reporting version.
+	dial := func(version string) {
+		db, m, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		Expect(err).NotTo(HaveOccurred())
+		mock = m
+		mock.ExpectQuery("SELECT VERSION()").WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow(version))
+		admin, err = mysqladmin.NewAdmin(ctx, db)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			mock.ExpectClose()
+			Expect(admin.Close()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	It("closes the connection when the version cannot be read", func() {
+		db, mock, err := sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+		mock.ExpectQuery("SELECT VERSION").WillReturnError(errors.New("access denied"))
+		mock.ExpectClose()
+		_, err = mysqladmin.NewAdmin(ctx, db)
+		Expect(err).To(MatchError("access denied"))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("speaks MASTER/SLAVE to servers before 8.0.23", func() {
+		dial("5.7.44-log")
+		mock.ExpectExec("CHANGE MASTER TO MASTER_HOST = ?, MASTER_PORT = ?, MASTER_USER = ?, MASTER_PASSWORD = ?, MASTER_AUTO_POSITION = 1, MASTER_SSL = 1").
+			WithArgs("primary", int32(3306), "replication", "secret").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("START SLAVE").WillReturnResult(sqlmock.NewResult(0, 0))
+		Expect(admin.StartReplica(ctx, mysqladmin.Endpoint{Host: "primary", Port: 3306, User: "replication", Password: "secret"})).To(Succeed())
+
+		// A broken replica has no lag and reports the failing thread.
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows([]string{
+			"Master_Host", "Slave_IO_Running", "Slave_SQL_Running", "Seconds_Behind_Master", "Last_IO_Error", "Last_SQL_Error",
+		}).AddRow("primary", "Yes", "No", nil, "", "Duplicate entry '1' for key 'PRIMARY'"))
+		status, err := admin.ReplicaStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.SourceHost).To(Equal("primary"))
+		Expect(status.Running()).To(BeFalse())
+		Expect(status.LagSeconds).To(BeNil())
+		Expect(status.LastError).To(ContainSubstring("Duplicate entry"))
+	})
+})
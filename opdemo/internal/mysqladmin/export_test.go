@@ -0,0 +1,4 @@
+package mysqladmin
+
+// NewAdmin lets the tests run an Admin on a mocked connection.
+var NewAdmin = newAdmin
@@ -0,0 +1,188 @@
+package servertest_test
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"strconv"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/binlogreplication"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"gitee.enflame.cn/ModelOps/opdemo/internal/mysqladmin"
+)
+
+// fakeReplication stands in for the replication threads of the in-process
+// server, which go-mysql-server leaves to the integrator.
+type fakeReplication struct {
+	options map[string]string
+	running bool
+	ioError string
+}
+
+var _ binlogreplication.BinlogReplicaController = &fakeReplication{}
+
+func (f *fakeReplication) StartReplica(*gmssql.Context) error {
+	f.running = true
+	return nil
+}
+
+func (f *fakeReplication) StopReplica(*gmssql.Context) error {
+	f.running = false
+	return nil
+}
+
+func (f *fakeReplication) SetReplicationSourceOptions(_ *gmssql.Context, options []binlogreplication.ReplicationOption) error {
+	for _, o := range options {
+		f.options[o.Name] = o.Value.String()
+	}
+	return nil
+}
+
+func (f *fakeReplication) SetReplicationFilterOptions(*gmssql.Context, []binlogreplication.ReplicationOption) error {
+	return nil
+}
+
+func (f *fakeReplication) GetReplicaStatus(*gmssql.Context) (*binlogreplication.ReplicaStatus, error) {
+	if len(f.options) == 0 {
+		return nil, nil
+	}
+	running := "No"
+	if f.running {
+		running = "Yes"
+	}
+	return &binlogreplication.ReplicaStatus{
+		SourceHost:        f.options["SOURCE_HOST"],
+		ReplicaIoRunning:  running,
+		ReplicaSqlRunning: running,
+		LastIoError:       f.ioError,
+		AutoPosition:      f.options["SOURCE_AUTO_POSITION"] == "1",
+	}, nil
+}
+
+func (f *fakeReplication) ResetReplica(*gmssql.Context, bool) error {
+	f.options = map[string]string{}
+	return nil
+}
+
+var _ = Describe("Admin", func() {
+	var (
+		ctx         context.Context
+		db          *sql.DB
+		replication *fakeReplication
+		admin       mysqladmin.Admin
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		provider := memory.NewDBProvider(memory.NewDatabase("webservice"))
+		engine := sqle.NewDefault(provider)
+		engine.Analyzer.Catalog.MySQLDb.SetEnabled(true)
+		engine.Analyzer.Catalog.MySQLDb.SetPersister(&mysql_db.NoopPersister{})
+		engine.Analyzer.Catalog.MySQLDb.AddRootAccount()
+		replication = &fakeReplication{options: map[string]string{}}
+		engine.Analyzer.Catalog.BinlogReplicaController = replication
+
+		srv, err := server.NewServer(server.Config{Protocol: "tcp", Address: "127.0.0.1:0"},
+			engine, gmssql.NewContext, memory.NewSessionBuilder(provider), nil)
+		Expect(err).NotTo(HaveOccurred())
+		go srv.Start()
+		DeferCleanup(srv.Close)
+
+		host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		p, err := strconv.Atoi(port)
+		Expect(err).NotTo(HaveOccurred())
+		admin, err = mysqladmin.Dial(ctx, mysqladmin.Endpoint{Host: host, Port: int32(p), User: "root"})
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(admin.Close)
+
+		db, err = sql.Open("mysql", "root@tcp("+srv.Listener.Addr().String()+")/")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(db.Close)
+	})
+
+	query := func(stmt string) string {
+		var value string
+		Expect(db.QueryRowContext(ctx, stmt).Scan(&value)).To(Succeed())
+		return value
+	}
+
+	It("toggles read only", func() {
+		Expect(admin.SetReadOnly(ctx, true)).To(Succeed())
+		Expect(query("SELECT @@global.super_read_only")).To(Equal("1"))
+		Expect(admin.SetReadOnly(ctx, false)).To(Succeed())
+		Expect(query("SELECT @@global.read_only")).To(Equal("0"))
+	})
+
+	It("creates the replication user once", func() {
+		Expect(admin.EnsureReplicationUser(ctx, "replication", "secret")).To(Succeed())
+		Expect(admin.EnsureReplicationUser(ctx, "replication", "other")).To(Succeed())
+		Expect(query("SELECT COUNT(*) FROM mysql.user WHERE user = 'replication'")).To(Equal("1"))
+		Expect(query("SHOW GRANTS FOR 'replication'@'%'")).To(ContainSubstring("REPLICATION SLAVE"))
+	})
+
+	It("reports no status before replication is configured", func() {
+		status, err := admin.ReplicaStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status).To(BeNil())
+	})
+
+	It("starts GTID replication from the source", func() {
+		source := mysqladmin.Endpoint{Host: "web-mysql-0.web-mysql-headless", Port: 3306, User: "replication", Password: "secret"}
+		Expect(admin.StartReplica(ctx, source)).To(Succeed())
+
+		Expect(replication.options).To(HaveKeyWithValue("SOURCE_HOST", source.Host))
+		Expect(replication.options).To(HaveKeyWithValue("SOURCE_PORT", "3306"))
+		Expect(replication.options).To(HaveKeyWithValue("SOURCE_USER", "replication"))
+		Expect(replication.options).To(HaveKeyWithValue("SOURCE_PASSWORD", "secret"))
+		Expect(replication.options).To(HaveKeyWithValue("SOURCE_AUTO_POSITION", "1"))
+		Expect(replication.options).To(HaveKeyWithValue("SOURCE_SSL", "1"))
+		Expect(replication.running).To(BeTrue())
+
+		status, err := admin.ReplicaStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.SourceHost).To(Equal(source.Host))
+		Expect(status.Running()).To(BeTrue())
+		Expect(status.LagSeconds).NotTo(BeNil())
+		Expect(status.LastError).To(BeEmpty())
+
+		// The server reports the GTID auto-positioning it was given.
+		rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+		Expect(err).NotTo(HaveOccurred())
+		defer rows.Close()
+		cols, err := rows.Columns()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows.Next()).To(BeTrue())
+		values := make([]sql.NullString, len(cols))
+		dest := make([]any, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		Expect(rows.Scan(dest...)).To(Succeed())
+		Expect(cols).To(ContainElement("Auto_Position"))
+		for i, col := range cols {
+			if col == "Auto_Position" {
+				Expect(values[i].String).To(Equal("1"))
+			}
+		}
+	})
+
+	It("reports a stopped replica and its error", func() {
+		Expect(admin.StartReplica(ctx, mysqladmin.Endpoint{Host: "primary", Port: 3306, User: "replication", Password: "secret"})).To(Succeed())
+		_, err := db.ExecContext(ctx, "STOP REPLICA")
+		Expect(err).NotTo(HaveOccurred())
+		replication.ioError = "error connecting to source 'replication@primary:3306'"
+
+		status, err := admin.ReplicaStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.Running()).To(BeFalse())
+		Expect(status.LastError).To(ContainSubstring("error connecting to source"))
+	})
+})
@@ -0,0 +1,6 @@
+// Package servertest runs the mysqladmin specs against an in-process
+// go-mysql-server, so the replication statements are parsed and executed
+// by a real server. It is a module of its own because go-mysql-server
+// v0.20.0, the first release that parses SOURCE_SSL, needs Go 1.23.3 and
+// the operator itself builds with Go 1.20.
+package servertest
@@ -0,0 +1,14 @@
+package servertest_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestMysqlAdmin(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "MySQL Admin Server Suite")
+}
@@ -0,0 +1,48 @@
+// Package naming builds the names of the objects the operator creates.
+// Names are joined from parts and kept within the limit of their kind; a
+// name that does not fit is cut and suffixed with a hash of the full name,
+// so two long names sharing a prefix still differ.
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// MaxLength is the length of a DNS label. Service names, label values
+	// and therefore Deployment and Job names must not exceed it.
+	MaxLength = 63
+	// MaxStatefulSetLength leaves room for the controller-revision-hash
+	// label, the StatefulSet name plus "-" and 10 characters, on its pods.
+	MaxStatefulSetLength = 52
+
+	hashLength = 8
+)
+
+// Join joins the non-empty parts with "-" and shortens the result to max
+// characters.
+func Join(max int, parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return Shorten(strings.Join(nonEmpty, "-"), max)
+}
+
+// Shorten returns name unchanged when it fits in max characters. Otherwise
+// it keeps as much of name as fits in front of "-" and a hash of name.
+func Shorten(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+	// A name must start and end alphanumeric, the cut may leave a
+	// separator at the end.
+	prefix := strings.TrimRight(name[:max-hashLength-1], "-.")
+	return prefix + "-" + hash
+}
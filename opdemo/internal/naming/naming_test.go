@@ -0,0 +1,38 @@
+package naming_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"gitee.enflame.cn/ModelOps/opdemo/internal/naming"
+)
+
+var _ = Describe("Join", func() {
+	It("joins the non-empty parts", func() {
+		Expect(naming.Join(naming.MaxLength, "shop", "", "mysql", "read")).To(Equal("shop-mysql-read"))
+	})
+
+	It("keeps names that fit", func() {
+		name := strings.Repeat("a", naming.MaxLength)
+		Expect(naming.Join(naming.MaxLength, name)).To(Equal(name))
+	})
+
+	It("shortens long names with a hash of the full name", func() {
+		base := strings.Repeat("a", 50)
+		first := naming.Join(naming.MaxStatefulSetLength, base, "mysql")
+		second := naming.Join(naming.MaxStatefulSetLength, base, "mariadb")
+
+		Expect(first).To(HaveLen(naming.MaxStatefulSetLength))
+		Expect(first).To(HavePrefix(base[:naming.MaxStatefulSetLength-9] + "-"))
+		Expect(first).NotTo(Equal(second))
+		Expect(naming.Join(naming.MaxStatefulSetLength, base, "mysql")).To(Equal(first))
+	})
+
+	It("does not leave a separator in front of the hash", func() {
+		name := naming.Shorten(strings.Repeat("a", 53)+"-"+strings.Repeat("b", 20), naming.MaxLength)
+		Expect(name).To(MatchRegexp(`^a+-[0-9a-f]{8}$`))
+		Expect(len(name)).To(BeNumerically("<=", naming.MaxLength))
+	})
+})
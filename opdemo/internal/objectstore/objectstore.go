@@ -0,0 +1,88 @@
+// Package objectstore reaches the S3-compatible buckets backups are
+// uploaded to. The upload itself runs in the backup Job, the operator only
+// checks the result and deletes backups that are no longer wanted. Open is
+// the implementation on top of minio-go, which speaks to AWS S3 as well as
+// MinIO.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrNotFound is returned by Stat for a missing object.
+var ErrNotFound = errors.New("object not found")
+
+// Config locates an object store and the account used to access it.
+type Config struct {
+	// Endpoint is a URL such as "http://minio.minio.svc:9000", the scheme
+	// selects TLS.
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// ObjectInfo is the metadata the operator reads from an object.
+type ObjectInfo struct {
+	Size int64
+	ETag string
+}
+
+// Store is a connection to one object store.
+type Store interface {
+	// Stat returns ErrNotFound if the object does not exist.
+	Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error)
+	// Delete removes the object, a missing object is not an error.
+	Delete(ctx context.Context, bucket, key string) error
+}
+
+// OpenFunc opens a Store for a config.
+type OpenFunc func(cfg Config) (Store, error)
+
+var _ OpenFunc = Open
+
+// Open returns a Store for cfg. No request is made until it is used.
+func Open(cfg Config) (Store, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint %q: %w", cfg.Endpoint, err)
+	}
+	if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("endpoint %q is not an http(s) URL", cfg.Endpoint)
+	}
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: u.Scheme == "https",
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &minioStore{client: client}, nil
+}
+
+type minioStore struct {
+	client *minio.Client
+}
+
+func (s *minioStore) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &ObjectInfo{Size: info.Size, ETag: info.ETag}, nil
+}
+
+func (s *minioStore) Delete(ctx context.Context, bucket, key string) error {
+	return s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
+}
@@ -0,0 +1,83 @@
+package objectstore_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"gitee.enflame.cn/ModelOps/opdemo/internal/objectstore"
+)
+
+const bucket = "backups"
+
+// The object store is an in-process S3 server standing in for MinIO.
+var _ = Describe("Store", func() {
+	var (
+		ctx     context.Context
+		backend *s3mem.Backend
+		store   objectstore.Store
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		backend = s3mem.New()
+		Expect(backend.CreateBucket(bucket)).To(Succeed())
+		server := httptest.NewServer(gofakes3.New(backend).Server())
+		DeferCleanup(server.Close)
+
+		var err error
+		store, err = objectstore.Open(objectstore.Config{
+			Endpoint:        server.URL,
+			Region:          "us-east-1",
+			AccessKeyID:     "minio",
+			SecretAccessKey: "minio123",
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	put := func(key, content string) {
+		// HTTP uploads get Last-Modified from the fake server, objects put
+		// into the backend directly need it set.
+		meta := map[string]string{"Last-Modified": time.Now().UTC().Format(http.TimeFormat)}
+		_, err := backend.PutObject(bucket, key, meta, strings.NewReader(content), int64(len(content)))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("reports the size of an object", func() {
+		put("demo/nightly.sql.gz", "-- dump")
+
+		info, err := store.Stat(ctx, bucket, "demo/nightly.sql.gz")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size).To(BeEquivalentTo(len("-- dump")))
+		Expect(info.ETag).NotTo(BeEmpty())
+	})
+
+	It("returns ErrNotFound for a missing object", func() {
+		_, err := store.Stat(ctx, bucket, "demo/missing.sql.gz")
+		Expect(err).To(MatchError(objectstore.ErrNotFound))
+	})
+
+	It("deletes an object", func() {
+		put("demo/nightly.sql.gz", "-- dump")
+
+		Expect(store.Delete(ctx, bucket, "demo/nightly.sql.gz")).To(Succeed())
+		_, err := store.Stat(ctx, bucket, "demo/nightly.sql.gz")
+		Expect(err).To(MatchError(objectstore.ErrNotFound))
+	})
+
+	It("ignores deleting a missing object", func() {
+		Expect(store.Delete(ctx, bucket, "demo/missing.sql.gz")).To(Succeed())
+	})
+
+	It("rejects an endpoint without a scheme", func() {
+		_, err := objectstore.Open(objectstore.Config{Endpoint: "minio:9000"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,14 @@
+package objectstore_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestObjectStore(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Object Store Suite")
+}
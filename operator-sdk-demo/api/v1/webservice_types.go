@@ -17,49 +17,600 @@ limitations under the License.
 package v1
 
 import (
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 type WebServiceDbSpec struct {
+	// Engine selects the database server. Changing it on a running
+	// WebService does not migrate the data.
+	// +kubebuilder:default=MySQL
+	// +optional
+	Engine DatabaseEngine `json:"engine,omitempty"`
+
 	Name      string                      `json:"name,omitempty"`
 	Size      *int32                      `json:"size"`
-	Image     string                      `json:"image"`
+	Image     string                      `json:"image,omitempty"`
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 	Envs      []corev1.EnvVar             `json:"envs,omitempty"`
 	Ports     []corev1.ServicePort        `json:"ports"`
+	// +optional
+	Auth *WebServiceDbAuthSpec `json:"auth,omitempty"`
+	// +optional
+	Storage *WebServiceDbStorageSpec `json:"storage,omitempty"`
+	// PodTemplate is merged into the database pod template, see
+	// WebServiceFrontendSpec.PodTemplate. The data volume mount belongs
+	// to the operator as well.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +optional
+	PodTemplate *runtime.RawExtension `json:"podTemplate,omitempty"`
+	// Probes override the default probes of the database server, which
+	// run the ping tool of the engine.
+	// +optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+	// PodSecurityContext is merged into the restricted defaults of the
+	// database pod, which runs as uid, gid and fsGroup 999 like the
+	// official images.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext is merged into the restricted defaults of the
+	// database container.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// DatabaseEngine is the database server run for a WebService.
+// +kubebuilder:validation:Enum=MySQL;MariaDB;PostgreSQL
+type DatabaseEngine string
+
+const (
+	DatabaseEngineMySQL      DatabaseEngine = "MySQL"
+	DatabaseEngineMariaDB    DatabaseEngine = "MariaDB"
+	DatabaseEnginePostgreSQL DatabaseEngine = "PostgreSQL"
+)
+
+// WebServiceDbStorageSpec configures the data volume of every database pod.
+type WebServiceDbStorageSpec struct {
+	// Size may only grow. A larger size expands the existing PVCs, which
+	// requires a StorageClass with allowVolumeExpansion.
+	// +kubebuilder:default="1Gi"
+	// +optional
+	Size resource.Quantity `json:"size,omitempty"`
+	// StorageClassName is fixed once the PVCs exist; unset uses the
+	// cluster default.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+// WebServiceDbAuthSpec configures where the database credentials come from.
+type WebServiceDbAuthSpec struct {
+	// ExistingSecretRef points to a user managed Secret with the keys
+	// "username" and "password", plus "root-password" for MySQL and
+	// MariaDB. When unset the operator generates "<name>-<engine>-auth"
+	// with random passwords, e.g. "<name>-mysql-auth".
+	// +optional
+	ExistingSecretRef *corev1.LocalObjectReference `json:"existingSecretRef,omitempty"`
 }
 
+// WebServiceFrontendSpec is one application tier, spec.frontend or an
+// entry of spec.components.
 type WebServiceFrontendSpec struct {
 	Name      string                      `json:"name,omitempty"`
 	Size      *int32                      `json:"size"`
-	Image     string                      `json:"image"`
+	Image     string                      `json:"image,omitempty"`
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 	Envs      []corev1.EnvVar             `json:"envs,omitempty"`
 	Ports     []corev1.ServicePort        `json:"ports"`
+	// DatabaseBinding controls how the database connection is passed to the
+	// frontend. Without it DB_* variables are injected.
+	// +optional
+	DatabaseBinding *DatabaseBindingSpec `json:"databaseBinding,omitempty"`
+	// Expose publishes the frontend. Without it the Service is a NodePort
+	// Service.
+	// +optional
+	Expose *ExposeSpec `json:"expose,omitempty"`
+	// DependsOn lists the components whose pods must all be ready before
+	// this one is rolled out. The database is always waited for.
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// PodTemplate is a partial pod template applied on top of the
+	// generated one with strategic merge patch semantics, lists like
+	// containers, initContainers, volumes and env merge by name. Pod
+	// labels set by the operator and image, ports, resources and volume
+	// mounts of the main container cannot be overridden.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +optional
+	PodTemplate *runtime.RawExtension `json:"podTemplate,omitempty"`
+	// Probes override the default probes, an HTTP GET of / or a TCP
+	// connect on the first port.
+	// +optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+	// PodSecurityContext is merged into the restricted pod defaults:
+	// runAsNonRoot and the RuntimeDefault seccomp profile. Set runAsUser
+	// for images whose user is root.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext is merged into the restricted defaults of the main
+	// container: allowPrivilegeEscalation false and all capabilities
+	// dropped.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// Rollout decides how a changed pod template reaches the pods. Without
+	// it the Deployment is updated in place by a rolling update.
+	// +optional
+	Rollout *RolloutSpec `json:"rollout,omitempty"`
+}
+
+// RolloutStrategy is the way a new pod template replaces the running one.
+// +kubebuilder:validation:Enum=Rolling;BlueGreen;Canary
+type RolloutStrategy string
+
+const (
+	// RolloutRolling updates the Deployment in place.
+	RolloutRolling RolloutStrategy = "Rolling"
+	// RolloutBlueGreen starts a second Deployment with the new template
+	// and switches the Service over once it is ready.
+	RolloutBlueGreen RolloutStrategy = "BlueGreen"
+	// RolloutCanary moves the replicas to a second Deployment step by
+	// step, the Service sends traffic to both.
+	RolloutCanary RolloutStrategy = "Canary"
+)
+
+// RolloutSpec configures the rollout of a component. A running rollout
+// is promoted or aborted by annotating the WebService with
+// webapps.my.domain/promote or webapps.my.domain/abort set to the
+// component name.
+// +kubebuilder:validation:XValidation:rule="self.strategy != 'Canary' || (has(self.steps) && size(self.steps) > 0)",message="a Canary rollout needs steps"
+type RolloutSpec struct {
+	// +kubebuilder:default=Rolling
+	// +optional
+	Strategy RolloutStrategy `json:"strategy,omitempty"`
+	// Steps of a Canary rollout, in order. The new template is promoted
+	// after the last one.
+	// +optional
+	Steps []CanaryStep `json:"steps,omitempty"`
+	// AutoPromote switches a BlueGreen rollout as soon as the new
+	// Deployment is ready. When false the switch waits for the promote
+	// annotation.
+	// +kubebuilder:default=true
+	// +optional
+	AutoPromote *bool `json:"autoPromote,omitempty"`
+	// ProgressDeadline aborts the rollout when the new pods of the current
+	// step are not all ready this long after the step started.
+	// +optional
+	ProgressDeadline *metav1.Duration `json:"progressDeadline,omitempty"`
+	// MaxRestarts aborts the rollout once the containers of the new pods
+	// have restarted more often than this in total.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRestarts *int32 `json:"maxRestarts,omitempty"`
+}
+
+// CanaryStep is one traffic weight of a Canary rollout.
+type CanaryStep struct {
+	// Weight is the share of the replicas, in percent, running the new
+	// template. At least one pod runs it.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+	// Pause is how long the step lasts from its start; the step also waits
+	// for its pods to be ready. Without a pause the rollout waits at this
+	// step for the promote annotation.
+	// +optional
+	Pause *metav1.Duration `json:"pause,omitempty"`
+}
+
+// ProbesSpec overrides the generated probes of a container. A probe with a
+// handler (exec, httpGet, tcpSocket or grpc) replaces the default handler,
+// its other non-zero fields replace the default timings. Probe changes roll
+// the pods like any other change.
+type ProbesSpec struct {
+	// +optional
+	Startup *corev1.Probe `json:"startup,omitempty"`
+	// +optional
+	Liveness *corev1.Probe `json:"liveness,omitempty"`
+	// +optional
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+}
+
+// ExposeSpec configures the frontend Service type and optionally an
+// Ingress and a Gateway API HTTPRoute in front of it. Both carry the name
+// of the Service and route to its first port.
+type ExposeSpec struct {
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:default=NodePort
+	// +optional
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+	// +optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+	// HTTPRoute is only created when the operator found the Gateway API
+	// at startup.
+	// +optional
+	HTTPRoute *HTTPRouteSpec `json:"httpRoute,omitempty"`
+}
+
+type IngressSpec struct {
+	Host string `json:"host"`
+	// Path is matched as a prefix.
+	// +kubebuilder:default="/"
+	// +optional
+	Path string `json:"path,omitempty"`
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+	// TLSSecretName enables TLS for Host with this certificate Secret.
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type HTTPRouteSpec struct {
+	// ParentRefs are the Gateways the route attaches to.
+	// +kubebuilder:validation:MinItems=1
+	ParentRefs []GatewayParentRef `json:"parentRefs"`
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+	// Path is matched as a prefix.
+	// +kubebuilder:default="/"
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+type GatewayParentRef struct {
+	Name string `json:"name"`
+	// Namespace of the Gateway, the WebService namespace when empty.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// SectionName selects one listener of the Gateway.
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// DatabaseBindingMode is Env (DB_HOST, DB_PORT, DB_NAME, DB_USER,
+// DB_PASSWORD), DSN (DATABASE_URL) or None.
+// +kubebuilder:validation:Enum=Env;DSN;None
+type DatabaseBindingMode string
+
+const (
+	DatabaseBindingEnv  DatabaseBindingMode = "Env"
+	DatabaseBindingDSN  DatabaseBindingMode = "DSN"
+	DatabaseBindingNone DatabaseBindingMode = "None"
+)
+
+// DatabaseBindingSpec configures the injection of the servicebinding.io
+// binding Secret (type, provider, host, port, database, username, password
+// and uri) into the frontend container.
+type DatabaseBindingSpec struct {
+	// +kubebuilder:default=Env
+	// +optional
+	Mode DatabaseBindingMode `json:"mode,omitempty"`
+	// EnvNames overrides the variable name per binding key, for example
+	// {"password": "MYSQL_PASSWORD"}.
+	// +optional
+	EnvNames map[string]string `json:"envNames,omitempty"`
+	// Mount also mounts the binding at /bindings/<type>, for example
+	// /bindings/mysql, and sets SERVICE_BINDING_ROOT.
+	// +optional
+	Mount bool `json:"mount,omitempty"`
 }
 
 // WebServiceSpec defines the desired state of WebService
+// +kubebuilder:validation:XValidation:rule="has(self.database) || has(self.mysql)",message="spec.database is required"
+// +kubebuilder:validation:XValidation:rule="has(self.frontend) || (has(self.components) && size(self.components) > 0)",message="spec.frontend or spec.components is required"
 type WebServiceSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	Mysql    *WebServiceDbSpec       `json:"mysql"`
-	Frontend *WebServiceFrontendSpec `json:"frontend"`
+	// Database is the database of the frontend.
+	// +optional
+	Database *WebServiceDbSpec `json:"database,omitempty"`
+	// Deprecated: use Database. Mysql is only read when Database is unset.
+	// +optional
+	Mysql *WebServiceDbSpec `json:"mysql,omitempty"`
+	// Frontend is the single tier of earlier releases, reconciled as the
+	// first component under its own name.
+	// +optional
+	Frontend *WebServiceFrontendSpec `json:"frontend,omitempty"`
+	// Components are additional tiers, each run as the Deployment and
+	// Service "<webservice>-<name>" once its dependsOn are ready.
+	// +optional
+	Components []WebServiceFrontendSpec `json:"components,omitempty"`
+
+	// DeletionPolicy controls whether the database Secret and PVCs survive the
+	// deletion of the WebService. Snapshot also dumps the database into a
+	// PVC of its own before the database is removed; MySQL and MariaDB only.
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// AdoptionPolicy applies when a Deployment, StatefulSet, Service or
+	// Secret of the WebService already exists without it as controller:
+	// Never reports a Conflict, IfLabeled adopts objects labelled
+	// "webapps.my.domain/adopt=<webservice>" and Always adopts any object
+	// that has no controller.
+	// +kubebuilder:default=Never
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// RevisionHistoryLimit is how many past specs are kept as
+	// ControllerRevisions to roll back to, the current spec counted.
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// RollbackTo asks for the spec recorded as this revision number to be
+	// restored. The operator writes that spec back, without rollbackTo,
+	// and it becomes the newest revision.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+
+	// Migrations are run against the database before the frontend is rolled out.
+	// +optional
+	Migrations *MigrationsSpec `json:"migrations,omitempty"`
+
+	// NetworkPolicy turns the generated NetworkPolicies of the database and
+	// the components off; they are created by default.
+	// +optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+}
+
+// NetworkPolicySpec controls the generated NetworkPolicies: the database
+// is reachable from the components and the operator Jobs of the
+// WebService only, a component on its own ports only.
+type NetworkPolicySpec struct {
+	// Enabled false deletes the generated NetworkPolicies.
+	// +kubebuilder:default=true
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// NetworkPolicyEnabled is false only when spec.networkPolicy.enabled is.
+func (s *WebServiceSpec) NetworkPolicyEnabled() bool {
+	return s.NetworkPolicy == nil || s.NetworkPolicy.Enabled == nil || *s.NetworkPolicy.Enabled
+}
+
+// DatabaseSpec returns spec.database, or the deprecated spec.mysql when it
+// is unset.
+func (s *WebServiceSpec) DatabaseSpec() *WebServiceDbSpec {
+	if s.Database != nil {
+		return s.Database
+	}
+	return s.Mysql
+}
+
+// MigrationsSpec takes the schema migrations from a ConfigMap of SQL files
+// or runs them from an image. They run once the database is up and whenever
+// the frontend image or the migrations change; the frontend Deployment is
+// held back until they succeed.
+type MigrationsSpec struct {
+	// Image runs Command with the database variables of the frontend. With
+	// only Command set it defaults to the frontend image.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// ConfigMap holds versioned SQL files, e.g. "V001__init.sql". The keys
+	// ending in .sql are applied in lexical order and remembered in the
+	// schema_migrations table, so each file runs once.
+	// +optional
+	ConfigMap *corev1.LocalObjectReference `json:"configMap,omitempty"`
+}
+
+// DeletionPolicy is what happens to the database data on deletion.
+// +kubebuilder:validation:Enum=Delete;Retain;Snapshot
+type DeletionPolicy string
+
+const (
+	DeletionPolicyDelete   DeletionPolicy = "Delete"
+	DeletionPolicyRetain   DeletionPolicy = "Retain"
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
+// AdoptionPolicy is how existing objects are taken over by a WebService.
+// +kubebuilder:validation:Enum=Never;IfLabeled;Always
+type AdoptionPolicy string
+
+const (
+	AdoptionPolicyNever     AdoptionPolicy = "Never"
+	AdoptionPolicyIfLabeled AdoptionPolicy = "IfLabeled"
+	AdoptionPolicyAlways    AdoptionPolicy = "Always"
+)
+
+// WebServicePhase is the overall state of a WebService.
+// +kubebuilder:validation:Enum=Pending;DatabaseProvisioning;Deploying;Ready;Degraded;Terminating
+type WebServicePhase string
+
+const (
+	PhasePending              WebServicePhase = "Pending"
+	PhaseDatabaseProvisioning WebServicePhase = "DatabaseProvisioning"
+	PhaseDeploying            WebServicePhase = "Deploying"
+	PhaseReady                WebServicePhase = "Ready"
+	PhaseDegraded             WebServicePhase = "Degraded"
+	PhaseTerminating          WebServicePhase = "Terminating"
+)
+
+const (
+	ConditionReady         = "Ready"
+	ConditionDatabaseReady = "DatabaseReady"
+	ConditionFrontendReady = "FrontendReady"
+	// ConditionComponentsReady sums up the readiness of spec.components.
+	ConditionComponentsReady = "ComponentsReady"
+	// ConditionTerminating is set while the finalizer tears the children
+	// down, its reason names the current step.
+	ConditionTerminating = "Terminating"
+	// ConditionStorageMigrated is set on WebServices created before MySQL
+	// ran as a StatefulSet, while their data is dumped and restored.
+	ConditionStorageMigrated = "StorageMigrated"
+	// ConditionSchemaMigrated is false while spec.migrations are running
+	// or have failed for the current frontend image; the frontend
+	// Deployment is not updated meanwhile.
+	ConditionSchemaMigrated = "SchemaMigrated"
+	// ConditionExposed reports whether spec.frontend.expose could be
+	// applied as asked.
+	ConditionExposed = "Exposed"
+	// ConditionPodSecurityCompliant is false when the generated pods would
+	// be rejected by the Pod Security level enforced on the namespace.
+	ConditionPodSecurityCompliant = "PodSecurityCompliant"
+	// ConditionConflict is true while an existing object blocks a child
+	// and spec.adoptionPolicy does not allow adopting it.
+	ConditionConflict = "Conflict"
+)
+
+// MigrationStatus records which migrations were applied for which frontend
+// image.
+type MigrationStatus struct {
+	// AppliedVersion is the last SQL file without .sql, or the migration
+	// image.
+	AppliedVersion string `json:"appliedVersion,omitempty"`
+	// FrontendImage is the frontend image the run was for.
+	FrontendImage string `json:"frontendImage,omitempty"`
+	// Hash covers the migrations and the frontend image; the migrations
+	// run again when it changes.
+	Hash string `json:"hash,omitempty"`
+	// Job is the migration Job of that run.
+	Job string `json:"job,omitempty"`
+	// AppliedTime is the completion time of the Job.
+	AppliedTime *metav1.Time `json:"appliedTime,omitempty"`
+}
+
+// ComponentStatus is the observed state of the workload behind a component.
+type ComponentStatus struct {
+	Name            string `json:"name,omitempty"`
+	DesiredReplicas int32  `json:"desiredReplicas"`
+	ReadyReplicas   int32  `json:"readyReplicas"`
+	Image           string `json:"image,omitempty"`
+	// Endpoint is host:port of the component Service inside the cluster.
+	Endpoint string `json:"endpoint,omitempty"`
 }
 
 // WebServiceStatus defines the observed state of WebService
 type WebServiceStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	appsv1.DeploymentStatus `json:",inline"`
+
+	// ObservedGeneration is the last spec generation applied to the children.
+	ObservedGeneration int64           `json:"observedGeneration,omitempty"`
+	Phase              WebServicePhase `json:"phase,omitempty"`
+
+	// Mysql is the database StatefulSet, whatever the engine.
+	Mysql    *ComponentStatus `json:"mysql,omitempty"`
+	Frontend *ComponentStatus `json:"frontend,omitempty"`
+	// Components are the created workloads of spec.components, in the
+	// order they are rolled out.
+	Components []ComponentStatus `json:"components,omitempty"`
+
+	// DatabaseSecret names the Secret holding the database credentials.
+	DatabaseSecret string `json:"databaseSecret,omitempty"`
+	// Binding is the servicebinding.io Provisioned Service reference to the
+	// binding Secret.
+	Binding *corev1.LocalObjectReference `json:"binding,omitempty"`
+	// URL reaches the frontend from outside the cluster, taken from the
+	// Ingress, the first HTTPRoute hostname or the LoadBalancer address in
+	// that order.
+	URL string `json:"url,omitempty"`
+
+	// Migrations is the last successful migration run.
+	Migrations *MigrationStatus `json:"migrations,omitempty"`
+
+	// Rollouts track the Deployments of each component that has a
+	// rollout strategy other than Rolling or went through one.
+	// +listType=map
+	// +listMapKey=component
+	// +optional
+	Rollouts []RolloutStatus `json:"rollouts,omitempty"`
+
+	// Revision is the number of the spec revision the children were last
+	// synced to and CurrentRevision the name of its ControllerRevision.
+	// +optional
+	Revision int64 `json:"revision,omitempty"`
+	// +optional
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// BlockedOn is the child the other children are waiting for, as long
+	// as one of them is not applied yet.
+	// +optional
+	BlockedOn *BlockedNode `json:"blockedOn,omitempty"`
+
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// BlockedNode is a step of the reconcile that is not ready.
+type BlockedNode struct {
+	// Node is the name of the step, e.g. "database", "migrations" or
+	// "component/api".
+	Node string `json:"node"`
+	// Reason says what the step is waiting for.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Since is when the reconcile got stuck on this step; the WebService
+	// is checked less often the longer it waits.
+	Since metav1.Time `json:"since"`
+}
+
+// RolloutPhase is where the rollout of a component stands.
+type RolloutPhase string
+
+const (
+	// RolloutStable means the active Deployment runs the current template.
+	RolloutStable RolloutPhase = "Stable"
+	// RolloutProgressing means the new pods are starting.
+	RolloutProgressing RolloutPhase = "Progressing"
+	// RolloutPaused means the new pods are ready and the rollout waits for
+	// the pause of the step to end or for the promote annotation.
+	RolloutPaused RolloutPhase = "Paused"
+	// RolloutAborted means the new template was given up; the active
+	// Deployment serves alone until the spec changes or the rollout is
+	// promoted again.
+	RolloutAborted RolloutPhase = "Aborted"
+)
+
+// RolloutStatus is the progress of the rollout of one component.
+type RolloutStatus struct {
+	Component string `json:"component"`
+	// ActiveSlot is the Deployment serving the stable template: blue is
+	// named like the component, green has the suffix "-green".
+	ActiveSlot string       `json:"activeSlot,omitempty"`
+	Phase      RolloutPhase `json:"phase,omitempty"`
+	// StableRevision is the pod template revision of the active slot.
+	StableRevision string `json:"stableRevision,omitempty"`
+	// Revision is the pod template being rolled out, or the one aborted.
+	Revision string `json:"revision,omitempty"`
+	// Step is the index of the current Canary step.
+	Step int32 `json:"step,omitempty"`
+	// Weight is the share of replicas, in percent, running Revision.
+	Weight        int32        `json:"weight,omitempty"`
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+	Message       string       `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Database",type=integer,JSONPath=`.status.mysql.readyReplicas`,description="Ready database replicas"
+// +kubebuilder:printcolumn:name="Frontend",type=integer,JSONPath=`.status.frontend.readyReplicas`,description="Ready frontend replicas"
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.frontend.desiredReplicas`,description="Desired frontend replicas"
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.frontend.image`,priority=1
+// +kubebuilder:printcolumn:name="Endpoint",type=string,JSONPath=`.status.frontend.endpoint`,priority=1
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`,priority=1
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.revision`,priority=1
+// +kubebuilder:printcolumn:name="Waiting For",type=string,JSONPath=`.status.blockedOn.node`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // WebService is the Schema for the webservices API
 type WebService struct {
@@ -0,0 +1,257 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command kubectl-webservice is a kubectl plugin for the spec history of
+// WebServices. Put it on the PATH and run "kubectl webservice --help".
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(webappsv1.AddToScheme(scheme))
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// plugin holds the connection flags shared by the subcommands.
+type plugin struct {
+	loading   *clientcmd.ClientConfigLoadingRules
+	overrides *clientcmd.ConfigOverrides
+}
+
+func newRootCommand() *cobra.Command {
+	p := &plugin{loading: clientcmd.NewDefaultClientConfigLoadingRules(), overrides: &clientcmd.ConfigOverrides{}}
+	root := &cobra.Command{
+		Use:          "kubectl-webservice",
+		Short:        "List, compare and roll back the spec revisions of a WebService",
+		SilenceUsage: true,
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&p.loading.ExplicitPath, "kubeconfig", "", "path to the kubeconfig file")
+	flags.StringVar(&p.overrides.CurrentContext, "context", "", "kubeconfig context to use")
+	flags.StringVarP(&p.overrides.Context.Namespace, "namespace", "n", "", "namespace of the WebService")
+
+	var revision int64
+	history := &cobra.Command{
+		Use:   "history NAME",
+		Short: "List the revisions, or show what --revision changed",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			h, err := p.load(cmd.Context(), cmd.OutOrStdout(), args[0])
+			if err != nil {
+				return err
+			}
+			if revision == 0 {
+				return h.list()
+			}
+			return h.diff(0, revision)
+		},
+	}
+	history.Flags().Int64Var(&revision, "revision", 0, "show the changes of this revision against the one before it")
+
+	diff := &cobra.Command{
+		Use:   "diff NAME FROM [TO]",
+		Short: "Compare two revisions, TO defaults to the current one",
+		Args:  cobra.RangeArgs(2, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			numbers, err := parseRevisions(args[1:])
+			if err != nil {
+				return err
+			}
+			h, err := p.load(cmd.Context(), cmd.OutOrStdout(), args[0])
+			if err != nil {
+				return err
+			}
+			to := h.webService.Status.Revision
+			if len(numbers) == 2 {
+				to = numbers[1]
+			}
+			return h.diff(numbers[0], to)
+		},
+	}
+
+	rollback := &cobra.Command{
+		Use:   "rollback NAME REVISION",
+		Short: "Restore the spec of a revision through spec.rollbackTo",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			numbers, err := parseRevisions(args[1:])
+			if err != nil {
+				return err
+			}
+			h, err := p.load(cmd.Context(), cmd.OutOrStdout(), args[0])
+			if err != nil {
+				return err
+			}
+			return h.rollback(cmd.Context(), numbers[0])
+		},
+	}
+
+	root.AddCommand(history, diff, rollback)
+	return root
+}
+
+func parseRevisions(args []string) ([]int64, error) {
+	numbers := make([]int64, 0, len(args))
+	for _, arg := range args {
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("revision %q is not a positive number", arg)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// specHistory is a WebService with its spec revisions, oldest first. The
+// subcommands print to out.
+type specHistory struct {
+	client.Client
+	out        io.Writer
+	webService *webappsv1.WebService
+	revisions  []appsv1.ControllerRevision
+}
+
+func (p *plugin) load(ctx context.Context, out io.Writer, name string) (*specHistory, error) {
+	kubeconfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(p.loading, p.overrides)
+	namespace, _, err := kubeconfig.Namespace()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := kubeconfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	return loadHistory(ctx, c, out, types.NamespacedName{Namespace: namespace, Name: name})
+}
+
+// loadHistory reads the WebService key and its spec revisions through c.
+func loadHistory(ctx context.Context, c client.Client, out io.Writer, key types.NamespacedName) (*specHistory, error) {
+	webService := &webappsv1.WebService{}
+	if err := c.Get(ctx, key, webService); err != nil {
+		return nil, err
+	}
+	list := &appsv1.ControllerRevisionList{}
+	if err := c.List(ctx, list, client.InNamespace(key.Namespace),
+		client.MatchingLabels(resources.SpecHistorySelector(key.Name))); err != nil {
+		return nil, err
+	}
+	resources.SortRevisions(list.Items)
+	return &specHistory{Client: c, out: out, webService: webService, revisions: list.Items}, nil
+}
+
+func (h *specHistory) find(n int64) (*appsv1.ControllerRevision, error) {
+	if rev := resources.FindRevision(h.revisions, n); rev != nil {
+		return rev, nil
+	}
+	return nil, fmt.Errorf("WebService %s has no revision %d", h.webService.Name, n)
+}
+
+func (h *specHistory) list() error {
+	if len(h.revisions) == 0 {
+		fmt.Fprintf(h.out, "WebService %s has no spec revisions yet\n", h.webService.Name)
+		return nil
+	}
+	w := tabwriter.NewWriter(h.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tNAME\tAGE\tCURRENT")
+	for _, rev := range h.revisions {
+		current := ""
+		if rev.Name == h.webService.Status.CurrentRevision {
+			current = "*"
+		}
+		age := time.Since(rev.CreationTimestamp.Time).Round(time.Second)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", rev.Revision, rev.Name, age, current)
+	}
+	return w.Flush()
+}
+
+// diff prints the change from revision from to revision to. A from of 0
+// compares to with the revision before it.
+func (h *specHistory) diff(from, to int64) error {
+	b, err := h.find(to)
+	if err != nil {
+		return err
+	}
+	var a *appsv1.ControllerRevision
+	if from != 0 {
+		if a, err = h.find(from); err != nil {
+			return err
+		}
+	} else {
+		for i := range h.revisions {
+			if h.revisions[i].Revision < to {
+				a = &h.revisions[i]
+			}
+		}
+	}
+	out, err := resources.DiffRevisions(a, b)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		out = "No differences.\n"
+	}
+	_, err = fmt.Fprint(h.out, out)
+	return err
+}
+
+// rollback sets spec.rollbackTo and leaves the restore to the operator.
+func (h *specHistory) rollback(ctx context.Context, n int64) error {
+	if _, err := h.find(n); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(h.webService.DeepCopy())
+	h.webService.Spec.RollbackTo = &n
+	if err := h.Patch(ctx, h.webService, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(h.out, "WebService %s rolls back to revision %d\n", h.webService.Name, n)
+	return nil
+}
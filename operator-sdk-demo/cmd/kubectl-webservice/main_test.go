@@ -0,0 +1,164 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+// historyFixture is a WebService on nginx:1.25 whose revision 1 ran nginx.
+func historyFixture(t *testing.T) (client.Client, types.NamespacedName) {
+	t.Helper()
+	webService := &webappsv1.WebService{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default", UID: "shop-uid"},
+		Spec: webappsv1.WebServiceSpec{
+			Frontend: &webappsv1.WebServiceFrontendSpec{Name: "nginx", Image: "nginx"},
+		},
+	}
+	first, err := resources.NewSpecRevision(webService, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webService.Spec.Frontend.Image = "nginx:1.25"
+	second, err := resources.NewSpecRevision(webService, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webService.Status.Revision = 2
+	webService.Status.CurrentRevision = second.Name
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(webService, first, second).Build()
+	return c, client.ObjectKeyFromObject(webService)
+}
+
+func TestHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(h *specHistory) error
+		contains []string
+		err      string
+	}{
+		{
+			name:     "revision",
+			run:      func(h *specHistory) error { return h.diff(0, 2) },
+			contains: []string{"--- revision 1", "+++ revision 2", "-  image: nginx\n", "+  image: nginx:1.25\n"},
+		},
+		{
+			name:     "first revision",
+			run:      func(h *specHistory) error { return h.diff(0, 1) },
+			contains: []string{"--- /dev/null", "+++ revision 1", "+  image: nginx\n"},
+		},
+		{
+			name:     "same revision",
+			run:      func(h *specHistory) error { return h.diff(2, 2) },
+			contains: []string{"No differences."},
+		},
+		{
+			name: "unknown revision",
+			run:  func(h *specHistory) error { return h.diff(1, 3) },
+			err:  "WebService shop has no revision 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c, key := historyFixture(t)
+			out := &bytes.Buffer{}
+			h, err := loadHistory(context.Background(), c, out, key)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			err = tt.run(h)
+			if tt.err != "" {
+				g.Expect(err).To(MatchError(tt.err))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			for _, s := range tt.contains {
+				g.Expect(out.String()).To(ContainSubstring(s))
+			}
+		})
+	}
+}
+
+func TestHistoryList(t *testing.T) {
+	g := NewWithT(t)
+	c, key := historyFixture(t)
+	out := &bytes.Buffer{}
+	h, err := loadHistory(context.Background(), c, out, key)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(h.list()).To(Succeed())
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	g.Expect(lines).To(HaveLen(3))
+	g.Expect(lines[0]).To(HavePrefix("REVISION"))
+	g.Expect(lines[1]).To(HavePrefix("1 "))
+	g.Expect(lines[1]).NotTo(HaveSuffix("*"))
+	g.Expect(lines[2]).To(HaveSuffix("*"))
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		revision int64
+		err      string
+	}{
+		{name: "recorded", revision: 1},
+		{name: "unknown", revision: 3, err: "WebService shop has no revision 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			c, key := historyFixture(t)
+			out := &bytes.Buffer{}
+			h, err := loadHistory(ctx, c, out, key)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			err = h.rollback(ctx, tt.revision)
+			webService := &webappsv1.WebService{}
+			g.Expect(c.Get(ctx, key, webService)).To(Succeed())
+			if tt.err != "" {
+				g.Expect(err).To(MatchError(tt.err))
+				g.Expect(webService.Spec.RollbackTo).To(BeNil())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(webService.Spec.RollbackTo).To(HaveValue(Equal(tt.revision)))
+			g.Expect(out.String()).To(Equal("WebService shop rolls back to revision 1\n"))
+		})
+	}
+}
+
+func TestParseRevisions(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(parseRevisions([]string{"2", "3"})).To(Equal([]int64{2, 3}))
+	for _, s := range []string{"0", "-1", "latest"} {
+		_, err := parseRevisions([]string{s})
+		g.Expect(err).To(MatchError(ContainSubstring("is not a positive number")), s)
+	}
+}
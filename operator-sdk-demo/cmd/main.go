@@ -36,6 +36,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
 	"my.domain/demo/internal/controller"
 	// +kubebuilder:scaffold:imports
 )
@@ -58,6 +59,7 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var configFile string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -69,6 +71,8 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&configFile, "config", "",
+		"Path to the versioned operator config file. It is watched and reloaded on change.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -144,9 +148,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	operatorConfig, err := config.NewStore(configFile, ctrl.Log.WithName("config"))
+	if err != nil {
+		setupLog.Error(err, "unable to load operator config", "config", configFile)
+		os.Exit(1)
+	}
+	if err := mgr.Add(operatorConfig); err != nil {
+		setupLog.Error(err, "unable to watch operator config")
+		os.Exit(1)
+	}
+
+	gatewayAPI := controller.HasGatewayAPI(mgr.GetRESTMapper())
+	setupLog.Info("Gateway API detection", "httpRoutes", gatewayAPI)
 	if err = (&controller.WebServiceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Config:     operatorConfig,
+		GatewayAPI: gatewayAPI,
+		Recorder:   mgr.GetEventRecorderFor("webservice-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "WebService")
 		os.Exit(1)
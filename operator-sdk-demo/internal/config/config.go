@@ -0,0 +1,210 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	APIVersion = "config.webapps.my.domain/v1alpha1"
+	Kind       = "OperatorConfig"
+)
+
+// Component names used as keys for the per-component defaults.
+const (
+	ComponentMysql      = "mysql"
+	ComponentMariadb    = "mariadb"
+	ComponentPostgresql = "postgresql"
+	ComponentFrontend   = "frontend"
+)
+
+// OperatorConfig is the versioned operator configuration file. Anything set
+// on a WebService overrides the matching default here.
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Images maps a component name to its default image.
+	Images map[string]string `json:"images,omitempty"`
+	// Registry rewrites image references, e.g. to go through a mirror.
+	Registry RegistryConfig `json:"registry,omitempty"`
+	// ImagePullSecrets are added to every pod the operator creates.
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Resources maps a component name to its default resource requirements.
+	Resources map[string]corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Labels and Annotations are added to every object the operator creates.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	Mysql    MysqlConfig    `json:"mysql,omitempty"`
+	Frontend FrontendConfig `json:"frontend,omitempty"`
+}
+
+type RegistryConfig struct {
+	Mirrors []RegistryMirror `json:"mirrors,omitempty"`
+}
+
+// RegistryMirror replaces Prefix with Replacement on fully qualified image
+// references, e.g. "docker.io/" -> "registry.example.com/docker.io/".
+type RegistryMirror struct {
+	Prefix      string `json:"prefix"`
+	Replacement string `json:"replacement"`
+}
+
+// MysqlConfig applies to every database engine despite its name. It has no
+// credentials on purpose, they are generated per WebService or taken from
+// spec.database.auth.
+type MysqlConfig struct {
+	Database string `json:"database,omitempty"`
+}
+
+type FrontendConfig struct {
+	ContainerName string `json:"containerName,omitempty"`
+}
+
+// Default returns the configuration used when no config file is given.
+func Default() *OperatorConfig {
+	return &OperatorConfig{
+		TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		Images: map[string]string{
+			ComponentMysql:      "mysql:5.7",
+			ComponentMariadb:    "mariadb:10.11",
+			ComponentPostgresql: "postgres:16",
+			ComponentFrontend:   "nginx",
+		},
+		Mysql: MysqlConfig{
+			Database: "webservice",
+		},
+		Frontend: FrontendConfig{
+			ContainerName: "nginx",
+		},
+	}
+}
+
+// Load reads the config file at path. Fields missing from the file keep
+// their default value.
+func Load(path string) (*OperatorConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (*OperatorConfig, error) {
+	cfg := &OperatorConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("decode operator config: %w", err)
+	}
+	if cfg.APIVersion != APIVersion || cfg.Kind != Kind {
+		return nil, fmt.Errorf("unsupported operator config %s/%s, want %s/%s", cfg.APIVersion, cfg.Kind, APIVersion, Kind)
+	}
+
+	def := Default()
+	for component, image := range def.Images {
+		if cfg.Images == nil {
+			cfg.Images = map[string]string{}
+		}
+		if cfg.Images[component] == "" {
+			cfg.Images[component] = image
+		}
+	}
+	if cfg.Mysql.Database == "" {
+		cfg.Mysql.Database = def.Mysql.Database
+	}
+	if cfg.Frontend.ContainerName == "" {
+		cfg.Frontend.ContainerName = def.Frontend.ContainerName
+	}
+	return cfg, nil
+}
+
+// Image returns override if set, the component default otherwise, with the
+// registry mirror rules applied.
+func (c *OperatorConfig) Image(component, override string) string {
+	image := override
+	if image == "" {
+		image = c.Images[component]
+	}
+	return c.Registry.Rewrite(image)
+}
+
+// Rewrite applies the first matching mirror rule to image.
+func (r RegistryConfig) Rewrite(image string) string {
+	if image == "" || len(r.Mirrors) == 0 {
+		return image
+	}
+	full := qualifyImage(image)
+	for _, m := range r.Mirrors {
+		if m.Prefix != "" && strings.HasPrefix(full, m.Prefix) {
+			return m.Replacement + strings.TrimPrefix(full, m.Prefix)
+		}
+	}
+	return image
+}
+
+// qualifyImage expands short docker hub references, "nginx" becomes
+// "docker.io/library/nginx" and "bitnami/redis" becomes "docker.io/bitnami/redis".
+func qualifyImage(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return "docker.io/library/" + image
+	}
+	if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return "docker.io/" + image
+	}
+	return image
+}
+
+// ResourcesFor returns override unless it is empty.
+func (c *OperatorConfig) ResourcesFor(component string, override corev1.ResourceRequirements) corev1.ResourceRequirements {
+	if len(override.Limits) > 0 || len(override.Requests) > 0 {
+		return override
+	}
+	res := c.Resources[component]
+	return *res.DeepCopy()
+}
+
+// WithLabels returns the default labels merged with labels, labels win.
+func (c *OperatorConfig) WithLabels(labels map[string]string) map[string]string {
+	return merge(c.Labels, labels)
+}
+
+// WithAnnotations returns the default annotations merged with annotations,
+// annotations win.
+func (c *OperatorConfig) WithAnnotations(annotations map[string]string) map[string]string {
+	return merge(c.Annotations, annotations)
+}
+
+func merge(defaults, values map[string]string) map[string]string {
+	if len(defaults) == 0 && len(values) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(defaults)+len(values))
+	for k, v := range defaults {
+		out[k] = v
+	}
+	for k, v := range values {
+		out[k] = v
+	}
+	return out
+}
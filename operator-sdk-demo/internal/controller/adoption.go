@@ -0,0 +1,131 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// adoptLabel lets the IfLabeled policy adopt an object; the value is the
+// name of the adopting WebService.
+const adoptLabel = "webapps.my.domain/adopt"
+
+const (
+	reasonAdopted         = "Adopted"
+	reasonAdoptionRefused = "AdoptionRefused"
+)
+
+// ConflictError is returned when a child cannot be applied because an
+// object of the same name is not the WebService's to take.
+type ConflictError struct {
+	Kind, Name, Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s already exists and %s", e.Kind, e.Name, e.Reason)
+}
+
+// adopt runs before desired is applied. An existing object of that name
+// without the controller of desired is either adopted, i.e. given the
+// controller reference and the labels of desired, or refused with the
+// Conflict condition, depending on spec.adoptionPolicy. The apply that
+// follows an adoption brings the object to the desired state.
+func (r *WebServiceReconciler) adopt(ctx context.Context, webService *webappsv1.WebService, desired client.Object) error {
+	owner := metav1.GetControllerOf(desired)
+	if owner == nil {
+		return nil
+	}
+	gvk, err := apiutil.GVKForObject(desired, r.Scheme)
+	if err != nil {
+		return err
+	}
+	obj, err := r.Scheme.New(gvk)
+	if err != nil {
+		return err
+	}
+	existing := obj.(client.Object)
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	controller := metav1.GetControllerOf(existing)
+	if controller != nil && controller.UID == owner.UID {
+		return nil
+	}
+
+	reason := ""
+	switch policy := webService.Spec.AdoptionPolicy; {
+	case controller != nil:
+		reason = fmt.Sprintf("is controlled by %s %s", controller.Kind, controller.Name)
+	case policy == webappsv1.AdoptionPolicyAlways:
+	case policy == webappsv1.AdoptionPolicyIfLabeled:
+		if existing.GetLabels()[adoptLabel] != webService.Name {
+			reason = fmt.Sprintf("is not labelled %s=%s", adoptLabel, webService.Name)
+		}
+	default:
+		reason = "the adoptionPolicy is Never"
+	}
+	if reason != "" {
+		conflict := &ConflictError{Kind: gvk.Kind, Name: existing.GetName(), Reason: reason}
+		r.Recorder.Event(webService, corev1.EventTypeWarning, reasonAdoptionRefused, conflict.Error())
+		patch := client.MergeFrom(webService.DeepCopy())
+		meta.SetStatusCondition(&webService.Status.Conditions, metav1.Condition{
+			Type:               webappsv1.ConditionConflict,
+			Status:             metav1.ConditionTrue,
+			Reason:             reasonAdoptionRefused,
+			Message:            conflict.Error(),
+			ObservedGeneration: webService.Generation,
+		})
+		if err := r.Status().Patch(ctx, webService, patch); err != nil {
+			return err
+		}
+		return conflict
+	}
+
+	patch := client.MergeFrom(existing.DeepCopyObject().(client.Object))
+	existing.SetOwnerReferences(append(existing.GetOwnerReferences(), *owner))
+	labels := existing.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range desired.GetLabels() {
+		labels[k] = v
+	}
+	existing.SetLabels(labels)
+	if err := r.Client.Patch(ctx, existing, patch); err != nil {
+		return err
+	}
+	log.Println("Adopted", gvk.Kind, existing.GetName(), "for webService", webService.Name)
+	r.Recorder.Eventf(webService, corev1.EventTypeNormal, reasonAdopted, "Adopted %s %s", gvk.Kind, existing.GetName())
+	return nil
+}
+
+// adoptAndApply is apply for the children subject to spec.adoptionPolicy.
+func (r *WebServiceReconciler) adoptAndApply(ctx context.Context, webService *webappsv1.WebService, obj client.Object) error {
+	if err := r.adopt(ctx, webService, obj); err != nil {
+		return err
+	}
+	return r.apply(ctx, obj)
+}
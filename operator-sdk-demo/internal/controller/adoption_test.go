@@ -0,0 +1,112 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+func TestAdoptAndApply(t *testing.T) {
+	controller := true
+	foreign := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid", Controller: &controller}
+	tests := []struct {
+		name     string
+		policy   webappsv1.AdoptionPolicy
+		label    string
+		owner    *metav1.OwnerReference
+		conflict string
+	}{
+		{name: "never", conflict: "Service nginx already exists and the adoptionPolicy is Never"},
+		{name: "labelled", policy: webappsv1.AdoptionPolicyIfLabeled, label: "shop"},
+		{name: "labelled for another", policy: webappsv1.AdoptionPolicyIfLabeled, label: "blog", conflict: "is not labelled webapps.my.domain/adopt=shop"},
+		{name: "always", policy: webappsv1.AdoptionPolicyAlways},
+		{name: "other controller", policy: webappsv1.AdoptionPolicyAlways, owner: &foreign, conflict: "is controlled by ConfigMap other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			webService := sampleWebService("shop")
+			webService.Spec.AdoptionPolicy = tt.policy
+			existing := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: webService.Namespace, Labels: map[string]string{"team": "shop"}},
+				Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 8080}}},
+			}
+			if tt.label != "" {
+				existing.Labels[adoptLabel] = tt.label
+			}
+			if tt.owner != nil {
+				existing.OwnerReferences = []metav1.OwnerReference{*tt.owner}
+			}
+			r := fakeReconciler(t, webService, existing)
+			events := r.Recorder.(*record.FakeRecorder).Events
+			desired := resources.NewComponentService(webService, r.Config.Get(), resources.PrimaryComponent(webService))
+
+			err := r.adoptAndApply(ctx, webService, desired)
+			stored := &corev1.Service{}
+			g.Expect(r.Client.Get(ctx, objectKey(webService, "nginx"), stored)).To(Succeed())
+			if tt.conflict != "" {
+				var conflict *ConflictError
+				g.Expect(errors.As(err, &conflict)).To(BeTrue())
+				g.Expect(conflict.Error()).To(ContainSubstring(tt.conflict))
+				g.Expect(metav1.IsControlledBy(stored, webService)).To(BeFalse())
+				g.Expect(stored.Spec.Ports).To(ConsistOf(HaveField("Port", int32(8080))), "a refused object is not applied")
+				g.Expect(r.Client.Get(ctx, objectKey(webService, webService.Name), webService)).To(Succeed())
+				g.Expect(meta.FindStatusCondition(webService.Status.Conditions, webappsv1.ConditionConflict)).To(And(
+					HaveField("Status", metav1.ConditionTrue),
+					HaveField("Reason", reasonAdoptionRefused),
+				))
+				g.Expect(events).To(Receive(HavePrefix("Warning " + reasonAdoptionRefused)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(metav1.IsControlledBy(stored, webService)).To(BeTrue())
+			g.Expect(stored.Labels).To(HaveKeyWithValue(resources.WebServiceLabel, "shop"))
+			g.Expect(stored.Spec.Ports).To(ConsistOf(HaveField("Port", int32(80))), "the apply follows the adoption")
+			g.Expect(events).To(Receive(HavePrefix("Normal " + reasonAdopted)))
+		})
+	}
+}
+
+func TestAdoptSkipsOwnAndMissingObjects(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	r := fakeReconciler(t, webService)
+	desired := resources.NewComponentService(webService, r.Config.Get(), resources.PrimaryComponent(webService))
+
+	g.Expect(r.adopt(ctx, webService, desired)).To(Succeed(), "nothing to adopt")
+	g.Expect(r.Client.Create(ctx, desired.DeepCopy())).To(Succeed())
+	g.Expect(r.adopt(ctx, webService, desired)).To(Succeed(), "already controlled by the WebService")
+
+	// Objects without a controller reference are not checked.
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "shop-retained", Namespace: webService.Namespace}}
+	g.Expect(r.Client.Create(ctx, secret.DeepCopy())).To(Succeed())
+	g.Expect(r.adopt(ctx, webService, secret)).To(Succeed())
+	g.Expect(r.Recorder.(*record.FakeRecorder).Events).To(BeEmpty())
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	webappsv1 "my.domain/demo/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// fieldOwner owns every field the operator sets on its children.
+	fieldOwner = client.FieldOwner("webservice-operator")
+
+	// legacySpecAnnotation held the JSON spec of the last reconcile before
+	// the operator switched to generation tracking.
+	legacySpecAnnotation = "spec"
+)
+
+// legacyFieldManagers wrote the children with Create/Update. Without an
+// explicit owner controller-runtime uses the binary name.
+var legacyFieldManagers = sets.New("manager")
+
+// apply server-side applies obj. Forcing ownership resets fields edited out
+// of band, so every reconcile corrects drift.
+func (r *WebServiceReconciler) apply(ctx context.Context, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	return r.Client.Patch(ctx, obj, client.Apply, fieldOwner, client.ForceOwnership)
+}
+
+// migrateLegacySpec hands the fields written by older releases over to
+// fieldOwner, so fields no longer set get pruned on the next apply, and
+// drops the "spec" annotation.
+func (r *WebServiceReconciler) migrateLegacySpec(ctx context.Context, webService *webappsv1.WebService) error {
+	if _, ok := webService.Annotations[legacySpecAnnotation]; !ok {
+		return nil
+	}
+
+	children := []client.Object{}
+	for _, name := range []string{databaseName(webService), frontendName(webService)} {
+		if name == "" {
+			continue
+		}
+		children = append(children,
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webService.Namespace}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webService.Namespace}})
+	}
+	for _, obj := range children {
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		patch, err := csaupgrade.UpgradeManagedFieldsPatch(obj, legacyFieldManagers, string(fieldOwner))
+		if err != nil {
+			return err
+		}
+		if patch == nil {
+			continue
+		}
+		if err := r.Client.Patch(ctx, obj, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+			return err
+		}
+	}
+
+	patch := client.MergeFrom(webService.DeepCopy())
+	delete(webService.Annotations, legacySpecAnnotation)
+	return r.Client.Patch(ctx, webService, patch)
+}
+
+func databaseName(webService *webappsv1.WebService) string {
+	if webService.Spec.DatabaseSpec() == nil {
+		return ""
+	}
+	return webService.Spec.DatabaseSpec().Name
+}
+
+func frontendName(webService *webappsv1.WebService) string {
+	if webService.Spec.Frontend == nil {
+		return ""
+	}
+	return webService.Spec.Frontend.Name
+}
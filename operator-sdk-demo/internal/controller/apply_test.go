@@ -0,0 +1,129 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+func TestApplyForcesOwnership(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	r := fakeReconciler(t)
+	var applied []client.PatchOptions
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			g.Expect(patch.Type()).To(Equal(types.ApplyPatchType))
+			po := client.PatchOptions{}
+			applied = append(applied, *po.ApplyOptions(opts))
+			return fakeApply(ctx, c, obj, patch, opts...)
+		},
+	})
+
+	// What was read back from the API server must not go into the patch.
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "nginx", Namespace: "default", ResourceVersion: "7",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl-edit"}},
+		},
+		Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+	}
+	g.Expect(r.apply(ctx, svc)).To(Succeed())
+	g.Expect(svc.GroupVersionKind()).To(Equal(corev1.SchemeGroupVersion.WithKind("Service")))
+
+	// Drift is undone by the next apply.
+	current := &corev1.Service{}
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(svc), current)).To(Succeed())
+	current.Spec.Type = corev1.ServiceTypeNodePort
+	g.Expect(r.Client.Update(ctx, current)).To(Succeed())
+	g.Expect(r.apply(ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+	})).To(Succeed())
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(svc), current)).To(Succeed())
+	g.Expect(current.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+
+	g.Expect(applied).To(HaveLen(2))
+	for _, po := range applied {
+		g.Expect(po.FieldManager).To(Equal(string(fieldOwner)))
+		g.Expect(po.Force).To(HaveValue(BeTrue()))
+	}
+}
+
+func TestMigrateLegacySpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(*webappsv1.WebService)
+		children []string
+	}{
+		{name: "frontend and database", mutate: func(*webappsv1.WebService) {}, children: []string{"mysql", "nginx"}},
+		{name: "frontend only", mutate: func(ws *webappsv1.WebService) { ws.Spec.Database = nil }, children: []string{"nginx"}},
+		{name: "database only", mutate: func(ws *webappsv1.WebService) { ws.Spec.Frontend = nil }, children: []string{"mysql"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			webService := sampleWebService("shop")
+			webService.Annotations = map[string]string{legacySpecAnnotation: "{}"}
+			tt.mutate(webService)
+			r := fakeReconciler(t, webService)
+			// The children of an older release, written with Update.
+			legacy := func(name, apiVersion string) metav1.ObjectMeta {
+				return metav1.ObjectMeta{Name: name, Namespace: webService.Namespace, ManagedFields: []metav1.ManagedFieldsEntry{{
+					Manager:    "manager",
+					Operation:  metav1.ManagedFieldsOperationUpdate,
+					APIVersion: apiVersion,
+					FieldsType: "FieldsV1",
+					FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{}}}`)},
+				}}}
+			}
+			for _, name := range tt.children {
+				g.Expect(r.Client.Create(ctx, &appsv1.Deployment{ObjectMeta: legacy(name, "apps/v1")})).To(Succeed())
+				g.Expect(r.Client.Create(ctx, &corev1.Service{ObjectMeta: legacy(name, "v1")})).To(Succeed())
+			}
+
+			g.Expect(r.migrateLegacySpec(ctx, webService)).To(Succeed())
+
+			g.Expect(r.Client.Get(ctx, objectKey(webService, "shop"), webService)).To(Succeed())
+			g.Expect(webService.Annotations).NotTo(HaveKey(legacySpecAnnotation))
+			ownedByOperator := ConsistOf(And(
+				HaveField("Manager", string(fieldOwner)),
+				HaveField("Operation", metav1.ManagedFieldsOperationApply),
+			))
+			for _, name := range tt.children {
+				deploy := &appsv1.Deployment{}
+				g.Expect(r.Client.Get(ctx, objectKey(webService, name), deploy)).To(Succeed())
+				g.Expect(deploy.ManagedFields).To(ownedByOperator, "Deployment %s", name)
+				svc := &corev1.Service{}
+				g.Expect(r.Client.Get(ctx, objectKey(webService, name), svc)).To(Succeed())
+				g.Expect(svc.ManagedFields).To(ownedByOperator, "Service %s", name)
+			}
+		})
+	}
+}
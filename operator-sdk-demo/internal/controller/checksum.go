@@ -0,0 +1,133 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"log"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Field indexes of WebServices by the names of the ConfigMaps and Secrets
+// they read, see configRefs.
+const (
+	configMapIndex = "configMaps"
+	secretIndex    = "secrets"
+)
+
+// configRefs lists the ConfigMaps and Secrets webService reads: those of
+// the component pod templates and the migrations ConfigMap.
+func (r *WebServiceReconciler) configRefs(webService *webappsv1.WebService) (configMaps, secrets sets.Set[string]) {
+	configMaps, secrets = sets.New[string](), sets.New[string]()
+	cfg := r.Config.Get()
+	for _, c := range resources.Components(webService) {
+		spec := resources.NewComponentDeployment(webService, cfg, c).Spec.Template.Spec
+		podConfigMaps, podSecrets := resources.PodConfigRefs(&spec)
+		configMaps = configMaps.Union(podConfigMaps)
+		secrets = secrets.Union(podSecrets)
+	}
+	if m := webService.Spec.Migrations; m != nil && m.ConfigMap != nil {
+		configMaps.Insert(m.ConfigMap.Name)
+	}
+	return configMaps, secrets
+}
+
+// configChecksum hashes the data of the ConfigMaps and Secrets the pods of
+// c read, skipping those marked for hot reload. A missing object counts
+// as absent, so creating it changes the checksum as well.
+func (r *WebServiceReconciler) configChecksum(ctx context.Context, webService *webappsv1.WebService, c resources.Component) (string, error) {
+	spec := resources.NewComponentDeployment(webService, r.Config.Get(), c).Spec.Template.Spec
+	configMaps, secrets := resources.PodConfigRefs(&spec)
+	h := sha256.New()
+	for _, name := range sets.List(configMaps) {
+		configMap := &corev1.ConfigMap{}
+		err := r.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: webService.Namespace}, configMap)
+		if client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		if err != nil {
+			fmt.Fprintf(h, "configmap %s absent\n", name)
+		} else if configMap.Annotations[resources.HotReloadAnnotation] != "true" {
+			fmt.Fprintf(h, "configmap %s\n", name)
+			writeData(h, configMap.Data, configMap.BinaryData)
+		}
+	}
+	for _, name := range sets.List(secrets) {
+		secret := &corev1.Secret{}
+		err := r.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: webService.Namespace}, secret)
+		if client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		if err != nil {
+			fmt.Fprintf(h, "secret %s absent\n", name)
+		} else if secret.Annotations[resources.HotReloadAnnotation] != "true" {
+			fmt.Fprintf(h, "secret %s\n", name)
+			writeData(h, nil, secret.Data)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// writeData writes the keys and values of a ConfigMap or Secret to h,
+// sorted by key and length prefixed.
+func writeData(h hash.Hash, data map[string]string, binaryData map[string][]byte) {
+	values := make(map[string][]byte, len(data)+len(binaryData))
+	for k, v := range data {
+		values[k] = []byte(v)
+	}
+	for k, v := range binaryData {
+		values[k] = v
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s %d\n", k, len(values[k]))
+		h.Write(values[k])
+	}
+}
+
+// webServicesReading returns a map func that requeues the WebServices the
+// field index finds for a ConfigMap or Secret.
+func (r *WebServiceReconciler) webServicesReading(index string) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		webServices := &webappsv1.WebServiceList{}
+		if err := r.Client.List(ctx, webServices, client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{index: obj.GetName()}); err != nil {
+			log.Println("WebService list failure for", index, obj.GetName(), ":", err)
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(webServices.Items))
+		for i := range webServices.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&webServices.Items[i])})
+		}
+		return requests
+	}
+}
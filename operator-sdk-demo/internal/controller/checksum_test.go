@@ -0,0 +1,146 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+// readingConfig makes the frontend of webService read the ConfigMap
+// "web-config" and the Secret "web-token".
+func readingConfig(webService *webappsv1.WebService) *webappsv1.WebService {
+	webService.Spec.Frontend.Envs = []corev1.EnvVar{
+		{Name: "LEVEL", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}, Key: "level"}}},
+		{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "web-token"}, Key: "token"}}},
+	}
+	return webService
+}
+
+func TestConfigRefs(t *testing.T) {
+	g := NewWithT(t)
+	webService := readingConfig(sampleWebService("shop"))
+	webService.Spec.Migrations = &webappsv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "shop-sql"}}
+	r := fakeReconciler(t)
+
+	configMaps, secrets := r.configRefs(webService)
+	g.Expect(sets.List(configMaps)).To(ContainElements("web-config", "shop-sql"))
+	g.Expect(sets.List(secrets)).To(ContainElement("web-token"))
+}
+
+func TestConfigChecksum(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := readingConfig(sampleWebService("shop"))
+	r := fakeReconciler(t, webService)
+	c := resources.PrimaryComponent(webService)
+	checksum := func() string {
+		sum, err := r.configChecksum(ctx, webService, c)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(sum).To(HaveLen(16))
+		return sum
+	}
+
+	absent := checksum()
+	g.Expect(checksum()).To(Equal(absent), "the checksum is stable")
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: webService.Namespace},
+		Data:       map[string]string{"level": "info"},
+	}
+	g.Expect(r.Client.Create(ctx, configMap)).To(Succeed())
+	created := checksum()
+	g.Expect(created).NotTo(Equal(absent), "creating a ConfigMap changes the checksum")
+
+	configMap.Data["level"] = "debug"
+	g.Expect(r.Client.Update(ctx, configMap)).To(Succeed())
+	edited := checksum()
+	g.Expect(edited).NotTo(Equal(created), "editing a ConfigMap changes the checksum")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-token", Namespace: webService.Namespace},
+		Data:       map[string][]byte{"token": []byte("s3cret")},
+	}
+	g.Expect(r.Client.Create(ctx, secret)).To(Succeed())
+	withSecret := checksum()
+	g.Expect(withSecret).NotTo(Equal(edited), "creating a Secret changes the checksum")
+
+	configMap.Annotations = map[string]string{resources.HotReloadAnnotation: "true"}
+	g.Expect(r.Client.Update(ctx, configMap)).To(Succeed())
+	hotReload := checksum()
+	configMap.Data["level"] = "warn"
+	g.Expect(r.Client.Update(ctx, configMap)).To(Succeed())
+	g.Expect(checksum()).To(Equal(hotReload), "a hot reloaded ConfigMap is not hashed")
+}
+
+func TestWriteData(t *testing.T) {
+	g := NewWithT(t)
+	sum := func(data map[string]string, binaryData map[string][]byte) []byte {
+		h := sha256.New()
+		writeData(h, data, binaryData)
+		return h.Sum(nil)
+	}
+
+	g.Expect(sum(map[string]string{"a": "1", "b": "2"}, nil)).To(Equal(sum(map[string]string{"b": "2", "a": "1"}, nil)))
+	g.Expect(sum(map[string]string{"a": "1"}, nil)).To(Equal(sum(nil, map[string][]byte{"a": []byte("1")})))
+	g.Expect(sum(map[string]string{"a": "1b 1"}, nil)).NotTo(Equal(sum(map[string]string{"a": "1", "b": "1"}, nil)),
+		"values are length prefixed")
+}
+
+func TestWebServicesReading(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	shop := sampleWebService("shop")
+	shop.Spec.Migrations = &webappsv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "shop-sql"}}
+	blog := readingConfig(sampleWebService("blog"))
+	r := fakeReconciler(t)
+	r.Client = fake.NewClientBuilder().
+		WithScheme(r.Client.Scheme()).
+		WithObjects(shop, blog).
+		WithIndex(&webappsv1.WebService{}, configMapIndex, func(obj client.Object) []string {
+			configMaps, _ := r.configRefs(obj.(*webappsv1.WebService))
+			return sets.List(configMaps)
+		}).
+		WithIndex(&webappsv1.WebService{}, secretIndex, func(obj client.Object) []string {
+			_, secrets := r.configRefs(obj.(*webappsv1.WebService))
+			return sets.List(secrets)
+		}).
+		Build()
+	configMap := func(name string) client.Object {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	}
+
+	g.Expect(r.webServicesReading(configMapIndex)(ctx, configMap("shop-sql"))).To(ConsistOf(
+		reconcile.Request{NamespacedName: objectKey(shop, "shop")}))
+	g.Expect(r.webServicesReading(secretIndex)(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "web-token", Namespace: "default"}})).To(ConsistOf(
+		reconcile.Request{NamespacedName: objectKey(blog, "blog")}))
+	g.Expect(r.webServicesReading(configMapIndex)(ctx, configMap("unrelated"))).To(BeEmpty())
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ensureComponent applies the workloads, Service, NetworkPolicy and
+// exposure of c. Its step in the reconcile graph runs after the schema
+// migrations and the components c depends on. It returns when a running
+// rollout needs to be looked at again, zero otherwise.
+func (r *WebServiceReconciler) ensureComponent(ctx context.Context, webService *webappsv1.WebService, c resources.Component) (time.Duration, error) {
+	cfg := r.Config.Get()
+	checksum, err := r.configChecksum(ctx, webService, c)
+	if err != nil {
+		log.Println("Component", c.Name, "config checksum failure.")
+		return 0, err
+	}
+	c.ConfigChecksum = checksum
+	requeue, err := r.rolloutComponent(ctx, webService, c)
+	if err != nil {
+		log.Println("Component", c.Name, "deployment apply failure.")
+		return 0, err
+	}
+	service := resources.NewComponentService(webService, cfg, c)
+	service.Spec.Selector = serviceSelector(webService, c)
+	if err := r.adoptAndApply(ctx, webService, service); err != nil {
+		log.Println("Component", c.Name, "service apply failure.")
+		return 0, err
+	}
+	// Only once the Service has moved over.
+	if err := r.pruneInactiveSlot(ctx, webService, c); err != nil {
+		return 0, err
+	}
+	if err := r.ensureComponentNetworkPolicy(ctx, webService, c); err != nil {
+		log.Println("Component", c.Name, "networkpolicy apply failure.")
+		return 0, err
+	}
+	return requeue, r.ensureExposure(ctx, webService, c)
+}
+
+// isComponentRunning reports whether the active Deployment of c has all
+// its replicas ready. It does for components what isDatabaseRunning does
+// for the database.
+func (r *WebServiceReconciler) isComponentRunning(ctx context.Context, webService *webappsv1.WebService, c resources.Component) (bool, error) {
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: c.SlotName(activeSlot(webService, c)), Namespace: webService.Namespace}}
+	status, err := r.componentStatus(ctx, deploy)
+	if err != nil {
+		return false, err
+	}
+	return isComponentReady(status), nil
+}
+
+// pruneComponents removes the Deployment, Service, NetworkPolicy, Ingress
+// and HTTPRoute of every component that is no longer in the spec.
+func (r *WebServiceReconciler) pruneComponents(ctx context.Context, webService *webappsv1.WebService, components []resources.Component) error {
+	names := map[string]bool{}
+	for _, c := range components {
+		names[c.ObjectName] = true
+		names[c.SlotName(resources.SlotGreen)] = true
+	}
+	lists := []client.ObjectList{&appsv1.DeploymentList{}, &corev1.ServiceList{},
+		&networkingv1.NetworkPolicyList{}, &networkingv1.IngressList{}}
+	if r.GatewayAPI {
+		routes := &unstructured.UnstructuredList{}
+		routes.SetGroupVersionKind(resources.HTTPRouteGVK.GroupVersion().WithKind(resources.HTTPRouteGVK.Kind + "List"))
+		lists = append(lists, routes)
+	}
+	for _, list := range lists {
+		if err := r.Client.List(ctx, list, client.InNamespace(webService.Namespace),
+			client.MatchingLabels{resources.WebServiceLabel: webService.Name}); err != nil {
+			return err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj := item.(client.Object)
+			if names[obj.GetName()] || !metav1.IsControlledBy(obj, webService) {
+				continue
+			}
+			log.Println("Deleting", obj.GetName(), "of removed component", obj.GetLabels()[resources.ComponentLabel])
+			if err := r.Client.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
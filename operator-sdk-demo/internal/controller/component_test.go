@@ -0,0 +1,63 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+func TestPruneComponents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	one := int32(1)
+	webService := sampleWebService("shop")
+	webService.Spec.Components = []webappsv1.WebServiceFrontendSpec{{Name: "api", Size: &one, Image: "api"}}
+	child := func(obj client.Object, name, component string, controlled bool) client.Object {
+		obj.SetName(name)
+		obj.SetNamespace(webService.Namespace)
+		obj.SetLabels(map[string]string{resources.WebServiceLabel: webService.Name, resources.ComponentLabel: component})
+		if controlled {
+			obj.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(webService, webappsv1.GroupVersion.WithKind("WebService"))})
+		}
+		return obj
+	}
+	r := fakeReconciler(t, webService,
+		child(&appsv1.Deployment{}, "shop-api", "api", true),
+		child(&appsv1.Deployment{}, "shop-api-green", "api", true),
+		child(&appsv1.Deployment{}, "shop-worker", "worker", true),
+		child(&corev1.Service{}, "shop-worker", "worker", true),
+		child(&appsv1.Deployment{}, "shop-cron", "cron", false),
+	)
+
+	g.Expect(r.pruneComponents(ctx, webService, resources.Components(webService))).To(Succeed())
+	g.Expect(errors.IsNotFound(r.Client.Get(ctx, objectKey(webService, "shop-worker"), &appsv1.Deployment{}))).To(BeTrue())
+	g.Expect(errors.IsNotFound(r.Client.Get(ctx, objectKey(webService, "shop-worker"), &corev1.Service{}))).To(BeTrue())
+	g.Expect(r.Client.Get(ctx, objectKey(webService, "shop-api"), &appsv1.Deployment{})).To(Succeed())
+	g.Expect(r.Client.Get(ctx, objectKey(webService, "shop-api-green"), &appsv1.Deployment{})).To(Succeed(), "the green slot of a component is kept")
+	g.Expect(r.Client.Get(ctx, objectKey(webService, "shop-cron"), &appsv1.Deployment{})).To(Succeed(), "an object the WebService does not control is kept")
+}
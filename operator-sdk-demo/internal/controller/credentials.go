@@ -0,0 +1,155 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Keys of the database credentials Secret, DatabaseProvider.SecretKeys
+// tells which of them an engine uses.
+const (
+	keyRootPassword = "root-password"
+	keyUsername     = "username"
+	keyPassword     = "password"
+)
+
+const defaultDbUser = "demo"
+
+// dbSecretName returns the user provided Secret if spec.database.auth names
+// one, otherwise the per WebService "<name>-<engine>-auth".
+func dbSecretName(webService *webappsv1.WebService) string {
+	if hasExistingDbSecret(webService) {
+		return webService.Spec.DatabaseSpec().Auth.ExistingSecretRef.Name
+	}
+	return webService.Name + "-" + databaseProvider(webService).Component() + "-auth"
+}
+
+func hasExistingDbSecret(webService *webappsv1.WebService) bool {
+	spec := webService.Spec.DatabaseSpec()
+	if spec == nil || spec.Auth == nil {
+		return false
+	}
+	ref := spec.Auth.ExistingSecretRef
+	return ref != nil && ref.Name != ""
+}
+
+// databaseSecret returns the generated credentials. Whatever is stored
+// already wins over new random values, a password is never rotated by the
+// operator.
+func (r *WebServiceReconciler) databaseSecret(ctx context.Context, webService *webappsv1.WebService) (*corev1.Secret, error) {
+	cfg := r.Config.Get()
+	found := &corev1.Secret{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: dbSecretName(webService), Namespace: webService.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	data := map[string][]byte{}
+	for _, key := range databaseProvider(webService).SecretKeys() {
+		if value := found.Data[key]; len(value) > 0 {
+			data[key] = value
+			continue
+		}
+		if key == keyUsername {
+			data[key] = []byte(defaultDbUser)
+			continue
+		}
+		password, err := generatePassword(24)
+		if err != nil {
+			return nil, err
+		}
+		data[key] = []byte(password)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: dbSecretName(webService), Namespace: webService.Namespace,
+			Labels: cfg.WithLabels(databaseLabels(webService)), Annotations: cfg.WithAnnotations(nil)},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	controllerutil.SetControllerReference(webService, secret, r.Scheme)
+	return secret, nil
+}
+
+// validateDbSecret fails when the existing Secret lacks one of the keys.
+func (r *WebServiceReconciler) validateDbSecret(ctx context.Context, webService *webappsv1.WebService) error {
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: dbSecretName(webService), Namespace: webService.Namespace}, secret); err != nil {
+		return err
+	}
+	for _, key := range databaseProvider(webService).SecretKeys() {
+		if len(secret.Data[key]) == 0 {
+			return fmt.Errorf("database secret %s misses key %s", secret.Name, key)
+		}
+	}
+	return nil
+}
+
+// ensureBindingSecret applies the servicebinding.io Secret the frontend reads
+// its connection from. It follows the credentials Secret on every reconcile.
+func (r *WebServiceReconciler) ensureBindingSecret(ctx context.Context, webService *webappsv1.WebService) error {
+	if webService.Spec.DatabaseSpec() == nil {
+		return nil
+	}
+	creds := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: dbSecretName(webService), Namespace: webService.Namespace}, creds); err != nil {
+		return err
+	}
+	binding := resources.NewDatabaseBindingSecret(webService, r.Config.Get(),
+		string(creds.Data[keyUsername]), string(creds.Data[keyPassword]))
+	return r.adoptAndApply(ctx, webService, binding)
+}
+
+func dbSecretEnv(webService *webappsv1.WebService, name, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: dbSecretName(webService)},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+const passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// generatePassword draws n characters from crypto/rand.
+func generatePassword(n int) (string, error) {
+	password := make([]byte, n)
+	limit := big.NewInt(int64(len(passwordChars)))
+	for i := range password {
+		idx, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordChars[idx.Int64()]
+	}
+	return string(password), nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+func TestDatabaseSecretGeneratesOnce(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	r := fakeReconciler(t, webService)
+
+	secret, err := r.databaseSecret(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Name).To(Equal("shop-mysql-auth"))
+	g.Expect(metav1.IsControlledBy(secret, webService)).To(BeTrue())
+	g.Expect(secret.Data).To(HaveKeyWithValue(keyUsername, []byte(defaultDbUser)))
+	g.Expect(secret.Data).To(HaveKeyWithValue(keyRootPassword, HaveLen(24)))
+	g.Expect(secret.Data).To(HaveKeyWithValue(keyPassword, HaveLen(24)))
+	g.Expect(r.Client.Create(ctx, secret)).To(Succeed())
+
+	// Stored values win, only the missing password is generated.
+	stored := secret.DeepCopy()
+	stored.Data[keyUsername] = []byte("shop")
+	delete(stored.Data, keyPassword)
+	g.Expect(r.Client.Update(ctx, stored)).To(Succeed())
+	again, err := r.databaseSecret(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again.Data[keyUsername]).To(Equal([]byte("shop")))
+	g.Expect(again.Data[keyRootPassword]).To(Equal(secret.Data[keyRootPassword]))
+	g.Expect(again.Data[keyPassword]).To(HaveLen(24))
+}
+
+func TestDatabaseSecretPerEngine(t *testing.T) {
+	g := NewWithT(t)
+	webService := sampleWebService("shop")
+	webService.Spec.Database.Engine = webappsv1.DatabaseEnginePostgreSQL
+	r := fakeReconciler(t, webService)
+
+	secret, err := r.databaseSecret(context.Background(), webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(secret.Name).To(Equal("shop-postgresql-auth"))
+	g.Expect(secret.Data).To(HaveLen(2))
+	g.Expect(secret.Data).NotTo(HaveKey(keyRootPassword))
+}
+
+func TestValidateDbSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine webappsv1.DatabaseEngine
+		data   map[string]string
+		err    string
+	}{
+		{name: "mysql complete", data: map[string]string{keyRootPassword: "r", keyUsername: "u", keyPassword: "p"}},
+		{name: "mysql without root password", data: map[string]string{keyUsername: "u", keyPassword: "p"}, err: "misses key " + keyRootPassword},
+		{name: "empty password", data: map[string]string{keyRootPassword: "r", keyUsername: "u", keyPassword: ""}, err: "misses key " + keyPassword},
+		{name: "postgresql needs no root password", engine: webappsv1.DatabaseEnginePostgreSQL, data: map[string]string{keyUsername: "u", keyPassword: "p"}},
+		{name: "missing secret", err: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webService := sampleWebService("shop")
+			webService.Spec.Database.Engine = tt.engine
+			webService.Spec.Database.Auth = &webappsv1.WebServiceDbAuthSpec{ExistingSecretRef: &corev1.LocalObjectReference{Name: "shop-db"}}
+			r := fakeReconciler(t, webService)
+			if tt.data != nil {
+				secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "shop-db", Namespace: webService.Namespace}}
+				secret.Data = map[string][]byte{}
+				for k, v := range tt.data {
+					secret.Data[k] = []byte(v)
+				}
+				g.Expect(r.Client.Create(context.Background(), secret)).To(Succeed())
+			}
+
+			g.Expect(dbSecretName(webService)).To(Equal("shop-db"))
+			err := r.validateDbSecret(context.Background(), webService)
+			if tt.err == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.err)))
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// databaseStartupSeconds is how long a database server may take to start,
+// initializing a fresh data directory included.
+const databaseStartupSeconds = 300
+
+// DatabaseProvider renders the database of a WebService for one engine.
+// The StatefulSet is named after spec.database.name and governed by its
+// headless Service, the reconciler sets the owner of what is returned.
+type DatabaseProvider interface {
+	// Component is the tier label of the database objects and part of the
+	// generated Secret name.
+	Component() string
+	// Image runs the server and the client Jobs.
+	Image(webService *webappsv1.WebService, cfg *config.OperatorConfig) string
+	StatefulSet(webService *webappsv1.WebService, cfg *config.OperatorConfig) *appsv1.StatefulSet
+	// Services are the headless Service first, then the client Service.
+	Services(webService *webappsv1.WebService, cfg *config.OperatorConfig) []*corev1.Service
+	// SecretKeys are the credentials the engine reads; an existing Secret
+	// must have all of them.
+	SecretKeys() []string
+	// Ready reports whether the running StatefulSet serves clients.
+	Ready(sts *appsv1.StatefulSet) bool
+	// Validate rejects the parts of the spec the engine cannot run.
+	Validate(webService *webappsv1.WebService) error
+}
+
+var databaseProviders = map[webappsv1.DatabaseEngine]DatabaseProvider{
+	webappsv1.DatabaseEngineMySQL:      mysqlProvider{},
+	webappsv1.DatabaseEngineMariaDB:    mariadbProvider{},
+	webappsv1.DatabaseEnginePostgreSQL: postgresqlProvider{},
+}
+
+// databaseProvider returns the provider of spec.database.engine. WebServices
+// stored before the engine existed have none and run MySQL.
+func databaseProvider(webService *webappsv1.WebService) DatabaseProvider {
+	if provider, ok := databaseProviders[webService.Spec.DatabaseSpec().Engine]; ok {
+		return provider
+	}
+	return mysqlProvider{}
+}
+
+// mysqlCompatible reports whether the mysql client tools, used by the
+// snapshot and SQL migration Jobs, work against the engine.
+func mysqlCompatible(webService *webappsv1.WebService) bool {
+	switch databaseProvider(webService).(type) {
+	case mysqlProvider, mariadbProvider:
+		return true
+	}
+	return false
+}
+
+// validateDatabase checks the spec against the engine before anything is
+// applied.
+func validateDatabase(webService *webappsv1.WebService) error {
+	engine := webService.Spec.DatabaseSpec().Engine
+	if !mysqlCompatible(webService) {
+		if webService.Spec.DeletionPolicy == webappsv1.DeletionPolicySnapshot {
+			return fmt.Errorf("engine %s does not support the Snapshot deletion policy", engine)
+		}
+		if webService.Spec.Migrations != nil && webService.Spec.Migrations.ConfigMap != nil {
+			return fmt.Errorf("engine %s does not support SQL migrations from a ConfigMap", engine)
+		}
+	}
+	return databaseProvider(webService).Validate(webService)
+}
+
+func databaseLabels(webService *webappsv1.WebService) map[string]string {
+	return makeLabels(webService, databaseProvider(webService).Component())
+}
+
+func databaseStorageSize(webService *webappsv1.WebService) resource.Quantity {
+	if storage := webService.Spec.DatabaseSpec().Storage; storage != nil && !storage.Size.IsZero() {
+		return storage.Size
+	}
+	return resource.MustParse(defaultDatabaseStorage)
+}
+
+func (r *WebServiceReconciler) databaseStatefulSet(webService *webappsv1.WebService) *appsv1.StatefulSet {
+	sts := databaseProvider(webService).StatefulSet(webService, r.Config.Get())
+	// validatePodTemplates refuses an overlay that does not merge before
+	// the StatefulSet is built.
+	spec := webService.Spec.DatabaseSpec()
+	sts.Spec.Template, _ = resources.MergePodTemplate(sts.Spec.Template, spec.PodTemplate, spec.Name)
+	controllerutil.SetControllerReference(webService, sts, r.Scheme)
+	return sts
+}
+
+func (r *WebServiceReconciler) databaseServices(webService *webappsv1.WebService) []*corev1.Service {
+	services := databaseProvider(webService).Services(webService, r.Config.Get())
+	for _, svc := range services {
+		controllerutil.SetControllerReference(webService, svc, r.Scheme)
+	}
+	return services
+}
+
+func (r *WebServiceReconciler) isDatabaseRunning(webService *webappsv1.WebService) bool {
+	dbStatefulSet := &appsv1.StatefulSet{}
+	err := r.Client.Get(context.Background(), types.NamespacedName{Name: webService.Spec.DatabaseSpec().Name, Namespace: webService.Namespace}, dbStatefulSet)
+	if err != nil {
+		return false
+	}
+	return databaseProvider(webService).Ready(dbStatefulSet)
+}
+
+// newDatabaseStatefulSet wraps the server container of an engine. The
+// container gets its name, the first port of spec.database.ports, the data
+// volume mounted at dataPath and probes running ping, with the probes of
+// spec.database on top.
+func newDatabaseStatefulSet(webService *webappsv1.WebService, cfg *config.OperatorConfig, replicas *int32, container corev1.Container, dataPath string, ping ...string) *appsv1.StatefulSet {
+	spec := webService.Spec.DatabaseSpec()
+	myLabels := databaseLabels(webService)
+	var storageClass *string
+	if spec.Storage != nil {
+		storageClass = spec.Storage.StorageClassName
+	}
+	container.Name = spec.Name
+	container.ImagePullPolicy = corev1.PullIfNotPresent
+	container.Ports = []corev1.ContainerPort{
+		{
+			ContainerPort: spec.Ports[0].TargetPort.IntVal,
+			Name:          databaseProvider(webService).Component(),
+		},
+	}
+	container.VolumeMounts = []corev1.VolumeMount{
+		{Name: databaseDataVolume, MountPath: dataPath},
+	}
+	resources.SetProbes(&container, resources.DefaultProbes(corev1.ProbeHandler{
+		Exec: &corev1.ExecAction{Command: ping},
+	}, databaseStartupSeconds), spec.Probes)
+	podSecurity, security := databaseSecurityContexts(webService)
+	container.SecurityContext = security
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: spec.Name, Namespace: webService.Namespace,
+			Labels: cfg.WithLabels(myLabels), Annotations: cfg.WithAnnotations(nil)},
+		Spec: appsv1.StatefulSetSpec{
+			ServiceName: headlessName(spec.Name),
+			Replicas:    replicas,
+			Selector:    &metav1.LabelSelector{MatchLabels: myLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: cfg.WithLabels(myLabels), Annotations: cfg.WithAnnotations(nil)},
+				Spec: corev1.PodSpec{
+					SecurityContext:  podSecurity,
+					ImagePullSecrets: cfg.ImagePullSecrets,
+					Containers:       []corev1.Container{container},
+				},
+			},
+			// The claims are labelled like the pods so the deletion policy
+			// can find them.
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: databaseDataVolume, Labels: myLabels},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+						StorageClassName: storageClass,
+						Resources: corev1.VolumeResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceStorage: databaseStorageSize(webService)},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newDatabaseServices are the governing headless Service of the StatefulSet
+// and the Service clients connect to, both named after spec.database.name.
+func newDatabaseServices(webService *webappsv1.WebService, cfg *config.OperatorConfig) []*corev1.Service {
+	spec := webService.Spec.DatabaseSpec()
+	myLabels := databaseLabels(webService)
+	headless := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: headlessName(spec.Name), Namespace: webService.Namespace,
+			Labels: cfg.WithLabels(myLabels), Annotations: cfg.WithAnnotations(nil)},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                corev1.ClusterIPNone,
+			PublishNotReadyAddresses: true,
+			Ports: []corev1.ServicePort{
+				{Name: databaseProvider(webService).Component(), Port: spec.Ports[0].TargetPort.IntVal},
+			},
+			Selector: myLabels,
+		},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: spec.Name, Namespace: webService.Namespace,
+			Labels: cfg.WithLabels(myLabels), Annotations: cfg.WithAnnotations(nil)},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports:    spec.Ports,
+			Selector: myLabels,
+		},
+	}
+	return []*corev1.Service{headless, svc}
+}
+
+// validatePodTemplates checks every podTemplate overlay of webService.
+func validatePodTemplates(webService *webappsv1.WebService, cfg *config.OperatorConfig) error {
+	if err := resources.ValidateComponentPodTemplates(webService, cfg); err != nil {
+		return err
+	}
+	spec := webService.Spec.DatabaseSpec()
+	template := databaseProvider(webService).StatefulSet(webService, cfg).Spec.Template
+	if _, err := resources.MergePodTemplate(template, spec.PodTemplate, spec.Name); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+func TestDatabaseProviders(t *testing.T) {
+	tests := []struct {
+		engine        webappsv1.DatabaseEngine
+		image         string
+		secret        string
+		passwordEnv   string
+		headlessPort  int32
+		mysqlClientOK bool
+	}{
+		{engine: "", image: "mysql:5.7", secret: "shop-mysql-auth", passwordEnv: "MYSQL_ROOT_PASSWORD", headlessPort: 3306, mysqlClientOK: true},
+		{engine: webappsv1.DatabaseEngineMariaDB, image: "mariadb:10.11", secret: "shop-mariadb-auth", passwordEnv: "MARIADB_ROOT_PASSWORD", headlessPort: 3306, mysqlClientOK: true},
+		{engine: webappsv1.DatabaseEnginePostgreSQL, image: "postgres:16", secret: "shop-postgresql-auth", passwordEnv: "POSTGRES_PASSWORD", headlessPort: 5432},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.engine), func(t *testing.T) {
+			g := NewWithT(t)
+			webService := sampleWebService("shop")
+			webService.Spec.Database.Engine = tt.engine
+			webService.Spec.Database.Image = ""
+			if tt.engine == webappsv1.DatabaseEnginePostgreSQL {
+				webService.Spec.Database.Ports[0].Port = 5432
+				webService.Spec.Database.Ports[0].TargetPort.IntVal = 5432
+			}
+			r := fakeReconciler(t)
+
+			g.Expect(mysqlCompatible(webService)).To(Equal(tt.mysqlClientOK))
+			sts := r.databaseStatefulSet(webService)
+			g.Expect(sts.Name).To(Equal("mysql"), "the StatefulSet keeps spec.database.name")
+			container := sts.Spec.Template.Spec.Containers[0]
+			g.Expect(container.Image).To(HaveSuffix(tt.image))
+			g.Expect(container.Env).To(ContainElement(And(
+				HaveField("Name", tt.passwordEnv),
+				HaveField("ValueFrom.SecretKeyRef.Name", tt.secret),
+			)))
+			services := r.databaseServices(webService)
+			g.Expect(services).To(HaveLen(2))
+			g.Expect(services[0].Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+			g.Expect(services[0].Spec.Ports).To(ConsistOf(HaveField("Port", tt.headlessPort)))
+		})
+	}
+}
+
+func TestValidateDatabase(t *testing.T) {
+	two := int32(2)
+	tests := []struct {
+		name   string
+		engine webappsv1.DatabaseEngine
+		mutate func(*webappsv1.WebService)
+		err    string
+	}{
+		{name: "mysql replicas", engine: webappsv1.DatabaseEngineMySQL, mutate: func(ws *webappsv1.WebService) { ws.Spec.Database.Size = &two }},
+		{name: "mariadb replicas", engine: webappsv1.DatabaseEngineMariaDB, mutate: func(ws *webappsv1.WebService) { ws.Spec.Database.Size = &two }, err: "runs a single server"},
+		{name: "mariadb snapshot", engine: webappsv1.DatabaseEngineMariaDB, mutate: func(ws *webappsv1.WebService) {
+			ws.Spec.DeletionPolicy = webappsv1.DeletionPolicySnapshot
+		}},
+		{name: "postgresql snapshot", engine: webappsv1.DatabaseEnginePostgreSQL, mutate: func(ws *webappsv1.WebService) {
+			ws.Spec.DeletionPolicy = webappsv1.DeletionPolicySnapshot
+		}, err: "does not support the Snapshot deletion policy"},
+		{name: "postgresql SQL migrations", engine: webappsv1.DatabaseEnginePostgreSQL, mutate: func(ws *webappsv1.WebService) {
+			ws.Spec.Migrations = &webappsv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "shop-sql"}}
+		}, err: "does not support SQL migrations"},
+		{name: "postgresql single server", engine: webappsv1.DatabaseEnginePostgreSQL, mutate: func(ws *webappsv1.WebService) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webService := sampleWebService("shop")
+			webService.Spec.Database.Engine = tt.engine
+			tt.mutate(webService)
+
+			err := validateDatabase(webService)
+			if tt.err == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.err)))
+			}
+		})
+	}
+}
+
+func TestDatabasePodTemplate(t *testing.T) {
+	g := NewWithT(t)
+	webService := sampleWebService("shop")
+	webService.Spec.Database.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"spec":{"priorityClassName":"database"}}`)}
+	r := fakeReconciler(t)
+
+	g.Expect(validatePodTemplates(webService, r.Config.Get())).To(Succeed())
+	g.Expect(r.databaseStatefulSet(webService).Spec.Template.Spec.PriorityClassName).To(Equal("database"))
+
+	webService.Spec.Database.PodTemplate = &runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"mysql","image":"mariadb"}]}}`)}
+	g.Expect(validatePodTemplates(webService, r.Config.Get())).To(MatchError(HavePrefix("database: ")))
+	g.Expect(r.databaseStatefulSet(webService).Spec.Template.Spec.Containers[0].Image).To(HaveSuffix("mysql:5.7"))
+}
+
+func TestDatabaseProbes(t *testing.T) {
+	g := NewWithT(t)
+	webService := sampleWebService("shop")
+	webService.Spec.Database.Probes = &webappsv1.ProbesSpec{Startup: &corev1.Probe{FailureThreshold: 120}}
+	r := fakeReconciler(t)
+
+	container := r.databaseStatefulSet(webService).Spec.Template.Spec.Containers[0]
+	g.Expect(container.StartupProbe.Exec.Command).To(Equal(mysqlPing))
+	g.Expect(container.StartupProbe.FailureThreshold).To(BeEquivalentTo(120))
+	g.Expect(container.StartupProbe.PeriodSeconds).To(BeEquivalentTo(5))
+	g.Expect(container.LivenessProbe.Exec.Command).To(Equal(mysqlPing))
+	g.Expect(container.ReadinessProbe.FailureThreshold).To(BeEquivalentTo(3))
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// dbDumpDir is the mount path of the PVC in dbClientJob.
+	dbDumpDir     = "/dump"
+	dbDumpStorage = "1Gi"
+)
+
+// dbDumpStorageSize sizes a dump PVC after the data volume it dumps, a
+// dump does not outgrow the data directory. dbDumpStorage is only the
+// fallback when the database has no storage size.
+func dbDumpStorageSize(webService *webappsv1.WebService) resource.Quantity {
+	if db := webService.Spec.DatabaseSpec(); db != nil && db.Storage != nil && !db.Storage.Size.IsZero() {
+		return db.Storage.Size
+	}
+	return resource.MustParse(dbDumpStorage)
+}
+
+// dbDumpPVC is deliberately not owned by the WebService, dumps must
+// survive it.
+func (r *WebServiceReconciler) dbDumpPVC(webService *webappsv1.WebService, name, tier string) *corev1.PersistentVolumeClaim {
+	cfg := r.Config.Get()
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webService.Namespace,
+			Labels: cfg.WithLabels(makeLabels(webService, tier)), Annotations: cfg.WithAnnotations(nil)},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: dbDumpStorageSize(webService)},
+			},
+		},
+	}
+}
+
+// dbClientJob runs script in the database image as root against the
+// database Service, with the PVC claim mounted at dbDumpDir. env is added
+// to DB_HOST, DB_PORT and MYSQL_PWD and takes the place of those it names.
+func (r *WebServiceReconciler) dbClientJob(webService *webappsv1.WebService, name, tier, claim, script string, env ...corev1.EnvVar) *batchv1.Job {
+	cfg := r.Config.Get()
+	backoffLimit := int32(2)
+	// The dump claim is written as the database user, like the data
+	// directory of the server.
+	user := databaseUser
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webService.Namespace,
+			Labels: cfg.WithLabels(makeLabels(webService, tier)), Annotations: cfg.WithAnnotations(nil)},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: cfg.WithLabels(makeLabels(webService, tier))},
+				Spec: corev1.PodSpec{
+					RestartPolicy:    corev1.RestartPolicyNever,
+					SecurityContext:  resources.RestrictedPodSecurityContext(&user),
+					ImagePullSecrets: cfg.ImagePullSecrets,
+					Containers: []corev1.Container{
+						{
+							Name:    tier,
+							Image:   databaseProvider(webService).Image(webService, cfg),
+							Command: []string{"sh", "-c", script},
+							Env: []corev1.EnvVar{
+								{Name: "DB_HOST", Value: webService.Spec.DatabaseSpec().Name},
+								{Name: "DB_PORT", Value: fmt.Sprint(webService.Spec.DatabaseSpec().Ports[0].Port)},
+								dbSecretEnv(webService, "MYSQL_PWD", keyRootPassword),
+							},
+							VolumeMounts:    []corev1.VolumeMount{{Name: "dump", MountPath: dbDumpDir}},
+							SecurityContext: resources.RestrictedSecurityContext(),
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "dump",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, v := range env {
+		setEnvVar(&job.Spec.Template.Spec.Containers[0], v)
+	}
+	controllerutil.SetControllerReference(webService, job, r.Scheme)
+	return job
+}
+
+// setEnvVar sets v in c, replacing a variable of the same name.
+func setEnvVar(c *corev1.Container, v corev1.EnvVar) {
+	for i := range c.Env {
+		if c.Env[i].Name == v.Name {
+			c.Env[i] = v
+			return
+		}
+	}
+	c.Env = append(c.Env, v)
+}
+
+func isJobFailed(job *batchv1.Job) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
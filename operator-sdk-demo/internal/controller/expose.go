@@ -0,0 +1,182 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	reasonExposed               = "Exposed"
+	reasonGatewayAPIUnavailable = "GatewayAPIUnavailable"
+	reasonNoFrontendPort        = "NoFrontendPort"
+)
+
+// HasGatewayAPI looks up the HTTPRoute kind once at startup; the operator
+// has to be restarted to pick up a Gateway API installed later.
+func HasGatewayAPI(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(resources.HTTPRouteGVK.GroupKind(), resources.HTTPRouteGVK.Version)
+	return err == nil
+}
+
+// ensureExposure applies the Ingress and HTTPRoute of expose of component
+// c and deletes those that were removed from it.
+func (r *WebServiceReconciler) ensureExposure(ctx context.Context, webService *webappsv1.WebService, c resources.Component) error {
+	expose := c.Expose
+	routable := expose != nil && len(c.Ports) > 0
+	key := client.ObjectKey{Name: c.ObjectName, Namespace: webService.Namespace}
+
+	if routable && expose.Ingress != nil {
+		if err := r.apply(ctx, resources.NewComponentIngress(webService, r.Config.Get(), c)); err != nil {
+			log.Println("Component", c.Name, "ingress apply failure.")
+			return err
+		}
+	} else if err := r.deleteIfOwned(ctx, webService, key, &networkingv1.Ingress{}); err != nil {
+		return err
+	}
+
+	if !r.GatewayAPI {
+		return nil
+	}
+	if routable && expose.HTTPRoute != nil {
+		if err := r.apply(ctx, resources.NewComponentHTTPRoute(webService, r.Config.Get(), c)); err != nil {
+			log.Println("Component", c.Name, "httproute apply failure.")
+			return err
+		}
+		return nil
+	}
+	return r.deleteIfOwned(ctx, webService, key, resources.NewHTTPRouteObject())
+}
+
+// deleteIfOwned leaves objects of the same name created by someone else
+// alone.
+func (r *WebServiceReconciler) deleteIfOwned(ctx context.Context, webService *webappsv1.WebService, key client.ObjectKey, obj client.Object) error {
+	if err := r.Client.Get(ctx, key, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, webService) {
+		return nil
+	}
+	log.Println("Deleting", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), "no longer exposed.")
+	return client.IgnoreNotFound(r.Client.Delete(ctx, obj))
+}
+
+// exposedCondition returns nil when no component sets expose. Otherwise
+// it is the condition of the first component that could not be exposed as
+// asked, or of the first exposed one.
+func (r *WebServiceReconciler) exposedCondition(webService *webappsv1.WebService) *metav1.Condition {
+	var exposed *metav1.Condition
+	for _, c := range resources.Components(webService) {
+		cond := r.componentExposedCondition(webService, c)
+		if cond == nil {
+			continue
+		}
+		if exposed == nil || (exposed.Status == metav1.ConditionTrue && cond.Status == metav1.ConditionFalse) {
+			exposed = cond
+		}
+	}
+	return exposed
+}
+
+func (r *WebServiceReconciler) componentExposedCondition(webService *webappsv1.WebService, c resources.Component) *metav1.Condition {
+	expose := c.Expose
+	if expose == nil {
+		return nil
+	}
+	cond := &metav1.Condition{
+		Type:               webappsv1.ConditionExposed,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonExposed,
+		Message:            fmt.Sprintf("Service %s is of type %s", c.ObjectName, resources.ComponentServiceType(c)),
+		ObservedGeneration: webService.Generation,
+	}
+	if (expose.Ingress != nil || expose.HTTPRoute != nil) && len(c.Ports) == 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = reasonNoFrontendPort
+		cond.Message = fmt.Sprintf("ports of %s is empty, nothing to route to", c.Name)
+	} else if expose.HTTPRoute != nil && !r.GatewayAPI {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = reasonGatewayAPIUnavailable
+		cond.Message = "HTTPRoute is not served by the cluster, install the Gateway API and restart the operator"
+	}
+	return cond
+}
+
+// frontendURL is status.url, the first URL of a component. It is empty
+// when every component is only reachable inside the cluster or through
+// node ports.
+func (r *WebServiceReconciler) frontendURL(ctx context.Context, webService *webappsv1.WebService) (string, error) {
+	for _, c := range resources.Components(webService) {
+		url, err := r.componentURL(ctx, webService, c)
+		if err != nil || url != "" {
+			return url, err
+		}
+	}
+	return "", nil
+}
+
+func (r *WebServiceReconciler) componentURL(ctx context.Context, webService *webappsv1.WebService, c resources.Component) (string, error) {
+	expose := c.Expose
+	if expose == nil || len(c.Ports) == 0 {
+		return "", nil
+	}
+	if ingress := expose.Ingress; ingress != nil {
+		scheme := "http://"
+		if ingress.TLSSecretName != "" {
+			scheme = "https://"
+		}
+		return scheme + ingress.Host + resources.ExposePath(ingress.Path), nil
+	}
+	if route := expose.HTTPRoute; route != nil && r.GatewayAPI && len(route.Hostnames) > 0 {
+		return "http://" + route.Hostnames[0] + resources.ExposePath(route.Path), nil
+	}
+	if resources.ComponentServiceType(c) != corev1.ServiceTypeLoadBalancer {
+		return "", nil
+	}
+
+	svc := &corev1.Service{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: c.ObjectName, Namespace: webService.Namespace}, svc)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		host := ingress.Hostname
+		if host == "" {
+			host = ingress.IP
+		}
+		if host != "" {
+			return "http://" + net.JoinHostPort(host, strconv.Itoa(int(c.Ports[0].Port))), nil
+		}
+	}
+	return "", nil
+}
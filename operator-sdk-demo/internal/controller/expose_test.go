@@ -0,0 +1,150 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+func TestEnsureExposureIngress(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	webService.Spec.Frontend.Expose = &webappsv1.ExposeSpec{Ingress: &webappsv1.IngressSpec{Host: "shop.example.com"}}
+	r := fakeReconciler(t, webService)
+	c := resources.PrimaryComponent(webService)
+
+	g.Expect(r.ensureExposure(ctx, webService, c)).To(Succeed())
+	ingress := &networkingv1.Ingress{}
+	g.Expect(r.Client.Get(ctx, objectKey(webService, "nginx"), ingress)).To(Succeed())
+	g.Expect(metav1.IsControlledBy(ingress, webService)).To(BeTrue())
+
+	// Dropping expose.ingress deletes it.
+	c.Expose = &webappsv1.ExposeSpec{}
+	g.Expect(r.ensureExposure(ctx, webService, c)).To(Succeed())
+	g.Expect(errors.IsNotFound(r.Client.Get(ctx, objectKey(webService, "nginx"), ingress))).To(BeTrue())
+
+	// An Ingress of that name made by hand is left alone.
+	handMade := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: webService.Namespace}}
+	g.Expect(r.Client.Create(ctx, handMade)).To(Succeed())
+	g.Expect(r.ensureExposure(ctx, webService, c)).To(Succeed())
+	g.Expect(r.Client.Get(ctx, objectKey(webService, "nginx"), ingress)).To(Succeed())
+}
+
+func TestEnsureExposureNeedsAPort(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	webService.Spec.Frontend.Expose = &webappsv1.ExposeSpec{Ingress: &webappsv1.IngressSpec{Host: "shop.example.com"}}
+	webService.Spec.Frontend.Ports = nil
+	r := fakeReconciler(t, webService)
+
+	g.Expect(r.ensureExposure(ctx, webService, resources.PrimaryComponent(webService))).To(Succeed())
+	g.Expect(errors.IsNotFound(r.Client.Get(ctx, objectKey(webService, "nginx"), &networkingv1.Ingress{}))).To(BeTrue())
+}
+
+func TestExposedCondition(t *testing.T) {
+	route := &webappsv1.HTTPRouteSpec{ParentRefs: []webappsv1.GatewayParentRef{{Name: "public"}}}
+	tests := []struct {
+		name       string
+		expose     *webappsv1.ExposeSpec
+		noPorts    bool
+		gatewayAPI bool
+		status     metav1.ConditionStatus
+		reason     string
+	}{
+		{name: "load balancer", expose: &webappsv1.ExposeSpec{ServiceType: corev1.ServiceTypeLoadBalancer}, status: metav1.ConditionTrue, reason: reasonExposed},
+		{name: "route with gateway", expose: &webappsv1.ExposeSpec{HTTPRoute: route}, gatewayAPI: true, status: metav1.ConditionTrue, reason: reasonExposed},
+		{name: "route without gateway", expose: &webappsv1.ExposeSpec{HTTPRoute: route}, status: metav1.ConditionFalse, reason: reasonGatewayAPIUnavailable},
+		{name: "ingress without port", expose: &webappsv1.ExposeSpec{Ingress: &webappsv1.IngressSpec{Host: "shop.example.com"}},
+			noPorts: true, status: metav1.ConditionFalse, reason: reasonNoFrontendPort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webService := sampleWebService("shop")
+			webService.Spec.Frontend.Expose = tt.expose
+			if tt.noPorts {
+				webService.Spec.Frontend.Ports = nil
+			}
+			r := fakeReconciler(t)
+			r.GatewayAPI = tt.gatewayAPI
+
+			g.Expect(r.exposedCondition(webService)).To(And(
+				HaveField("Type", webappsv1.ConditionExposed),
+				HaveField("Status", tt.status),
+				HaveField("Reason", tt.reason),
+			))
+		})
+	}
+
+	t.Run("not exposed", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(fakeReconciler(t).exposedCondition(sampleWebService("shop"))).To(BeNil())
+	})
+}
+
+func TestFrontendURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		expose     *webappsv1.ExposeSpec
+		gatewayAPI bool
+		lb         []corev1.LoadBalancerIngress
+		url        string
+	}{
+		{name: "not exposed"},
+		{name: "node port", expose: &webappsv1.ExposeSpec{ServiceType: corev1.ServiceTypeNodePort}},
+		{name: "ingress with TLS",
+			expose: &webappsv1.ExposeSpec{Ingress: &webappsv1.IngressSpec{Host: "shop.example.com", Path: "/shop", TLSSecretName: "shop-tls"}},
+			url:    "https://shop.example.com/shop"},
+		{name: "route", gatewayAPI: true,
+			expose: &webappsv1.ExposeSpec{HTTPRoute: &webappsv1.HTTPRouteSpec{Hostnames: []string{"shop.example.com"}}},
+			url:    "http://shop.example.com/"},
+		{name: "route without gateway",
+			expose: &webappsv1.ExposeSpec{HTTPRoute: &webappsv1.HTTPRouteSpec{Hostnames: []string{"shop.example.com"}}}},
+		{name: "load balancer pending", expose: &webappsv1.ExposeSpec{ServiceType: corev1.ServiceTypeLoadBalancer}},
+		{name: "load balancer hostname", expose: &webappsv1.ExposeSpec{ServiceType: corev1.ServiceTypeLoadBalancer},
+			lb: []corev1.LoadBalancerIngress{{Hostname: "lb.example.com", IP: "192.0.2.10"}}, url: "http://lb.example.com:80"},
+		{name: "load balancer IPv6", expose: &webappsv1.ExposeSpec{ServiceType: corev1.ServiceTypeLoadBalancer},
+			lb: []corev1.LoadBalancerIngress{{IP: "2001:db8::1"}}, url: "http://[2001:db8::1]:80"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webService := sampleWebService("shop")
+			webService.Spec.Frontend.Expose = tt.expose
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: webService.Namespace},
+				Status:     corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: tt.lb}},
+			}
+			r := fakeReconciler(t, svc)
+			r.GatewayAPI = tt.gatewayAPI
+
+			g.Expect(r.frontendURL(context.Background(), webService)).To(Equal(tt.url))
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// Plain tests on the fake client, next to the envtest suite, for the parts
+// of the reconcile that do not need an API server.
+
+// fakeReconciler is a WebServiceReconciler with the default config whose
+// client starts out with objs.
+func fakeReconciler(t *testing.T, objs ...client.Object) *WebServiceReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(webappsv1.AddToScheme(scheme))
+	store, err := config.NewStore("", logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &WebServiceReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&webappsv1.WebService{}, &appsv1.Deployment{}, &appsv1.StatefulSet{}, &batchv1.Job{}).
+			WithInterceptorFuncs(interceptor.Funcs{Patch: fakeApply}).
+			Build(),
+		Scheme:   scheme,
+		Config:   store,
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+// fakeApply emulates server-side apply on the fake client, which rejects
+// apply patches: the object is created when missing and otherwise replaced
+// as a whole, status aside.
+func fakeApply(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	current := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), current); errors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(current.GetResourceVersion())
+	obj.SetUID(current.GetUID())
+	obj.SetCreationTimestamp(current.GetCreationTimestamp())
+	return c.Update(ctx, obj)
+}
+
+// sampleWebService is the WebService of config/samples: a frontend and a
+// MySQL database.
+func sampleWebService(name string) *webappsv1.WebService {
+	one := int32(1)
+	return &webappsv1.WebService{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid")},
+		Spec: webappsv1.WebServiceSpec{
+			Database: &webappsv1.WebServiceDbSpec{
+				Name:  "mysql",
+				Size:  &one,
+				Image: "mysql:5.7",
+				Ports: []corev1.ServicePort{{Port: 3306, TargetPort: intstr.FromInt32(3306)}},
+			},
+			Frontend: &webappsv1.WebServiceFrontendSpec{
+				Name:  "nginx",
+				Size:  &one,
+				Image: "nginx",
+				Ports: []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromInt32(80)}},
+			},
+		},
+	}
+}
+
+func objectKey(webService *webappsv1.WebService, name string) client.ObjectKey {
+	return client.ObjectKey{Name: name, Namespace: webService.Namespace}
+}
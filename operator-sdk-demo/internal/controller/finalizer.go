@@ -0,0 +1,250 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// teardownFinalizer keeps the WebService around until the frontend, the
+// optional snapshot and the database have been handled, in that order.
+const teardownFinalizer = "webapps.my.domain/teardown"
+
+const (
+	reasonDeletingFrontend     = "DeletingFrontend"
+	reasonSnapshottingDatabase = "SnapshottingDatabase"
+	reasonSnapshotFailed       = "SnapshotFailed"
+	reasonDeletingDatabase     = "DeletingDatabase"
+)
+
+// finalize advances the teardown by one step. Deleted children are waited
+// for; their delete events come back through the Owns() watches.
+func (r *WebServiceReconciler) finalize(ctx context.Context, webService *webappsv1.WebService) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(webService, teardownFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	deleted := true
+	for _, c := range resources.Components(webService) {
+		for _, slot := range []string{resources.SlotBlue, resources.SlotGreen} {
+			gone, err := r.deleteChildren(ctx, webService, c.SlotName(slot))
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			deleted = deleted && gone
+		}
+	}
+	if !deleted {
+		log.Println("Waiting for the components to be deleted.")
+		return ctrl.Result{}, r.setTerminating(ctx, webService, reasonDeletingFrontend, "Component Deployments and Services are being deleted")
+	}
+
+	policy := webService.Spec.DeletionPolicy
+	keepData := policy == webappsv1.DeletionPolicyRetain || policy == webappsv1.DeletionPolicySnapshot
+	// Snapshots need the mysql client tools, other engines keep the PVCs
+	// as with Retain.
+	if policy == webappsv1.DeletionPolicySnapshot && webService.Spec.DatabaseSpec() != nil && mysqlCompatible(webService) {
+		done, err := r.snapshotDatabase(ctx, webService)
+		if err != nil || !done {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if keepData {
+		if err := r.releaseDatabaseData(ctx, webService); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if err := r.deleteDatabaseData(ctx, webService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	deleted, err := r.deleteChildren(ctx, webService, databaseName(webService))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !deleted {
+		log.Println("Waiting for the database to be deleted.")
+		return ctrl.Result{}, r.setTerminating(ctx, webService, reasonDeletingDatabase, "Database StatefulSet and Services are being deleted")
+	}
+
+	log.Println("WebService teardown finished.")
+	controllerutil.RemoveFinalizer(webService, teardownFinalizer)
+	return ctrl.Result{}, r.Client.Update(ctx, webService)
+}
+
+// deleteChildren deletes the workload (Deployment or StatefulSet) and the
+// Services called name, and reports whether all of them are gone. Objects
+// the WebService does not control, left unadopted by spec.adoptionPolicy,
+// are not deleted and count as gone.
+func (r *WebServiceReconciler) deleteChildren(ctx context.Context, webService *webappsv1.WebService, name string) (bool, error) {
+	if name == "" {
+		return true, nil
+	}
+	deleted := true
+	for _, obj := range []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webService.Namespace}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webService.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webService.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: headlessName(name), Namespace: webService.Namespace}},
+	} {
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if !metav1.IsControlledBy(obj, webService) {
+			continue
+		}
+		deleted = false
+		if obj.GetDeletionTimestamp() == nil {
+			err := r.Client.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationForeground))
+			if client.IgnoreNotFound(err) != nil {
+				return false, err
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// deleteDatabaseData deletes the generated database Secret and the PVCs of
+// the database. A Secret of that name the WebService does not control is
+// kept.
+func (r *WebServiceReconciler) deleteDatabaseData(ctx context.Context, webService *webappsv1.WebService) error {
+	if !hasExistingDbSecret(webService) {
+		secret := &corev1.Secret{}
+		err := r.Client.Get(ctx, client.ObjectKey{Name: dbSecretName(webService), Namespace: webService.Namespace}, secret)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err == nil && metav1.IsControlledBy(secret, webService) {
+			if err := r.Client.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return r.Client.DeleteAllOf(ctx, &corev1.PersistentVolumeClaim{},
+		client.InNamespace(webService.Namespace), client.MatchingLabels(databaseLabels(webService)))
+}
+
+// releaseDatabaseData removes the owner reference to the WebService from
+// the database Secret and PVCs, garbage collection then leaves them alone.
+func (r *WebServiceReconciler) releaseDatabaseData(ctx context.Context, webService *webappsv1.WebService) error {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, pvcs, client.InNamespace(webService.Namespace), client.MatchingLabels(databaseLabels(webService))); err != nil {
+		return err
+	}
+	objs := []client.Object{}
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: dbSecretName(webService), Namespace: webService.Namespace}, secret); err == nil {
+		objs = append(objs, secret)
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	for i := range pvcs.Items {
+		objs = append(objs, &pvcs.Items[i])
+	}
+
+	for _, obj := range objs {
+		owners := obj.GetOwnerReferences()
+		kept := make([]metav1.OwnerReference, 0, len(owners))
+		for _, owner := range owners {
+			if owner.UID != webService.UID {
+				kept = append(kept, owner)
+			}
+		}
+		if len(kept) == len(owners) {
+			continue
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		obj.SetOwnerReferences(kept)
+		if err := r.Client.Patch(ctx, obj, patch); err != nil {
+			return err
+		}
+		log.Println("Released", obj.GetName(), "from the WebService.")
+	}
+	return nil
+}
+
+// snapshotDatabase runs mysqldump into the snapshot PVC and reports whether
+// it has finished. A failed Job blocks the teardown so no data is lost.
+func (r *WebServiceReconciler) snapshotDatabase(ctx context.Context, webService *webappsv1.WebService) (bool, error) {
+	name := webService.Name + "-mysql-snapshot"
+	if err := r.Client.Create(ctx, r.dbDumpPVC(webService, name, "snapshot")); err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	job := &batchv1.Job{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: webService.Namespace}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		file := fmt.Sprintf("%s-%d.sql", webService.Name, webService.DeletionTimestamp.Unix())
+		job = r.dbClientJob(webService, name, "snapshot", name,
+			`mysqldump -h "$DB_HOST" -P "$DB_PORT" -uroot --all-databases --single-transaction > "`+dbDumpDir+`/$SNAPSHOT_FILE"`,
+			corev1.EnvVar{Name: "SNAPSHOT_FILE", Value: file})
+		if err := r.Client.Create(ctx, job); err != nil {
+			return false, err
+		}
+		log.Println("Mysql snapshot job create success.")
+	}
+
+	if job.Status.Succeeded > 0 {
+		return true, nil
+	}
+	if isJobFailed(job) {
+		return false, r.setTerminating(ctx, webService, reasonSnapshotFailed,
+			fmt.Sprintf("Job %s failed, delete the Job to retry or change spec.deletionPolicy", name))
+	}
+	return false, r.setTerminating(ctx, webService, reasonSnapshottingDatabase,
+		fmt.Sprintf("Dumping the database into PVC %s", name))
+}
+
+func (r *WebServiceReconciler) setTerminating(ctx context.Context, webService *webappsv1.WebService, reason, message string) error {
+	patch := client.MergeFrom(webService.DeepCopy())
+	status := &webService.Status
+	status.Phase = webappsv1.PhaseTerminating
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               webappsv1.ConditionTerminating,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: webService.Generation,
+	})
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               webappsv1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             string(webappsv1.PhaseTerminating),
+		Message:            "WebService phase is " + string(webappsv1.PhaseTerminating),
+		ObservedGeneration: webService.Generation,
+	})
+	return r.Status().Patch(ctx, webService, patch)
+}
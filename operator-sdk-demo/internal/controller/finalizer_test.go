@@ -0,0 +1,163 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+// deletingWebService is sampleWebService after kubectl delete.
+func deletingWebService(policy webappsv1.DeletionPolicy) *webappsv1.WebService {
+	webService := sampleWebService("shop")
+	webService.Spec.DeletionPolicy = policy
+	webService.Finalizers = []string{teardownFinalizer}
+	now := metav1.Now()
+	webService.DeletionTimestamp = &now
+	return webService
+}
+
+// teardownFixture creates the frontend, the database and its data as
+// children of webService.
+type teardownFixture struct {
+	frontend, database []client.Object
+	data               []client.Object
+}
+
+func newTeardownFixture(t *testing.T, r *WebServiceReconciler, webService *webappsv1.WebService) teardownFixture {
+	t.Helper()
+	ns := webService.Namespace
+	f := teardownFixture{
+		frontend: []client.Object{
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: ns}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: ns}},
+		},
+		database: []client.Object{
+			&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: ns}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: ns}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: headlessName("mysql"), Namespace: ns}},
+		},
+		data: []client.Object{
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: dbSecretName(webService), Namespace: ns}},
+			&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-mysql-0", Namespace: ns, Labels: databaseLabels(webService)}},
+		},
+	}
+	for _, objs := range [][]client.Object{f.frontend, f.database, f.data} {
+		for _, obj := range objs {
+			if err := controllerutil.SetControllerReference(webService, obj, r.Scheme); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Client.Create(context.Background(), obj); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return f
+}
+
+func exists(g *WithT, r *WebServiceReconciler, obj client.Object) bool {
+	err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(obj), obj)
+	g.Expect(client.IgnoreNotFound(err)).To(Succeed())
+	return err == nil
+}
+
+func TestFinalizeTearsDownFrontendFirst(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := deletingWebService(webappsv1.DeletionPolicyDelete)
+	r := fakeReconciler(t, webService)
+	f := newTeardownFixture(t, r, webService)
+
+	_, err := r.finalize(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(meta.FindStatusCondition(webService.Status.Conditions, webappsv1.ConditionTerminating)).
+		To(HaveField("Reason", reasonDeletingFrontend))
+	for _, obj := range f.frontend {
+		g.Expect(exists(g, r, obj)).To(BeFalse(), "frontend %T %s", obj, obj.GetName())
+	}
+	for _, obj := range append(f.database, f.data...) {
+		g.Expect(exists(g, r, obj)).To(BeTrue(), "database %T %s", obj, obj.GetName())
+	}
+
+	_, err = r.finalize(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(meta.FindStatusCondition(webService.Status.Conditions, webappsv1.ConditionTerminating)).
+		To(HaveField("Reason", reasonDeletingDatabase))
+	for _, obj := range append(f.database, f.data...) {
+		g.Expect(exists(g, r, obj)).To(BeFalse(), "database %T %s", obj, obj.GetName())
+	}
+
+	_, err = r.finalize(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(exists(g, r, webService)).To(BeFalse())
+}
+
+func TestFinalizeSkipsUncontrolledObjects(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := deletingWebService(webappsv1.DeletionPolicyDelete)
+	// Objects of the child names that were there before the WebService and
+	// never got adopted.
+	frontend := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: webService.Namespace}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: dbSecretName(webService), Namespace: webService.Namespace}}
+	r := fakeReconciler(t, webService, frontend, secret)
+
+	for i := 0; i < 3 && exists(g, r, webService); i++ {
+		_, err := r.finalize(ctx, webService)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(exists(g, r, webService)).To(BeFalse())
+	g.Expect(exists(g, r, frontend)).To(BeTrue())
+	g.Expect(exists(g, r, secret)).To(BeTrue())
+}
+
+func TestFinalizeReleasesRetainedData(t *testing.T) {
+	for _, policy := range []webappsv1.DeletionPolicy{webappsv1.DeletionPolicyRetain, webappsv1.DeletionPolicySnapshot} {
+		t.Run(string(policy), func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			webService := deletingWebService(policy)
+			// Snapshots are taken of MySQL only.
+			webService.Spec.Database.Engine = webappsv1.DatabaseEnginePostgreSQL
+			r := fakeReconciler(t, webService)
+			f := newTeardownFixture(t, r, webService)
+
+			for i := 0; i < 3 && exists(g, r, webService); i++ {
+				_, err := r.finalize(ctx, webService)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(exists(g, r, webService)).To(BeFalse())
+			for _, obj := range f.database {
+				g.Expect(exists(g, r, obj)).To(BeFalse(), "%T %s", obj, obj.GetName())
+			}
+			for _, obj := range f.data {
+				g.Expect(exists(g, r, obj)).To(BeTrue(), "%T %s", obj, obj.GetName())
+				g.Expect(obj.GetOwnerReferences()).To(BeEmpty(), "%T %s", obj, obj.GetName())
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/graph"
+	"my.domain/demo/internal/resources"
+)
+
+// Step names of the reconcile graph built by reconcileGraph.
+const (
+	stepDBSecret  = "db-secret"
+	stepDBMove    = "db-move"
+	stepDBStorage = "db-storage"
+	stepDatabase  = "database"
+	stepDBRestore = "db-restore"
+	stepBinding   = "binding"
+	stepMigration = "migrations"
+)
+
+// componentStep is the step of the component named name.
+func componentStep(name string) string {
+	return "component/" + name
+}
+
+// reconcileGraph describes the children of webService and the order they
+// need: the database secret and StatefulSet, the dump restore, the binding
+// secret, the schema migrations and then every component once the
+// components it depends on run. Adding a child means adding a step here;
+// Reconcile only runs the graph. Rollouts in progress lower requeue to when
+// they have to be looked at again.
+func (r *WebServiceReconciler) reconcileGraph(webService *webappsv1.WebService, components []resources.Component, requeue *time.Duration) *graph.Graph {
+	sts := r.databaseStatefulSet(webService)
+
+	var g graph.Graph
+	g.Add(
+		graph.Node{
+			Name: stepDBSecret,
+			Apply: func(ctx context.Context) error {
+				if hasExistingDbSecret(webService) {
+					if err := r.validateDbSecret(ctx, webService); err != nil {
+						log.Println("Database secret is not usable:", err)
+						return err
+					}
+					return nil
+				}
+				secret, err := r.databaseSecret(ctx, webService)
+				if err != nil {
+					return err
+				}
+				return r.ensureDBSecret(webService, secret)
+			},
+		},
+		graph.Task(stepDBMove, "Moving the MySQL Deployment to a StatefulSet", func(ctx context.Context) (bool, error) {
+			return r.migrateDBDeployment(ctx, webService)
+		}, stepDBSecret),
+		graph.Task(stepDBStorage, "Expanding the database volumes", func(ctx context.Context) (bool, error) {
+			return r.expandDatabaseStorage(ctx, webService, sts)
+		}, stepDBMove),
+		graph.Node{
+			Name:      stepDatabase,
+			DependsOn: []string{stepDBStorage},
+			Apply: func(ctx context.Context) error {
+				if err := r.ensureDBStatefulSet(webService, sts); err != nil {
+					return err
+				}
+				for _, svc := range r.databaseServices(webService) {
+					if err := r.ensureDBService(webService, svc); err != nil {
+						return err
+					}
+				}
+				if err := r.ensureDBNetworkPolicy(ctx, webService); err != nil {
+					log.Println("Database networkpolicy apply failure.")
+					return err
+				}
+				return nil
+			},
+			// No polling, the ready replica update of the watched database
+			// StatefulSet requeues the WebService.
+			Ready: func(context.Context) (bool, string, error) {
+				return r.isDatabaseRunning(webService), "Database pod is not running yet", nil
+			},
+		},
+		graph.Task(stepDBRestore, "Restoring the MySQL Deployment dump", func(ctx context.Context) (bool, error) {
+			return r.restoreDBDump(ctx, webService)
+		}, stepDatabase),
+		graph.Node{
+			Name:      stepBinding,
+			DependsOn: []string{stepDBRestore},
+			Apply: func(ctx context.Context) error {
+				if err := r.ensureBindingSecret(ctx, webService); err != nil {
+					log.Println("Database binding secret apply failure.")
+					return err
+				}
+				return nil
+			},
+		},
+		// Until the migrations of the new primary image succeed the old
+		// Deployments keep serving; the finished Job requeues the WebService.
+		graph.Task(stepMigration, "Schema migration Job has not succeeded", func(ctx context.Context) (bool, error) {
+			return r.runMigrations(ctx, webService)
+		}, stepBinding),
+	)
+
+	needed := map[string]bool{}
+	for _, c := range components {
+		for _, dep := range c.DependsOn {
+			needed[dep] = true
+		}
+	}
+	for _, c := range components {
+		step := graph.Node{
+			Name:      componentStep(c.Name),
+			DependsOn: []string{stepMigration},
+			Apply: func(ctx context.Context) error {
+				after, err := r.ensureComponent(ctx, webService, c)
+				if after > 0 && (*requeue == 0 || after < *requeue) {
+					*requeue = after
+				}
+				return err
+			},
+		}
+		for _, dep := range c.DependsOn {
+			step.DependsOn = append(step.DependsOn, componentStep(dep))
+		}
+		if needed[c.Name] {
+			// Same as for the database: the watched Deployments requeue
+			// the WebService once their ready replicas change.
+			step.Ready = func(ctx context.Context) (bool, string, error) {
+				running, err := r.isComponentRunning(ctx, webService, c)
+				return running, "Component " + c.Name + " has unready replicas", err
+			}
+		}
+		g.Add(step)
+	}
+	return &g
+}
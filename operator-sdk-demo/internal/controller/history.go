@@ -0,0 +1,158 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rollbackAnnotation is the metadata form of spec.rollbackTo, its value is
+// the revision number to restore.
+const rollbackAnnotation = "webapps.my.domain/rollback-to"
+
+const (
+	reasonRolledBack      = "RolledBack"
+	reasonRollbackRefused = "RollbackRefused"
+)
+
+// specRevisions lists the spec revisions of webService, oldest first.
+func (r *WebServiceReconciler) specRevisions(ctx context.Context, webService *webappsv1.WebService) ([]appsv1.ControllerRevision, error) {
+	list := &appsv1.ControllerRevisionList{}
+	if err := r.Client.List(ctx, list, client.InNamespace(webService.Namespace),
+		client.MatchingLabels(resources.SpecHistorySelector(webService.Name))); err != nil {
+		return nil, err
+	}
+	var revs []appsv1.ControllerRevision
+	for _, rev := range list.Items {
+		if metav1.IsControlledBy(&rev, webService) {
+			revs = append(revs, rev)
+		}
+	}
+	resources.SortRevisions(revs)
+	return revs, nil
+}
+
+// recordSpecRevision keeps the spec the children were synced to as the
+// newest ControllerRevision and reports it in the status. When the same
+// spec was recorded before, as after a rollback, that revision is
+// renumbered instead. Revisions past spec.revisionHistoryLimit are
+// deleted, oldest first.
+func (r *WebServiceReconciler) recordSpecRevision(ctx context.Context, webService *webappsv1.WebService) error {
+	revs, err := r.specRevisions(ctx, webService)
+	if err != nil {
+		return err
+	}
+	next := int64(1)
+	if len(revs) > 0 {
+		next = revs[len(revs)-1].Revision + 1
+	}
+	desired, err := resources.NewSpecRevision(webService, next)
+	if err != nil {
+		return err
+	}
+
+	var recorded *appsv1.ControllerRevision
+	for i := range revs {
+		if revs[i].Name == desired.Name {
+			recorded = &revs[i]
+		}
+	}
+	switch {
+	case recorded == nil:
+		if err := r.Client.Create(ctx, desired); client.IgnoreAlreadyExists(err) != nil {
+			return err
+		}
+		revs = append(revs, *desired)
+		log.Println("Recorded spec revision", next, "of webService", webService.Name)
+	case recorded.Revision != next-1:
+		patch := client.MergeFrom(recorded.DeepCopy())
+		recorded.Revision = next
+		if err := r.Client.Patch(ctx, recorded, patch); err != nil {
+			return err
+		}
+		resources.SortRevisions(revs)
+		log.Println("Renumbered spec revision", desired.Name, "to", next, "for webService", webService.Name)
+	default:
+		next = recorded.Revision
+	}
+	webService.Status.Revision = next
+	webService.Status.CurrentRevision = desired.Name
+
+	limit := resources.DefaultRevisionHistoryLimit
+	if webService.Spec.RevisionHistoryLimit != nil {
+		limit = int(*webService.Spec.RevisionHistoryLimit)
+	}
+	for i := 0; i < len(revs)-limit; i++ {
+		if err := r.Client.Delete(ctx, &revs[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback restores the spec revision asked for by spec.rollbackTo or the
+// rollback annotation and clears the request. It returns true when
+// webService was written; the write triggers the next reconcile. An
+// unknown revision only clears the request and emits a warning event.
+func (r *WebServiceReconciler) rollback(ctx context.Context, webService *webappsv1.WebService) (bool, error) {
+	var number int64
+	value, annotated := webService.Annotations[rollbackAnnotation]
+	if webService.Spec.RollbackTo != nil {
+		number = *webService.Spec.RollbackTo
+		value = strconv.FormatInt(number, 10)
+	} else if annotated {
+		// A malformed value parses as 0, which matches no revision.
+		number, _ = strconv.ParseInt(value, 10, 64)
+	} else {
+		return false, nil
+	}
+	revs, err := r.specRevisions(ctx, webService)
+	if err != nil {
+		return false, err
+	}
+
+	patch := client.MergeFrom(webService.DeepCopy())
+	delete(webService.Annotations, rollbackAnnotation)
+	webService.Spec.RollbackTo = nil
+	rev := resources.FindRevision(revs, number)
+	if rev == nil {
+		r.Recorder.Eventf(webService, corev1.EventTypeWarning, reasonRollbackRefused,
+			"Cannot roll back to revision %q, it is not in the spec history", value)
+		return true, r.Client.Patch(ctx, webService, patch)
+	}
+	spec, err := resources.RevisionSpec(rev)
+	if err != nil {
+		return false, err
+	}
+	spec.RevisionHistoryLimit = webService.Spec.RevisionHistoryLimit
+	webService.Spec = *spec
+	if err := r.Client.Patch(ctx, webService, patch); err != nil {
+		return false, err
+	}
+	log.Println("webService", webService.Name, "rolled back to revision", number)
+	r.Recorder.Eventf(webService, corev1.EventTypeNormal, reasonRolledBack, "Rolled back to spec revision %d", number)
+	return true, nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+// specHistory returns the recorded revisions of webService, oldest first.
+func specHistory(t *testing.T, r *WebServiceReconciler, webService *webappsv1.WebService) []appsv1.ControllerRevision {
+	t.Helper()
+	revs, err := r.specRevisions(context.Background(), webService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revs
+}
+
+func TestRecordSpecRevision(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	r := fakeReconciler(t, webService)
+
+	g.Expect(r.recordSpecRevision(ctx, webService)).To(Succeed())
+	revs := specHistory(t, r, webService)
+	g.Expect(revs).To(HaveLen(1))
+	g.Expect(webService.Status.Revision).To(Equal(int64(1)))
+	g.Expect(webService.Status.CurrentRevision).To(Equal(revs[0].Name))
+
+	// An unchanged spec records nothing.
+	g.Expect(r.recordSpecRevision(ctx, webService)).To(Succeed())
+	g.Expect(specHistory(t, r, webService)).To(HaveLen(1))
+	g.Expect(webService.Status.Revision).To(Equal(int64(1)))
+
+	webService.Spec.Frontend.Image = "nginx:1.25"
+	g.Expect(r.recordSpecRevision(ctx, webService)).To(Succeed())
+	revs = specHistory(t, r, webService)
+	g.Expect(revs).To(HaveLen(2))
+	g.Expect(revs[1].Revision).To(Equal(int64(2)))
+	g.Expect(webService.Status.Revision).To(Equal(int64(2)))
+	g.Expect(webService.Status.CurrentRevision).To(Equal(revs[1].Name))
+	spec, err := resources.RevisionSpec(&revs[1])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(spec.Frontend.Image).To(Equal("nginx:1.25"))
+
+	// Going back to the first spec renumbers its revision.
+	webService.Spec.Frontend.Image = "nginx"
+	g.Expect(r.recordSpecRevision(ctx, webService)).To(Succeed())
+	revs = specHistory(t, r, webService)
+	g.Expect(revs).To(HaveLen(2))
+	g.Expect(revs[1].Revision).To(Equal(int64(3)))
+	g.Expect(webService.Status.CurrentRevision).To(Equal(revs[1].Name))
+}
+
+func TestSpecHistoryLimit(t *testing.T) {
+	two := int32(2)
+	tests := []struct {
+		name  string
+		limit *int32
+		kept  []int64
+	}{
+		{name: "default", kept: []int64{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+		{name: "revisionHistoryLimit", limit: &two, kept: []int64{11, 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			webService := sampleWebService("shop")
+			webService.Spec.RevisionHistoryLimit = tt.limit
+			r := fakeReconciler(t, webService)
+
+			for i := 1; i <= 12; i++ {
+				webService.Spec.Frontend.Ports[0].Port = int32(8000 + i)
+				g.Expect(r.recordSpecRevision(ctx, webService)).To(Succeed())
+			}
+			var kept []int64
+			for _, rev := range specHistory(t, r, webService) {
+				kept = append(kept, rev.Revision)
+			}
+			g.Expect(kept).To(Equal(tt.kept))
+			g.Expect(webService.Status.Revision).To(Equal(int64(12)))
+		})
+	}
+}
+
+func TestRollbackSpec(t *testing.T) {
+	first, missing := int64(1), int64(5)
+	tests := []struct {
+		name       string
+		rollbackTo *int64
+		annotation string
+		restored   bool
+		event      string
+	}{
+		{name: "rollbackTo", rollbackTo: &first, restored: true, event: "Normal RolledBack Rolled back to spec revision 1"},
+		{name: "annotation", annotation: "1", restored: true, event: "Normal RolledBack Rolled back to spec revision 1"},
+		{name: "unknown revision", rollbackTo: &missing, event: `Warning RollbackRefused Cannot roll back to revision "5", it is not in the spec history`},
+		{name: "malformed annotation", annotation: "latest", event: `Warning RollbackRefused Cannot roll back to revision "latest", it is not in the spec history`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			webService := sampleWebService("shop")
+			limit := int32(5)
+			webService.Spec.RevisionHistoryLimit = &limit
+			r := fakeReconciler(t, webService)
+			g.Expect(r.recordSpecRevision(ctx, webService)).To(Succeed())
+
+			g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(webService), webService)).To(Succeed())
+			webService.Spec.Frontend.Image = "nginx:1.25"
+			webService.Spec.RollbackTo = tt.rollbackTo
+			if tt.annotation != "" {
+				webService.Annotations = map[string]string{rollbackAnnotation: tt.annotation}
+			}
+			g.Expect(r.Client.Update(ctx, webService)).To(Succeed())
+
+			written, err := r.rollback(ctx, webService)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(written).To(BeTrue())
+
+			stored := &webappsv1.WebService{}
+			g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(webService), stored)).To(Succeed())
+			g.Expect(stored.Spec.RollbackTo).To(BeNil())
+			g.Expect(stored.Annotations).NotTo(HaveKey(rollbackAnnotation))
+			g.Expect(stored.Spec.RevisionHistoryLimit).To(HaveValue(Equal(limit)))
+			if tt.restored {
+				g.Expect(stored.Spec.Frontend.Image).To(Equal("nginx"))
+			} else {
+				g.Expect(stored.Spec.Frontend.Image).To(Equal("nginx:1.25"))
+			}
+			g.Expect(r.Recorder.(*record.FakeRecorder).Events).To(Receive(Equal(tt.event)))
+		})
+	}
+}
+
+func TestRollbackNotRequested(t *testing.T) {
+	g := NewWithT(t)
+	webService := sampleWebService("shop")
+	r := fakeReconciler(t, webService)
+
+	written, err := r.rollback(context.Background(), webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(written).To(BeFalse())
+}
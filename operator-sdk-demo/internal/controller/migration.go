@@ -0,0 +1,212 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WebServices created by older releases run MySQL as a Deployment with
+// the data in the container filesystem. The operator moves them to the
+// StatefulSet with a dump and restore:
+//
+//  1. the "<cr>-mysql-migrate-dump" Job writes the application databases
+//     to the "<cr>-mysql-migration" PVC, then the Deployment is deleted;
+//  2. the StatefulSet comes up and the "<cr>-mysql-migrate-restore" Job
+//     loads the dump. Deleting the dump Job afterwards completes the
+//     migration.
+//
+// The migration PVC is not owned and stays behind as a backup.
+
+const (
+	reasonDumpingLegacyDatabase = "DumpingLegacyDatabase"
+	reasonRemovingDeployment    = "RemovingDeployment"
+	reasonRestoringDatabase     = "RestoringDatabase"
+	reasonMigrationFailed       = "MigrationFailed"
+	reasonMigrated              = "Migrated"
+)
+
+const migrationDumpFile = dbDumpDir + "/legacy.sql"
+
+// migrationDumpScript leaves out the system schemas. They hold the users of
+// the Deployment, restoring them would overwrite the generated credentials
+// of the StatefulSet.
+const migrationDumpScript = `set -e
+dbs=$(mysql -h "$DB_HOST" -P "$DB_PORT" -uroot -N -e 'SHOW DATABASES' | grep -Ev '^(mysql|information_schema|performance_schema|sys)$' || true)
+if [ -z "$dbs" ]; then
+  : > ` + migrationDumpFile + `
+  exit 0
+fi
+mysqldump -h "$DB_HOST" -P "$DB_PORT" -uroot --databases $dbs --single-transaction --routines --events > ` + migrationDumpFile + `
+`
+
+func migrationPVCName(webService *webappsv1.WebService) string {
+	return webService.Name + "-mysql-migration"
+}
+
+func migrationDumpJobName(webService *webappsv1.WebService) string {
+	return webService.Name + "-mysql-migrate-dump"
+}
+
+// migrateDBDeployment dumps and deletes the legacy MySQL Deployment, which
+// has the same name as the StatefulSet. It returns true once there is no
+// Deployment left; other engines never ran as a Deployment.
+func (r *WebServiceReconciler) migrateDBDeployment(ctx context.Context, webService *webappsv1.WebService) (bool, error) {
+	if _, ok := databaseProvider(webService).(mysqlProvider); !ok {
+		return true, nil
+	}
+	deploy := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: webService.Spec.DatabaseSpec().Name, Namespace: webService.Namespace}, deploy)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if deploy.DeletionTimestamp != nil {
+		return false, nil
+	}
+
+	// A Deployment without a ready pod has no data to keep.
+	if deploy.Status.ReadyReplicas > 0 {
+		done, err := r.dumpDBDeployment(ctx, webService, deploy)
+		if err != nil || !done {
+			return false, err
+		}
+	}
+
+	log.Println("Legacy mysql deployment is deleted.")
+	if err := r.Client.Delete(ctx, deploy, client.PropagationPolicy(metav1.DeletePropagationForeground)); client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+	return false, r.setStorageMigrated(ctx, webService, metav1.ConditionFalse, reasonRemovingDeployment,
+		"Mysql Deployment is being deleted")
+}
+
+// legacyRootPassword returns MYSQL_PWD for dumping the legacy Deployment.
+// Older releases set MYSQL_ROOT_PASSWORD as a literal, later ones a
+// secretKeyRef to the generated Secret. The operator no longer updates the
+// Deployment, so it is taken from the Deployment as it is.
+func legacyRootPassword(deploy *appsv1.Deployment) corev1.EnvVar {
+	for _, c := range deploy.Spec.Template.Spec.Containers {
+		for _, env := range c.Env {
+			if env.Name == "MYSQL_ROOT_PASSWORD" {
+				return corev1.EnvVar{Name: "MYSQL_PWD", Value: env.Value, ValueFrom: env.ValueFrom}
+			}
+		}
+	}
+	return corev1.EnvVar{Name: "MYSQL_PWD"}
+}
+
+func (r *WebServiceReconciler) dumpDBDeployment(ctx context.Context, webService *webappsv1.WebService, deploy *appsv1.Deployment) (bool, error) {
+	name := migrationDumpJobName(webService)
+	claim := migrationPVCName(webService)
+	if err := r.Client.Create(ctx, r.dbDumpPVC(webService, claim, "migration")); err != nil && !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	job := &batchv1.Job{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: webService.Namespace}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		job = r.dbClientJob(webService, name, "migration", claim, migrationDumpScript, legacyRootPassword(deploy))
+		if err := r.Client.Create(ctx, job); err != nil {
+			return false, err
+		}
+		log.Println("Mysql migration dump job create success.")
+	}
+
+	if job.Status.Succeeded > 0 {
+		return true, nil
+	}
+	if isJobFailed(job) {
+		return false, r.setStorageMigrated(ctx, webService, metav1.ConditionFalse, reasonMigrationFailed,
+			fmt.Sprintf("Job %s failed, delete the Job to retry", name))
+	}
+	return false, r.setStorageMigrated(ctx, webService, metav1.ConditionFalse, reasonDumpingLegacyDatabase,
+		fmt.Sprintf("Dumping the Mysql Deployment into PVC %s", claim))
+}
+
+// restoreDBDump loads the migration dump into the running StatefulSet and
+// returns true when there is nothing (left) to restore.
+func (r *WebServiceReconciler) restoreDBDump(ctx context.Context, webService *webappsv1.WebService) (bool, error) {
+	dump := &batchv1.Job{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: migrationDumpJobName(webService), Namespace: webService.Namespace}, dump)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if dump.DeletionTimestamp != nil || dump.Status.Succeeded == 0 {
+		return true, nil
+	}
+
+	name := webService.Name + "-mysql-migrate-restore"
+	claim := migrationPVCName(webService)
+	job := &batchv1.Job{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: webService.Namespace}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		job = r.dbClientJob(webService, name, "migration", claim,
+			`mysql -h "$DB_HOST" -P "$DB_PORT" -uroot < `+migrationDumpFile)
+		if err := r.Client.Create(ctx, job); err != nil {
+			return false, err
+		}
+		log.Println("Mysql migration restore job create success.")
+	}
+
+	if job.Status.Succeeded > 0 {
+		// Without the dump Job the restore is never started again.
+		if err := r.Client.Delete(ctx, dump, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+		return true, r.setStorageMigrated(ctx, webService, metav1.ConditionTrue, reasonMigrated,
+			fmt.Sprintf("Mysql data restored, PVC %s is no longer needed", claim))
+	}
+	if isJobFailed(job) {
+		return false, r.setStorageMigrated(ctx, webService, metav1.ConditionFalse, reasonMigrationFailed,
+			fmt.Sprintf("Job %s failed, delete the Job to retry", name))
+	}
+	return false, r.setStorageMigrated(ctx, webService, metav1.ConditionFalse, reasonRestoringDatabase,
+		fmt.Sprintf("Restoring the dump from PVC %s", claim))
+}
+
+func (r *WebServiceReconciler) setStorageMigrated(ctx context.Context, webService *webappsv1.WebService, status metav1.ConditionStatus, reason, message string) error {
+	patch := client.MergeFrom(webService.DeepCopy())
+	meta.SetStatusCondition(&webService.Status.Conditions, metav1.Condition{
+		Type:               webappsv1.ConditionStorageMigrated,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: webService.Generation,
+	})
+	return r.Status().Patch(ctx, webService, patch)
+}
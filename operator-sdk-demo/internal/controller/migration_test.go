@@ -0,0 +1,143 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+// legacyMysqlDeployment is the MySQL Deployment the first release created,
+// with a ready pod.
+func legacyMysqlDeployment(r *WebServiceReconciler, webService *webappsv1.WebService) *appsv1.Deployment {
+	myLabels := makeLabels(webService, "mysql")
+	fromAuth := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "mysql-auth"}, Key: key}}
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: webService.Spec.DatabaseSpec().Name, Namespace: webService.Namespace},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: myLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: myLabels},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:  "mysql",
+					Image: "mysql:5.7",
+					Env: []corev1.EnvVar{
+						{Name: "MYSQL_ROOT_PASSWORD", Value: "password"},
+						{Name: "MYSQL_DATABASE", Value: "webservice"},
+						{Name: "username", ValueFrom: fromAuth("username")},
+						{Name: "password", ValueFrom: fromAuth("password")},
+					},
+				}}},
+			},
+		},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	controllerutil.SetControllerReference(webService, deploy, r.Scheme)
+	return deploy
+}
+
+func containerEnv(job *batchv1.Job, name string) []corev1.EnvVar {
+	var found []corev1.EnvVar
+	for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == name {
+			found = append(found, env)
+		}
+	}
+	return found
+}
+
+func TestMigrateLegacyMysqlDeployment(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	r := fakeReconciler(t, webService)
+	legacy := legacyMysqlDeployment(r, webService)
+	g.Expect(r.Create(ctx, legacy)).To(Succeed())
+
+	// The dump logs in with the password of the Deployment.
+	migrated, err := r.migrateDBDeployment(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(migrated).To(BeFalse())
+	dump := &batchv1.Job{}
+	g.Expect(r.Get(ctx, objectKey(webService, migrationDumpJobName(webService)), dump)).To(Succeed())
+	g.Expect(containerEnv(dump, "MYSQL_PWD")).To(Equal([]corev1.EnvVar{{Name: "MYSQL_PWD", Value: "password"}}))
+
+	dump.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, dump)).To(Succeed())
+	migrated, err = r.migrateDBDeployment(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(migrated).To(BeFalse())
+	g.Expect(errors.IsNotFound(r.Get(ctx, objectKey(webService, legacy.Name), &appsv1.Deployment{}))).To(BeTrue())
+	migrated, err = r.migrateDBDeployment(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(migrated).To(BeTrue())
+
+	// The restore logs in to the StatefulSet with the generated Secret.
+	restored, err := r.restoreDBDump(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(restored).To(BeFalse())
+	restore := &batchv1.Job{}
+	g.Expect(r.Get(ctx, objectKey(webService, "shop-mysql-migrate-restore"), restore)).To(Succeed())
+	g.Expect(containerEnv(restore, "MYSQL_PWD")).To(Equal([]corev1.EnvVar{dbSecretEnv(webService, "MYSQL_PWD", keyRootPassword)}))
+
+	restore.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, restore)).To(Succeed())
+	restored, err = r.restoreDBDump(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(restored).To(BeTrue())
+	g.Expect(r.Get(ctx, objectKey(webService, webService.Name), webService)).To(Succeed())
+	g.Expect(webService.Status.Conditions).To(ContainElement(And(
+		HaveField("Type", webappsv1.ConditionStorageMigrated),
+		HaveField("Status", metav1.ConditionTrue),
+	)))
+}
+
+func TestMigrateDeploymentWithGeneratedCredentials(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	r := fakeReconciler(t, webService)
+	legacy := legacyMysqlDeployment(r, webService)
+	rootPassword := dbSecretEnv(webService, "MYSQL_ROOT_PASSWORD", keyRootPassword)
+	legacy.Spec.Template.Spec.Containers[0].Env[0] = rootPassword
+	g.Expect(r.Create(ctx, legacy)).To(Succeed())
+
+	_, err := r.migrateDBDeployment(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	dump := &batchv1.Job{}
+	g.Expect(r.Get(ctx, objectKey(webService, migrationDumpJobName(webService)), dump)).To(Succeed())
+	g.Expect(containerEnv(dump, "MYSQL_PWD")).To(Equal([]corev1.EnvVar{{Name: "MYSQL_PWD", ValueFrom: rootPassword.ValueFrom}}))
+
+	// Only the application databases are dumped, the accounts stay those of
+	// the StatefulSet.
+	script := dump.Spec.Template.Spec.Containers[0].Command[2]
+	g.Expect(script).NotTo(ContainSubstring("--all-databases"))
+	g.Expect(script).To(ContainSubstring("grep -Ev '^(mysql|information_schema|performance_schema|sys)$'"))
+}
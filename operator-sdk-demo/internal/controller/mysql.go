@@ -0,0 +1,111 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+const mysqlDataPath = "/var/lib/mysql"
+
+// mysqlPing succeeds once the server takes TCP connections, the MariaDB
+// image ships mysqladmin as well. It does not need a password.
+var mysqlPing = []string{"mysqladmin", "ping", "-h", "127.0.0.1"}
+
+// mysqlProvider runs spec.database.size MySQL servers with the official
+// image.
+type mysqlProvider struct{}
+
+func (mysqlProvider) Component() string { return "mysql" }
+
+func (mysqlProvider) Image(webService *webappsv1.WebService, cfg *config.OperatorConfig) string {
+	return cfg.Image(config.ComponentMysql, webService.Spec.DatabaseSpec().Image)
+}
+
+func (p mysqlProvider) StatefulSet(webService *webappsv1.WebService, cfg *config.OperatorConfig) *appsv1.StatefulSet {
+	spec := webService.Spec.DatabaseSpec()
+	return newDatabaseStatefulSet(webService, cfg, spec.Size, corev1.Container{
+		Image:     p.Image(webService, cfg),
+		Resources: cfg.ResourcesFor(config.ComponentMysql, spec.Resources),
+		Env:       mysqlServerEnv(webService, cfg, "MYSQL_"),
+	}, mysqlDataPath, mysqlPing...)
+}
+
+func (mysqlProvider) Services(webService *webappsv1.WebService, cfg *config.OperatorConfig) []*corev1.Service {
+	return newDatabaseServices(webService, cfg)
+}
+
+func (mysqlProvider) SecretKeys() []string {
+	return []string{keyRootPassword, keyUsername, keyPassword}
+}
+
+func (mysqlProvider) Ready(sts *appsv1.StatefulSet) bool {
+	return sts.Status.ReadyReplicas > 0
+}
+
+func (mysqlProvider) Validate(webService *webappsv1.WebService) error { return nil }
+
+// mysqlServerEnv configures the MySQL and MariaDB images, which read the
+// same variables under their own prefix.
+func mysqlServerEnv(webService *webappsv1.WebService, cfg *config.OperatorConfig, prefix string) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		dbSecretEnv(webService, prefix+"ROOT_PASSWORD", keyRootPassword),
+		{Name: prefix + "DATABASE", Value: cfg.Mysql.Database},
+		dbSecretEnv(webService, prefix+"USER", keyUsername),
+		dbSecretEnv(webService, prefix+"PASSWORD", keyPassword),
+	}
+}
+
+// mariadbProvider runs a single MariaDB server. The mysql client tools of
+// the image serve the snapshot and SQL migration Jobs as for MySQL.
+type mariadbProvider struct {
+	mysqlProvider
+}
+
+func (mariadbProvider) Component() string { return "mariadb" }
+
+func (mariadbProvider) Image(webService *webappsv1.WebService, cfg *config.OperatorConfig) string {
+	return cfg.Image(config.ComponentMariadb, webService.Spec.DatabaseSpec().Image)
+}
+
+func (p mariadbProvider) StatefulSet(webService *webappsv1.WebService, cfg *config.OperatorConfig) *appsv1.StatefulSet {
+	replicas := int32(1)
+	return newDatabaseStatefulSet(webService, cfg, &replicas, corev1.Container{
+		Image:     p.Image(webService, cfg),
+		Resources: cfg.ResourcesFor(config.ComponentMariadb, webService.Spec.DatabaseSpec().Resources),
+		Env:       mysqlServerEnv(webService, cfg, "MARIADB_"),
+	}, mysqlDataPath, mysqlPing...)
+}
+
+func (mariadbProvider) Validate(webService *webappsv1.WebService) error {
+	return singleServer(webService)
+}
+
+// singleServer rejects a size above one for the engines without
+// replication.
+func singleServer(webService *webappsv1.WebService) error {
+	spec := webService.Spec.DatabaseSpec()
+	if spec.Size != nil && *spec.Size > 1 {
+		return fmt.Errorf("engine %s runs a single server, size %d is not supported", spec.Engine, *spec.Size)
+	}
+	return nil
+}
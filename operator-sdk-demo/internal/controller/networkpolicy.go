@@ -0,0 +1,77 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// databaseNetworkPolicy admits to the database port the pods of every
+// component and the pods labelled by makeLabels for webService whatever
+// their tier, which are the database pods and the client Jobs.
+func (r *WebServiceReconciler) databaseNetworkPolicy(webService *webappsv1.WebService) *networkingv1.NetworkPolicy {
+	cfg := r.Config.Get()
+	spec := webService.Spec.DatabaseSpec()
+	ownPods := makeLabels(webService, "")
+	delete(ownPods, "tier")
+	from := []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: ownPods}}}
+	for _, c := range resources.Components(webService) {
+		from = append(from, networkingv1.NetworkPolicyPeer{PodSelector: c.SlotPodSelector()})
+	}
+	port := intstr.FromInt32(spec.Ports[0].TargetPort.IntVal)
+
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: spec.Name, Namespace: webService.Namespace,
+			Labels: cfg.WithLabels(databaseLabels(webService)), Annotations: cfg.WithAnnotations(nil)},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: databaseLabels(webService)},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{Ports: []networkingv1.NetworkPolicyPort{{Port: &port}}, From: from},
+			},
+		},
+	}
+	controllerutil.SetControllerReference(webService, policy, r.Scheme)
+	return policy
+}
+
+// ensureDBNetworkPolicy applies the NetworkPolicy of the database, or
+// deletes it when spec.networkPolicy turns it off.
+func (r *WebServiceReconciler) ensureDBNetworkPolicy(ctx context.Context, webService *webappsv1.WebService) error {
+	if !webService.Spec.NetworkPolicyEnabled() {
+		key := client.ObjectKey{Name: webService.Spec.DatabaseSpec().Name, Namespace: webService.Namespace}
+		return r.deleteIfOwned(ctx, webService, key, &networkingv1.NetworkPolicy{})
+	}
+	return r.apply(ctx, r.databaseNetworkPolicy(webService))
+}
+
+// ensureComponentNetworkPolicy does the same for component c.
+func (r *WebServiceReconciler) ensureComponentNetworkPolicy(ctx context.Context, webService *webappsv1.WebService, c resources.Component) error {
+	if !webService.Spec.NetworkPolicyEnabled() {
+		key := client.ObjectKey{Name: c.ObjectName, Namespace: webService.Namespace}
+		return r.deleteIfOwned(ctx, webService, key, &networkingv1.NetworkPolicy{})
+	}
+	return r.apply(ctx, resources.NewComponentNetworkPolicy(webService, r.Config.Get(), c))
+}
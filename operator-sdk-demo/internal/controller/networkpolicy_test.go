@@ -0,0 +1,120 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+func TestNetworkPolicies(t *testing.T) {
+	one := int32(1)
+	disabled := false
+	tests := []struct {
+		name   string
+		mutate func(webService *webappsv1.WebService)
+		// ports are the ports each component accepts connections on, the
+		// database admits all of these components.
+		ports   map[string][]intstr.IntOrString
+		deleted bool
+	}{
+		{
+			name:   "default",
+			mutate: func(webService *webappsv1.WebService) {},
+			ports:  map[string][]intstr.IntOrString{"nginx": {intstr.FromInt32(80)}},
+		},
+		{
+			name: "components",
+			mutate: func(webService *webappsv1.WebService) {
+				webService.Spec.Components = []webappsv1.WebServiceFrontendSpec{
+					{Name: "api", Size: &one, Image: "api", Ports: []corev1.ServicePort{{Port: 8080, TargetPort: intstr.FromString("http")}}},
+					{Name: "worker", Size: &one, Image: "worker"},
+				}
+			},
+			ports: map[string][]intstr.IntOrString{
+				"nginx":       {intstr.FromInt32(80)},
+				"shop-api":    {intstr.FromString("http")},
+				"shop-worker": nil,
+			},
+		},
+		{
+			name: "disabled",
+			mutate: func(webService *webappsv1.WebService) {
+				webService.Spec.NetworkPolicy = &webappsv1.NetworkPolicySpec{Enabled: &disabled}
+			},
+			deleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			webService := sampleWebService("shop")
+			r := fakeReconciler(t, webService)
+			reconcile := func() {
+				g.Expect(r.ensureDBNetworkPolicy(ctx, webService)).To(Succeed())
+				for _, c := range resources.Components(webService) {
+					g.Expect(r.ensureComponentNetworkPolicy(ctx, webService, c)).To(Succeed())
+				}
+			}
+			// The policies of an earlier reconcile, which the current one
+			// updates or deletes.
+			reconcile()
+
+			tt.mutate(webService)
+			reconcile()
+			db := &networkingv1.NetworkPolicy{}
+			err := r.Client.Get(ctx, objectKey(webService, "mysql"), db)
+			if tt.deleted {
+				g.Expect(errors.IsNotFound(err)).To(BeTrue())
+				g.Expect(errors.IsNotFound(r.Client.Get(ctx, objectKey(webService, "nginx"), &networkingv1.NetworkPolicy{}))).To(BeTrue())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(db.Spec.PodSelector.MatchLabels).To(Equal(databaseLabels(webService)))
+			g.Expect(db.Spec.Ingress).To(HaveLen(1))
+			from := db.Spec.Ingress[0].From
+			g.Expect(from).To(HaveLen(1+len(tt.ports)), "the own pods and Jobs, and every component")
+
+			components := resources.Components(webService)
+			g.Expect(components).To(HaveLen(len(tt.ports)))
+			for _, c := range components {
+				g.Expect(from).To(ContainElement(HaveField("PodSelector", Equal(c.SlotPodSelector()))), c.ObjectName)
+				policy := &networkingv1.NetworkPolicy{}
+				g.Expect(r.Client.Get(ctx, objectKey(webService, c.ObjectName), policy)).To(Succeed())
+				g.Expect(policy.Spec.PodSelector).To(Equal(*c.SlotPodSelector()))
+				var ports []intstr.IntOrString
+				for _, rule := range policy.Spec.Ingress {
+					g.Expect(rule.From).To(BeEmpty(), "%s accepts every client on its ports", c.ObjectName)
+					for _, p := range rule.Ports {
+						ports = append(ports, *p.Port)
+					}
+				}
+				g.Expect(ports).To(Equal(tt.ports[c.ObjectName]), c.ObjectName)
+			}
+		})
+	}
+}
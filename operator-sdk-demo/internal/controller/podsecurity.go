@@ -0,0 +1,94 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+// databaseUser owns the data directory in the MySQL, MariaDB and
+// PostgreSQL images.
+const databaseUser int64 = 999
+
+// podSecurityEnforceLabel holds the Pod Security Standard admission
+// enforces in a namespace.
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// databaseSecurityContexts run the server as databaseUser, which also
+// group-owns the data volume, with the overrides of spec.database on top.
+func databaseSecurityContexts(webService *webappsv1.WebService) (*corev1.PodSecurityContext, *corev1.SecurityContext) {
+	user := databaseUser
+	spec := webService.Spec.DatabaseSpec()
+	return resources.MergeSecurityContext(resources.RestrictedPodSecurityContext(&user), spec.PodSecurityContext),
+		resources.MergeSecurityContext(resources.RestrictedSecurityContext(), spec.SecurityContext)
+}
+
+// podSecurityCondition compares the pod templates of webService with the
+// level the namespace enforces. The pods are rejected at admission and the
+// workloads never become ready when they break it, the condition says why.
+func (r *WebServiceReconciler) podSecurityCondition(ctx context.Context, webService *webappsv1.WebService) (metav1.Condition, error) {
+	cond := metav1.Condition{
+		Type:               webappsv1.ConditionPodSecurityCompliant,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: webService.Generation,
+	}
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: webService.Namespace}, namespace); err != nil {
+		return cond, err
+	}
+	level := namespace.Labels[podSecurityEnforceLabel]
+	if level != resources.PodSecurityBaseline && level != resources.PodSecurityRestricted {
+		cond.Reason = "NotEnforced"
+		cond.Message = "The namespace enforces neither the baseline nor the restricted level"
+		return cond, nil
+	}
+
+	cfg := r.Config.Get()
+	var violations []string
+	for _, c := range resources.Components(webService) {
+		spec := resources.NewComponentDeployment(webService, cfg, c).Spec.Template.Spec
+		for _, v := range resources.PodSecurityViolations(level, &spec) {
+			violations = append(violations, c.Name+": "+v)
+		}
+	}
+	if webService.Spec.DatabaseSpec() != nil {
+		spec := r.databaseStatefulSet(webService).Spec.Template.Spec
+		for _, v := range resources.PodSecurityViolations(level, &spec) {
+			violations = append(violations, "database: "+v)
+		}
+	}
+
+	if len(violations) == 0 {
+		cond.Reason = "Compliant"
+		cond.Message = fmt.Sprintf("The pods meet the %s level of the namespace", level)
+		return cond, nil
+	}
+	log.Println("WebService", webService.Name, "breaks the", level, "pod security level:", strings.Join(violations, "; "))
+	cond.Status = metav1.ConditionFalse
+	cond.Reason = "Violation"
+	cond.Message = fmt.Sprintf("The namespace enforces the %s level and will reject the pods: %s", level, strings.Join(violations, "; "))
+	return cond, nil
+}
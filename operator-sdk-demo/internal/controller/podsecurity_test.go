@@ -0,0 +1,92 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"my.domain/demo/internal/resources"
+)
+
+func TestDatabaseSecurityContexts(t *testing.T) {
+	g := NewWithT(t)
+	webService := sampleWebService("shop")
+	webService.Spec.Database.SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: new(bool)}
+
+	pod := fakeReconciler(t).databaseStatefulSet(webService).Spec.Template.Spec
+	g.Expect(pod.SecurityContext.RunAsUser).To(HaveValue(Equal(databaseUser)))
+	g.Expect(pod.SecurityContext.FSGroup).To(HaveValue(Equal(databaseUser)))
+	container := pod.Containers[0].SecurityContext
+	g.Expect(container.ReadOnlyRootFilesystem).To(HaveValue(BeFalse()))
+	g.Expect(container.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+	g.Expect(resources.PodSecurityViolations(resources.PodSecurityRestricted, &pod)).To(BeEmpty())
+}
+
+func TestPodSecurityCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    string
+		overlay  string
+		database bool
+		status   metav1.ConditionStatus
+		reason   string
+		message  string
+	}{
+		{name: "not enforced", status: metav1.ConditionTrue, reason: "NotEnforced"},
+		{name: "privileged", level: "privileged", status: metav1.ConditionTrue, reason: "NotEnforced"},
+		{name: "restricted", level: resources.PodSecurityRestricted, status: metav1.ConditionTrue, reason: "Compliant"},
+		{name: "frontend override", level: resources.PodSecurityRestricted,
+			overlay: `{"spec":{"containers":[{"name":"nginx","securityContext":{"allowPrivilegeEscalation":true}}]}}`,
+			status:  metav1.ConditionFalse, reason: "Violation",
+			message: "nginx: container nginx: allowPrivilegeEscalation must be false"},
+		{name: "database override", level: resources.PodSecurityBaseline, database: true,
+			overlay: `{"spec":{"volumes":[{"name":"logs","hostPath":{"path":"/var/log"}}]}}`,
+			status:  metav1.ConditionFalse, reason: "Violation",
+			message: "database: volume logs must not be a hostPath"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webService := sampleWebService("shop")
+			if tt.overlay != "" {
+				overlay := &runtime.RawExtension{Raw: []byte(tt.overlay)}
+				if tt.database {
+					webService.Spec.Database.PodTemplate = overlay
+				} else {
+					webService.Spec.Frontend.PodTemplate = overlay
+				}
+			}
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: webService.Namespace}}
+			if tt.level != "" {
+				namespace.Labels = map[string]string{podSecurityEnforceLabel: tt.level}
+			}
+			r := fakeReconciler(t, namespace)
+
+			cond, err := r.podSecurityCondition(context.Background(), webService)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(cond.Status).To(Equal(tt.status))
+			g.Expect(cond.Reason).To(Equal(tt.reason))
+			g.Expect(cond.Message).To(ContainSubstring(tt.message))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+const postgresqlDataPath = "/var/lib/postgresql/data"
+
+// postgresqlProvider runs a single PostgreSQL server with the official
+// image. The generated user owns the database, there is no root password.
+type postgresqlProvider struct{}
+
+func (postgresqlProvider) Component() string { return "postgresql" }
+
+func (postgresqlProvider) Image(webService *webappsv1.WebService, cfg *config.OperatorConfig) string {
+	return cfg.Image(config.ComponentPostgresql, webService.Spec.DatabaseSpec().Image)
+}
+
+func (p postgresqlProvider) StatefulSet(webService *webappsv1.WebService, cfg *config.OperatorConfig) *appsv1.StatefulSet {
+	spec := webService.Spec.DatabaseSpec()
+	port := strconv.Itoa(int(spec.Ports[0].TargetPort.IntVal))
+	replicas := int32(1)
+	return newDatabaseStatefulSet(webService, cfg, &replicas, corev1.Container{
+		Image:     p.Image(webService, cfg),
+		Args:      []string{"postgres", "-p", port},
+		Resources: cfg.ResourcesFor(config.ComponentPostgresql, spec.Resources),
+		Env: []corev1.EnvVar{
+			{Name: "POSTGRES_DB", Value: cfg.Mysql.Database},
+			dbSecretEnv(webService, "POSTGRES_USER", keyUsername),
+			dbSecretEnv(webService, "POSTGRES_PASSWORD", keyPassword),
+			// initdb wants an empty directory, the root of a fresh volume
+			// may hold lost+found.
+			{Name: "PGDATA", Value: postgresqlDataPath + "/pgdata"},
+		},
+	}, postgresqlDataPath,
+		"sh", "-c", `pg_isready -h 127.0.0.1 -p `+port+` -U "$POSTGRES_USER" -d "$POSTGRES_DB"`)
+}
+
+func (postgresqlProvider) Services(webService *webappsv1.WebService, cfg *config.OperatorConfig) []*corev1.Service {
+	return newDatabaseServices(webService, cfg)
+}
+
+func (postgresqlProvider) SecretKeys() []string {
+	return []string{keyUsername, keyPassword}
+}
+
+func (postgresqlProvider) Ready(sts *appsv1.StatefulSet) bool {
+	return sts.Status.ReadyReplicas > 0
+}
+
+func (postgresqlProvider) Validate(webService *webappsv1.WebService) error {
+	return singleServer(webService)
+}
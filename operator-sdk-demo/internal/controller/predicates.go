@@ -0,0 +1,123 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// deploymentChanged passes spec changes and ready replica changes. The
+// latter is what moves the WebService past the database readiness gate.
+var deploymentChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldDeploy, okOld := e.ObjectOld.(*appsv1.Deployment)
+		newDeploy, okNew := e.ObjectNew.(*appsv1.Deployment)
+		if !okOld || !okNew {
+			return true
+		}
+		return oldDeploy.Generation != newDeploy.Generation ||
+			oldDeploy.Status.ReadyReplicas != newDeploy.Status.ReadyReplicas ||
+			appliedMetadataChanged(oldDeploy, newDeploy)
+	},
+}
+
+// statefulSetChanged is deploymentChanged for the database StatefulSet.
+var statefulSetChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSts, okOld := e.ObjectOld.(*appsv1.StatefulSet)
+		newSts, okNew := e.ObjectNew.(*appsv1.StatefulSet)
+		if !okOld || !okNew {
+			return true
+		}
+		return oldSts.Generation != newSts.Generation ||
+			oldSts.Status.ReadyReplicas != newSts.Status.ReadyReplicas ||
+			appliedMetadataChanged(oldSts, newSts)
+	},
+}
+
+// serviceChanged ignores Service status updates other than a new load
+// balancer address, which is reported as status.url. Services carry no
+// generation, so the spec is compared instead.
+var serviceChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSvc, okOld := e.ObjectOld.(*corev1.Service)
+		newSvc, okNew := e.ObjectNew.(*corev1.Service)
+		if !okOld || !okNew {
+			return true
+		}
+		return !equality.Semantic.DeepEqual(oldSvc.Spec, newSvc.Spec) ||
+			!equality.Semantic.DeepEqual(oldSvc.Status.LoadBalancer, newSvc.Status.LoadBalancer) ||
+			appliedMetadataChanged(oldSvc, newSvc)
+	},
+}
+
+// secretChanged passes changes of the secret content.
+var secretChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSecret, okOld := e.ObjectOld.(*corev1.Secret)
+		newSecret, okNew := e.ObjectNew.(*corev1.Secret)
+		if !okOld || !okNew {
+			return true
+		}
+		return oldSecret.Type != newSecret.Type ||
+			!equality.Semantic.DeepEqual(oldSecret.Data, newSecret.Data) ||
+			appliedMetadataChanged(oldSecret, newSecret)
+	},
+}
+
+// configMapChanged passes the ConfigMap edits that change a config
+// checksum or the migrations.
+var configMapChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldConfigMap, okOld := e.ObjectOld.(*corev1.ConfigMap)
+		newConfigMap, okNew := e.ObjectNew.(*corev1.ConfigMap)
+		if !okOld || !okNew {
+			return true
+		}
+		return !equality.Semantic.DeepEqual(oldConfigMap.Data, newConfigMap.Data) ||
+			!equality.Semantic.DeepEqual(oldConfigMap.BinaryData, newConfigMap.BinaryData) ||
+			oldConfigMap.Annotations[resources.HotReloadAnnotation] != newConfigMap.Annotations[resources.HotReloadAnnotation]
+	},
+}
+
+// appliedMetadataChanged compares the metadata fields the operator applies,
+// resourceVersion and managedFields bumps alone are ignored.
+func appliedMetadataChanged(oldObj, newObj client.Object) bool {
+	return !equality.Semantic.DeepEqual(oldObj.GetLabels(), newObj.GetLabels()) ||
+		!equality.Semantic.DeepEqual(oldObj.GetAnnotations(), newObj.GetAnnotations()) ||
+		!equality.Semantic.DeepEqual(oldObj.GetOwnerReferences(), newObj.GetOwnerReferences())
+}
+
+// jobFinished passes the transitions of the snapshot Job the teardown
+// waits for.
+var jobFinished = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldJob, okOld := e.ObjectOld.(*batchv1.Job)
+		newJob, okNew := e.ObjectNew.(*batchv1.Job)
+		if !okOld || !okNew {
+			return true
+		}
+		return oldJob.Status.Succeeded != newJob.Status.Succeeded || isJobFailed(oldJob) != isJobFailed(newJob)
+	},
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"my.domain/demo/internal/resources"
+)
+
+func TestOwnedPredicates(t *testing.T) {
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "mysql", Generation: 2}}
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Generation: 1}}
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "nginx"}, Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "mysql-credentials"}, Data: map[string][]byte{"user": []byte("app")}}
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "nginx-conf"}, Data: map[string]string{"nginx.conf": ""}}
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "mysql-snapshot"}}
+
+	tests := []struct {
+		name      string
+		predicate predicate.Funcs
+		old       client.Object
+		update    func(client.Object)
+		want      bool
+	}{
+		{"database ready", statefulSetChanged, sts, func(o client.Object) { o.(*appsv1.StatefulSet).Status.ReadyReplicas = 1 }, true},
+		{"database status heartbeat", statefulSetChanged, sts, func(o client.Object) { o.(*appsv1.StatefulSet).Status.ObservedGeneration = 2 }, false},
+		{"frontend spec", deploymentChanged, deploy, func(o client.Object) { o.SetGeneration(2) }, true},
+		{"frontend resync", deploymentChanged, deploy, func(o client.Object) { o.SetResourceVersion("9") }, false},
+		{"frontend owner", deploymentChanged, deploy, func(o client.Object) {
+			o.SetOwnerReferences([]metav1.OwnerReference{{Name: "shop", UID: "shop-uid"}})
+		}, true},
+		{"load balancer address", serviceChanged, svc, func(o client.Object) {
+			o.(*corev1.Service).Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "shop.example.com"}}
+		}, true},
+		{"service condition", serviceChanged, svc, func(o client.Object) {
+			o.(*corev1.Service).Status.Conditions = []metav1.Condition{{Type: "Ready"}}
+		}, false},
+		{"secret rotated", secretChanged, secret, func(o client.Object) { o.(*corev1.Secret).Data["user"] = []byte("shop") }, true},
+		{"secret managed fields", secretChanged, secret, func(o client.Object) {
+			o.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+		}, false},
+		{"config edited", configMapChanged, configMap, func(o client.Object) { o.(*corev1.ConfigMap).Data["nginx.conf"] = "worker_processes 2;" }, true},
+		{"config hot reload", configMapChanged, configMap, func(o client.Object) {
+			o.SetAnnotations(map[string]string{resources.HotReloadAnnotation: "true"})
+		}, true},
+		{"config labeled", configMapChanged, configMap, func(o client.Object) { o.SetLabels(map[string]string{"team": "shop"}) }, false},
+		{"snapshot done", jobFinished, job, func(o client.Object) { o.(*batchv1.Job).Status.Succeeded = 1 }, true},
+		{"snapshot pod started", jobFinished, job, func(o client.Object) { o.(*batchv1.Job).Status.Active = 1 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			updated := tt.old.DeepCopyObject().(client.Object)
+			tt.update(updated)
+			g.Expect(tt.predicate.Update(event.UpdateEvent{ObjectOld: tt.old, ObjectNew: updated})).To(Equal(tt.want))
+		})
+	}
+}
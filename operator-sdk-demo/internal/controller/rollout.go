@@ -0,0 +1,386 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// promoteAnnotation and abortAnnotation are set on the WebService to
+	// the name of the component whose rollout is promoted or aborted. The
+	// operator removes them once they are acted upon.
+	promoteAnnotation = "webapps.my.domain/promote"
+	abortAnnotation   = "webapps.my.domain/abort"
+
+	// rolloutPollInterval is how often a running rollout is looked at for
+	// its deadline and the restarts of its pods, which raise no event.
+	rolloutPollInterval = 15 * time.Second
+)
+
+const (
+	reasonRolloutStarted  = "RolloutStarted"
+	reasonRolloutPromoted = "RolloutPromoted"
+	reasonRolloutAborted  = "RolloutAborted"
+)
+
+// findRollout returns the rollout status of component, nil if there is
+// none.
+func findRollout(webService *webappsv1.WebService, component string) *webappsv1.RolloutStatus {
+	for i := range webService.Status.Rollouts {
+		if webService.Status.Rollouts[i].Component == component {
+			return &webService.Status.Rollouts[i]
+		}
+	}
+	return nil
+}
+
+// activeSlot is the slot of c whose Deployment serves the stable template.
+func activeSlot(webService *webappsv1.WebService, c resources.Component) string {
+	if status := findRollout(webService, c.Name); status != nil && status.ActiveSlot != "" {
+		return status.ActiveSlot
+	}
+	return resources.SlotBlue
+}
+
+// serviceSelector selects the pods of the active slot, or of both slots
+// while a Canary rollout runs.
+func serviceSelector(webService *webappsv1.WebService, c resources.Component) map[string]string {
+	status := findRollout(webService, c.Name)
+	if status != nil && resources.RolloutStrategy(c) == webappsv1.RolloutCanary &&
+		(status.Phase == webappsv1.RolloutProgressing || status.Phase == webappsv1.RolloutPaused) {
+		return c.ServingPodLabels(webService)
+	}
+	return c.SlotPodLabels(activeSlot(webService, c))
+}
+
+// rolloutComponent applies the Deployments of c. The active slot is
+// updated in place when the strategy is Rolling or its template did not
+// change; otherwise the new template is rolled out in the other slot by
+// progressRollout. It returns after how long the rollout has to be looked
+// at again, zero when Deployment events are enough.
+func (r *WebServiceReconciler) rolloutComponent(ctx context.Context, webService *webappsv1.WebService, c resources.Component) (time.Duration, error) {
+	original := webService.DeepCopy()
+	if findRollout(webService, c.Name) == nil {
+		slot, err := r.detectActiveSlot(ctx, webService, c)
+		if err != nil {
+			return 0, err
+		}
+		webService.Status.Rollouts = append(webService.Status.Rollouts, webappsv1.RolloutStatus{Component: c.Name, ActiveSlot: slot})
+	}
+	status := findRollout(webService, c.Name)
+
+	active := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: c.SlotName(status.ActiveSlot), Namespace: webService.Namespace}, active)
+	if errors.IsNotFound(err) {
+		active = nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	revision := resources.Revision(webService, r.Config.Get(), c)
+	strategy := resources.RolloutStrategy(c)
+	var requeue time.Duration
+	if active == nil || strategy == webappsv1.RolloutRolling || active.Annotations[resources.RevisionAnnotation] == revision {
+		err = r.settleRollout(ctx, webService, c, status, revision)
+	} else {
+		requeue, err = r.progressRollout(ctx, webService, c, status, active, revision)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if strategy == webappsv1.RolloutRolling && status.ActiveSlot == resources.SlotBlue {
+		// Nothing to track for a plain Deployment.
+		pruneRollouts(webService, func(s webappsv1.RolloutStatus) bool { return s.Component != c.Name })
+	}
+	if equality.Semantic.DeepEqual(original.Status.Rollouts, webService.Status.Rollouts) {
+		return requeue, nil
+	}
+	return requeue, r.Status().Patch(ctx, webService, client.MergeFrom(original))
+}
+
+// detectActiveSlot picks the active slot of a component without rollout
+// status: green when only the green Deployment exists, else blue.
+func (r *WebServiceReconciler) detectActiveSlot(ctx context.Context, webService *webappsv1.WebService, c resources.Component) (string, error) {
+	for _, slot := range []string{resources.SlotBlue, resources.SlotGreen} {
+		deploy := &appsv1.Deployment{}
+		err := r.Client.Get(ctx, client.ObjectKey{Name: c.SlotName(slot), Namespace: webService.Namespace}, deploy)
+		if err == nil && metav1.IsControlledBy(deploy, webService) {
+			return slot, nil
+		}
+		if client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+	}
+	return resources.SlotBlue, nil
+}
+
+// settleRollout runs revision with all replicas in the active slot. The
+// other slot is removed by pruneInactiveSlot once the Service no longer
+// selects it.
+func (r *WebServiceReconciler) settleRollout(ctx context.Context, webService *webappsv1.WebService, c resources.Component, status *webappsv1.RolloutStatus, revision string) error {
+	deploy := resources.NewComponentSlotDeployment(webService, r.Config.Get(), c, status.ActiveSlot, c.Size)
+	if err := r.adoptAndApply(ctx, webService, deploy); err != nil {
+		return err
+	}
+	status.Phase = webappsv1.RolloutStable
+	status.StableRevision = revision
+	status.Revision = ""
+	status.Step, status.Weight = 0, 0
+	status.StepStartTime = nil
+	status.Message = ""
+	return nil
+}
+
+// progressRollout moves the rollout of revision in the inactive slot one
+// step further. The active slot keeps its pod template throughout.
+func (r *WebServiceReconciler) progressRollout(ctx context.Context, webService *webappsv1.WebService, c resources.Component, status *webappsv1.RolloutStatus, active *appsv1.Deployment, revision string) (time.Duration, error) {
+	promote, abort, err := r.takeRolloutAnnotations(ctx, webService, c)
+	if err != nil {
+		return 0, err
+	}
+	now := metav1.Now()
+	strategy := resources.RolloutStrategy(c)
+
+	if status.Revision != revision {
+		// A new template, also one changed during a rollout, starts over.
+		status.Revision = revision
+		status.StableRevision = active.Annotations[resources.RevisionAnnotation]
+		status.Phase = webappsv1.RolloutProgressing
+		status.Step, status.StepStartTime, status.Message = 0, &now, ""
+		log.Println("Rolling out revision", revision, "of component", c.Name, "with", strategy)
+		r.Recorder.Eventf(webService, corev1.EventTypeNormal, reasonRolloutStarted, "%s rollout of revision %s of %s started", strategy, revision, c.Name)
+	}
+	if status.Phase == webappsv1.RolloutAborted {
+		if !promote {
+			return 0, r.holdRollout(ctx, webService, c, status, active)
+		}
+		// Promoting an aborted rollout retries it.
+		status.Phase = webappsv1.RolloutProgressing
+		status.Step, status.StepStartTime, status.Message = 0, &now, ""
+		promote = false
+	}
+	if abort {
+		return 0, r.abortRollout(ctx, webService, c, status, active, "aborted by the "+abortAnnotation+" annotation")
+	}
+
+	size := *c.Size
+	replicas := size
+	status.Weight = 0
+	if strategy == webappsv1.RolloutCanary {
+		// The steps may have been shortened meanwhile.
+		status.Step = min(status.Step, int32(len(c.Rollout.Steps)-1))
+		step := c.Rollout.Steps[status.Step]
+		replicas = resources.CanaryReplicas(size, step.Weight)
+		status.Weight = step.Weight
+	}
+	stableReplicas := size
+	if strategy == webappsv1.RolloutCanary {
+		stableReplicas = resources.StableReplicas(size, replicas)
+	}
+	// BlueGreen keeps all replicas in the stable slot until the switch.
+	if err := r.applyStableSlot(ctx, webService, c, status, active, stableReplicas); err != nil {
+		return 0, err
+	}
+	candidate := resources.NewComponentSlotDeployment(webService, r.Config.Get(), c, resources.OtherSlot(status.ActiveSlot), &replicas)
+	if err := r.adoptAndApply(ctx, webService, candidate); err != nil {
+		return 0, err
+	}
+
+	ready := candidate.Status.ObservedGeneration >= candidate.Generation &&
+		candidate.Status.UpdatedReplicas >= replicas && candidate.Status.ReadyReplicas >= replicas
+	breach, err := r.rolloutBreach(ctx, webService, c, status, ready, now)
+	if err != nil {
+		return 0, err
+	}
+	if breach != "" {
+		return 0, r.abortRollout(ctx, webService, c, status, active, breach)
+	}
+	if !ready {
+		status.Phase = webappsv1.RolloutProgressing
+		status.Message = fmt.Sprintf("%d of %d pods of revision %s are ready", candidate.Status.ReadyReplicas, replicas, revision)
+		return rolloutPollInterval, nil
+	}
+
+	if strategy == webappsv1.RolloutBlueGreen {
+		if promote || c.Rollout.AutoPromote == nil || *c.Rollout.AutoPromote {
+			return 0, r.promoteRollout(ctx, webService, c, status, revision)
+		}
+		status.Phase = webappsv1.RolloutPaused
+		status.Message = fmt.Sprintf("Revision %s is ready, waiting for the %s annotation", revision, promoteAnnotation)
+		return rolloutPollInterval, nil
+	}
+
+	step := c.Rollout.Steps[status.Step]
+	elapsed := now.Sub(status.StepStartTime.Time)
+	switch {
+	case promote || (step.Pause != nil && elapsed >= step.Pause.Duration):
+		status.Step++
+		if int(status.Step) == len(c.Rollout.Steps) {
+			return 0, r.promoteRollout(ctx, webService, c, status, revision)
+		}
+		status.StepStartTime = &now
+		status.Phase = webappsv1.RolloutProgressing
+		status.Message = fmt.Sprintf("Moving to step %d", status.Step+1)
+		// The status patch requeues the WebService for the next step.
+		return rolloutPollInterval, nil
+	case step.Pause != nil:
+		status.Phase = webappsv1.RolloutPaused
+		status.Message = fmt.Sprintf("Step %d at %d%% until %s", status.Step+1, step.Weight,
+			status.StepStartTime.Add(step.Pause.Duration).UTC().Format(time.RFC3339))
+		return min(step.Pause.Duration-elapsed, rolloutPollInterval), nil
+	default:
+		status.Phase = webappsv1.RolloutPaused
+		status.Message = fmt.Sprintf("Step %d at %d%%, waiting for the %s annotation", status.Step+1, step.Weight, promoteAnnotation)
+		return rolloutPollInterval, nil
+	}
+}
+
+// applyStableSlot scales the active slot to replicas, with the pod
+// template it already runs.
+func (r *WebServiceReconciler) applyStableSlot(ctx context.Context, webService *webappsv1.WebService, c resources.Component, status *webappsv1.RolloutStatus, active *appsv1.Deployment, replicas int32) error {
+	deploy := resources.NewComponentSlotDeployment(webService, r.Config.Get(), c, status.ActiveSlot, &replicas)
+	deploy.Spec.Template = *active.Spec.Template.DeepCopy()
+	deploy.Annotations[resources.RevisionAnnotation] = active.Annotations[resources.RevisionAnnotation]
+	return r.adoptAndApply(ctx, webService, deploy)
+}
+
+// holdRollout keeps an aborted rollout down: all replicas in the active
+// slot, none in the other one.
+func (r *WebServiceReconciler) holdRollout(ctx context.Context, webService *webappsv1.WebService, c resources.Component, status *webappsv1.RolloutStatus, active *appsv1.Deployment) error {
+	if err := r.applyStableSlot(ctx, webService, c, status, active, *c.Size); err != nil {
+		return err
+	}
+	var none int32
+	candidate := resources.NewComponentSlotDeployment(webService, r.Config.Get(), c, resources.OtherSlot(status.ActiveSlot), &none)
+	status.Weight = 0
+	return r.adoptAndApply(ctx, webService, candidate)
+}
+
+func (r *WebServiceReconciler) abortRollout(ctx context.Context, webService *webappsv1.WebService, c resources.Component, status *webappsv1.RolloutStatus, active *appsv1.Deployment, reason string) error {
+	status.Phase = webappsv1.RolloutAborted
+	status.Message = fmt.Sprintf("Revision %s %s", status.Revision, reason)
+	log.Println("Rollout of component", c.Name, "aborted:", status.Message)
+	r.Recorder.Eventf(webService, corev1.EventTypeWarning, reasonRolloutAborted, "Rollout of %s: %s", c.Name, status.Message)
+	return r.holdRollout(ctx, webService, c, status, active)
+}
+
+// promoteRollout makes the slot running revision the active one.
+func (r *WebServiceReconciler) promoteRollout(ctx context.Context, webService *webappsv1.WebService, c resources.Component, status *webappsv1.RolloutStatus, revision string) error {
+	status.ActiveSlot = resources.OtherSlot(status.ActiveSlot)
+	log.Println("Promoting revision", revision, "of component", c.Name, "to the", status.ActiveSlot, "slot")
+	r.Recorder.Eventf(webService, corev1.EventTypeNormal, reasonRolloutPromoted, "Revision %s of %s promoted", revision, c.Name)
+	return r.settleRollout(ctx, webService, c, status, revision)
+}
+
+// rolloutBreach returns why the rollout has to be aborted automatically:
+// the pods of the step missed spec.rollout.progressDeadline, or their
+// containers restarted more than spec.rollout.maxRestarts times.
+func (r *WebServiceReconciler) rolloutBreach(ctx context.Context, webService *webappsv1.WebService, c resources.Component, status *webappsv1.RolloutStatus, ready bool, now metav1.Time) (string, error) {
+	spec := c.Rollout
+	if deadline := spec.ProgressDeadline; !ready && deadline != nil && now.Sub(status.StepStartTime.Time) > deadline.Duration {
+		return fmt.Sprintf("was not ready within the progress deadline of %s", deadline.Duration), nil
+	}
+	if spec.MaxRestarts == nil {
+		return "", nil
+	}
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(webService.Namespace),
+		client.MatchingLabels(c.SlotPodLabels(resources.OtherSlot(status.ActiveSlot)))); err != nil {
+		return "", err
+	}
+	var restarts int32
+	for _, pod := range pods.Items {
+		if pod.Annotations[resources.RevisionAnnotation] != status.Revision {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			restarts += cs.RestartCount
+		}
+	}
+	if restarts > *spec.MaxRestarts {
+		return fmt.Sprintf("restarted %d times, more than maxRestarts %d", restarts, *spec.MaxRestarts), nil
+	}
+	return "", nil
+}
+
+// takeRolloutAnnotations reports whether the WebService asks to promote or
+// abort the rollout of c and removes these annotations.
+func (r *WebServiceReconciler) takeRolloutAnnotations(ctx context.Context, webService *webappsv1.WebService, c resources.Component) (bool, bool, error) {
+	promote := webService.Annotations[promoteAnnotation] == c.Name
+	abort := webService.Annotations[abortAnnotation] == c.Name
+	if !promote && !abort {
+		return false, false, nil
+	}
+	patch := client.MergeFrom(webService.DeepCopy())
+	if promote {
+		delete(webService.Annotations, promoteAnnotation)
+	}
+	if abort {
+		delete(webService.Annotations, abortAnnotation)
+	}
+	// Patching the metadata returns the stored status, keep the one being
+	// built.
+	status := webService.Status.DeepCopy()
+	if err := r.Client.Patch(ctx, webService, patch); err != nil {
+		return false, false, err
+	}
+	webService.Status = *status
+	return promote, abort, nil
+}
+
+// pruneInactiveSlot deletes the Deployment of the inactive slot of c once
+// no rollout uses it.
+func (r *WebServiceReconciler) pruneInactiveSlot(ctx context.Context, webService *webappsv1.WebService, c resources.Component) error {
+	if status := findRollout(webService, c.Name); status != nil && status.Phase != webappsv1.RolloutStable {
+		return nil
+	}
+	deploy := &appsv1.Deployment{}
+	key := client.ObjectKey{Name: c.SlotName(resources.OtherSlot(activeSlot(webService, c))), Namespace: webService.Namespace}
+	if err := r.Client.Get(ctx, key, deploy); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(deploy, webService) {
+		return nil
+	}
+	log.Println("Deleting the inactive Deployment", deploy.Name, "of component", c.Name)
+	return client.IgnoreNotFound(r.Client.Delete(ctx, deploy))
+}
+
+// pruneRollouts keeps the rollout status entries for which keep is true.
+func pruneRollouts(webService *webappsv1.WebService, keep func(webappsv1.RolloutStatus) bool) {
+	kept := webService.Status.Rollouts[:0]
+	for _, s := range webService.Status.Rollouts {
+		if keep(s) {
+			kept = append(kept, s)
+		}
+	}
+	webService.Status.Rollouts = kept
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	schemaMigrationTier = "schema-migration"
+	migrationsDir       = "/migrations"
+)
+
+const (
+	reasonMigrating         = "Migrating"
+	reasonInvalidMigrations = "InvalidMigrations"
+)
+
+// sqlMigrationsScript applies the mounted .sql files in lexical order and
+// skips those already listed in schema_migrations.
+const sqlMigrationsScript = `set -e
+run() { mysql -h "$DB_HOST" -P "$DB_PORT" -uroot "$DB_NAME" "$@"; }
+run -e 'CREATE TABLE IF NOT EXISTS schema_migrations (version VARCHAR(255) NOT NULL PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)'
+for file in $(ls ` + migrationsDir + ` | grep '\.sql$' | LC_ALL=C sort); do
+  version="${file%.sql}"
+  if [ -n "$(run -N -e "SELECT 1 FROM schema_migrations WHERE version = '$version'")" ]; then
+    continue
+  fi
+  echo "Applying $file"
+  run < "` + migrationsDir + `/$file"
+  run -e "INSERT INTO schema_migrations (version) VALUES ('$version')"
+done
+`
+
+// migrationRun is what spec.migrations resolves to for the current
+// frontend image.
+type migrationRun struct {
+	version       string
+	frontendImage string
+	hash          string
+}
+
+// runMigrations reports whether the frontend Deployment may be applied. It
+// starts the "<cr>-migrate-<hash>" Job when the migrations or the frontend
+// image changed since the last successful run. A failed Job is kept and
+// holds the frontend back until it is deleted or the migrations change.
+func (r *WebServiceReconciler) runMigrations(ctx context.Context, webService *webappsv1.WebService) (bool, error) {
+	if webService.Spec.Migrations == nil {
+		if meta.FindStatusCondition(webService.Status.Conditions, webappsv1.ConditionSchemaMigrated) == nil {
+			return true, nil
+		}
+		patch := client.MergeFrom(webService.DeepCopy())
+		meta.RemoveStatusCondition(&webService.Status.Conditions, webappsv1.ConditionSchemaMigrated)
+		return true, r.Status().Patch(ctx, webService, patch)
+	}
+
+	cfg := r.Config.Get()
+	run, err := r.resolveMigrations(ctx, webService, cfg)
+	if err != nil {
+		return false, r.setSchemaMigrated(ctx, webService, metav1.ConditionFalse, reasonInvalidMigrations, err.Error())
+	}
+	if last := webService.Status.Migrations; last != nil && last.Hash == run.hash {
+		if meta.IsStatusConditionTrue(webService.Status.Conditions, webappsv1.ConditionSchemaMigrated) {
+			return true, nil
+		}
+		return true, r.setSchemaMigrated(ctx, webService, metav1.ConditionTrue, reasonMigrated,
+			fmt.Sprintf("Schema at %s for frontend image %s", last.AppliedVersion, last.FrontendImage))
+	}
+
+	name := fmt.Sprintf("%s-migrate-%s", webService.Name, run.hash)
+	job := &batchv1.Job{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: webService.Namespace}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		job = r.migrationJob(webService, cfg, name)
+		if err := r.Client.Create(ctx, job); err != nil {
+			return false, err
+		}
+		log.Println("Schema migration job create success:", name, "version:", run.version)
+	}
+
+	if job.Status.Succeeded > 0 {
+		patch := client.MergeFrom(webService.DeepCopy())
+		webService.Status.Migrations = &webappsv1.MigrationStatus{
+			AppliedVersion: run.version,
+			FrontendImage:  run.frontendImage,
+			Hash:           run.hash,
+			Job:            name,
+			AppliedTime:    job.Status.CompletionTime,
+		}
+		meta.SetStatusCondition(&webService.Status.Conditions, schemaMigratedCondition(webService, metav1.ConditionTrue, reasonMigrated,
+			fmt.Sprintf("Schema at %s for frontend image %s", run.version, run.frontendImage)))
+		if err := r.Status().Patch(ctx, webService, patch); err != nil {
+			return false, err
+		}
+		return true, r.deleteOldMigrationJobs(ctx, webService, name)
+	}
+	if isJobFailed(job) {
+		return false, r.setSchemaMigrated(ctx, webService, metav1.ConditionFalse, reasonMigrationFailed,
+			fmt.Sprintf("Job %s failed, the frontend is not updated until the migrations are fixed or the Job is deleted", name))
+	}
+	return false, r.setSchemaMigrated(ctx, webService, metav1.ConditionFalse, reasonMigrating,
+		fmt.Sprintf("Job %s is migrating the schema to %s", name, run.version))
+}
+
+// resolveMigrations checks spec.migrations and hashes it together with the
+// frontend image.
+func (r *WebServiceReconciler) resolveMigrations(ctx context.Context, webService *webappsv1.WebService, cfg *config.OperatorConfig) (*migrationRun, error) {
+	spec := webService.Spec.Migrations
+	run := &migrationRun{frontendImage: cfg.Image(config.ComponentFrontend, resources.PrimaryComponent(webService).Image)}
+	h := sha256.New()
+	fmt.Fprintf(h, "frontend=%s\n", run.frontendImage)
+
+	switch {
+	case spec.ConfigMap != nil && (spec.Image != "" || len(spec.Command) > 0 || len(spec.Args) > 0):
+		return nil, fmt.Errorf("spec.migrations sets both a ConfigMap and an image")
+	case spec.ConfigMap != nil:
+		cm := &corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: spec.ConfigMap.Name, Namespace: webService.Namespace}, cm); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, fmt.Errorf("migrations ConfigMap %s not found", spec.ConfigMap.Name)
+			}
+			return nil, err
+		}
+		var files []string
+		for key := range cm.Data {
+			if strings.HasSuffix(key, ".sql") {
+				files = append(files, key)
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("migrations ConfigMap %s has no .sql file", spec.ConfigMap.Name)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			fmt.Fprintf(h, "file=%s\n%s\n", file, cm.Data[file])
+		}
+		run.version = strings.TrimSuffix(files[len(files)-1], ".sql")
+	case spec.Image == "" && len(spec.Command) == 0:
+		return nil, fmt.Errorf("spec.migrations needs a ConfigMap, an image or a command")
+	default:
+		run.version = resources.MigrationImage(webService, cfg)
+		fmt.Fprintf(h, "image=%s\ncommand=%q\nargs=%q\n", run.version, spec.Command, spec.Args)
+	}
+	run.hash = hex.EncodeToString(h.Sum(nil))[:10]
+	return run, nil
+}
+
+// migrationJob is a dbClientJob running the SQL files of the ConfigMap, or
+// one running the migration container of the frontend.
+func (r *WebServiceReconciler) migrationJob(webService *webappsv1.WebService, cfg *config.OperatorConfig, name string) *batchv1.Job {
+	job := r.dbClientJob(webService, name, schemaMigrationTier, "", sqlMigrationsScript,
+		corev1.EnvVar{Name: "DB_NAME", Value: cfg.Mysql.Database})
+	pod := &job.Spec.Template.Spec
+	if cm := webService.Spec.Migrations.ConfigMap; cm != nil {
+		pod.Volumes = []corev1.Volume{{
+			Name:         "migrations",
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: *cm}},
+		}}
+		pod.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "migrations", MountPath: migrationsDir, ReadOnly: true}}
+		return job
+	}
+	migration := resources.NewMigrationPod(webService, cfg)
+	pod.SecurityContext = migration.SecurityContext
+	pod.Containers = migration.Containers
+	pod.Volumes = migration.Volumes
+	return job
+}
+
+// deleteOldMigrationJobs removes the Jobs of earlier migration runs.
+func (r *WebServiceReconciler) deleteOldMigrationJobs(ctx context.Context, webService *webappsv1.WebService, current string) error {
+	jobs := &batchv1.JobList{}
+	if err := r.Client.List(ctx, jobs, client.InNamespace(webService.Namespace),
+		client.MatchingLabels(makeLabels(webService, schemaMigrationTier))); err != nil {
+		return err
+	}
+	for i := range jobs.Items {
+		if jobs.Items[i].Name == current {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *WebServiceReconciler) setSchemaMigrated(ctx context.Context, webService *webappsv1.WebService, status metav1.ConditionStatus, reason, message string) error {
+	patch := client.MergeFrom(webService.DeepCopy())
+	meta.SetStatusCondition(&webService.Status.Conditions, schemaMigratedCondition(webService, status, reason, message))
+	return r.Status().Patch(ctx, webService, patch)
+}
+
+func schemaMigratedCondition(webService *webappsv1.WebService, status metav1.ConditionStatus, reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:               webappsv1.ConditionSchemaMigrated,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: webService.Generation,
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+// onlyMigrationJob returns the only schema migration Job of webService.
+func onlyMigrationJob(g *WithT, r *WebServiceReconciler, webService *webappsv1.WebService) *batchv1.Job {
+	jobs := &batchv1.JobList{}
+	g.Expect(r.Client.List(context.Background(), jobs, client.InNamespace(webService.Namespace),
+		client.MatchingLabels(makeLabels(webService, schemaMigrationTier)))).To(Succeed())
+	g.Expect(jobs.Items).To(HaveLen(1))
+	return &jobs.Items[0]
+}
+
+func TestRunMigrationsHoldsFrontendBack(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	webService.Spec.Migrations = &webappsv1.MigrationsSpec{Image: "shop-migrate:1", Args: []string{"up"}}
+	r := fakeReconciler(t, webService)
+	migrated := func() *metav1.Condition {
+		return meta.FindStatusCondition(webService.Status.Conditions, webappsv1.ConditionSchemaMigrated)
+	}
+
+	ok, err := r.runMigrations(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(migrated()).To(HaveField("Reason", reasonMigrating))
+	job := onlyMigrationJob(g, r, webService)
+	g.Expect(job.Spec.Template.Spec.Containers).To(ConsistOf(And(
+		HaveField("Image", HaveSuffix("shop-migrate:1")),
+		HaveField("Args", []string{"up"}),
+	)))
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	ok, err = r.runMigrations(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(migrated()).To(HaveField("Reason", reasonMigrationFailed))
+
+	job.Status.Conditions = nil
+	job.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	ok, err = r.runMigrations(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(migrated()).To(HaveField("Status", metav1.ConditionTrue))
+	g.Expect(webService.Status.Migrations).To(HaveField("Job", job.Name))
+	g.Expect(webService.Status.Migrations.AppliedVersion).To(HaveSuffix("shop-migrate:1"))
+
+	// A new frontend image is migrated for before it rolls out, the
+	// finished run of the old image is cleaned up.
+	webService.Spec.Frontend.Image = "nginx:1.27"
+	g.Expect(r.Client.Update(ctx, webService)).To(Succeed())
+	ok, err = r.runMigrations(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	next := &batchv1.Job{}
+	jobs := &batchv1.JobList{}
+	g.Expect(r.Client.List(ctx, jobs, client.InNamespace(webService.Namespace))).To(Succeed())
+	for i := range jobs.Items {
+		if jobs.Items[i].Name != job.Name {
+			next = &jobs.Items[i]
+		}
+	}
+	next.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, next)).To(Succeed())
+	ok, err = r.runMigrations(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(webService.Status.Migrations.FrontendImage).To(HaveSuffix("nginx:1.27"))
+	g.Expect(onlyMigrationJob(g, r, webService).Name).To(Equal(next.Name))
+}
+
+func TestRunMigrationsSQLVersion(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	webService.Spec.Migrations = &webappsv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "shop-sql"}}
+	r := fakeReconciler(t, webService, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop-sql", Namespace: webService.Namespace},
+		Data:       map[string]string{"10_b.sql": "", "09_a.sql": "", "notes.txt": ""},
+	})
+
+	_, err := r.runMigrations(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	job := onlyMigrationJob(g, r, webService)
+	g.Expect(job.Spec.Template.Spec.Volumes).To(ConsistOf(HaveField("ConfigMap.Name", "shop-sql")))
+	job.Status.Succeeded = 1
+	g.Expect(r.Status().Update(ctx, job)).To(Succeed())
+	ok, err := r.runMigrations(ctx, webService)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(webService.Status.Migrations.AppliedVersion).To(Equal("10_b"))
+}
+
+func TestRunMigrationsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    webappsv1.MigrationsSpec
+		message string
+	}{
+		{name: "both", spec: webappsv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "shop-sql"}, Image: "m:1"}, message: "both a ConfigMap and an image"},
+		{name: "missing ConfigMap", spec: webappsv1.MigrationsSpec{ConfigMap: &corev1.LocalObjectReference{Name: "shop-sql"}}, message: "not found"},
+		{name: "empty", spec: webappsv1.MigrationsSpec{}, message: "needs a ConfigMap, an image or a command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webService := sampleWebService("shop")
+			webService.Spec.Migrations = &tt.spec
+			r := fakeReconciler(t, webService)
+
+			ok, err := r.runMigrations(context.Background(), webService)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(ok).To(BeFalse())
+			g.Expect(meta.FindStatusCondition(webService.Status.Conditions, webappsv1.ConditionSchemaMigrated)).To(And(
+				HaveField("Reason", reasonInvalidMigrations),
+				HaveField("Message", ContainSubstring(tt.message)),
+			))
+		})
+	}
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/graph"
+	"my.domain/demo/internal/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// updateStatus recomputes the component health from the workloads the
+// WebService owns and reports the step blocked the reconcile graph waits
+// for. ObservedGeneration only moves when nothing is blocked, i.e. the
+// children have been brought in line with the current spec.
+func (r *WebServiceReconciler) updateStatus(ctx context.Context, webService *webappsv1.WebService, blocked *graph.Blocked) error {
+	patch := client.MergeFrom(webService.DeepCopy())
+
+	var mysql, frontend *webappsv1.ComponentStatus
+	var err error
+	if webService.Spec.DatabaseSpec() != nil {
+		sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: webService.Spec.DatabaseSpec().Name, Namespace: webService.Namespace}}
+		if mysql, err = r.componentStatus(ctx, sts); err != nil {
+			return err
+		}
+	}
+	components := resources.Components(webService)
+	if ordered, err := resources.OrderComponents(components); err == nil {
+		components = ordered
+	}
+	var statuses []webappsv1.ComponentStatus
+	var notReady []string
+	all := make([]*webappsv1.ComponentStatus, 0, len(components))
+	for _, c := range components {
+		deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: c.SlotName(activeSlot(webService, c)), Namespace: webService.Namespace}}
+		cs, err := r.componentStatus(ctx, deploy)
+		if err != nil {
+			return err
+		}
+		all = append(all, cs)
+		switch {
+		case c.IsFrontend(webService):
+			frontend = cs
+		case cs != nil:
+			statuses = append(statuses, *cs)
+		}
+		if !c.IsFrontend(webService) && !isComponentReady(cs) {
+			notReady = append(notReady, c.Name)
+		}
+	}
+
+	names := map[string]bool{}
+	for _, c := range components {
+		names[c.Name] = true
+	}
+	pruneRollouts(webService, func(s webappsv1.RolloutStatus) bool { return names[s.Component] })
+
+	status := &webService.Status
+	status.Mysql = mysql
+	status.Frontend = frontend
+	status.Components = statuses
+	if webService.Spec.DatabaseSpec() != nil {
+		status.DatabaseSecret = dbSecretName(webService)
+		status.Binding = &corev1.LocalObjectReference{Name: resources.BindingSecretName(webService)}
+	}
+	status.URL = ""
+	var exposed *metav1.Condition
+	if len(components) > 0 {
+		if status.URL, err = r.frontendURL(ctx, webService); err != nil {
+			return err
+		}
+		exposed = r.exposedCondition(webService)
+	}
+	if exposed != nil {
+		meta.SetStatusCondition(&status.Conditions, *exposed)
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, webappsv1.ConditionExposed)
+	}
+	podSecurity, err := r.podSecurityCondition(ctx, webService)
+	if err != nil {
+		return err
+	}
+	meta.SetStatusCondition(&status.Conditions, podSecurity)
+	status.Phase = computePhase(status.Phase, mysql, all)
+	switch {
+	case blocked == nil:
+		status.BlockedOn = nil
+	case status.BlockedOn == nil || status.BlockedOn.Node != blocked.Node:
+		// Since only restarts for another step, the requeue backoff grows
+		// from it.
+		status.BlockedOn = &webappsv1.BlockedNode{Node: blocked.Node, Reason: blocked.Reason, Since: metav1.Now()}
+	default:
+		status.BlockedOn.Reason = blocked.Reason
+	}
+	if blocked == nil {
+		if err := r.recordSpecRevision(ctx, webService); err != nil {
+			return err
+		}
+		status.ObservedGeneration = webService.Generation
+		meta.RemoveStatusCondition(&status.Conditions, webappsv1.ConditionConflict)
+	}
+
+	meta.SetStatusCondition(&status.Conditions, componentCondition(webService, webappsv1.ConditionDatabaseReady, "Database", mysql))
+	if webService.Spec.Frontend != nil {
+		meta.SetStatusCondition(&status.Conditions, componentCondition(webService, webappsv1.ConditionFrontendReady, "Frontend", frontend))
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, webappsv1.ConditionFrontendReady)
+	}
+	if len(webService.Spec.Components) > 0 {
+		meta.SetStatusCondition(&status.Conditions, componentsCondition(webService, notReady))
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, webappsv1.ConditionComponentsReady)
+	}
+	readyStatus := metav1.ConditionFalse
+	if status.Phase == webappsv1.PhaseReady {
+		readyStatus = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               webappsv1.ConditionReady,
+		Status:             readyStatus,
+		Reason:             string(status.Phase),
+		Message:            "WebService phase is " + string(status.Phase),
+		ObservedGeneration: webService.Generation,
+	})
+
+	return r.Status().Patch(ctx, webService, patch)
+}
+
+// componentStatus returns nil if the component workload, a Deployment or
+// a StatefulSet, is not created yet. Workload and Service of a component
+// share the same name.
+func (r *WebServiceReconciler) componentStatus(ctx context.Context, workload client.Object) (*webappsv1.ComponentStatus, error) {
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(workload), workload); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var replicas *int32
+	var template *corev1.PodTemplateSpec
+	component := &webappsv1.ComponentStatus{Name: workload.GetName()}
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		replicas, template, component.ReadyReplicas = w.Spec.Replicas, &w.Spec.Template, w.Status.ReadyReplicas
+	case *appsv1.StatefulSet:
+		replicas, template, component.ReadyReplicas = w.Spec.Replicas, &w.Spec.Template, w.Status.ReadyReplicas
+	}
+	if replicas != nil {
+		component.DesiredReplicas = *replicas
+	}
+	if template != nil && len(template.Spec.Containers) > 0 {
+		component.Image = template.Spec.Containers[0].Image
+	}
+
+	name, namespace := workload.GetName(), workload.GetNamespace()
+	svc := &corev1.Service{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, svc); err == nil {
+		if len(svc.Spec.Ports) > 0 {
+			component.Endpoint = fmt.Sprintf("%s.%s.svc:%d", svc.Name, svc.Namespace, svc.Spec.Ports[0].Port)
+		}
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return component, nil
+}
+
+func isComponentReady(component *webappsv1.ComponentStatus) bool {
+	return component != nil && component.ReadyReplicas > 0 && component.ReadyReplicas >= component.DesiredReplicas
+}
+
+// computePhase never goes back to a provisioning phase after the WebService
+// was Ready once; missing replicas are reported as Degraded instead.
+// components holds nil for a component whose Deployment does not exist.
+func computePhase(prev webappsv1.WebServicePhase, mysql *webappsv1.ComponentStatus, components []*webappsv1.ComponentStatus) webappsv1.WebServicePhase {
+	ready := isComponentReady(mysql) && len(components) > 0
+	for _, component := range components {
+		ready = ready && isComponentReady(component)
+	}
+	if ready {
+		return webappsv1.PhaseReady
+	}
+	if prev == webappsv1.PhaseReady || prev == webappsv1.PhaseDegraded {
+		return webappsv1.PhaseDegraded
+	}
+	if mysql == nil {
+		return webappsv1.PhasePending
+	}
+	if !isComponentReady(mysql) {
+		return webappsv1.PhaseDatabaseProvisioning
+	}
+	return webappsv1.PhaseDeploying
+}
+
+func componentCondition(webService *webappsv1.WebService, condType, component string, cs *webappsv1.ComponentStatus) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               condType,
+		Status:             metav1.ConditionFalse,
+		Reason:             component + "NotReady",
+		Message:            "Workload not created yet",
+		ObservedGeneration: webService.Generation,
+	}
+	if cs != nil {
+		condition.Message = fmt.Sprintf("%d/%d replicas ready", cs.ReadyReplicas, cs.DesiredReplicas)
+	}
+	if isComponentReady(cs) {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = component + "Ready"
+	}
+	return condition
+}
+
+// componentsCondition reports spec.components, notReady names those that
+// are not running yet.
+func componentsCondition(webService *webappsv1.WebService, notReady []string) metav1.Condition {
+	total := len(webService.Spec.Components)
+	condition := metav1.Condition{
+		Type:               webappsv1.ConditionComponentsReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ComponentsReady",
+		Message:            fmt.Sprintf("%d/%d components ready", total, total),
+		ObservedGeneration: webService.Generation,
+	}
+	if len(notReady) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ComponentsNotReady"
+		condition.Message = fmt.Sprintf("%d/%d components ready, not ready: %s", total-len(notReady), total, strings.Join(notReady, ", "))
+	}
+	return condition
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/graph"
+)
+
+func TestComputePhase(t *testing.T) {
+	ready := &webappsv1.ComponentStatus{DesiredReplicas: 2, ReadyReplicas: 2}
+	short := &webappsv1.ComponentStatus{DesiredReplicas: 2, ReadyReplicas: 1}
+	tests := []struct {
+		name       string
+		prev       webappsv1.WebServicePhase
+		mysql      *webappsv1.ComponentStatus
+		components []*webappsv1.ComponentStatus
+		want       webappsv1.WebServicePhase
+	}{
+		{"new", "", nil, []*webappsv1.ComponentStatus{nil}, webappsv1.PhasePending},
+		{"database starting", webappsv1.PhasePending, short, []*webappsv1.ComponentStatus{nil}, webappsv1.PhaseDatabaseProvisioning},
+		{"frontend starting", "", ready, []*webappsv1.ComponentStatus{short}, webappsv1.PhaseDeploying},
+		{"component missing", "", ready, []*webappsv1.ComponentStatus{ready, nil}, webappsv1.PhaseDeploying},
+		{"ready", webappsv1.PhaseDeploying, ready, []*webappsv1.ComponentStatus{ready, ready}, webappsv1.PhaseReady},
+		{"replica lost", webappsv1.PhaseReady, ready, []*webappsv1.ComponentStatus{short}, webappsv1.PhaseDegraded},
+		{"database lost", webappsv1.PhaseDegraded, nil, []*webappsv1.ComponentStatus{ready}, webappsv1.PhaseDegraded},
+		{"recovered", webappsv1.PhaseDegraded, ready, []*webappsv1.ComponentStatus{ready}, webappsv1.PhaseReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			NewWithT(t).Expect(computePhase(tt.prev, tt.mysql, tt.components)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webService := sampleWebService("shop")
+	webService.Generation = 2
+	one := int32(1)
+	webService.Spec.Components = []webappsv1.WebServiceFrontendSpec{{
+		Name:  "api",
+		Size:  &one,
+		Image: "api:1",
+		Ports: []corev1.ServicePort{{Port: 8080, TargetPort: intstr.FromInt32(8080)}},
+	}}
+	r := fakeReconciler(t, webService, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+
+	workload := func(obj client.Object, image string) {
+		switch w := obj.(type) {
+		case *appsv1.Deployment:
+			w.Spec.Replicas = &one
+			w.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: image}}
+		case *appsv1.StatefulSet:
+			w.Spec.Replicas = &one
+			w.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: image}}
+		}
+		obj.SetNamespace(webService.Namespace)
+		g.Expect(controllerutil.SetControllerReference(webService, obj, r.Scheme)).To(Succeed())
+		g.Expect(r.Create(ctx, obj)).To(Succeed())
+	}
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "mysql"}}
+	frontend := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "nginx"}}
+	api := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "shop-api"}}
+	workload(sts, "mysql:5.7")
+	workload(frontend, "nginx")
+	workload(api, "api:1")
+	g.Expect(r.Create(ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: webService.Namespace},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+	})).To(Succeed())
+
+	setReady := func(obj client.Object, ready int32) {
+		switch w := obj.(type) {
+		case *appsv1.Deployment:
+			w.Status.ReadyReplicas = ready
+		case *appsv1.StatefulSet:
+			w.Status.ReadyReplicas = ready
+		}
+		g.Expect(r.Status().Update(ctx, obj)).To(Succeed())
+	}
+	refresh := func(blocked *graph.Blocked) *webappsv1.WebServiceStatus {
+		g.Expect(r.updateStatus(ctx, webService, blocked)).To(Succeed())
+		g.Expect(r.Get(ctx, client.ObjectKeyFromObject(webService), webService)).To(Succeed())
+		return &webService.Status
+	}
+
+	setReady(sts, 1)
+	setReady(frontend, 1)
+	status := refresh(&graph.Blocked{Node: "api", Reason: "starting"})
+	g.Expect(status.Phase).To(Equal(webappsv1.PhaseDeploying))
+	g.Expect(status.Frontend).To(HaveValue(Equal(webappsv1.ComponentStatus{
+		Name: "nginx", Image: "nginx", DesiredReplicas: 1, ReadyReplicas: 1, Endpoint: "nginx.default.svc:80",
+	})))
+	g.Expect(status.Components).To(ConsistOf(HaveField("Name", "shop-api")))
+	g.Expect(status.ObservedGeneration).To(BeZero())
+	g.Expect(status.BlockedOn).To(HaveValue(HaveField("Node", "api")))
+	g.Expect(meta.IsStatusConditionTrue(status.Conditions, webappsv1.ConditionDatabaseReady)).To(BeTrue())
+	g.Expect(meta.IsStatusConditionTrue(status.Conditions, webappsv1.ConditionFrontendReady)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(status.Conditions, webappsv1.ConditionComponentsReady)).To(HaveValue(And(
+		HaveField("Status", metav1.ConditionFalse),
+		HaveField("Message", "0/1 components ready, not ready: api"),
+	)))
+
+	setReady(api, 1)
+	status = refresh(nil)
+	g.Expect(status.Phase).To(Equal(webappsv1.PhaseReady))
+	g.Expect(status.ObservedGeneration).To(Equal(int64(2)))
+	g.Expect(status.BlockedOn).To(BeNil())
+	g.Expect(meta.IsStatusConditionTrue(status.Conditions, webappsv1.ConditionComponentsReady)).To(BeTrue())
+	g.Expect(meta.FindStatusCondition(status.Conditions, webappsv1.ConditionReady)).To(HaveValue(And(
+		HaveField("Status", metav1.ConditionTrue),
+		HaveField("ObservedGeneration", int64(2)),
+	)))
+
+	setReady(sts, 0)
+	status = refresh(nil)
+	g.Expect(status.Phase).To(Equal(webappsv1.PhaseDegraded))
+	g.Expect(meta.IsStatusConditionFalse(status.Conditions, webappsv1.ConditionDatabaseReady)).To(BeTrue())
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"log"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	databaseDataVolume     = "data"
+	defaultDatabaseStorage = "1Gi"
+)
+
+// headlessName is the governing Service of the StatefulSet called name.
+func headlessName(name string) string {
+	return name + "-headless"
+}
+
+// expandDatabaseStorage compares the volume claim template of sts with the
+// running StatefulSet. volumeClaimTemplates cannot be updated, so a larger
+// size is patched into the existing PVCs and the StatefulSet is deleted
+// with its pods orphaned, to be created again with the new template. A
+// smaller size and a new storage class are dropped from sts. It returns
+// false until sts can be applied.
+func (r *WebServiceReconciler) expandDatabaseStorage(ctx context.Context, webService *webappsv1.WebService, sts *appsv1.StatefulSet) (bool, error) {
+	current := &appsv1.StatefulSet{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(sts), current); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if current.DeletionTimestamp != nil {
+		return false, nil
+	}
+	if len(current.Spec.VolumeClaimTemplates) == 0 {
+		return true, nil
+	}
+
+	currentSpec := current.Spec.VolumeClaimTemplates[0].Spec
+	desiredSpec := &sts.Spec.VolumeClaimTemplates[0].Spec
+	desiredSpec.StorageClassName = currentSpec.StorageClassName
+	currentSize := currentSpec.Resources.Requests[corev1.ResourceStorage]
+	desiredSize := desiredSpec.Resources.Requests[corev1.ResourceStorage]
+	if desiredSize.Cmp(currentSize) == 0 {
+		return true, nil
+	}
+	if desiredSize.Cmp(currentSize) < 0 {
+		log.Println("Database storage can not shrink, keeping", currentSize.String())
+		desiredSpec.Resources.Requests[corev1.ResourceStorage] = currentSize
+		return true, nil
+	}
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, pvcs, client.InNamespace(webService.Namespace), client.MatchingLabels(databaseLabels(webService))); err != nil {
+		return false, err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; size.Cmp(desiredSize) >= 0 {
+			continue
+		}
+		patch := client.MergeFrom(pvc.DeepCopy())
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = desiredSize
+		if err := r.Client.Patch(ctx, pvc, patch); err != nil {
+			return false, err
+		}
+		log.Println("Database PVC", pvc.Name, "expanded to", desiredSize.String())
+	}
+
+	log.Println("Database statefulset is recreated for the new volume size.")
+	err := r.Client.Delete(ctx, current, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	return false, client.IgnoreNotFound(err)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+// withStorage sets the data volume of webService.
+func withStorage(webService *webappsv1.WebService, size, class string) *webappsv1.WebService {
+	webService.Spec.Database.Storage = &webappsv1.WebServiceDbStorageSpec{Size: resource.MustParse(size), StorageClassName: &class}
+	return webService
+}
+
+func claimTemplate(sts *appsv1.StatefulSet) corev1.PersistentVolumeClaimSpec {
+	return sts.Spec.VolumeClaimTemplates[0].Spec
+}
+
+func TestExpandDatabaseStorageKeepsFixedFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      string
+		class     string
+		wantSize  string
+		wantClass string
+	}{
+		{name: "unchanged", size: "1Gi", class: "standard", wantSize: "1Gi", wantClass: "standard"},
+		{name: "shrunk", size: "512Mi", class: "standard", wantSize: "1Gi", wantClass: "standard"},
+		{name: "other class", size: "1Gi", class: "fast", wantSize: "1Gi", wantClass: "standard"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			r := fakeReconciler(t)
+			current := r.databaseStatefulSet(withStorage(sampleWebService("shop"), "1Gi", "standard"))
+			g.Expect(r.Client.Create(ctx, current)).To(Succeed())
+
+			webService := withStorage(sampleWebService("shop"), tt.size, tt.class)
+			sts := r.databaseStatefulSet(webService)
+			ok, err := r.expandDatabaseStorage(ctx, webService, sts)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(ok).To(BeTrue())
+			size := claimTemplate(sts).Resources.Requests[corev1.ResourceStorage]
+			g.Expect(size.String()).To(Equal(tt.wantSize))
+			g.Expect(claimTemplate(sts).StorageClassName).To(HaveValue(Equal(tt.wantClass)))
+		})
+	}
+}
+
+func TestExpandDatabaseStorageGrows(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	r := fakeReconciler(t)
+	old := withStorage(sampleWebService("shop"), "1Gi", "standard")
+	current := r.databaseStatefulSet(old)
+	g.Expect(r.Client.Create(ctx, current)).To(Succeed())
+	for i, size := range []string{"1Gi", "1Gi", "8Gi"} {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("data-mysql-%d", i), Namespace: old.Namespace, Labels: databaseLabels(old)},
+			Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			}},
+		}
+		g.Expect(r.Client.Create(ctx, pvc)).To(Succeed())
+	}
+
+	webService := withStorage(sampleWebService("shop"), "5Gi", "standard")
+	ok, err := r.expandDatabaseStorage(ctx, webService, r.databaseStatefulSet(webService))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+
+	// The claims grow in place, a larger one is left alone, and the
+	// StatefulSet goes to be created again with the new template.
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	g.Expect(r.Client.List(ctx, pvcs, client.InNamespace(webService.Namespace))).To(Succeed())
+	sizes := map[string]string{}
+	for _, pvc := range pvcs.Items {
+		size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		sizes[pvc.Name] = size.String()
+	}
+	g.Expect(sizes).To(Equal(map[string]string{"data-mysql-0": "5Gi", "data-mysql-1": "5Gi", "data-mysql-2": "8Gi"}))
+	g.Expect(exists(g, r, current)).To(BeFalse())
+
+	// Without the old StatefulSet the new one can be applied.
+	ok, err = r.expandDatabaseStorage(ctx, webService, r.databaseStatefulSet(webService))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+}
+
+func TestDumpPVCSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage *webappsv1.WebServiceDbStorageSpec
+		want    string
+	}{
+		{name: "default", want: dbDumpStorage},
+		{name: "no size", storage: &webappsv1.WebServiceDbStorageSpec{}, want: dbDumpStorage},
+		{name: "data volume size", storage: &webappsv1.WebServiceDbStorageSpec{Size: resource.MustParse("20Gi")}, want: "20Gi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			r := fakeReconciler(t)
+			webService := sampleWebService("shop")
+			webService.Spec.Database.Storage = tt.storage
+			for _, tier := range []string{"snapshot", "migration"} {
+				pvc := r.dbDumpPVC(webService, "shop-mysql-"+tier, tier)
+				size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+				g.Expect(size.String()).To(Equal(tt.want), tier)
+			}
+		})
+	}
+}
@@ -19,31 +19,59 @@ package controller
 import (
 	"context"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/json"
 	"my.domain/demo/internal/resources"
-	"reflect"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	"log"
 	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/graph"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // WebServiceReconciler reconciles a WebService object
 type WebServiceReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Config *config.Store
+	// GatewayAPI is set when the cluster serves HTTPRoutes, see
+	// HasGatewayAPI.
+	GatewayAPI bool
+	// Recorder reports adoptions, rollouts and rollbacks on the WebService.
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=webapps.my.domain,resources=webservices,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=webapps.my.domain,resources=webservices/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=webapps.my.domain,resources=webservices/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -70,103 +98,135 @@ func (r *WebServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	if webService.DeletionTimestamp != nil {
 		log.Println("WebService will be delete.")
-		return ctrl.Result{}, nil
+		return r.finalize(ctx, webService)
 	}
-
-	r.ensureDBSecret(webService, r.mysqlSecret(webService))
-	r.ensureDBDeployment(webService, r.mysqlDeployment(webService))
-	r.ensureDBService(webService, r.mysqlService(webService))
-
-	running := r.isMysqlRunning(webService)
-	if !running {
-		delay := time.Second * time.Duration(5)
-		return ctrl.Result{RequeueAfter: delay}, err
-	}
-	log.Println("Mysql pod is running...")
-
-	deploy := &appsv1.Deployment{}
-	err = r.Client.Get(ctx, types.NamespacedName{Namespace: webService.Namespace, Name: webService.Spec.Frontend.Name}, deploy)
-	if err != nil && errors.IsNotFound(err) {
-		log.Println("Frontend deployment not found.")
-
-		if err := r.Client.Create(ctx, resources.NewFrontendDeployment(webService)); err != nil {
-			log.Println("Frontend deployment create failure.")
-			return ctrl.Result{}, err
-		}
-		log.Println("Frontend deployment create success.")
-
-		if err := r.Client.Create(ctx, resources.NewFrontendService(webService)); err != nil {
-			log.Println("Frontend service create failure.")
-			return ctrl.Result{}, err
-		}
-		log.Println("Frontend service create success.")
-
-		specData, _ := json.Marshal(webService.Spec)
-		if webService.Annotations != nil {
-			webService.Annotations["spec"] = string(specData)
-		} else {
-			webService.Annotations = map[string]string{"spec": string(specData)}
-		}
+	if controllerutil.AddFinalizer(webService, teardownFinalizer) {
 		if err := r.Client.Update(ctx, webService); err != nil {
-			log.Println("webService update failure.")
+			log.Println("webService finalizer add failure.")
 			return ctrl.Result{}, err
 		}
-		log.Println("webService update success.")
-
-		return ctrl.Result{}, nil
 	}
 
-	oldSpec := webappsv1.WebServiceSpec{}
-	if err := json.Unmarshal([]byte(webService.Annotations["spec"]), &oldSpec); err != nil {
-		log.Println("Annotations['spec'] unmarshal failure.")
+	if err := r.migrateLegacySpec(ctx, webService); err != nil {
+		log.Println("webService spec annotation migration failure.")
 		return ctrl.Result{}, err
 	}
-
-	if !reflect.DeepEqual(webService.Spec, oldSpec) {
-		log.Println("webService necessary update.")
-
-		newFrontendDeploy := resources.NewFrontendDeployment(webService)
-		oldFrontendDeploy := &appsv1.Deployment{}
-		r.Client.Get(ctx, types.NamespacedName{Name: webService.Spec.Frontend.Name, Namespace: webService.Namespace}, oldFrontendDeploy)
-		oldFrontendDeploy.Spec = newFrontendDeploy.Spec
-		if err := r.Update(ctx, oldFrontendDeploy); err != nil {
-			log.Println("Frontend deployment update failure.")
-			return ctrl.Result{}, err
-		}
-		log.Println("Frontend deployment update success.")
-
-		newFrontendSvc := resources.NewFrontendService(webService)
-		oldFrontendSvc := &corev1.Service{}
-		r.Client.Get(ctx, types.NamespacedName{Name: webService.Spec.Frontend.Name, Namespace: webService.Namespace}, oldFrontendSvc)
-		newFrontendSvc.Spec.ClusterIP = oldFrontendSvc.Spec.ClusterIP
-		oldFrontendSvc.Spec = newFrontendSvc.Spec
-		if err := r.Update(ctx, oldFrontendSvc); err != nil {
-			log.Println("Frontend service update failure.")
-			return ctrl.Result{}, err
-		}
-		log.Println("Frontend service update success.")
-
-		specData, _ := json.Marshal(webService.Spec)
-		if webService.Annotations != nil {
-			webService.Annotations["spec"] = string(specData)
-		} else {
-			webService.Annotations = map[string]string{"spec": string(specData)}
-		}
-		if err := r.Client.Update(ctx, webService); err != nil {
-			log.Println("webService update failure.")
-			return ctrl.Result{}, err
+	if rolledBack, err := r.rollback(ctx, webService); err != nil || rolledBack {
+		if err != nil {
+			log.Println("webService rollback failure.")
 		}
-		log.Println("webService update success.")
+		return ctrl.Result{}, err
+	}
+	// The children are applied on every reconcile to correct drift; the
+	// generation only tells whether the spec moved since the last sync.
+	if webService.Generation != webService.Status.ObservedGeneration {
+		log.Println("webService spec changed, generation:", webService.Generation,
+			"observedGeneration:", webService.Status.ObservedGeneration)
+	}
 
-		return ctrl.Result{}, nil
+	if err := validateDatabase(webService); err != nil {
+		log.Println("Database spec is not supported:", err)
+		return ctrl.Result{}, err
+	}
+	components, err := resources.OrderComponents(resources.Components(webService))
+	if err != nil {
+		log.Println("Components cannot be ordered:", err)
+		return ctrl.Result{}, err
+	}
+	if err := validatePodTemplates(webService, r.Config.Get()); err != nil {
+		log.Println("podTemplate is refused:", err)
+		return ctrl.Result{}, err
+	}
+	var requeue time.Duration
+	result, err := r.reconcileGraph(webService, components, &requeue).Run(ctx)
+	if err != nil {
+		log.Println("webService reconcile failure:", err)
+		return ctrl.Result{}, err
+	}
+	if result.Blocked != nil {
+		log.Println("webService is waiting for", result.Blocked.Node+":", result.Blocked.Reason,
+			"skipped:", result.Waiting)
+	}
+	// Removed components have no step, their children are pruned on every
+	// reconcile.
+	if err := r.pruneComponents(ctx, webService, components); err != nil {
+		log.Println("Removed component cleanup failure.")
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	if err := r.updateStatus(ctx, webService, result.Blocked); err != nil {
+		log.Println("webService status update failure.")
+		return ctrl.Result{}, err
+	}
+	if blocked := webService.Status.BlockedOn; blocked != nil {
+		backoff := graph.Backoff(time.Since(blocked.Since.Time))
+		if requeue == 0 || backoff < requeue {
+			requeue = backoff
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WebServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	ctx := context.Background()
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &webappsv1.WebService{}, configMapIndex, func(obj client.Object) []string {
+		configMaps, _ := r.configRefs(obj.(*webappsv1.WebService))
+		return sets.List(configMaps)
+	}); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &webappsv1.WebService{}, secretIndex, func(obj client.Object) []string {
+		_, secrets := r.configRefs(obj.(*webappsv1.WebService))
+		return sets.List(secrets)
+	}); err != nil {
+		return err
+	}
+
+	// Owned children are watched so that a deleted child is re-created and a
+	// hand edited one is applied again on the next reconcile.
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&webappsv1.WebService{}).
-		Complete(r)
+		Owns(&appsv1.Deployment{}, builder.WithPredicates(deploymentChanged)).
+		Owns(&appsv1.StatefulSet{}, builder.WithPredicates(statefulSetChanged)).
+		Owns(&corev1.Service{}, builder.WithPredicates(serviceChanged)).
+		Owns(&corev1.Secret{}, builder.WithPredicates(secretChanged)).
+		Owns(&batchv1.Job{}, builder.WithPredicates(jobFinished)).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		// ConfigMaps and Secrets the pods read are looked up in the field
+		// indexes. Their edits roll the pods through the config checksum; a
+		// changed migrations ConfigMap starts a new migration run.
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.webServicesReading(configMapIndex)),
+			builder.WithPredicates(configMapChanged)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.webServicesReading(secretIndex)),
+			builder.WithPredicates(secretChanged))
+
+	if r.GatewayAPI {
+		bldr = bldr.Owns(resources.NewHTTPRouteObject())
+	}
+
+	if r.Config != nil {
+		// A reloaded operator config may change images, pull secrets or labels
+		// of every child, so requeue all WebServices.
+		configChanged := make(chan event.GenericEvent)
+		r.Config.OnChange(func(ctx context.Context, _ *config.OperatorConfig) {
+			webServices := &webappsv1.WebServiceList{}
+			if err := r.Client.List(ctx, webServices); err != nil {
+				log.Println("WebService list failure after config reload:", err)
+				return
+			}
+			for i := range webServices.Items {
+				// The config reload waits for this listener, stop on shutdown.
+				select {
+				case configChanged <- event.GenericEvent{Object: &webServices.Items[i]}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+		bldr = bldr.WatchesRawSource(source.Channel(configChanged, &handler.EnqueueRequestForObject{}))
+	}
+
+	return bldr.Complete(r)
 }
@@ -18,16 +18,24 @@ package controller
 
 import (
 	"context"
+	"time"
 
+	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/resources"
 )
 
 var _ = Describe("WebService Controller", func() {
@@ -82,3 +90,350 @@ var _ = Describe("WebService Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("WebService rollouts", func() {
+	var (
+		ctx        context.Context
+		recorder   *record.FakeRecorder
+		reconciler *WebServiceReconciler
+		webService *webappsv1.WebService
+	)
+
+	// create stores a WebService whose frontend rolls out with rollout. The
+	// frontend is named after the WebService, so that the specs do not
+	// share Deployments.
+	create := func(name string, size int32, rollout *webappsv1.RolloutSpec) {
+		webService = sampleWebService(name)
+		webService.UID = ""
+		webService.Spec.Frontend.Name = name + "-web"
+		webService.Spec.Frontend.Size = &size
+		webService.Spec.Frontend.Rollout = rollout
+		Expect(k8sClient.Create(ctx, webService)).To(Succeed())
+		DeferCleanup(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, webService))).To(Succeed())
+		})
+	}
+
+	frontend := func() resources.Component {
+		return resources.Components(webService)[0]
+	}
+
+	// rollout runs the rollout of the frontend on the stored WebService,
+	// as a reconcile does.
+	rollout := func() time.Duration {
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(webService), webService)).To(Succeed())
+		requeue, err := reconciler.rolloutComponent(ctx, webService, frontend())
+		Expect(err).NotTo(HaveOccurred())
+		return requeue
+	}
+
+	status := func() *webappsv1.RolloutStatus {
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(webService), webService)).To(Succeed())
+		s := findRollout(webService, frontend().Name)
+		Expect(s).NotTo(BeNil())
+		return s
+	}
+
+	update := func(mutate func()) {
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(webService), webService)).To(Succeed())
+		mutate()
+		Expect(k8sClient.Update(ctx, webService)).To(Succeed())
+	}
+
+	annotate := func(key string) {
+		update(func() {
+			if webService.Annotations == nil {
+				webService.Annotations = map[string]string{}
+			}
+			webService.Annotations[key] = frontend().Name
+		})
+	}
+
+	// backdateStep moves the start of the current step by d into the past.
+	backdateStep := func(d time.Duration) {
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(webService), webService)).To(Succeed())
+		patch := client.MergeFrom(webService.DeepCopy())
+		s := findRollout(webService, frontend().Name)
+		s.StepStartTime = &metav1.Time{Time: s.StepStartTime.Add(-d)}
+		Expect(k8sClient.Status().Patch(ctx, webService, patch)).To(Succeed())
+	}
+
+	deployment := func(slot string) *appsv1.Deployment {
+		deploy := &appsv1.Deployment{}
+		key := client.ObjectKey{Name: frontend().SlotName(slot), Namespace: webService.Namespace}
+		Expect(k8sClient.Get(ctx, key, deploy)).To(Succeed())
+		return deploy
+	}
+
+	// markReady reports the pods of slot as started, which no Deployment
+	// controller does in envtest.
+	markReady := func(slot string, replicas int32) {
+		deploy := deployment(slot)
+		deploy.Status = appsv1.DeploymentStatus{
+			ObservedGeneration: deploy.Generation,
+			Replicas:           replicas,
+			UpdatedReplicas:    replicas,
+			ReadyReplicas:      replicas,
+			AvailableReplicas:  replicas,
+		}
+		Expect(k8sClient.Status().Update(ctx, deploy)).To(Succeed())
+	}
+
+	// startRollout brings the frontend to a stable blue Deployment and then
+	// changes its image. It returns the revision being rolled out.
+	startRollout := func() string {
+		Expect(rollout()).To(BeZero())
+		Expect(status().Phase).To(Equal(webappsv1.RolloutStable))
+		Expect(status().ActiveSlot).To(Equal(resources.SlotBlue))
+		stable := deployment(resources.SlotBlue).Annotations[resources.RevisionAnnotation]
+		Expect(stable).NotTo(BeEmpty())
+
+		update(func() { webService.Spec.Frontend.Image = "nginx:next" })
+		revision := resources.Revision(webService, reconciler.Config.Get(), frontend())
+		Expect(revision).NotTo(Equal(stable))
+		return revision
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		store, err := config.NewStore("", logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		recorder = record.NewFakeRecorder(100)
+		reconciler = &WebServiceReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Config:   store,
+			Recorder: recorder,
+		}
+	})
+
+	Context("with the BlueGreen strategy", func() {
+		It("switches to the new Deployment once it is ready", func() {
+			create("bluegreen-auto", 2, &webappsv1.RolloutSpec{Strategy: webappsv1.RolloutBlueGreen})
+			revision := startRollout()
+
+			Expect(rollout()).To(Equal(rolloutPollInterval))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutProgressing))
+			Expect(status().Revision).To(Equal(revision))
+			Expect(recorder.Events).To(Receive(ContainSubstring(reasonRolloutStarted)))
+			green := deployment(resources.SlotGreen)
+			Expect(green.Annotations).To(HaveKeyWithValue(resources.RevisionAnnotation, revision))
+			Expect(green.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+			// The stable Deployment keeps its template and all replicas.
+			blue := deployment(resources.SlotBlue)
+			Expect(blue.Annotations[resources.RevisionAnnotation]).To(Equal(status().StableRevision))
+			Expect(blue.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+			Expect(serviceSelector(webService, frontend())).To(Equal(frontend().SlotPodLabels(resources.SlotBlue)))
+
+			markReady(resources.SlotGreen, 2)
+			Expect(rollout()).To(BeZero())
+			Expect(status().Phase).To(Equal(webappsv1.RolloutStable))
+			Expect(status().ActiveSlot).To(Equal(resources.SlotGreen))
+			Expect(status().StableRevision).To(Equal(revision))
+			Expect(status().Revision).To(BeEmpty())
+			Expect(recorder.Events).To(Receive(ContainSubstring(reasonRolloutPromoted)))
+			Expect(serviceSelector(webService, frontend())).To(Equal(frontend().SlotPodLabels(resources.SlotGreen)))
+
+			Expect(reconciler.pruneInactiveSlot(ctx, webService, frontend())).To(Succeed())
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(blue), &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("waits for the promote annotation without autoPromote", func() {
+			autoPromote := false
+			create("bluegreen-manual", 2, &webappsv1.RolloutSpec{Strategy: webappsv1.RolloutBlueGreen, AutoPromote: &autoPromote})
+			revision := startRollout()
+			rollout()
+			markReady(resources.SlotGreen, 2)
+
+			Expect(rollout()).To(Equal(rolloutPollInterval))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutPaused))
+			Expect(status().Message).To(ContainSubstring(promoteAnnotation))
+			Expect(status().ActiveSlot).To(Equal(resources.SlotBlue))
+
+			annotate(promoteAnnotation)
+			Expect(rollout()).To(BeZero())
+			Expect(status().Phase).To(Equal(webappsv1.RolloutStable))
+			Expect(status().ActiveSlot).To(Equal(resources.SlotGreen))
+			Expect(status().StableRevision).To(Equal(revision))
+			Expect(webService.Annotations).NotTo(HaveKey(promoteAnnotation))
+		})
+
+		It("aborts on the abort annotation and retries on promote", func() {
+			create("bluegreen-abort", 2, &webappsv1.RolloutSpec{Strategy: webappsv1.RolloutBlueGreen})
+			revision := startRollout()
+			rollout()
+
+			annotate(abortAnnotation)
+			Expect(rollout()).To(BeZero())
+			Expect(status().Phase).To(Equal(webappsv1.RolloutAborted))
+			Expect(status().Revision).To(Equal(revision))
+			Expect(status().Message).To(ContainSubstring(abortAnnotation))
+			Expect(webService.Annotations).NotTo(HaveKey(abortAnnotation))
+			Expect(recorder.Events).To(Receive(ContainSubstring(reasonRolloutStarted)))
+			Expect(recorder.Events).To(Receive(ContainSubstring(reasonRolloutAborted)))
+			Expect(deployment(resources.SlotGreen).Spec.Replicas).To(HaveValue(BeZero()))
+			Expect(deployment(resources.SlotBlue).Spec.Replicas).To(HaveValue(Equal(int32(2))))
+
+			// The rollout stays down, even with the new pods ready.
+			markReady(resources.SlotGreen, 2)
+			Expect(rollout()).To(BeZero())
+			Expect(status().Phase).To(Equal(webappsv1.RolloutAborted))
+			Expect(status().ActiveSlot).To(Equal(resources.SlotBlue))
+			Expect(serviceSelector(webService, frontend())).To(Equal(frontend().SlotPodLabels(resources.SlotBlue)))
+
+			annotate(promoteAnnotation)
+			Expect(rollout()).To(Equal(rolloutPollInterval))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutProgressing))
+			Expect(deployment(resources.SlotGreen).Spec.Replicas).To(HaveValue(Equal(int32(2))))
+		})
+
+		It("aborts when the new pods miss the progress deadline", func() {
+			create("bluegreen-deadline", 2, &webappsv1.RolloutSpec{
+				Strategy:         webappsv1.RolloutBlueGreen,
+				ProgressDeadline: &metav1.Duration{Duration: time.Minute},
+			})
+			startRollout()
+			Expect(rollout()).To(Equal(rolloutPollInterval))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutProgressing))
+
+			backdateStep(2 * time.Minute)
+			Expect(rollout()).To(BeZero())
+			Expect(status().Phase).To(Equal(webappsv1.RolloutAborted))
+			Expect(status().Message).To(ContainSubstring("progress deadline of 1m0s"))
+			Expect(deployment(resources.SlotGreen).Spec.Replicas).To(HaveValue(BeZero()))
+		})
+
+		It("aborts when the new pods restart more than maxRestarts", func() {
+			maxRestarts := int32(1)
+			create("bluegreen-restarts", 2, &webappsv1.RolloutSpec{
+				Strategy:    webappsv1.RolloutBlueGreen,
+				MaxRestarts: &maxRestarts,
+			})
+			revision := startRollout()
+			rollout()
+
+			// A crashing pod of the new revision; the ones of other
+			// revisions do not count.
+			for i, rev := range []string{revision, "older"} {
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        webService.Name + "-crash-" + rev,
+						Namespace:   webService.Namespace,
+						Labels:      frontend().SlotPodLabels(resources.SlotGreen),
+						Annotations: map[string]string{resources.RevisionAnnotation: rev},
+					},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx:next"}}},
+				}
+				Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+				DeferCleanup(k8sClient.Delete, ctx, pod)
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+					Name:         "web",
+					Image:        "nginx:next",
+					RestartCount: int32(2 + i),
+				}}
+				Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+			}
+
+			Expect(rollout()).To(BeZero())
+			Expect(status().Phase).To(Equal(webappsv1.RolloutAborted))
+			Expect(status().Message).To(ContainSubstring("restarted 2 times, more than maxRestarts 1"))
+		})
+	})
+
+	Context("with the Canary strategy", func() {
+		It("holds a step for its pause, then waits for promote at the last one", func() {
+			create("canary-pause", 4, &webappsv1.RolloutSpec{
+				Strategy: webappsv1.RolloutCanary,
+				Steps: []webappsv1.CanaryStep{
+					{Weight: 25, Pause: &metav1.Duration{Duration: 10 * time.Minute}},
+					{Weight: 50},
+				},
+			})
+			revision := startRollout()
+
+			Expect(rollout()).To(Equal(rolloutPollInterval))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutProgressing))
+			Expect(status().Step).To(BeZero())
+			Expect(status().Weight).To(Equal(int32(25)))
+			Expect(deployment(resources.SlotGreen).Spec.Replicas).To(HaveValue(Equal(int32(1))))
+			Expect(deployment(resources.SlotBlue).Spec.Replicas).To(HaveValue(Equal(int32(3))))
+			// Both Deployments take traffic.
+			Expect(serviceSelector(webService, frontend())).To(Equal(frontend().ServingPodLabels(webService)))
+
+			markReady(resources.SlotGreen, 1)
+			requeue := rollout()
+			Expect(requeue).To(BeNumerically(">", 0))
+			Expect(requeue).To(BeNumerically("<=", rolloutPollInterval))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutPaused))
+			Expect(status().Message).To(ContainSubstring("until"))
+
+			backdateStep(11 * time.Minute)
+			Expect(rollout()).To(Equal(rolloutPollInterval))
+			Expect(status().Step).To(Equal(int32(1)))
+			Expect(status().Message).To(Equal("Moving to step 2"))
+
+			Expect(rollout()).To(Equal(rolloutPollInterval))
+			Expect(status().Weight).To(Equal(int32(50)))
+			Expect(deployment(resources.SlotGreen).Spec.Replicas).To(HaveValue(Equal(int32(2))))
+			Expect(deployment(resources.SlotBlue).Spec.Replicas).To(HaveValue(Equal(int32(2))))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutProgressing))
+
+			// The last step has no pause and waits for the annotation.
+			markReady(resources.SlotGreen, 2)
+			Expect(rollout()).To(Equal(rolloutPollInterval))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutPaused))
+			Expect(status().Message).To(ContainSubstring(promoteAnnotation))
+
+			annotate(promoteAnnotation)
+			Expect(rollout()).To(BeZero())
+			Expect(status().Phase).To(Equal(webappsv1.RolloutStable))
+			Expect(status().ActiveSlot).To(Equal(resources.SlotGreen))
+			Expect(status().StableRevision).To(Equal(revision))
+			Expect(status().Weight).To(BeZero())
+			Expect(deployment(resources.SlotGreen).Spec.Replicas).To(HaveValue(Equal(int32(4))))
+		})
+
+		DescribeTable("keeps a stable pod until the promotion",
+			func(name string, size int32, canary int32) {
+				create(name, size, &webappsv1.RolloutSpec{
+					Strategy: webappsv1.RolloutCanary,
+					Steps:    []webappsv1.CanaryStep{{Weight: 50}, {Weight: 100}},
+				})
+				startRollout()
+				Expect(rollout()).To(Equal(rolloutPollInterval))
+				Expect(deployment(resources.SlotGreen).Spec.Replicas).To(HaveValue(Equal(int32(1))))
+				Expect(deployment(resources.SlotBlue).Spec.Replicas).To(HaveValue(Equal(int32(1))))
+
+				markReady(resources.SlotGreen, 1)
+				annotate(promoteAnnotation)
+				rollout()
+				Expect(status().Step).To(Equal(int32(1)))
+				Expect(rollout()).To(Equal(rolloutPollInterval))
+				Expect(deployment(resources.SlotGreen).Spec.Replicas).To(HaveValue(Equal(canary)))
+				Expect(deployment(resources.SlotBlue).Spec.Replicas).To(HaveValue(Equal(int32(1))))
+			},
+			Entry("with one replica", "canary-one", int32(1), int32(1)),
+			Entry("with two replicas", "canary-two", int32(2), int32(2)),
+		)
+
+		It("stays within steps that were removed during the rollout", func() {
+			create("canary-shortened", 4, &webappsv1.RolloutSpec{
+				Strategy: webappsv1.RolloutCanary,
+				Steps:    []webappsv1.CanaryStep{{Weight: 25}, {Weight: 50}},
+			})
+			startRollout()
+			rollout()
+			markReady(resources.SlotGreen, 1)
+			annotate(promoteAnnotation)
+			rollout()
+			Expect(status().Step).To(Equal(int32(1)))
+
+			update(func() { webService.Spec.Frontend.Rollout.Steps = webService.Spec.Frontend.Rollout.Steps[:1] })
+			rollout()
+			Expect(status().Step).To(BeZero())
+			Expect(status().Weight).To(Equal(int32(25)))
+			Expect(status().Phase).To(Equal(webappsv1.RolloutPaused))
+		})
+	})
+})
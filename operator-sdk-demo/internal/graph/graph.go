@@ -0,0 +1,202 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package graph is a small reconcile engine. The children of a custom
+// resource are nodes that list the nodes they need and may say when they
+// are ready; Run works out which nodes can be applied now, applies them and
+// returns the node everything else is waiting on. Controllers describe
+// their children as a graph instead of a hand written sequence of gates.
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// MinBackoff is the first requeue delay of a blocked reconcile.
+	MinBackoff = 5 * time.Second
+	// MaxBackoff caps the requeue delay of a blocked reconcile.
+	MaxBackoff = 5 * time.Minute
+)
+
+// ReadyFunc tells whether a node is ready and, if not, why.
+type ReadyFunc func(ctx context.Context) (ready bool, reason string, err error)
+
+// Node is one child, or a group of children applied together.
+type Node struct {
+	// Name identifies the node in DependsOn and in the Result.
+	Name string
+	// DependsOn are the nodes that have to be ready first.
+	DependsOn []string
+	// Apply creates or updates the children. It may be nil for a node
+	// that only waits for something.
+	Apply func(ctx context.Context) error
+	// Ready is called after Apply. A nil Ready counts as ready.
+	Ready ReadyFunc
+}
+
+// Task wraps a step that does its work and says whether it finished in the
+// same call, like the migration helpers of the controller. The node stays
+// not ready, with reason, until fn returns true.
+func Task(name, reason string, fn func(ctx context.Context) (bool, error), dependsOn ...string) Node {
+	finished := false
+	return Node{
+		Name:      name,
+		DependsOn: dependsOn,
+		Apply: func(ctx context.Context) error {
+			var err error
+			finished, err = fn(ctx)
+			return err
+		},
+		Ready: func(context.Context) (bool, string, error) {
+			return finished, reason, nil
+		},
+	}
+}
+
+// Graph holds the nodes of one reconcile. Use the zero value.
+type Graph struct {
+	nodes []Node
+}
+
+// Add adds nodes to the graph; a node may depend on one added after it.
+func (g *Graph) Add(nodes ...Node) {
+	g.nodes = append(g.nodes, nodes...)
+}
+
+// Order sorts the nodes so that every node comes after its dependencies,
+// otherwise keeping the order of Add. Duplicate names, dependencies on
+// missing nodes and cycles are errors.
+func (g *Graph) Order() ([]Node, error) {
+	position := make(map[string]int, len(g.nodes))
+	for i, n := range g.nodes {
+		if _, dup := position[n.Name]; dup {
+			return nil, fmt.Errorf("duplicate node %s", n.Name)
+		}
+		position[n.Name] = i
+	}
+	indegree := make([]int, len(g.nodes))
+	next := make([][]int, len(g.nodes))
+	for i, n := range g.nodes {
+		for _, dep := range n.DependsOn {
+			j, found := position[dep]
+			if !found {
+				return nil, fmt.Errorf("node %s depends on %s, which does not exist", n.Name, dep)
+			}
+			indegree[i]++
+			next[j] = append(next[j], i)
+		}
+	}
+
+	sorted := make([]Node, 0, len(g.nodes))
+	placed := make([]bool, len(g.nodes))
+	for len(sorted) < len(g.nodes) {
+		pick := -1
+		for i := range g.nodes {
+			if !placed[i] && indegree[i] == 0 {
+				pick = i
+				break
+			}
+		}
+		if pick < 0 {
+			var cycle []string
+			for i, n := range g.nodes {
+				if !placed[i] {
+					cycle = append(cycle, n.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between %v", cycle)
+		}
+		placed[pick] = true
+		sorted = append(sorted, g.nodes[pick])
+		for _, i := range next[pick] {
+			indegree[i]--
+		}
+	}
+	return sorted, nil
+}
+
+// Blocked names a node that is not ready and why.
+type Blocked struct {
+	Node   string
+	Reason string
+}
+
+// Result is the outcome of Run.
+type Result struct {
+	// Applied are the nodes that were applied, in the order of the run.
+	Applied []string
+	// Waiting are the nodes skipped for a dependency that is not ready.
+	Waiting []string
+	// Blocked is the earliest node that was not ready, or nil.
+	Blocked *Blocked
+}
+
+// Run goes through the nodes in dependency order. Nodes whose dependencies
+// are all ready are applied, the others are skipped and so are the nodes
+// after them; independent nodes carry on. Run returns at the first error,
+// prefixed with the node it came from.
+func (g *Graph) Run(ctx context.Context) (*Result, error) {
+	sorted, err := g.Order()
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{}
+	ready := make(map[string]bool, len(sorted))
+	for _, n := range sorted {
+		if !allReady(ready, n.DependsOn) {
+			res.Waiting = append(res.Waiting, n.Name)
+			continue
+		}
+		if n.Apply != nil {
+			if err := n.Apply(ctx); err != nil {
+				return res, fmt.Errorf("%s: %w", n.Name, err)
+			}
+		}
+		res.Applied = append(res.Applied, n.Name)
+
+		ok, reason := true, ""
+		if n.Ready != nil {
+			if ok, reason, err = n.Ready(ctx); err != nil {
+				return res, fmt.Errorf("%s: %w", n.Name, err)
+			}
+		}
+		ready[n.Name] = ok
+		if !ok && res.Blocked == nil {
+			res.Blocked = &Blocked{Node: n.Name, Reason: reason}
+		}
+	}
+	return res, nil
+}
+
+func allReady(ready map[string]bool, names []string) bool {
+	for _, name := range names {
+		if !ready[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// Backoff returns how long to wait before looking at a reconcile again
+// that has been blocked for the given time: as long as it has been blocked
+// already, between MinBackoff and MaxBackoff, so each check doubles the
+// wait. A change to a watched child still triggers a reconcile right away.
+func Backoff(blocked time.Duration) time.Duration {
+	return min(max(blocked, MinBackoff), MaxBackoff)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"my.domain/demo/internal/graph"
+)
+
+var _ = Describe("Graph", func() {
+	var (
+		ctx     = context.Background()
+		applied []string
+		notDone map[string]string
+	)
+
+	// step records its name when applied and is ready unless notDone has
+	// a reason for it.
+	step := func(name string, deps ...string) graph.Node {
+		return graph.Node{
+			Name:      name,
+			DependsOn: deps,
+			Apply: func(context.Context) error {
+				applied = append(applied, name)
+				return nil
+			},
+			Ready: func(context.Context) (bool, string, error) {
+				reason, waiting := notDone[name]
+				return !waiting, reason, nil
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		applied, notDone = nil, map[string]string{}
+	})
+
+	It("orders dependencies first and keeps the rest as added", func() {
+		var g graph.Graph
+		g.Add(step("frontend", "migrations"), step("database"), step("migrations", "database"), step("secret"))
+		order, err := g.Order()
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, n := range order {
+			names = append(names, n.Name)
+		}
+		Expect(names).To(Equal([]string{"database", "migrations", "frontend", "secret"}))
+	})
+
+	It("refuses broken graphs", func() {
+		var missing graph.Graph
+		missing.Add(step("frontend", "database"))
+		_, err := missing.Order()
+		Expect(err).To(MatchError(ContainSubstring("does not exist")))
+
+		var dup graph.Graph
+		dup.Add(step("database"), step("database"))
+		_, err = dup.Run(ctx)
+		Expect(err).To(MatchError("duplicate node database"))
+
+		var cycle graph.Graph
+		cycle.Add(step("a", "b"), step("b", "a"))
+		_, err = cycle.Run(ctx)
+		Expect(err).To(MatchError(ContainSubstring("dependency cycle between [a b]")))
+		Expect(applied).To(BeEmpty())
+	})
+
+	It("skips the dependents of a node that is not ready", func() {
+		notDone["database"] = "starting"
+		var g graph.Graph
+		g.Add(step("database"), step("migrations", "database"), step("frontend", "migrations"), step("secret"))
+		res, err := g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(Equal([]string{"database", "secret"}))
+		Expect(res.Waiting).To(Equal([]string{"migrations", "frontend"}))
+		Expect(res.Blocked).To(Equal(&graph.Blocked{Node: "database", Reason: "starting"}))
+
+		delete(notDone, "database")
+		applied = nil
+		res, err = g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(Equal([]string{"database", "migrations", "frontend", "secret"}))
+		Expect(res.Blocked).To(BeNil())
+	})
+
+	It("stops on errors", func() {
+		var g graph.Graph
+		g.Add(graph.Node{Name: "database", Apply: func(context.Context) error { return errors.New("denied") }}, step("secret"))
+		_, err := g.Run(ctx)
+		Expect(err).To(MatchError("database: denied"))
+		Expect(applied).To(BeEmpty())
+	})
+
+	It("waits for a task to finish", func() {
+		finished := false
+		var g graph.Graph
+		g.Add(graph.Task("migrations", "job running", func(context.Context) (bool, error) { return finished, nil }),
+			step("frontend", "migrations"))
+		res, err := g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Blocked).To(Equal(&graph.Blocked{Node: "migrations", Reason: "job running"}))
+		Expect(res.Waiting).To(Equal([]string{"frontend"}))
+
+		finished = true
+		res, err = g.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Applied).To(Equal([]string{"migrations", "frontend"}))
+	})
+
+	It("backs off within bounds", func() {
+		Expect(graph.Backoff(time.Second)).To(Equal(graph.MinBackoff))
+		Expect(graph.Backoff(30 * time.Second)).To(Equal(30 * time.Second))
+		Expect(graph.Backoff(24 * time.Hour)).To(Equal(graph.MaxBackoff))
+	})
+})
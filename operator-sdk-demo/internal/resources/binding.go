@@ -0,0 +1,207 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// Well-known servicebinding.io entries of the binding Secret.
+const (
+	BindingType     = "type"
+	BindingProvider = "provider"
+	BindingHost     = "host"
+	BindingPort     = "port"
+	BindingDatabase = "database"
+	BindingUsername = "username"
+	BindingPassword = "password"
+	BindingURI      = "uri"
+)
+
+const (
+	serviceBindingRoot = "/bindings"
+	bindingVolumeName  = "database-binding"
+)
+
+// bindingTypes are the servicebinding.io types per engine, the type is also
+// the uri scheme and the directory of the mounted binding.
+var bindingTypes = map[webappsv1.DatabaseEngine]string{
+	webappsv1.DatabaseEngineMySQL:      "mysql",
+	webappsv1.DatabaseEngineMariaDB:    "mariadb",
+	webappsv1.DatabaseEnginePostgreSQL: "postgresql",
+}
+
+// defaultDatabasePorts apply when spec.database.ports is empty.
+var defaultDatabasePorts = map[string]int32{
+	"mysql":      3306,
+	"mariadb":    3306,
+	"postgresql": 5432,
+}
+
+// bindingEnvNames are the default names in Env mode, in injection order.
+var bindingEnvNames = []struct{ key, env string }{
+	{BindingHost, "DB_HOST"},
+	{BindingPort, "DB_PORT"},
+	{BindingDatabase, "DB_NAME"},
+	{BindingUsername, "DB_USER"},
+	{BindingPassword, "DB_PASSWORD"},
+}
+
+func BindingSecretName(webService *webappsv1.WebService) string {
+	return webService.Name + "-db-binding"
+}
+
+// DatabaseBindingType is the servicebinding.io type of the database of
+// webService, the engine in lower case.
+func DatabaseBindingType(webService *webappsv1.WebService) string {
+	if bindingType, ok := bindingTypes[webService.Spec.DatabaseSpec().Engine]; ok {
+		return bindingType
+	}
+	return "mysql"
+}
+
+// NewDatabaseBindingSecret lays out the database connection as a
+// servicebinding.io binding of the engine type, e.g. mysql or postgresql.
+func NewDatabaseBindingSecret(webService *webappsv1.WebService, cfg *config.OperatorConfig, username, password string) *corev1.Secret {
+	bindingType := DatabaseBindingType(webService)
+	host := webService.Spec.DatabaseSpec().Name
+	port := strconv.Itoa(int(databasePort(webService)))
+	uri := &url.URL{
+		Scheme: bindingType,
+		User:   url.UserPassword(username, password),
+		Host:   net.JoinHostPort(host, port),
+		Path:   "/" + cfg.Mysql.Database,
+	}
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: BindingSecretName(webService), Namespace: webService.Namespace,
+			Labels:      cfg.WithLabels(nil),
+			Annotations: cfg.WithAnnotations(nil),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(webService, schema.GroupVersionKind{
+					Group:   webappsv1.GroupVersion.Group,
+					Version: webappsv1.GroupVersion.Version,
+					Kind:    "WebService",
+				}),
+			},
+		},
+		Type: corev1.SecretType("servicebinding.io/" + bindingType),
+		Data: map[string][]byte{
+			BindingType:     []byte(bindingType),
+			BindingProvider: []byte("webservice-operator"),
+			BindingHost:     []byte(host),
+			BindingPort:     []byte(port),
+			BindingDatabase: []byte(cfg.Mysql.Database),
+			BindingUsername: []byte(username),
+			BindingPassword: []byte(password),
+			BindingURI:      []byte(uri.String()),
+		},
+	}
+}
+
+// databaseBinding returns the effective binding of component c, nil when
+// the WebService has no database to bind.
+func databaseBinding(webService *webappsv1.WebService, c Component) *webappsv1.DatabaseBindingSpec {
+	if webService.Spec.DatabaseSpec() == nil {
+		return nil
+	}
+	binding := &webappsv1.DatabaseBindingSpec{}
+	if c.DatabaseBinding != nil {
+		binding = c.DatabaseBinding.DeepCopy()
+	}
+	if binding.Mode == "" {
+		binding.Mode = webappsv1.DatabaseBindingEnv
+	}
+	return binding
+}
+
+func databasePort(webService *webappsv1.WebService) int32 {
+	if len(webService.Spec.DatabaseSpec().Ports) == 0 {
+		return defaultDatabasePorts[DatabaseBindingType(webService)]
+	}
+	return webService.Spec.DatabaseSpec().Ports[0].Port
+}
+
+// newBindingEnv builds the injected variables. Credentials are always read
+// through a secretKeyRef, never copied into the pod spec.
+func newBindingEnv(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) []corev1.EnvVar {
+	binding := databaseBinding(webService, c)
+	if binding == nil {
+		return nil
+	}
+	name := func(key, def string) string {
+		if env, ok := binding.EnvNames[key]; ok && env != "" {
+			return env
+		}
+		return def
+	}
+	secretRef := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: BindingSecretName(webService)},
+			Key:                  key,
+		}}
+	}
+
+	envs := []corev1.EnvVar{}
+	if binding.Mount {
+		envs = append(envs, corev1.EnvVar{Name: "SERVICE_BINDING_ROOT", Value: serviceBindingRoot})
+	}
+	switch binding.Mode {
+	case webappsv1.DatabaseBindingEnv:
+		plain := map[string]string{
+			BindingHost:     webService.Spec.DatabaseSpec().Name,
+			BindingPort:     strconv.Itoa(int(databasePort(webService))),
+			BindingDatabase: cfg.Mysql.Database,
+		}
+		for _, e := range bindingEnvNames {
+			if value, ok := plain[e.key]; ok {
+				envs = append(envs, corev1.EnvVar{Name: name(e.key, e.env), Value: value})
+			} else {
+				envs = append(envs, corev1.EnvVar{Name: name(e.key, e.env), ValueFrom: secretRef(e.key)})
+			}
+		}
+	case webappsv1.DatabaseBindingDSN:
+		envs = append(envs, corev1.EnvVar{Name: name(BindingURI, "DATABASE_URL"), ValueFrom: secretRef(BindingURI)})
+	}
+	return envs
+}
+
+func newBindingVolumes(webService *webappsv1.WebService, c Component) ([]corev1.Volume, []corev1.VolumeMount) {
+	if binding := databaseBinding(webService, c); binding == nil || !binding.Mount {
+		return nil, nil
+	}
+	volumes := []corev1.Volume{
+		{
+			Name: bindingVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: BindingSecretName(webService)},
+			},
+		},
+	}
+	mounts := []corev1.VolumeMount{
+		{Name: bindingVolumeName, MountPath: serviceBindingRoot + "/" + DatabaseBindingType(webService), ReadOnly: true},
+	}
+	return volumes, mounts
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/resources"
+)
+
+var _ = Describe("Config checksums", func() {
+	It("lists the ConfigMaps and Secrets a pod reads", func() {
+		spec := &corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init", EnvFrom: []corev1.EnvFromSource{
+				{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "init-env"}}},
+			}}},
+			Containers: []corev1.Container{{
+				Name: "nginx",
+				Env: []corev1.EnvVar{
+					{Name: "PLAIN", Value: "1"},
+					{Name: "LEVEL", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}, Key: "level"}}},
+					{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "web-token"}, Key: "token"}}},
+				},
+				EnvFrom: []corev1.EnvFromSource{
+					{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-env"}}},
+				},
+			}},
+			Volumes: []corev1.Volume{
+				{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "web-files"}}}},
+				{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "web-tls"}}},
+				{Name: "projected", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+					{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "web-ca"}}},
+					{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "web-key"}}},
+				}}}},
+				{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			},
+		}
+
+		configMaps, secrets := resources.PodConfigRefs(spec)
+		Expect(sets.List(configMaps)).To(Equal([]string{"init-env", "web-ca", "web-config", "web-files"}))
+		Expect(sets.List(secrets)).To(Equal([]string{"web-env", "web-key", "web-tls", "web-token"}))
+	})
+
+	It("puts the checksum on the pod template", func() {
+		webService := newWebService()
+		c := resources.PrimaryComponent(webService)
+		deploy := resources.NewComponentDeployment(webService, config.Default(), c)
+		Expect(deploy.Spec.Template.Annotations).NotTo(HaveKey(resources.ConfigChecksumAnnotation))
+
+		c.ConfigChecksum = "0123456789abcdef"
+		deploy = resources.NewComponentDeployment(webService, config.Default(), c)
+		Expect(deploy.Spec.Template.Annotations).To(HaveKeyWithValue(resources.ConfigChecksumAnnotation, "0123456789abcdef"))
+	})
+})
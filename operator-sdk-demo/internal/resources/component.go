@@ -0,0 +1,131 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+// ComponentLabel carries the component name on the Deployment, Service,
+// Ingress and HTTPRoute of a component.
+const ComponentLabel = "webapps.my.domain/component"
+
+// WebServiceLabel carries the WebService name on the same objects.
+const WebServiceLabel = "webapps.my.domain/webservice"
+
+// Component is an application tier of a WebService.
+type Component struct {
+	*webappsv1.WebServiceFrontendSpec
+	// ObjectName is shared by the Deployment, Service, Ingress and
+	// HTTPRoute of the component.
+	ObjectName string
+	// ConfigChecksum is the hash of the ConfigMaps and Secrets the pods
+	// read, set by the controller before the Deployments are built. It is
+	// part of the pod template and therefore of the Revision.
+	ConfigChecksum string
+}
+
+// PodLabels select the pods of the component.
+func (c Component) PodLabels() map[string]string {
+	return map[string]string{"app": c.ObjectName}
+}
+
+func (c Component) objectLabels(webService *webappsv1.WebService) map[string]string {
+	return map[string]string{WebServiceLabel: webService.Name, ComponentLabel: c.Name}
+}
+
+// IsFrontend reports whether c is spec.frontend.
+func (c Component) IsFrontend(webService *webappsv1.WebService) bool {
+	return c.WebServiceFrontendSpec == webService.Spec.Frontend
+}
+
+// Components returns spec.frontend, named as in earlier releases, followed
+// by spec.components.
+func Components(webService *webappsv1.WebService) []Component {
+	components := []Component{}
+	if frontend := webService.Spec.Frontend; frontend != nil {
+		components = append(components, Component{WebServiceFrontendSpec: frontend, ObjectName: frontend.Name})
+	}
+	for i := range webService.Spec.Components {
+		spec := &webService.Spec.Components[i]
+		components = append(components, Component{WebServiceFrontendSpec: spec, ObjectName: webService.Name + "-" + spec.Name})
+	}
+	return components
+}
+
+// PrimaryComponent is the tier the schema migrations are run for.
+func PrimaryComponent(webService *webappsv1.WebService) Component {
+	return Components(webService)[0]
+}
+
+// OrderComponents puts every component after the ones it depends on and
+// keeps the spec order otherwise. It fails on empty or duplicate names,
+// unknown dependencies and cycles.
+func OrderComponents(components []Component) ([]Component, error) {
+	known := map[string]bool{}
+	for _, c := range components {
+		if c.Name == "" {
+			return nil, fmt.Errorf("every component needs a name")
+		}
+		if known[c.Name] {
+			return nil, fmt.Errorf("component name %q is used twice", c.Name)
+		}
+		known[c.Name] = true
+	}
+	for _, c := range components {
+		for _, dep := range c.DependsOn {
+			if !known[dep] {
+				return nil, fmt.Errorf("component %q depends on %q, which does not exist", c.Name, dep)
+			}
+		}
+	}
+
+	ordered := make([]Component, 0, len(components))
+	done := map[string]bool{}
+	for len(ordered) < len(components) {
+		before := len(ordered)
+		for _, c := range components {
+			if done[c.Name] || !dependenciesDone(c, done) {
+				continue
+			}
+			ordered = append(ordered, c)
+			done[c.Name] = true
+		}
+		if len(ordered) == before {
+			var cycle []string
+			for _, c := range components {
+				if !done[c.Name] {
+					cycle = append(cycle, c.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between components %s", strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func dependenciesDone(c Component, done map[string]bool) bool {
+	for _, dep := range c.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
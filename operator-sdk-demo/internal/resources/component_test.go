@@ -0,0 +1,90 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/resources"
+)
+
+var _ = Describe("Components", func() {
+	component := func(name string, dependsOn ...string) webappsv1.WebServiceFrontendSpec {
+		one := int32(1)
+		return webappsv1.WebServiceFrontendSpec{Name: name, Size: &one, Image: name, DependsOn: dependsOn}
+	}
+	names := func(components []resources.Component) []string {
+		var names []string
+		for _, c := range components {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	It("lists the frontend first under its earlier name", func() {
+		webService := newWebService()
+		webService.Spec.Components = []webappsv1.WebServiceFrontendSpec{component("api"), component("worker")}
+
+		components := resources.Components(webService)
+		Expect(names(components)).To(Equal([]string{"nginx", "api", "worker"}))
+		Expect(components[0].ObjectName).To(Equal("nginx"))
+		Expect(components[0].IsFrontend(webService)).To(BeTrue())
+		Expect(components[1].ObjectName).To(Equal("shop-api"))
+		Expect(components[1].IsFrontend(webService)).To(BeFalse())
+		Expect(components[1].PodLabels()).To(Equal(map[string]string{"app": "shop-api"}))
+		Expect(resources.PrimaryComponent(webService).Name).To(Equal("nginx"))
+
+		webService.Spec.Frontend = nil
+		Expect(resources.PrimaryComponent(webService).Name).To(Equal("api"))
+	})
+
+	DescribeTable("orders the components by their dependencies",
+		func(specs []webappsv1.WebServiceFrontendSpec, order []string, failure string) {
+			webService := newWebService()
+			webService.Spec.Frontend = nil
+			webService.Spec.Components = specs
+
+			ordered, err := resources.OrderComponents(resources.Components(webService))
+			if failure != "" {
+				Expect(err).To(MatchError(failure))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names(ordered)).To(Equal(order))
+		},
+		Entry("in spec order without dependencies",
+			[]webappsv1.WebServiceFrontendSpec{component("web"), component("api"), component("worker")},
+			[]string{"web", "api", "worker"}, ""),
+		Entry("with dependencies first",
+			[]webappsv1.WebServiceFrontendSpec{component("web", "api"), component("api", "cache"), component("worker"), component("cache")},
+			[]string{"worker", "cache", "api", "web"}, ""),
+		Entry("unless a name is empty",
+			[]webappsv1.WebServiceFrontendSpec{component("")},
+			nil, "every component needs a name"),
+		Entry("unless a name is used twice",
+			[]webappsv1.WebServiceFrontendSpec{component("web"), component("web")},
+			nil, `component name "web" is used twice`),
+		Entry("unless a dependency does not exist",
+			[]webappsv1.WebServiceFrontendSpec{component("web", "api")},
+			nil, `component "web" depends on "api", which does not exist`),
+		Entry("unless the dependencies form a cycle",
+			[]webappsv1.WebServiceFrontendSpec{component("worker"), component("web", "api"), component("api", "web")},
+			nil, "dependency cycle between components web, api"),
+	)
+})
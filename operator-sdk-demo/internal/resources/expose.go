@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// HTTPRouteGVK is the Gateway API kind of NewHTTPRoute. The Gateway API
+// types are not a dependency, routes are unstructured.
+var HTTPRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// NewHTTPRouteObject is an empty HTTPRoute to get, watch or delete.
+func NewHTTPRouteObject() *unstructured.Unstructured {
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(HTTPRouteGVK)
+	return route
+}
+
+// ExposePath defaults an empty expose path to "/".
+func ExposePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func ownerReferences(webService *webappsv1.WebService) []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		*metav1.NewControllerRef(webService, schema.GroupVersionKind{
+			Group:   webappsv1.GroupVersion.Group,
+			Version: webappsv1.GroupVersion.Version,
+			Kind:    "WebService",
+		}),
+	}
+}
+
+// NewComponentIngress routes expose.ingress of c to the component Service.
+func NewComponentIngress(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) *networkingv1.Ingress {
+	spec := c.Expose.Ingress
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{Name: c.ObjectName, Namespace: webService.Namespace,
+			Labels:          cfg.WithLabels(c.objectLabels(webService)),
+			Annotations:     cfg.WithAnnotations(spec.Annotations),
+			OwnerReferences: ownerReferences(webService),
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: spec.IngressClassName,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: spec.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{
+							{
+								Path:     ExposePath(spec.Path),
+								PathType: &pathType,
+								Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+									Name: c.ObjectName,
+									Port: networkingv1.ServiceBackendPort{Number: c.Ports[0].Port},
+								}},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{spec.Host}, SecretName: spec.TLSSecretName}}
+	}
+	return ingress
+}
+
+// NewComponentHTTPRoute attaches the component Service to the Gateways of
+// expose.httpRoute.
+func NewComponentHTTPRoute(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) *unstructured.Unstructured {
+	spec := c.Expose.HTTPRoute
+	parentRefs := make([]interface{}, 0, len(spec.ParentRefs))
+	for _, ref := range spec.ParentRefs {
+		parentRef := map[string]interface{}{"name": ref.Name}
+		if ref.Namespace != "" {
+			parentRef["namespace"] = ref.Namespace
+		}
+		if ref.SectionName != "" {
+			parentRef["sectionName"] = ref.SectionName
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+	routeSpec := map[string]interface{}{
+		"parentRefs": parentRefs,
+		"rules": []interface{}{
+			map[string]interface{}{
+				"matches": []interface{}{
+					map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": ExposePath(spec.Path)}},
+				},
+				"backendRefs": []interface{}{
+					map[string]interface{}{"name": c.ObjectName, "port": int64(c.Ports[0].Port)},
+				},
+			},
+		},
+	}
+	if len(spec.Hostnames) > 0 {
+		hostnames := make([]interface{}, 0, len(spec.Hostnames))
+		for _, hostname := range spec.Hostnames {
+			hostnames = append(hostnames, hostname)
+		}
+		routeSpec["hostnames"] = hostnames
+	}
+
+	route := NewHTTPRouteObject()
+	route.SetName(c.ObjectName)
+	route.SetNamespace(webService.Namespace)
+	route.SetLabels(cfg.WithLabels(c.objectLabels(webService)))
+	route.SetAnnotations(cfg.WithAnnotations(nil))
+	route.SetOwnerReferences(ownerReferences(webService))
+	route.Object["spec"] = routeSpec
+	return route
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/resources"
+)
+
+var _ = Describe("Exposure", func() {
+	var (
+		webService *webappsv1.WebService
+		cfg        *config.OperatorConfig
+	)
+
+	BeforeEach(func() {
+		webService = newWebService()
+		cfg = config.Default()
+	})
+
+	It("routes the Ingress host to the first port of the Service", func() {
+		webService.Spec.Frontend.Expose = &webappsv1.ExposeSpec{Ingress: &webappsv1.IngressSpec{
+			Host:          "shop.example.com",
+			TLSSecretName: "shop-tls",
+			Annotations:   map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "8m"},
+		}}
+		c := resources.PrimaryComponent(webService)
+
+		ingress := resources.NewComponentIngress(webService, cfg, c)
+		Expect(ingress.Name).To(Equal("nginx"))
+		Expect(ingress.OwnerReferences).To(ConsistOf(HaveField("UID", webService.UID)))
+		Expect(ingress.Annotations).To(HaveKey("nginx.ingress.kubernetes.io/proxy-body-size"))
+		Expect(ingress.Spec.TLS).To(ConsistOf(networkingv1.IngressTLS{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"}))
+		Expect(ingress.Spec.Rules).To(HaveLen(1))
+		Expect(ingress.Spec.Rules[0].Host).To(Equal("shop.example.com"))
+		path := ingress.Spec.Rules[0].HTTP.Paths[0]
+		Expect(path.Path).To(Equal("/"))
+		Expect(path.Backend.Service.Name).To(Equal("nginx"))
+		Expect(path.Backend.Service.Port.Number).To(Equal(int32(80)))
+	})
+
+	It("leaves TLS off without a certificate Secret", func() {
+		webService.Spec.Frontend.Expose = &webappsv1.ExposeSpec{Ingress: &webappsv1.IngressSpec{Host: "shop.example.com", Path: "/shop"}}
+
+		ingress := resources.NewComponentIngress(webService, cfg, resources.PrimaryComponent(webService))
+		Expect(ingress.Spec.TLS).To(BeEmpty())
+		Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Path).To(Equal("/shop"))
+	})
+
+	It("attaches the HTTPRoute to the Gateways", func() {
+		webService.Spec.Frontend.Expose = &webappsv1.ExposeSpec{HTTPRoute: &webappsv1.HTTPRouteSpec{
+			ParentRefs: []webappsv1.GatewayParentRef{{Name: "public", Namespace: "gateways", SectionName: "https"}, {Name: "internal"}},
+			Hostnames:  []string{"shop.example.com"},
+			Path:       "/api",
+		}}
+
+		route := resources.NewComponentHTTPRoute(webService, cfg, resources.PrimaryComponent(webService))
+		Expect(route.GroupVersionKind()).To(Equal(resources.HTTPRouteGVK))
+		Expect(route.GetName()).To(Equal("nginx"))
+		Expect(route.GetOwnerReferences()).To(ConsistOf(HaveField("UID", webService.UID)))
+		parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+		Expect(parents).To(ConsistOf(
+			map[string]interface{}{"name": "public", "namespace": "gateways", "sectionName": "https"},
+			map[string]interface{}{"name": "internal"},
+		))
+		hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+		Expect(hostnames).To(Equal([]string{"shop.example.com"}))
+		rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+		Expect(rules).To(HaveLen(1))
+		rule := rules[0].(map[string]interface{})
+		Expect(rule["matches"]).To(ConsistOf(HaveKeyWithValue("path", map[string]interface{}{"type": "PathPrefix", "value": "/api"})))
+		Expect(rule["backendRefs"]).To(ConsistOf(map[string]interface{}{"name": "nginx", "port": int64(80)}))
+	})
+
+	It("keeps the node port off a ClusterIP Service", func() {
+		webService.Spec.Frontend.Ports[0].NodePort = 30080
+		webService.Spec.Frontend.Expose = &webappsv1.ExposeSpec{ServiceType: corev1.ServiceTypeClusterIP}
+
+		svc := resources.NewComponentService(webService, cfg, resources.PrimaryComponent(webService))
+		Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+		Expect(svc.Spec.Ports).To(ConsistOf(HaveField("NodePort", int32(0))))
+		Expect(webService.Spec.Frontend.Ports[0].NodePort).To(Equal(int32(30080)), "the spec is not modified")
+	})
+
+	It("defaults to a NodePort Service", func() {
+		Expect(resources.ComponentServiceType(resources.PrimaryComponent(webService))).To(Equal(corev1.ServiceTypeNodePort))
+	})
+})
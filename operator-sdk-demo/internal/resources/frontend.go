@@ -11,14 +11,38 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
 )
 
-func NewFrontendDeployment(webService *webappsv1.WebService) *appsv1.Deployment {
-	myLabels := map[string]string{"app": webService.Spec.Frontend.Name}
+// NewComponentDeployment runs the pods of component c in the blue slot.
+func NewComponentDeployment(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) *appsv1.Deployment {
+	return NewComponentSlotDeployment(webService, cfg, c, SlotBlue, c.Size)
+}
+
+// NewComponentSlotDeployment runs the current pod template of c in the
+// Deployment of slot with replicas.
+func NewComponentSlotDeployment(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component, slot string, replicas *int32) *appsv1.Deployment {
+	myLabels := c.SlotPodLabels(slot)
 	mySelector := &metav1.LabelSelector{MatchLabels: myLabels}
+	objectLabels := c.objectLabels(webService)
+	for k, v := range myLabels {
+		objectLabels[k] = v
+	}
+	template := componentPodTemplate(webService, cfg, c)
+	revision := templateRevision(template)
+	for k, v := range myLabels {
+		template.Labels[k] = v
+	}
+	// On the pods too, to tell the pods of a rollout from older ones.
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[RevisionAnnotation] = revision
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
-		ObjectMeta: metav1.ObjectMeta{Name: webService.Spec.Frontend.Name, Namespace: webService.Namespace,
+		ObjectMeta: metav1.ObjectMeta{Name: c.SlotName(slot), Namespace: webService.Namespace,
+			Labels:      cfg.WithLabels(objectLabels),
+			Annotations: cfg.WithAnnotations(map[string]string{RevisionAnnotation: revision}),
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(webService, schema.GroupVersionKind{
 					Group:   webappsv1.GroupVersion.Group,
@@ -28,45 +52,102 @@ func NewFrontendDeployment(webService *webappsv1.WebService) *appsv1.Deployment
 			},
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: webService.Spec.Frontend.Size,
+			Replicas: replicas,
 			Selector: mySelector,
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{Labels: myLabels},
-				Spec: corev1.PodSpec{
-					Containers: newFrontendContainers(webService),
-				},
-			},
+			Template: template,
 		},
 	}
 
 	return deployment
 }
 
-func newFrontendContainers(webService *webappsv1.WebService) []corev1.Container {
+// componentPodTemplate is the pod template of c with its podTemplate
+// merged in, without the slot labels. A podTemplate that does not merge
+// is refused by ValidateComponentPodTemplates before this is applied.
+func componentPodTemplate(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) corev1.PodTemplateSpec {
+	template, _ := MergePodTemplate(newComponentPodTemplate(webService, cfg, c), c.PodTemplate, componentContainerName(webService, cfg, c))
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	if c.ConfigChecksum != "" {
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[ConfigChecksumAnnotation] = c.ConfigChecksum
+	}
+	return template
+}
+
+// newComponentPodTemplate is the generated pod template of c, before its
+// podTemplate is merged in.
+func newComponentPodTemplate(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) corev1.PodTemplateSpec {
+	bindingVolumes, _ := newBindingVolumes(webService, c)
+	podSecurity, _ := componentSecurityContexts(c)
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: cfg.WithLabels(c.ServingPodLabels(webService)), Annotations: cfg.WithAnnotations(nil)},
+		Spec: corev1.PodSpec{
+			SecurityContext:  podSecurity,
+			Containers:       newComponentContainers(webService, cfg, c),
+			ImagePullSecrets: cfg.ImagePullSecrets,
+			Volumes:          bindingVolumes,
+		},
+	}
+}
+
+// componentContainerName is the name of the main container of c.
+// spec.frontend keeps the configured container name, components use their
+// own name.
+func componentContainerName(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) string {
+	if c.IsFrontend(webService) {
+		return cfg.Frontend.ContainerName
+	}
+	return c.Name
+}
+
+func newComponentContainers(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) []corev1.Container {
 	containerPorts := []corev1.ContainerPort{}
-	for _, svcPort := range webService.Spec.Frontend.Ports {
+	for _, svcPort := range c.Ports {
 		cPort := corev1.ContainerPort{}
 		cPort.ContainerPort = svcPort.TargetPort.IntVal
 		containerPorts = append(containerPorts, cPort)
 	}
 
+	_, bindingMounts := newBindingVolumes(webService, c)
+	_, security := componentSecurityContexts(c)
 	containers := []corev1.Container{
 		{
-			Name:            "nginx",
-			Image:           webService.Spec.Frontend.Image,
+			Name:            componentContainerName(webService, cfg, c),
+			Image:           cfg.Image(config.ComponentFrontend, c.Image),
 			ImagePullPolicy: corev1.PullIfNotPresent,
-			Resources:       webService.Spec.Frontend.Resources,
-			Env:             webService.Spec.Frontend.Envs,
+			Resources:       cfg.ResourcesFor(config.ComponentFrontend, c.Resources),
+			// User Envs are appended after the binding and win on a name clash.
+			Env:             append(newBindingEnv(webService, cfg, c), c.Envs...),
 			Ports:           containerPorts,
+			VolumeMounts:    bindingMounts,
+			SecurityContext: security,
 		},
 	}
+	SetProbes(&containers[0], componentProbes(c), c.Probes)
 	return containers
 }
 
-func NewFrontendService(webService *webappsv1.WebService) *corev1.Service {
+// NewComponentService is the Service of component c.
+func NewComponentService(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) *corev1.Service {
+	serviceType := ComponentServiceType(c)
+	ports := c.Ports
+	if serviceType == corev1.ServiceTypeClusterIP {
+		// nodePort is refused on a ClusterIP Service.
+		ports = make([]corev1.ServicePort, 0, len(c.Ports))
+		for _, port := range c.Ports {
+			port.NodePort = 0
+			ports = append(ports, port)
+		}
+	}
 	service := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
-		ObjectMeta: metav1.ObjectMeta{Name: webService.Spec.Frontend.Name, Namespace: webService.Namespace,
+		ObjectMeta: metav1.ObjectMeta{Name: c.ObjectName, Namespace: webService.Namespace,
+			Labels:      cfg.WithLabels(c.objectLabels(webService)),
+			Annotations: cfg.WithAnnotations(nil),
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(webService, schema.GroupVersionKind{
 					Group:   webappsv1.GroupVersion.Group,
@@ -76,11 +157,19 @@ func NewFrontendService(webService *webappsv1.WebService) *corev1.Service {
 			},
 		},
 		Spec: corev1.ServiceSpec{
-			Type:     corev1.ServiceTypeNodePort,
-			Ports:    webService.Spec.Frontend.Ports,
-			Selector: map[string]string{"app": webService.Spec.Frontend.Name},
+			Type:     serviceType,
+			Ports:    ports,
+			Selector: c.PodLabels(),
 		},
 	}
 
 	return service
 }
+
+// ComponentServiceType is expose.serviceType of c, NodePort when unset.
+func ComponentServiceType(c Component) corev1.ServiceType {
+	if expose := c.Expose; expose != nil && expose.ServiceType != "" {
+		return expose.ServiceType
+	}
+	return corev1.ServiceTypeNodePort
+}
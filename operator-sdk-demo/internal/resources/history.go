@@ -0,0 +1,138 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pmezard/go-difflib/difflib"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	webappsv1 "my.domain/demo/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// SpecHistoryLabel is set on the ControllerRevisions holding the past specs
+// of a WebService, its value is the WebService name.
+const SpecHistoryLabel = "webapps.my.domain/spec-history"
+
+// DefaultRevisionHistoryLimit applies when spec.revisionHistoryLimit is
+// unset.
+const DefaultRevisionHistoryLimit = 10
+
+// SpecHistorySelector selects the spec revisions of the WebService named
+// webService.
+func SpecHistorySelector(webService string) map[string]string {
+	return map[string]string{SpecHistoryLabel: webService}
+}
+
+// SnapshotSpec returns the form spec is recorded in and its hash. The
+// fields steering the history, rollbackTo and revisionHistoryLimit, are
+// not recorded.
+func SnapshotSpec(spec *webappsv1.WebServiceSpec) ([]byte, string, error) {
+	s := spec.DeepCopy()
+	s.RollbackTo = nil
+	s.RevisionHistoryLimit = nil
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:])[:10], nil
+}
+
+// NewSpecRevision records the spec of webService as revision number.
+func NewSpecRevision(webService *webappsv1.WebService, number int64) (*appsv1.ControllerRevision, error) {
+	data, hash, err := SnapshotSpec(&webService.Spec)
+	if err != nil {
+		return nil, err
+	}
+	return &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webService.Name + "-" + hash,
+			Namespace: webService.Namespace,
+			Labels: map[string]string{
+				WebServiceLabel:  webService.Name,
+				SpecHistoryLabel: webService.Name,
+			},
+			OwnerReferences: ownerReferences(webService),
+		},
+		Data:     runtime.RawExtension{Raw: data},
+		Revision: number,
+	}, nil
+}
+
+// RevisionSpec decodes the spec recorded in rev.
+func RevisionSpec(rev *appsv1.ControllerRevision) (*webappsv1.WebServiceSpec, error) {
+	spec := &webappsv1.WebServiceSpec{}
+	if err := json.Unmarshal(rev.Data.Raw, spec); err != nil {
+		return nil, fmt.Errorf("decoding revision %d (%s): %w", rev.Revision, rev.Name, err)
+	}
+	return spec, nil
+}
+
+// SortRevisions puts revs in ascending revision order.
+func SortRevisions(revs []appsv1.ControllerRevision) {
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Revision < revs[j].Revision })
+}
+
+// FindRevision returns the entry of revs numbered n, or nil.
+func FindRevision(revs []appsv1.ControllerRevision, n int64) *appsv1.ControllerRevision {
+	for i := range revs {
+		if revs[i].Revision == n {
+			return &revs[i]
+		}
+	}
+	return nil
+}
+
+// DiffRevisions renders the change from the spec in from to the spec in
+// to as a unified diff of their YAML. Equal specs give an empty string;
+// with a nil from every line of to is added.
+func DiffRevisions(from, to *appsv1.ControllerRevision) (string, error) {
+	a, fromName, err := revisionYAML(from)
+	if err != nil {
+		return "", err
+	}
+	b, toName, err := revisionYAML(to)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+}
+
+func revisionYAML(rev *appsv1.ControllerRevision) (string, string, error) {
+	if rev == nil {
+		return "", "/dev/null", nil
+	}
+	data, err := yaml.JSONToYAML(rev.Data.Raw)
+	if err != nil {
+		return "", "", fmt.Errorf("decoding revision %d (%s): %w", rev.Revision, rev.Name, err)
+	}
+	return string(data), fmt.Sprintf("revision %d (%s)", rev.Revision, rev.Name), nil
+}
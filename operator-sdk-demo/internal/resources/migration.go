@@ -0,0 +1,56 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// MigrationImage is the image spec.migrations runs in, the image of the
+// primary component unless one is set.
+func MigrationImage(webService *webappsv1.WebService, cfg *config.OperatorConfig) string {
+	if image := webService.Spec.Migrations.Image; image != "" {
+		return image
+	}
+	return cfg.Image(config.ComponentFrontend, PrimaryComponent(webService).Image)
+}
+
+// NewMigrationPod runs the command of spec.migrations with the
+// environment, binding volumes and security contexts of the primary
+// component, so that the migration tool finds the database the way the
+// application does. Only the containers, volumes and security context of
+// the returned spec are set.
+func NewMigrationPod(webService *webappsv1.WebService, cfg *config.OperatorConfig) corev1.PodSpec {
+	primary := PrimaryComponent(webService)
+	volumes, mounts := newBindingVolumes(webService, primary)
+	podSecurity, security := componentSecurityContexts(primary)
+	return corev1.PodSpec{
+		SecurityContext: podSecurity,
+		Containers: []corev1.Container{{
+			Name:            "migrations",
+			Image:           MigrationImage(webService, cfg),
+			Command:         webService.Spec.Migrations.Command,
+			Args:            webService.Spec.Migrations.Args,
+			Env:             append(newBindingEnv(webService, cfg, primary), primary.Envs...),
+			VolumeMounts:    mounts,
+			SecurityContext: security,
+		}},
+		Volumes: volumes,
+	}
+}
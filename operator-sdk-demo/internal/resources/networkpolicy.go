@@ -0,0 +1,61 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// NewComponentNetworkPolicy opens the target ports of component c to every
+// client and isolates the pods otherwise. A component without ports gets
+// no ingress at all.
+func NewComponentNetworkPolicy(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) *networkingv1.NetworkPolicy {
+	var ingress []networkingv1.NetworkPolicyIngressRule
+	if len(c.Ports) > 0 {
+		rule := networkingv1.NetworkPolicyIngressRule{}
+		for _, servicePort := range c.Ports {
+			port := servicePort.TargetPort
+			if port == (intstr.IntOrString{}) {
+				port = intstr.FromInt32(servicePort.Port)
+			}
+			protocol := servicePort.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port})
+		}
+		ingress = append(ingress, rule)
+	}
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{Name: c.ObjectName, Namespace: webService.Namespace,
+			Labels:          cfg.WithLabels(c.objectLabels(webService)),
+			Annotations:     cfg.WithAnnotations(nil),
+			OwnerReferences: ownerReferences(webService),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *c.SlotPodSelector(),
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     ingress,
+		},
+	}
+}
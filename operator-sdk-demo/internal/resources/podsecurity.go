@@ -0,0 +1,178 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"encoding/json"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// Pod Security Standard levels, as set by the
+// pod-security.kubernetes.io/enforce label of a namespace.
+const (
+	PodSecurityBaseline   = "baseline"
+	PodSecurityRestricted = "restricted"
+)
+
+// RestrictedPodSecurityContext meets the pod level controls of the
+// restricted standard. A non-nil user becomes uid, gid and fsGroup, so
+// volumes are writable for it; otherwise the image user is kept and must
+// not be root.
+func RestrictedPodSecurityContext(user *int64) *corev1.PodSecurityContext {
+	nonRoot := true
+	sc := &corev1.PodSecurityContext{
+		RunAsNonRoot:   &nonRoot,
+		SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	if user != nil {
+		onRootMismatch := corev1.FSGroupChangeOnRootMismatch
+		sc.RunAsUser = user
+		sc.RunAsGroup = user
+		sc.FSGroup = user
+		sc.FSGroupChangePolicy = &onRootMismatch
+	}
+	return sc
+}
+
+// RestrictedSecurityContext meets the container level controls of the
+// restricted standard.
+func RestrictedSecurityContext() *corev1.SecurityContext {
+	escalation := false
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &escalation,
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+}
+
+// MergeSecurityContext applies the fields set in override to defaults.
+func MergeSecurityContext[T corev1.PodSecurityContext | corev1.SecurityContext](defaults, override *T) *T {
+	if override == nil {
+		return defaults
+	}
+	original, _ := json.Marshal(defaults)
+	patch, _ := json.Marshal(override)
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, *new(T))
+	if err != nil {
+		return override
+	}
+	result := new(T)
+	if err := json.Unmarshal(merged, result); err != nil {
+		return override
+	}
+	return result
+}
+
+// componentSecurityContexts returns the pod and the main container
+// security context of c.
+func componentSecurityContexts(c Component) (*corev1.PodSecurityContext, *corev1.SecurityContext) {
+	return MergeSecurityContext(RestrictedPodSecurityContext(nil), c.PodSecurityContext),
+		MergeSecurityContext(RestrictedSecurityContext(), c.SecurityContext)
+}
+
+// Capabilities the baseline standard allows to add.
+var baselineCapabilities = []corev1.Capability{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// PodSecurityViolations checks spec against the baseline or restricted
+// level. It evaluates the controls a securityContext, volume or container
+// can break; AppArmor, SELinux, procMount and sysctls are left to the
+// admission controller.
+func PodSecurityViolations(level string, spec *corev1.PodSpec) []string {
+	restricted := level == PodSecurityRestricted
+	var violations []string
+	if spec.HostNetwork || spec.HostPID || spec.HostIPC {
+		violations = append(violations, "hostNetwork, hostPID and hostIPC must be false")
+	}
+	for _, v := range spec.Volumes {
+		src := v.VolumeSource
+		switch {
+		case src.HostPath != nil:
+			violations = append(violations, "volume "+v.Name+" must not be a hostPath")
+		case restricted && src.ConfigMap == nil && src.CSI == nil && src.DownwardAPI == nil && src.EmptyDir == nil &&
+			src.Ephemeral == nil && src.PersistentVolumeClaim == nil && src.Projected == nil && src.Secret == nil:
+			violations = append(violations, "volume "+v.Name+" has a type the restricted level does not allow")
+		}
+	}
+
+	podSC := spec.SecurityContext
+	if podSC == nil {
+		podSC = &corev1.PodSecurityContext{}
+	}
+	for _, c := range append(slices.Clone(spec.InitContainers), spec.Containers...) {
+		sc := c.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		fail := func(msg string) { violations = append(violations, "container "+c.Name+": "+msg) }
+
+		if sc.Privileged != nil && *sc.Privileged {
+			fail("privileged must be false")
+		}
+		if slices.ContainsFunc(c.Ports, func(p corev1.ContainerPort) bool { return p.HostPort != 0 }) {
+			fail("hostPort must not be set")
+		}
+		seccomp := sc.SeccompProfile
+		if seccomp == nil {
+			seccomp = podSC.SeccompProfile
+		}
+		if seccomp != nil && seccomp.Type == corev1.SeccompProfileTypeUnconfined {
+			fail("seccompProfile must not be Unconfined")
+		}
+		caps := sc.Capabilities
+		if caps == nil {
+			caps = &corev1.Capabilities{}
+		}
+		for _, capability := range caps.Add {
+			if !slices.Contains(baselineCapabilities, capability) || restricted && capability != "NET_BIND_SERVICE" {
+				fail("capability " + string(capability) + " must not be added")
+			}
+		}
+		if !restricted {
+			continue
+		}
+
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			fail("allowPrivilegeEscalation must be false")
+		}
+		nonRoot := sc.RunAsNonRoot
+		if nonRoot == nil {
+			nonRoot = podSC.RunAsNonRoot
+		}
+		if nonRoot == nil || !*nonRoot {
+			fail("runAsNonRoot must be true")
+		}
+		user := sc.RunAsUser
+		if user == nil {
+			user = podSC.RunAsUser
+		}
+		if user != nil && *user == 0 {
+			fail("runAsUser must not be 0")
+		}
+		if seccomp == nil {
+			fail("seccompProfile must be RuntimeDefault or Localhost")
+		}
+		if !slices.Contains(caps.Drop, "ALL") {
+			fail("capabilities must drop ALL")
+		}
+	}
+	return violations
+}
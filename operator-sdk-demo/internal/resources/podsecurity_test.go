@@ -0,0 +1,155 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/resources"
+)
+
+var _ = Describe("Pod security", func() {
+	var webService *webappsv1.WebService
+
+	BeforeEach(func() {
+		webService = newWebService()
+	})
+
+	podSpec := func() corev1.PodSpec {
+		deploy := resources.NewComponentDeployment(webService, config.Default(), resources.PrimaryComponent(webService))
+		return deploy.Spec.Template.Spec
+	}
+
+	It("runs the components under the restricted level", func() {
+		spec := podSpec()
+		Expect(spec.SecurityContext.RunAsNonRoot).To(HaveValue(BeTrue()))
+		Expect(spec.SecurityContext.RunAsUser).To(BeNil(), "the image user is kept")
+		Expect(spec.SecurityContext.SeccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+		container := spec.Containers[0].SecurityContext
+		Expect(container.AllowPrivilegeEscalation).To(HaveValue(BeFalse()))
+		Expect(container.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+		Expect(resources.PodSecurityViolations(resources.PodSecurityRestricted, &spec)).To(BeEmpty())
+	})
+
+	It("merges the overrides field by field", func() {
+		user := int64(1000)
+		webService.Spec.Frontend.PodSecurityContext = &corev1.PodSecurityContext{RunAsUser: &user}
+		webService.Spec.Frontend.SecurityContext = &corev1.SecurityContext{
+			Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_BIND_SERVICE"}},
+		}
+
+		spec := podSpec()
+		Expect(spec.SecurityContext.RunAsUser).To(HaveValue(Equal(user)))
+		Expect(spec.SecurityContext.RunAsNonRoot).To(HaveValue(BeTrue()))
+		Expect(spec.SecurityContext.SeccompProfile).NotTo(BeNil())
+		container := spec.Containers[0].SecurityContext
+		Expect(container.Capabilities.Add).To(ConsistOf(corev1.Capability("NET_BIND_SERVICE")))
+		Expect(container.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+		Expect(container.AllowPrivilegeEscalation).To(HaveValue(BeFalse()))
+	})
+
+	It("gives a database user the data volume", func() {
+		user := int64(999)
+		sc := resources.RestrictedPodSecurityContext(&user)
+		Expect(sc.RunAsUser).To(HaveValue(Equal(user)))
+		Expect(sc.RunAsGroup).To(HaveValue(Equal(user)))
+		Expect(sc.FSGroup).To(HaveValue(Equal(user)))
+		Expect(sc.FSGroupChangePolicy).To(HaveValue(Equal(corev1.FSGroupChangeOnRootMismatch)))
+	})
+
+	DescribeTable("lists the violations of a level",
+		func(level string, mutate func(*corev1.PodSpec), violations []string) {
+			spec := corev1.PodSpec{
+				SecurityContext: resources.RestrictedPodSecurityContext(nil),
+				Containers:      []corev1.Container{{Name: "web", SecurityContext: resources.RestrictedSecurityContext()}},
+			}
+			mutate(&spec)
+			Expect(resources.PodSecurityViolations(level, &spec)).To(Equal(violations))
+		},
+		Entry("host namespaces", resources.PodSecurityBaseline,
+			func(s *corev1.PodSpec) { s.HostPID = true },
+			[]string{"hostNetwork, hostPID and hostIPC must be false"}),
+		Entry("a hostPath volume", resources.PodSecurityBaseline,
+			func(s *corev1.PodSpec) {
+				s.Volumes = []corev1.Volume{{Name: "logs", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/log"}}}}
+			},
+			[]string{"volume logs must not be a hostPath"}),
+		Entry("an NFS volume under baseline", resources.PodSecurityBaseline,
+			func(s *corev1.PodSpec) {
+				s.Volumes = []corev1.Volume{{Name: "share", VolumeSource: corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{Server: "nfs", Path: "/"}}}}
+			},
+			nil),
+		Entry("an NFS volume under restricted", resources.PodSecurityRestricted,
+			func(s *corev1.PodSpec) {
+				s.Volumes = []corev1.Volume{{Name: "share", VolumeSource: corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{Server: "nfs", Path: "/"}}}}
+			},
+			[]string{"volume share has a type the restricted level does not allow"}),
+		Entry("a privileged sidecar", resources.PodSecurityBaseline,
+			func(s *corev1.PodSpec) {
+				privileged := true
+				s.Containers = append(s.Containers, corev1.Container{Name: "proxy", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}})
+			},
+			[]string{"container proxy: privileged must be false"}),
+		Entry("a host port", resources.PodSecurityBaseline,
+			func(s *corev1.PodSpec) {
+				s.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 80}}
+			},
+			[]string{"container web: hostPort must not be set"}),
+		Entry("an unconfined seccomp profile", resources.PodSecurityBaseline,
+			func(s *corev1.PodSpec) { s.SecurityContext.SeccompProfile.Type = corev1.SeccompProfileTypeUnconfined },
+			[]string{"container web: seccompProfile must not be Unconfined"}),
+		Entry("a baseline capability under restricted", resources.PodSecurityRestricted,
+			func(s *corev1.PodSpec) {
+				s.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"NET_BIND_SERVICE", "CHOWN"}
+			},
+			[]string{"container web: capability CHOWN must not be added"}),
+		Entry("SYS_ADMIN under baseline", resources.PodSecurityBaseline,
+			func(s *corev1.PodSpec) {
+				s.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"CHOWN", "SYS_ADMIN"}
+			},
+			[]string{"container web: capability SYS_ADMIN must not be added"}),
+		Entry("a root init container", resources.PodSecurityRestricted,
+			func(s *corev1.PodSpec) {
+				root := int64(0)
+				sc := resources.RestrictedSecurityContext()
+				sc.RunAsUser = &root
+				s.InitContainers = []corev1.Container{{Name: "init", SecurityContext: sc}}
+			},
+			[]string{"container init: runAsUser must not be 0"}),
+		Entry("no security context under restricted", resources.PodSecurityRestricted,
+			func(s *corev1.PodSpec) {
+				s.SecurityContext = nil
+				s.Containers[0].SecurityContext = nil
+			},
+			[]string{
+				"container web: allowPrivilegeEscalation must be false",
+				"container web: runAsNonRoot must be true",
+				"container web: seccompProfile must be RuntimeDefault or Localhost",
+				"container web: capabilities must drop ALL",
+			}),
+		Entry("no security context under baseline", resources.PodSecurityBaseline,
+			func(s *corev1.PodSpec) {
+				s.SecurityContext = nil
+				s.Containers[0].SecurityContext = nil
+			},
+			nil),
+	)
+})
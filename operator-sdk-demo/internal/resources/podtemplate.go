@@ -0,0 +1,106 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// MergePodTemplate applies overlay to template as a strategic merge patch.
+// It fails when the overlay touches a field the operator owns: the pod
+// labels it generated, its volumes, and image, ports, resources and volume
+// mounts of the container named main. template is returned unchanged on
+// error.
+func MergePodTemplate(template corev1.PodTemplateSpec, overlay *runtime.RawExtension, main string) (corev1.PodTemplateSpec, error) {
+	if overlay == nil || len(overlay.Raw) == 0 {
+		return template, nil
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return template, err
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, overlay.Raw, corev1.PodTemplateSpec{})
+	if err != nil {
+		return template, fmt.Errorf("podTemplate does not apply: %w", err)
+	}
+	var merged corev1.PodTemplateSpec
+	if err := json.Unmarshal(patched, &merged); err != nil {
+		return template, fmt.Errorf("podTemplate does not apply: %w", err)
+	}
+
+	var owned []string
+	for key, value := range template.Labels {
+		if merged.Labels[key] != value {
+			owned = append(owned, "metadata.labels."+key)
+		}
+	}
+	for _, volume := range template.Spec.Volumes {
+		i := slices.IndexFunc(merged.Spec.Volumes, func(v corev1.Volume) bool { return v.Name == volume.Name })
+		if i < 0 || !equality.Semantic.DeepEqual(merged.Spec.Volumes[i], volume) {
+			owned = append(owned, "spec.volumes."+volume.Name)
+		}
+	}
+	want := slices.IndexFunc(template.Spec.Containers, func(c corev1.Container) bool { return c.Name == main })
+	got := slices.IndexFunc(merged.Spec.Containers, func(c corev1.Container) bool { return c.Name == main })
+	if want >= 0 {
+		prefix := "spec.containers." + main
+		if got < 0 {
+			owned = append(owned, prefix)
+		} else {
+			before, after := template.Spec.Containers[want], merged.Spec.Containers[got]
+			if before.Image != after.Image {
+				owned = append(owned, prefix+".image")
+			}
+			if !equality.Semantic.DeepEqual(before.Ports, after.Ports) {
+				owned = append(owned, prefix+".ports")
+			}
+			if !equality.Semantic.DeepEqual(before.Resources, after.Resources) {
+				owned = append(owned, prefix+".resources")
+			}
+			for _, mount := range before.VolumeMounts {
+				if !slices.Contains(after.VolumeMounts, mount) {
+					owned = append(owned, prefix+".volumeMounts."+mount.Name)
+				}
+			}
+		}
+	}
+	if len(owned) > 0 {
+		return template, fmt.Errorf("podTemplate changes fields owned by the operator: %s", strings.Join(owned, ", "))
+	}
+	return merged, nil
+}
+
+// ValidateComponentPodTemplates reports the first component whose
+// podTemplate cannot be merged.
+func ValidateComponentPodTemplates(webService *webappsv1.WebService, cfg *config.OperatorConfig) error {
+	for _, c := range Components(webService) {
+		if _, err := MergePodTemplate(newComponentPodTemplate(webService, cfg, c), c.PodTemplate, componentContainerName(webService, cfg, c)); err != nil {
+			return fmt.Errorf("component %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/resources"
+)
+
+var _ = Describe("Pod template overlays", func() {
+	var (
+		webService *webappsv1.WebService
+		cfg        *config.OperatorConfig
+	)
+
+	BeforeEach(func() {
+		webService = newWebService()
+		cfg = config.Default()
+	})
+
+	overlay := func(raw string) *runtime.RawExtension {
+		return &runtime.RawExtension{Raw: []byte(raw)}
+	}
+	podSpec := func() corev1.PodSpec {
+		deploy := resources.NewComponentDeployment(webService, cfg, resources.PrimaryComponent(webService))
+		return deploy.Spec.Template.Spec
+	}
+
+	It("adds a sidecar and a service account", func() {
+		webService.Spec.Frontend.PodTemplate = overlay(`{"spec":{"serviceAccountName":"shop","containers":[{"name":"proxy","image":"envoy"}]}}`)
+
+		spec := podSpec()
+		Expect(spec.ServiceAccountName).To(Equal("shop"))
+		Expect(spec.Containers).To(ConsistOf(
+			And(HaveField("Name", "nginx"), HaveField("Image", "nginx")),
+			And(HaveField("Name", "proxy"), HaveField("Image", "envoy")),
+		))
+	})
+
+	It("merges env by name into the main container", func() {
+		webService.Spec.Frontend.PodTemplate = overlay(`{"spec":{"containers":[{"name":"nginx","env":[{"name":"LOG_LEVEL","value":"debug"}]}]}}`)
+
+		spec := podSpec()
+		Expect(spec.Containers).To(HaveLen(1))
+		Expect(spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+		Expect(spec.Containers[0].Env).To(ContainElement(HaveField("Name", "DB_HOST")), "the generated env is kept")
+	})
+
+	DescribeTable("refuses overlays of operator owned fields",
+		func(raw, field string) {
+			webService.Spec.Frontend.PodTemplate = overlay(raw)
+
+			err := resources.ValidateComponentPodTemplates(webService, cfg)
+			Expect(err).To(MatchError(HavePrefix("component nginx: ")))
+			Expect(err).To(MatchError(ContainSubstring(field)))
+			Expect(podSpec().Containers[0].Image).To(Equal("nginx"), "the generated template is the fallback")
+		},
+		Entry("image", `{"spec":{"containers":[{"name":"nginx","image":"httpd"}]}}`, "spec.containers.nginx.image"),
+		Entry("resources", `{"spec":{"containers":[{"name":"nginx","resources":{"limits":{"cpu":"1"}}}]}}`, "spec.containers.nginx.resources"),
+		Entry("a generated label", `{"metadata":{"labels":{"webapps.my.domain/component":"other"}}}`, "metadata.labels.webapps.my.domain/component"),
+		Entry("the main container", `{"spec":{"containers":[{"name":"nginx","$patch":"delete"}]}}`, "spec.containers.nginx"),
+		Entry("a malformed template", `{"spec":{"containers":"nginx"}}`, "podTemplate does not apply"),
+	)
+
+	It("leaves a template without overlay alone", func() {
+		template := corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "mysql", Image: "mysql:5.7"}}}}
+		Expect(resources.MergePodTemplate(template, nil, "mysql")).To(Equal(template))
+		Expect(resources.ValidateComponentPodTemplates(webService, cfg)).To(Succeed())
+	})
+})
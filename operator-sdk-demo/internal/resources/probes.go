@@ -0,0 +1,131 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+const (
+	// componentStartupSeconds is how long a component may take to start
+	// before the liveness probe takes over.
+	componentStartupSeconds = 120
+	probePeriodSeconds      = 5
+)
+
+// DefaultProbes uses handler for the startup, liveness and readiness
+// probes. The startup probe gives the container startupSeconds to come up,
+// readiness is checked twice as often as liveness.
+func DefaultProbes(handler corev1.ProbeHandler, startupSeconds int32) webappsv1.ProbesSpec {
+	return webappsv1.ProbesSpec{
+		Startup: &corev1.Probe{
+			ProbeHandler:     *handler.DeepCopy(),
+			PeriodSeconds:    probePeriodSeconds,
+			TimeoutSeconds:   probePeriodSeconds,
+			FailureThreshold: startupSeconds / probePeriodSeconds,
+		},
+		Liveness: &corev1.Probe{
+			ProbeHandler:     *handler.DeepCopy(),
+			PeriodSeconds:    2 * probePeriodSeconds,
+			TimeoutSeconds:   probePeriodSeconds,
+			FailureThreshold: 3,
+		},
+		Readiness: &corev1.Probe{
+			ProbeHandler:     *handler.DeepCopy(),
+			PeriodSeconds:    probePeriodSeconds,
+			TimeoutSeconds:   probePeriodSeconds,
+			FailureThreshold: 3,
+		},
+	}
+}
+
+// SetProbes sets the probes of container to defaults with override on top,
+// see ProbesSpec.
+func SetProbes(container *corev1.Container, defaults webappsv1.ProbesSpec, override *webappsv1.ProbesSpec) {
+	if override == nil {
+		override = &webappsv1.ProbesSpec{}
+	}
+	container.StartupProbe = mergeProbe(defaults.Startup, override.Startup)
+	container.LivenessProbe = mergeProbe(defaults.Liveness, override.Liveness)
+	container.ReadinessProbe = mergeProbe(defaults.Readiness, override.Readiness)
+}
+
+// mergeProbe returns override completed from def. Without a handler on
+// either side there is nothing to probe and nil is returned.
+func mergeProbe(def, override *corev1.Probe) *corev1.Probe {
+	if override == nil {
+		return def.DeepCopy()
+	}
+	probe := override.DeepCopy()
+	if def == nil {
+		def = &corev1.Probe{}
+	}
+	if probe.ProbeHandler == (corev1.ProbeHandler{}) {
+		probe.ProbeHandler = *def.ProbeHandler.DeepCopy()
+	}
+	if probe.ProbeHandler == (corev1.ProbeHandler{}) {
+		return nil
+	}
+	if probe.InitialDelaySeconds == 0 {
+		probe.InitialDelaySeconds = def.InitialDelaySeconds
+	}
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = def.TimeoutSeconds
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = def.PeriodSeconds
+	}
+	if probe.SuccessThreshold == 0 {
+		probe.SuccessThreshold = def.SuccessThreshold
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = def.FailureThreshold
+	}
+	if probe.TerminationGracePeriodSeconds == nil {
+		probe.TerminationGracePeriodSeconds = def.TerminationGracePeriodSeconds
+	}
+	return probe
+}
+
+// componentProbes are the default probes of c. They check the first port,
+// with an HTTP GET of / when its appProtocol or name is http or https (or
+// starts with "http-" or "https-") and with a TCP connect otherwise. A
+// component without ports has no default probes.
+func componentProbes(c Component) webappsv1.ProbesSpec {
+	if len(c.Ports) == 0 {
+		return webappsv1.ProbesSpec{}
+	}
+	port := c.Ports[0]
+	target := intstr.FromInt32(port.TargetPort.IntVal)
+	protocol := port.Name
+	if port.AppProtocol != nil {
+		protocol = *port.AppProtocol
+	}
+
+	handler := corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: target}}
+	switch {
+	case protocol == "https" || strings.HasPrefix(protocol, "https-"):
+		handler = corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: target, Scheme: corev1.URISchemeHTTPS}}
+	case protocol == "http" || strings.HasPrefix(protocol, "http-"):
+		handler = corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: target, Scheme: corev1.URISchemeHTTP}}
+	}
+	return DefaultProbes(handler, componentStartupSeconds)
+}
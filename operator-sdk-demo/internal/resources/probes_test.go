@@ -0,0 +1,106 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+	"my.domain/demo/internal/resources"
+)
+
+var _ = Describe("Probes", func() {
+	var webService *webappsv1.WebService
+
+	BeforeEach(func() {
+		webService = newWebService()
+	})
+
+	mainContainer := func() corev1.Container {
+		deploy := resources.NewComponentDeployment(webService, config.Default(), resources.PrimaryComponent(webService))
+		return deploy.Spec.Template.Spec.Containers[0]
+	}
+	https := "https"
+
+	DescribeTable("check the first port of a component",
+		func(port corev1.ServicePort, handler corev1.ProbeHandler) {
+			webService.Spec.Frontend.Ports = []corev1.ServicePort{port}
+
+			container := mainContainer()
+			for _, probe := range []*corev1.Probe{container.StartupProbe, container.LivenessProbe, container.ReadinessProbe} {
+				Expect(probe).NotTo(BeNil())
+				Expect(probe.ProbeHandler).To(Equal(handler))
+			}
+			Expect(container.StartupProbe.FailureThreshold * container.StartupProbe.PeriodSeconds).To(BeEquivalentTo(120))
+			Expect(container.LivenessProbe.PeriodSeconds).To(Equal(2 * container.ReadinessProbe.PeriodSeconds))
+		},
+		Entry("with a TCP connect",
+			corev1.ServicePort{Port: 80, TargetPort: intstr.FromInt32(8080)},
+			corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)}}),
+		Entry("with an HTTP GET for an http port name",
+			corev1.ServicePort{Name: "http-web", Port: 80, TargetPort: intstr.FromInt32(8080)},
+			corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt32(8080), Scheme: corev1.URISchemeHTTP}}),
+		Entry("with an HTTPS GET for the https appProtocol",
+			corev1.ServicePort{Name: "web", AppProtocol: &https, Port: 443, TargetPort: intstr.FromInt32(8443)},
+			corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt32(8443), Scheme: corev1.URISchemeHTTPS}}),
+		Entry("with a TCP connect for a name only starting with http",
+			corev1.ServicePort{Name: "httpbin", Port: 80, TargetPort: intstr.FromInt32(80)},
+			corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(80)}}),
+	)
+
+	It("leaves a component without ports unprobed", func() {
+		webService.Spec.Frontend.Ports = nil
+
+		container := mainContainer()
+		Expect(container.StartupProbe).To(BeNil())
+		Expect(container.LivenessProbe).To(BeNil())
+		Expect(container.ReadinessProbe).To(BeNil())
+	})
+
+	It("completes an override from the defaults", func() {
+		webService.Spec.Frontend.Probes = &webappsv1.ProbesSpec{
+			Startup: &corev1.Probe{FailureThreshold: 60},
+			Readiness: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/ready", Port: intstr.FromInt32(80)},
+			}},
+		}
+
+		container := mainContainer()
+		Expect(container.StartupProbe.TCPSocket).NotTo(BeNil())
+		Expect(container.StartupProbe.FailureThreshold).To(BeEquivalentTo(60))
+		Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/ready"))
+		Expect(container.ReadinessProbe.PeriodSeconds).To(BeEquivalentTo(5))
+		Expect(container.LivenessProbe.TCPSocket).NotTo(BeNil())
+	})
+
+	It("adds an override where there is no default", func() {
+		handler := corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}
+		container := corev1.Container{}
+
+		resources.SetProbes(&container, webappsv1.ProbesSpec{}, &webappsv1.ProbesSpec{
+			Liveness:  &corev1.Probe{ProbeHandler: handler},
+			Readiness: &corev1.Probe{PeriodSeconds: 10},
+		})
+		Expect(container.LivenessProbe).To(Equal(&corev1.Probe{ProbeHandler: handler}))
+		Expect(container.ReadinessProbe).To(BeNil(), "a probe without handler has nothing to run")
+		Expect(container.StartupProbe).To(BeNil())
+	})
+})
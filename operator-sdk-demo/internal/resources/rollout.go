@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webappsv1 "my.domain/demo/api/v1"
+	"my.domain/demo/internal/config"
+)
+
+// The two Deployments a component can run. Blue is the Deployment of
+// earlier releases, green only exists for BlueGreen and Canary rollouts.
+const (
+	SlotBlue  = "blue"
+	SlotGreen = "green"
+)
+
+// RevisionAnnotation holds the revision of the pod template a Deployment
+// runs, see Revision.
+const RevisionAnnotation = "webapps.my.domain/revision"
+
+// OtherSlot returns the slot that is not slot.
+func OtherSlot(slot string) string {
+	if slot == SlotGreen {
+		return SlotBlue
+	}
+	return SlotGreen
+}
+
+// SlotName names the Deployment of slot.
+func (c Component) SlotName(slot string) string {
+	if slot == SlotGreen {
+		return c.ObjectName + "-" + SlotGreen
+	}
+	return c.ObjectName
+}
+
+// SlotPodLabels select the pods of the Deployment of slot.
+func (c Component) SlotPodLabels(slot string) map[string]string {
+	return map[string]string{"app": c.SlotName(slot)}
+}
+
+// ServingPodLabels select the pods of both slots; a Canary Service
+// selects them.
+func (c Component) ServingPodLabels(webService *webappsv1.WebService) map[string]string {
+	return c.objectLabels(webService)
+}
+
+// SlotPodSelector matches the pods of both slots by their slot labels, so
+// it also covers pods started before ServingPodLabels were set.
+func (c Component) SlotPodSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+		Key:      "app",
+		Operator: metav1.LabelSelectorOpIn,
+		Values:   []string{c.SlotName(SlotBlue), c.SlotName(SlotGreen)},
+	}}}
+}
+
+// RolloutStrategy is spec.rollout.strategy of c, Rolling when unset.
+func RolloutStrategy(c Component) webappsv1.RolloutStrategy {
+	if c.Rollout == nil || c.Rollout.Strategy == "" {
+		return webappsv1.RolloutRolling
+	}
+	return c.Rollout.Strategy
+}
+
+// Revision identifies the pod template generated for c, whatever the slot
+// it runs in. The Deployments carry it as RevisionAnnotation.
+func Revision(webService *webappsv1.WebService, cfg *config.OperatorConfig, c Component) string {
+	return templateRevision(componentPodTemplate(webService, cfg, c))
+}
+
+func templateRevision(template corev1.PodTemplateSpec) string {
+	data, _ := json.Marshal(template)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// CanaryReplicas is the number of the size replicas of c running the new
+// template at weight percent: rounded up, at least one, at most size.
+func CanaryReplicas(size, weight int32) int32 {
+	if size <= 0 {
+		return 0
+	}
+	replicas := (size*weight + 99) / 100
+	return max(replicas, 1)
+}
+
+// StableReplicas is the number of the size replicas the stable slot keeps
+// while canary of them run the new template: the rest, but at least one,
+// so a small component keeps a pod of the known-good revision until the
+// rollout is promoted.
+func StableReplicas(size, canary int32) int32 {
+	if size <= 0 {
+		return 0
+	}
+	return max(size-canary, 1)
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"my.domain/demo/internal/resources"
+)
+
+var _ = Describe("Canary rollouts", func() {
+	DescribeTable("split the replicas between the slots",
+		func(size, weight, canary, stable int32) {
+			Expect(resources.CanaryReplicas(size, weight)).To(Equal(canary))
+			Expect(resources.StableReplicas(size, canary)).To(Equal(stable))
+		},
+		Entry("a single replica keeps its stable pod", int32(1), int32(25), int32(1), int32(1)),
+		Entry("a single replica at full weight", int32(1), int32(100), int32(1), int32(1)),
+		Entry("two replicas split", int32(2), int32(50), int32(1), int32(1)),
+		Entry("two replicas at full weight", int32(2), int32(100), int32(2), int32(1)),
+		Entry("four replicas", int32(4), int32(25), int32(1), int32(3)),
+		Entry("no replicas", int32(0), int32(50), int32(0), int32(0)),
+	)
+})
@@ -0,0 +1,58 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	webappsv1 "my.domain/demo/api/v1"
+)
+
+func TestResources(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Resources Suite")
+}
+
+// newWebService is the WebService of config/samples: a frontend and a
+// MySQL database.
+func newWebService() *webappsv1.WebService {
+	one := int32(1)
+	return &webappsv1.WebService{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default", UID: "shop-uid"},
+		Spec: webappsv1.WebServiceSpec{
+			Database: &webappsv1.WebServiceDbSpec{
+				Name:  "mysql",
+				Size:  &one,
+				Image: "mysql:5.7",
+				Ports: []corev1.ServicePort{{Port: 3306, TargetPort: intstr.FromInt32(3306)}},
+			},
+			Frontend: &webappsv1.WebServiceFrontendSpec{
+				Name:  "nginx",
+				Size:  &one,
+				Image: "nginx",
+				Ports: []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromInt32(80)}},
+			},
+		},
+	}
+}